@@ -0,0 +1,64 @@
+// Package anonymize redacts personal data from free text, such as chat
+// transcripts, before it leaves the system.
+package anonymize
+
+import (
+	"regexp"
+)
+
+// Rule replaces every match of Pattern with Replacement.
+type Rule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// DefaultRules redact email addresses, card numbers, Norwegian national
+// identity numbers and phone numbers. Longer numbers are matched first, so a
+// card number is not mistaken for a phone number.
+var DefaultRules = []Rule{
+	{
+		Name:        "email",
+		Pattern:     regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		Replacement: "[email]",
+	},
+	{
+		Name:        "card",
+		Pattern:     regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		Replacement: "[card]",
+	},
+	{
+		Name:        "nin",
+		Pattern:     regexp.MustCompile(`\b\d{6} ?\d{5}\b`),
+		Replacement: "[nin]",
+	},
+	{
+		Name:        "phone",
+		Pattern:     regexp.MustCompile(`(?:\+|\b00)47[ \-]?(?:\d[ \-]?){7}\d\b|\b\d{3} ?\d{2} ?\d{3}\b|\b\d{2} \d{2} \d{2} \d{2}\b`),
+		Replacement: "[phone]",
+	},
+}
+
+// Anonymizer applies a list of rules in order.
+type Anonymizer struct {
+	Rules []Rule
+}
+
+// New returns an Anonymizer with the given rules, or DefaultRules if none
+// are given.
+func New(rules ...Rule) *Anonymizer {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+
+	return &Anonymizer{Rules: rules}
+}
+
+// String returns s with every match of the rules replaced.
+func (a *Anonymizer) String(s string) string {
+	for _, rule := range a.Rules {
+		s = rule.Pattern.ReplaceAllString(s, rule.Replacement)
+	}
+
+	return s
+}
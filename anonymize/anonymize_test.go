@@ -0,0 +1,28 @@
+package anonymize_test
+
+import (
+	"testing"
+
+	"github.com/atb-as/kindly/anonymize"
+)
+
+func TestAnonymizer_String(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"mail me at ola.nordmann@example.no please", "mail me at [email] please"},
+		{"call +47 912 34 567", "call [phone]"},
+		{"call 91234567 or 22 33 44 55", "call [phone] or [phone]"},
+		{"my fnr is 01017012345", "my fnr is [nin]"},
+		{"card 4111 1111 1111 1111 was charged", "card [card] was charged"},
+		{"ticket costs 42 kr, route 100", "ticket costs 42 kr, route 100"},
+	}
+
+	a := anonymize.New()
+	for _, tt := range tests {
+		if got := a.String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
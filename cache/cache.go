@@ -0,0 +1,72 @@
+// Package cache defines the cache used by the kindly tools to keep upstream
+// responses, with implementations in memory, in a directory and in Redis.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache stores values by key for a limited time. Implementations must be
+// safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored for key, and false if there is none or
+	// it has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value for key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Memory is a Cache that keeps values in memory.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	sets    int
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory returns an empty Memory cache.
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]entry)}
+}
+
+// Get implements Cache.
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok || !time.Now().Before(e.expires) {
+		return nil, false, nil
+	}
+
+	return e.value, true, nil
+}
+
+// pruneEvery is the number of Set calls between removals of expired entries.
+const pruneEvery = 1000
+
+// Set implements Cache.
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.entries[key] = entry{value: value, expires: now.Add(ttl)}
+
+	m.sets++
+	if m.sets%pruneEvery == 0 {
+		for k, e := range m.entries {
+			if !now.Before(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+	}
+
+	return nil
+}
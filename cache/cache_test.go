@@ -0,0 +1,175 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/cache"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemory()
+
+	if _, ok, err := c.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("got ok=%v err=%v for missing key, want false", ok, err)
+	}
+
+	if err := c.Set(ctx, "k", []byte("v"), time.Hour); err != nil {
+		t.Fatalf("c.Set() err=%v", err)
+	}
+	if got, ok, _ := c.Get(ctx, "k"); !ok || string(got) != "v" {
+		t.Errorf("got %q ok=%v, want %q", got, ok, "v")
+	}
+
+	if err := c.Set(ctx, "expired", []byte("v"), -time.Second); err != nil {
+		t.Fatalf("c.Set() err=%v", err)
+	}
+	if _, ok, _ := c.Get(ctx, "expired"); ok {
+		t.Errorf("got expired entry, want none")
+	}
+}
+
+func TestDir(t *testing.T) {
+	ctx := context.Background()
+	c, err := cache.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("cache.NewDir() err=%v", err)
+	}
+
+	if _, ok, err := c.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("got ok=%v err=%v for missing key, want false", ok, err)
+	}
+
+	if err := c.Set(ctx, "jobs/1|?a=b", []byte("v"), time.Hour); err != nil {
+		t.Fatalf("c.Set() err=%v", err)
+	}
+	if got, ok, _ := c.Get(ctx, "jobs/1|?a=b"); !ok || string(got) != "v" {
+		t.Errorf("got %q ok=%v, want %q", got, ok, "v")
+	}
+
+	if err := c.Set(ctx, "expired", []byte("v"), -time.Second); err != nil {
+		t.Fatalf("c.Set() err=%v", err)
+	}
+	if _, ok, _ := c.Get(ctx, "expired"); ok {
+		t.Errorf("got expired entry, want none")
+	}
+}
+
+// fakeRedis serves GET, SET with PX, DEL, AUTH and SELECT from memory.
+func fakeRedis(t *testing.T, password string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var mu sync.Mutex
+	values := make(map[string]string)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		authed := password == ""
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, n)
+			for i := range args {
+				hdr, _ := rd.ReadString('\n')
+				size, _ := strconv.Atoi(strings.TrimSpace(hdr[1:]))
+				arg := make([]byte, size+2)
+				io.ReadFull(rd, arg)
+				args[i] = string(arg[:size])
+			}
+
+			mu.Lock()
+			switch {
+			case args[0] == "AUTH":
+				authed = args[len(args)-1] == password
+				fmt.Fprint(conn, "+OK\r\n")
+			case !authed:
+				fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+			case args[0] == "SELECT":
+				fmt.Fprint(conn, "+OK\r\n")
+			case args[0] == "GET":
+				if v, ok := values[args[1]]; ok {
+					fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(v), v)
+				} else {
+					fmt.Fprint(conn, "$-1\r\n")
+				}
+			case args[0] == "SET" && len(args) == 5 && args[3] == "PX":
+				values[args[1]] = args[2]
+				fmt.Fprint(conn, "+OK\r\n")
+			case args[0] == "DEL":
+				delete(values, args[1])
+				fmt.Fprint(conn, ":1\r\n")
+			default:
+				fmt.Fprintf(conn, "-ERR unknown command %q\r\n", args[0])
+			}
+			mu.Unlock()
+		}
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	addr := fakeRedis(t, "secret")
+
+	c, err := cache.NewRedis("redis://:secret@" + addr + "/2?prefix=test:")
+	if err != nil {
+		t.Fatalf("cache.NewRedis() err=%v", err)
+	}
+	defer c.Close()
+
+	if _, ok, err := c.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("got ok=%v err=%v for missing key, want false", ok, err)
+	}
+	value := "line 1\r\nline 2"
+	if err := c.Set(ctx, "k", []byte(value), time.Hour); err != nil {
+		t.Fatalf("c.Set() err=%v", err)
+	}
+	if got, ok, _ := c.Get(ctx, "k"); !ok || string(got) != value {
+		t.Errorf("got %q ok=%v, want %q", got, ok, value)
+	}
+
+	// Another replica shares the values.
+	other, _ := cache.NewRedis("redis://:secret@" + addr + "/2?prefix=test:")
+	defer other.Close()
+	if got, ok, _ := other.Get(ctx, "k"); !ok || string(got) != value {
+		t.Errorf("got %q ok=%v from another client, want %q", got, ok, value)
+	}
+
+	if err := c.Set(ctx, "k", []byte("v"), -time.Second); err != nil {
+		t.Fatalf("c.Set() err=%v", err)
+	}
+	if _, ok, _ := c.Get(ctx, "k"); ok {
+		t.Errorf("got expired entry, want none")
+	}
+
+	wrong, _ := cache.NewRedis("redis://:wrong@" + addr)
+	if _, _, err := wrong.Get(ctx, "k"); err == nil {
+		t.Errorf("got err=nil with a wrong password")
+	}
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dir is a Cache that keeps values in files in a directory, so they survive
+// restarts and large values do not take up memory.
+type Dir struct {
+	path string
+
+	mu   sync.Mutex
+	sets int
+}
+
+// NewDir returns a Dir cache in path, creating the directory if needed.
+func NewDir(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+
+	return &Dir{path: path}, nil
+}
+
+// file returns the path of the file for key. Keys are hashed, as they may
+// contain characters that are not allowed in file names.
+func (d *Dir) file(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.path, hex.EncodeToString(sum[:]))
+}
+
+// Get implements Cache.
+func (d *Dir) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b, err := os.ReadFile(d.file(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(b) < 8 || !time.Now().Before(time.Unix(0, int64(binary.BigEndian.Uint64(b)))) {
+		return nil, false, nil
+	}
+
+	return b[8:], true, nil
+}
+
+// Set implements Cache. The file is written to a temporary name first, so
+// concurrent readers never see a partial value.
+func (d *Dir) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	f, err := os.CreateTemp(d.path, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	var expires [8]byte
+	binary.BigEndian.PutUint64(expires[:], uint64(time.Now().Add(ttl).UnixNano()))
+	if _, err := f.Write(expires[:]); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(value); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), d.file(key)); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.sets++
+	prune := d.sets%pruneEvery == 0
+	d.mu.Unlock()
+	if prune {
+		d.prune()
+	}
+
+	return nil
+}
+
+// prune removes the files of expired values.
+func (d *Dir) prune() {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".tmp-") {
+			continue
+		}
+		path := filepath.Join(d.path, e.Name())
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		var expires [8]byte
+		_, err = f.Read(expires[:])
+		f.Close()
+		if err == nil && !now.Before(time.Unix(0, int64(binary.BigEndian.Uint64(expires[:])))) {
+			os.Remove(path)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisIdleConns is the number of idle connections kept open.
+	redisIdleConns = 8
+	// redisTimeout bounds commands whose context has no deadline.
+	redisTimeout = 5 * time.Second
+)
+
+// Redis is a Cache that keeps values in Redis, so the replicas of a
+// deployment share them. It speaks the Redis protocol over a small pool of
+// connections.
+type Redis struct {
+	addr     string
+	username string
+	password string
+	db       int
+	prefix   string
+	tls      *tls.Config
+
+	idle chan *redisConn
+}
+
+// NewRedis returns a Redis cache for the server at uri, of the form
+// redis://[[user]:password@]host[:port][/db][?prefix=p]. Use rediss:// for
+// TLS. Keys are prefixed with prefix, so deployments can share a server.
+// Connections are opened when first used.
+func NewRedis(uri string) (*Redis, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("cache: parsing redis url: %w", err)
+	}
+
+	r := &Redis{
+		addr:   u.Host,
+		prefix: u.Query().Get("prefix"),
+		idle:   make(chan *redisConn, redisIdleConns),
+	}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		r.tls = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("cache: unknown redis url scheme %q, want redis or rediss", u.Scheme)
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("cache: redis database %q is not a number", db)
+		}
+	}
+
+	return r, nil
+}
+
+// Get implements Cache.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := r.do(ctx, "GET", r.prefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	if v == nil {
+		return nil, false, nil
+	}
+
+	return v, true, nil
+}
+
+// Set implements Cache. Values with a ttl below a millisecond are not
+// stored, as they would have expired.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := ttl.Milliseconds()
+	if ms < 1 {
+		_, err := r.do(ctx, "DEL", r.prefix+key)
+		return err
+	}
+
+	_, err := r.do(ctx, "SET", r.prefix+key, string(value), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+// Close closes the idle connections.
+func (r *Redis) Close() error {
+	for {
+		select {
+		case c := <-r.idle:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do runs the command on a pooled connection and returns the reply. Nil
+// replies are returned as a nil slice.
+func (r *Redis) do(ctx context.Context, args ...string) ([]byte, error) {
+	c, err := r.conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cache: redis: %w", err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	c.SetDeadline(deadline)
+
+	v, err := c.do(args...)
+	var rerr redisError
+	if err != nil && !errors.As(err, &rerr) {
+		// The connection is in an unknown state.
+		c.Close()
+		return nil, fmt.Errorf("cache: redis: %w", err)
+	}
+
+	select {
+	case r.idle <- c:
+	default:
+		c.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache: redis: %w", err)
+	}
+
+	return v, nil
+}
+
+// conn returns an idle connection, or a new one that is authenticated and
+// has the database selected.
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.idle:
+		return c, nil
+	default:
+	}
+
+	d := &net.Dialer{Timeout: redisTimeout}
+	nc, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, err
+	}
+	if r.tls != nil {
+		nc = tls.Client(nc, r.tls)
+	}
+	c := &redisConn{Conn: nc, rd: bufio.NewReader(nc)}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	c.SetDeadline(deadline)
+	if r.password != "" {
+		args := []string{"AUTH", r.password}
+		if r.username != "" {
+			args = []string{"AUTH", r.username, r.password}
+		}
+		if _, err := c.do(args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(r.db)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// redisError is an error reply of the server. The connection stays usable.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+type redisConn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+// do writes the command and reads its reply.
+func (c *redisConn) do(args ...string) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.Conn, b.String()); err != nil {
+		return nil, err
+	}
+
+	return c.reply()
+}
+
+// reply reads a simple string, error, integer or bulk string reply.
+func (c *redisConn) reply() ([]byte, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.rd, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	default:
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,541 @@
+// Package chat provides a client for searching and reading chat transcripts,
+// and for reading and writing the context variables of chats, through the
+// Kindly API.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/atb-as/kindly"
+)
+
+const (
+	BaseURL  = "https://api.kindly.ai/api/v2/bot"
+	InboxURL = "https://app.kindly.ai/workspace"
+)
+
+type Client struct {
+	BotID   string
+	BaseURL string
+	// InboxURL is the base of links to chats in the Kindly inbox.
+	InboxURL string
+	logger   Logger
+	doer     Doer
+}
+
+func NewClient(opts ...ClientOption) *Client {
+	c := &Client{logger: &nopLogger{}, doer: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+type ClientOption func(c *Client)
+
+// WithDoer sets the Doer used for requests. The Doer is responsible for
+// authenticating requests with the bot API key.
+func WithDoer(doer Doer) ClientOption {
+	return func(c *Client) {
+		c.doer = doer
+	}
+}
+
+func WithLogger(logger Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+type nopLogger struct {
+}
+
+func (l *nopLogger) Log(keyvals ...interface{}) error {
+	return nil
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+// Chat is a conversation between a user and the bot, and possibly an agent.
+type Chat struct {
+	ID       string   `json:"id"`
+	Source   string   `json:"source"`
+	Language string   `json:"language_code"`
+	LabelIDs []string `json:"label_ids"`
+	Handover Handover `json:"takeover"`
+	// Feedback is nil if the user gave no feedback.
+	Feedback *Feedback   `json:"feedback"`
+	Created  kindly.Time `json:"created"`
+	Updated  kindly.Time `json:"updated"`
+}
+
+// Feedback is a rating given by the user at the end of a chat.
+type Feedback struct {
+	Type   FeedbackType `json:"type"`
+	Rating int          `json:"rating"`
+}
+
+// FeedbackType is the kind of rating scale used for feedback, matching
+// statistics.Feedback.
+type FeedbackType string
+
+const (
+	FeedbackBinary FeedbackType = "binary"
+	FeedbackEmoji  FeedbackType = "emojis"
+)
+
+// Handover is the handover state of a chat.
+type Handover struct {
+	Requested bool `json:"requested"`
+	Started   bool `json:"started"`
+	Ended     bool `json:"ended"`
+}
+
+// HandoverStatus selects chats by handover state in searches.
+type HandoverStatus int
+
+const (
+	HandoverAny HandoverStatus = iota
+	// HandoverNone matches chats where handover was never requested.
+	HandoverNone
+	HandoverRequested
+	HandoverStarted
+	HandoverEnded
+)
+
+func (h HandoverStatus) String() string {
+	switch h {
+	case HandoverNone:
+		return "none"
+	case HandoverRequested:
+		return "requested"
+	case HandoverStarted:
+		return "started"
+	case HandoverEnded:
+		return "ended"
+	default:
+		return "any"
+	}
+}
+
+// SearchFilter narrows down a chat search. Zero values are not sent.
+type SearchFilter struct {
+	// Text matches chats containing the text in any message.
+	Text     string
+	LabelIDs []string
+	Sources  []string
+	Handover HandoverStatus
+	// FeedbackType and Ratings match chats where the user gave one of the
+	// ratings on the given scale.
+	FeedbackType FeedbackType
+	Ratings      []int
+	From         time.Time
+	To           time.Time
+	// Limit is the page size.
+	Limit int
+	// Cursor continues a previous search, see Page.NextCursor.
+	Cursor string
+}
+
+func (f *SearchFilter) Query() url.Values {
+	if f == nil {
+		return url.Values{}
+	}
+
+	q := url.Values{}
+
+	if f.Text != "" {
+		q.Add("q", f.Text)
+	}
+
+	for _, id := range f.LabelIDs {
+		q.Add("label_ids[]", id)
+	}
+
+	for _, source := range f.Sources {
+		q.Add("sources[]", source)
+	}
+
+	if f.Handover != HandoverAny {
+		q.Add("takeover", f.Handover.String())
+	}
+
+	if f.FeedbackType != "" {
+		q.Add("feedback_type", string(f.FeedbackType))
+	}
+
+	for _, rating := range f.Ratings {
+		q.Add("ratings[]", strconv.Itoa(rating))
+	}
+
+	if !f.From.IsZero() {
+		q.Add("from", f.From.Format(time.RFC3339))
+	}
+
+	if !f.To.IsZero() {
+		q.Add("to", f.To.Format(time.RFC3339))
+	}
+
+	if f.Limit != 0 {
+		q.Add("limit", strconv.Itoa(f.Limit))
+	}
+
+	if f.Cursor != "" {
+		q.Add("cursor", f.Cursor)
+	}
+
+	return q
+}
+
+// Page is a page of search results.
+type Page struct {
+	Chats []*Chat
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// Search returns the first page of chats matching f. Use Page.NextCursor to
+// fetch subsequent pages, or SearchAll to iterate over every match.
+func (c *Client) Search(ctx context.Context, f *SearchFilter) (*Page, error) {
+	req, err := c.newRequest(ctx, "chats", f.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data       []*Chat `json:"data"`
+		NextCursor string  `json:"next_cursor"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Data == nil {
+		resp.Data = make([]*Chat, 0)
+	}
+
+	return &Page{Chats: resp.Data, NextCursor: resp.NextCursor}, nil
+}
+
+// SearchAll calls fn for every chat matching f, following cursors until the
+// last page or until fn returns an error.
+func (c *Client) SearchAll(ctx context.Context, f *SearchFilter, fn func(*Chat) error) error {
+	temp := SearchFilter{}
+	if f != nil {
+		temp = *f
+	}
+
+	for {
+		page, err := c.Search(ctx, &temp)
+		if err != nil {
+			return err
+		}
+
+		for _, chat := range page.Chats {
+			if err := fn(chat); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		temp.Cursor = page.NextCursor
+	}
+}
+
+// RatedChats returns every chat in [from, to) where the user gave the rating
+// on the given scale, e.g. to investigate a dip in satisfaction. Use
+// TranscriptURL to link to the chats in the inbox.
+func (c *Client) RatedChats(ctx context.Context, typ FeedbackType, rating int, from, to time.Time) ([]*Chat, error) {
+	chats := make([]*Chat, 0)
+	err := c.SearchAll(ctx, &SearchFilter{
+		FeedbackType: typ,
+		Ratings:      []int{rating},
+		From:         from,
+		To:           to,
+	}, func(chat *Chat) error {
+		chats = append(chats, chat)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chats, nil
+}
+
+// FeedbackChats returns every chat in [from, to) where the user gave feedback
+// on the given scale, whatever the rating, e.g. to join the ratings with the
+// labels of the chats.
+func (c *Client) FeedbackChats(ctx context.Context, typ FeedbackType, from, to time.Time) ([]*Chat, error) {
+	chats := make([]*Chat, 0)
+	err := c.SearchAll(ctx, &SearchFilter{
+		FeedbackType: typ,
+		From:         from,
+		To:           to,
+	}, func(chat *Chat) error {
+		if chat.Feedback != nil && chat.Feedback.Type == typ {
+			chats = append(chats, chat)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chats, nil
+}
+
+// HandedOverChat is a chat that was handed over to an agent.
+type HandedOverChat struct {
+	*Chat
+	// DialogueID is the dialogue that requested the handover, see
+	// RequestingDialogue. It is empty without the transcript.
+	DialogueID string `json:"dialogue_id,omitempty"`
+	// Messages is the transcript, if it was requested.
+	Messages []*Message `json:"messages,omitempty"`
+}
+
+// HandedOverChats returns every chat in [from, to) that was handed over to
+// an agent, oldest first, e.g. for a weekly review. With transcripts, the
+// messages of every chat are fetched too, one call per chat, and the
+// requesting dialogue is set.
+func (c *Client) HandedOverChats(ctx context.Context, from, to time.Time, transcripts bool) ([]*HandedOverChat, error) {
+	seen := make(map[string]bool)
+	chats := make([]*HandedOverChat, 0)
+	// Chats whose handover has ended are not necessarily matched as started.
+	for _, status := range []HandoverStatus{HandoverStarted, HandoverEnded} {
+		err := c.SearchAll(ctx, &SearchFilter{Handover: status, From: from, To: to}, func(chat *Chat) error {
+			if !seen[chat.ID] {
+				seen[chat.ID] = true
+				chats = append(chats, &HandedOverChat{Chat: chat})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(chats, func(i, j int) bool {
+		return chats[i].Created.Before(chats[j].Created.Time)
+	})
+
+	if !transcripts {
+		return chats, nil
+	}
+	for _, chat := range chats {
+		messages, err := c.Messages(ctx, chat.ID)
+		if err != nil {
+			return nil, fmt.Errorf("fetching messages of chat %s: %w", chat.ID, err)
+		}
+		chat.Messages = messages
+		chat.DialogueID = RequestingDialogue(messages)
+	}
+
+	return chats, nil
+}
+
+// RequestingDialogue returns the dialogue of the last bot reply before the
+// first agent message, which is usually the one that offered the handover.
+// If no agent replied, the dialogue of the last bot reply is returned.
+func RequestingDialogue(messages []*Message) string {
+	id := ""
+	for _, msg := range messages {
+		if msg.Sender == SenderAgent {
+			break
+		}
+		if msg.Sender == SenderBot && msg.DialogueID != "" {
+			id = msg.DialogueID
+		}
+	}
+
+	return id
+}
+
+// Sender is the author of a message.
+type Sender string
+
+const (
+	SenderUser  Sender = "user"
+	SenderBot   Sender = "bot"
+	SenderAgent Sender = "agent"
+)
+
+// Message is a single message in a chat transcript.
+type Message struct {
+	ID      string      `json:"id"`
+	Sender  Sender      `json:"sender"`
+	Text    string      `json:"message"`
+	Created kindly.Time `json:"created"`
+	// DialogueID is the dialogue that produced a bot reply.
+	DialogueID string `json:"dialogue_id,omitempty"`
+	Fallback   bool   `json:"fallback,omitempty"`
+}
+
+// Messages returns the transcript of the chat, oldest message first.
+func (c *Client) Messages(ctx context.Context, chatID string) ([]*Message, error) {
+	req, err := c.newRequest(ctx, fmt.Sprintf("chats/%s/messages", url.PathEscape(chatID)), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data []*Message `json:"data"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Data == nil {
+		resp.Data = make([]*Message, 0)
+	}
+
+	return resp.Data, nil
+}
+
+// Label is a chat label defined for the bot.
+type Label struct {
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+	Color string `json:"color"`
+}
+
+// Labels returns the labels currently defined for the bot.
+func (c *Client) Labels(ctx context.Context) ([]*Label, error) {
+	req, err := c.newRequest(ctx, "labels", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data []*Label `json:"data"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Data == nil {
+		resp.Data = make([]*Label, 0)
+	}
+
+	return resp.Data, nil
+}
+
+// TranscriptURL returns a link to the chat in the Kindly inbox.
+func (c *Client) TranscriptURL(chatID string) string {
+	inboxURL := c.InboxURL
+	if inboxURL == "" {
+		inboxURL = InboxURL
+	}
+
+	return fmt.Sprintf("%s/%s/inbox/chat/%s", inboxURL, c.BotID, chatID)
+}
+
+func (c *Client) newRequest(ctx context.Context, endpoint string, query url.Values) (*http.Request, error) {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s/%s", baseURL, c.BotID, endpoint), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", kindly.UserAgent())
+
+	return req, nil
+}
+
+// newBodyRequest returns a request with v encoded as its JSON body, or no
+// body if v is nil.
+func (c *Client) newBodyRequest(ctx context.Context, method, endpoint string, v interface{}) (*http.Request, error) {
+	req, err := c.newRequest(ctx, endpoint, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	req.Method = method
+	if v == nil {
+		return req, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	req.ContentLength = int64(len(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
+
+type Error struct {
+	statusCode int
+	body       []byte
+}
+
+func (e *Error) StatusCode() int {
+	return e.statusCode
+}
+
+func (e *Error) Body() []byte {
+	return e.body
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("chat: errenous status from upstream: %q", http.StatusText(e.StatusCode()))
+}
+
+// do executes r and decodes the JSON response body into v.
+func (c *Client) do(r *http.Request, v interface{}) error {
+	if c.doer == nil {
+		c.doer = http.DefaultClient
+	}
+
+	begin := time.Now()
+
+	resp, err := c.doer.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	c.logger.Log("method", r.Method, "url", r.URL.String(), "code", resp.StatusCode, "took", time.Since(begin))
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode > 399 {
+		return &Error{statusCode: resp.StatusCode, body: body}
+	}
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,308 @@
+package chat_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/chat"
+)
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return d(r)
+}
+
+func TestSearchFilter_Query(t *testing.T) {
+	f := chat.SearchFilter{
+		Text:     "refund",
+		LabelIDs: []string{"l1"},
+		Sources:  []string{"web"},
+		Handover: chat.HandoverRequested,
+		From:     time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	q := f.Query()
+	for k, want := range map[string]string{
+		"q":           "refund",
+		"label_ids[]": "l1",
+		"sources[]":   "web",
+		"takeover":    "requested",
+		"from":        "2021-02-01T00:00:00Z",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("got %s=%q, want %q", k, got, want)
+		}
+	}
+	if q.Get("to") != "" {
+		t.Errorf("expected zero To to be omitted")
+	}
+}
+
+func TestClient_SearchAll(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"data":[{"id":"1","created":"2021-02-01T10:00:00.000000"}],"next_cursor":"c2"}`,
+		"c2": `{"data":[{"id":"2","created":"2021-02-01T11:00:00.000000"}],"next_cursor":""}`,
+	}
+	c := chat.NewClient(chat.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if want := fmt.Sprintf("%s/%s/chats", chat.BaseURL, "bot"); !strings.HasPrefix(r.URL.String(), want) {
+			t.Errorf("got URL %q, want prefix %q", r.URL.String(), want)
+		}
+		body := pages[r.URL.Query().Get("cursor")]
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "bot"
+
+	ids := make([]string, 0)
+	err := c.SearchAll(context.Background(), &chat.SearchFilter{Text: "refund"}, func(c *chat.Chat) error {
+		ids = append(ids, c.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SearchAll() err=%v", err)
+	}
+
+	if strings.Join(ids, ",") != "1,2" {
+		t.Errorf("got chats %v, want [1 2]", ids)
+	}
+}
+
+func TestClient_RatedChats(t *testing.T) {
+	c := chat.NewClient(chat.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query()
+		if q.Get("feedback_type") != "emojis" || q.Get("ratings[]") != "1" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		body := `{"data":[{"id":"42","feedback":{"type":"emojis","rating":1}}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "bot"
+
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	chats, err := c.RatedChats(context.Background(), chat.FeedbackEmoji, 1, from, from.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("RatedChats() err=%v", err)
+	}
+	if len(chats) != 1 || chats[0].Feedback == nil || chats[0].Feedback.Rating != 1 {
+		t.Fatalf("unexpected chats %+v", chats)
+	}
+
+	if got, want := c.TranscriptURL(chats[0].ID), chat.InboxURL+"/bot/inbox/chat/42"; got != want {
+		t.Errorf("got TranscriptURL %q, want %q", got, want)
+	}
+}
+
+func TestClient_FeedbackChats(t *testing.T) {
+	c := chat.NewClient(chat.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query()
+		if q.Get("feedback_type") != "binary" || q.Get("ratings[]") != "" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		body := `{"data":[{"id":"1","feedback":{"type":"binary","rating":0}},{"id":"2","feedback":{"type":"binary","rating":1}},{"id":"3"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "bot"
+
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	chats, err := c.FeedbackChats(context.Background(), chat.FeedbackBinary, from, from.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("FeedbackChats() err=%v", err)
+	}
+	if len(chats) != 2 || chats[0].Feedback.Rating != 0 || chats[1].Feedback.Rating != 1 {
+		t.Errorf("got %+v, want the two rated chats", chats)
+	}
+}
+
+func TestClient_HandedOverChats(t *testing.T) {
+	c := chat.NewClient(chat.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		var body string
+		switch {
+		case r.URL.Path == "/api/v2/bot/123/chats/c2/messages":
+			body = `{"data":[{"sender":"user","message":"Human please"},{"sender":"bot","dialogue_id":"handover"},{"sender":"agent"},{"sender":"bot","dialogue_id":"goodbye"}]}`
+		case strings.HasSuffix(r.URL.Path, "/messages"):
+			body = `{"data":[]}`
+		case r.URL.Query().Get("takeover") == "started":
+			body = `{"data":[{"id":"c2","created":"2021-02-02T10:00:00.000000"}]}`
+		case r.URL.Query().Get("takeover") == "ended":
+			body = `{"data":[{"id":"c1","created":"2021-02-01T10:00:00.000000"},{"id":"c2","created":"2021-02-02T10:00:00.000000"}]}`
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "123"
+
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	chats, err := c.HandedOverChats(context.Background(), from, from.AddDate(0, 0, 7), true)
+	if err != nil {
+		t.Fatalf("HandedOverChats() err=%v", err)
+	}
+	if len(chats) != 2 || chats[0].ID != "c1" || chats[1].ID != "c2" {
+		t.Fatalf("got %+v, want c1 and c2 once each, oldest first", chats)
+	}
+	if chats[1].DialogueID != "handover" || len(chats[1].Messages) != 4 {
+		t.Errorf("got dialogue %q and %d messages, want handover and 4", chats[1].DialogueID, len(chats[1].Messages))
+	}
+}
+
+func TestClient_Messages(t *testing.T) {
+	c := chat.NewClient(chat.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if want := "/api/v2/bot/123/chats/c1/messages"; r.URL.Path != want {
+			t.Errorf("got path %q, want %q", r.URL.Path, want)
+		}
+		body := `{"data":[{"id":"m1","sender":"user","message":"Hi","created":"2021-02-01T10:00:00.000000"},{"id":"m2","sender":"bot","message":"Hello","dialogue_id":"greeting","created":"2021-02-01T10:00:01.000000"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "123"
+
+	msgs, err := c.Messages(context.Background(), "c1")
+	if err != nil {
+		t.Fatalf("c.Messages() err=%v", err)
+	}
+	if len(msgs) != 2 || msgs[0].Sender != chat.SenderUser || msgs[1].DialogueID != "greeting" || msgs[1].Created.Second() != 1 {
+		t.Errorf("got %+v %+v, want a user message followed by a greeting reply", msgs[0], msgs[1])
+	}
+}
+
+func TestClient_Labels(t *testing.T) {
+	c := chat.NewClient(chat.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if want := "/api/v2/bot/123/labels"; r.URL.Path != want {
+			t.Errorf("got path %q, want %q", r.URL.Path, want)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[{"id":"l1","text":"Refund","color":"red"}]}`))}, nil
+	})))
+	c.BotID = "123"
+
+	labels, err := c.Labels(context.Background())
+	if err != nil {
+		t.Fatalf("c.Labels() err=%v", err)
+	}
+	if len(labels) != 1 || labels[0].ID != "l1" || labels[0].Text != "Refund" {
+		t.Errorf("got %+v, want label l1 Refund", labels)
+	}
+}
+
+func TestClient_Variables(t *testing.T) {
+	var calls []string
+	c := chat.NewClient(chat.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		body := `{"data":{"customer_id":"c-1","tier":2}}`
+		switch r.Method {
+		case http.MethodPatch:
+			b, _ := io.ReadAll(r.Body)
+			if got, want := string(b), `{"context":{"tier":3}}`; got != want {
+				t.Errorf("got body %s, want %s", got, want)
+			}
+			body = `{"data":{"customer_id":"c-1","tier":3}}`
+		case http.MethodDelete:
+			return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "123"
+
+	vars, err := c.Variables(context.Background(), "c1")
+	if err != nil || vars["customer_id"] != "c-1" {
+		t.Errorf("Variables() = %v, %v, want customer_id c-1", vars, err)
+	}
+	vars, err = c.SetVariables(context.Background(), "c1", chat.Variables{"tier": 3})
+	if err != nil || vars["tier"] != 3.0 {
+		t.Errorf("SetVariables() = %v, %v, want tier 3", vars, err)
+	}
+	if err := c.DeleteVariable(context.Background(), "c1", "tier"); err != nil {
+		t.Errorf("DeleteVariable() err=%v", err)
+	}
+
+	want := []string{"GET /api/v2/bot/123/chats/c1/context", "PATCH /api/v2/bot/123/chats/c1/context", "DELETE /api/v2/bot/123/chats/c1/context/tier"}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Errorf("got calls %v, want %v", calls, want)
+	}
+}
+
+func TestClient_HandoverOutcomesBetween(t *testing.T) {
+	c := chat.NewClient(chat.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		var body string
+		switch {
+		case r.URL.Path == "/api/v2/bot/123/chats/c3/messages":
+			body = `{"data":[{"sender":"user"},{"sender":"agent"},{"sender":"bot","dialogue_id":"goodbye"}]}`
+		case r.URL.Path == "/api/v2/bot/123/chats/c4/messages":
+			body = `{"data":[{"sender":"agent"},{"sender":"user","message":"One more thing"},{"sender":"bot","dialogue_id":"tickets"}]}`
+		case strings.HasSuffix(r.URL.Path, "/messages"):
+			t.Errorf("unexpected request %s", r.URL)
+		case r.URL.Query().Get("takeover") == "requested":
+			body = `{"data":[{"id":"c1","takeover":{"requested":true},"created":"2021-02-01T10:00:00.000000"}]}`
+		case r.URL.Query().Get("takeover") == "started":
+			body = `{"data":[{"id":"c2","takeover":{"requested":true,"started":true},"created":"2021-02-02T10:00:00.000000"}]}`
+		case r.URL.Query().Get("takeover") == "ended":
+			body = `{"data":[{"id":"c3","takeover":{"requested":true,"started":true,"ended":true},"created":"2021-02-03T10:00:00.000000"},{"id":"c4","takeover":{"requested":true,"started":true,"ended":true},"created":"2021-02-04T10:00:00.000000"}]}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "123"
+
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	chats, err := c.HandoverOutcomesBetween(context.Background(), from, from.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("HandoverOutcomesBetween() err=%v", err)
+	}
+
+	var got []string
+	for _, ch := range chats {
+		got = append(got, ch.ID+"="+string(ch.Outcome))
+	}
+	if want := "c1=abandoned c2=ongoing c3=resolved c4=returned"; strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestPlanLabelSync(t *testing.T) {
+	current := []*chat.Label{
+		{ID: "l1", Text: "Refund", Color: "red"},
+		{ID: "l2", Text: "Lost item", Color: "blue"},
+		{ID: "l3", Text: "Staging only"},
+	}
+	want := []*chat.Label{
+		{Text: "refund", Color: "red"},
+		{Text: "Lost property", Color: "blue"},
+		{Text: "Ticket", Color: "green"},
+		{Text: "Staging only"},
+	}
+
+	var got []string
+	for _, ch := range chat.PlanLabelSync(current, want, map[string]string{"Lost item": "Lost property"}) {
+		got = append(got, ch.String())
+	}
+	if want := `rename "Refund" to "refund"; rename "Lost item" to "Lost property"; create "Ticket"`; strings.Join(got, "; ") != want {
+		t.Errorf("got %s, want %s", strings.Join(got, "; "), want)
+	}
+}
+
+func TestClient_ApplyLabelChanges(t *testing.T) {
+	var calls []string
+	c := chat.NewClient(chat.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+string(b))
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":{"id":"l9","text":"Ticket"}}`))}, nil
+	})))
+	c.BotID = "123"
+
+	err := c.ApplyLabelChanges(context.Background(), []*chat.LabelChange{
+		{To: &chat.Label{Text: "Ticket", Color: "green"}},
+		{From: &chat.Label{ID: "l2", Text: "Lost item"}, To: &chat.Label{ID: "l2", Text: "Lost property"}},
+	})
+	if err != nil {
+		t.Fatalf("ApplyLabelChanges() err=%v", err)
+	}
+	want := []string{
+		`POST /api/v2/bot/123/labels {"text":"Ticket","color":"green"}`,
+		`PATCH /api/v2/bot/123/labels/l2 {"text":"Lost property"}`,
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got calls\n%s\nwant\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
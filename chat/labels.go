@@ -0,0 +1,134 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// labelBody is the body of requests writing a label.
+type labelBody struct {
+	Text  string `json:"text"`
+	Color string `json:"color,omitempty"`
+}
+
+// CreateLabel defines a label for the bot and returns it with its ID.
+func (c *Client) CreateLabel(ctx context.Context, text, color string) (*Label, error) {
+	req, err := c.newBodyRequest(ctx, http.MethodPost, "labels", &labelBody{Text: text, Color: color})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data *Label `json:"data"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("chat: no label in response to creating %q", text)
+	}
+
+	return resp.Data, nil
+}
+
+// UpdateLabel sets the text and color of the label with the ID of l. Chats
+// keep the label, so reports by label ID are unaffected.
+func (c *Client) UpdateLabel(ctx context.Context, l *Label) (*Label, error) {
+	req, err := c.newBodyRequest(ctx, http.MethodPatch, fmt.Sprintf("labels/%s", url.PathEscape(l.ID)), &labelBody{Text: l.Text, Color: l.Color})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data *Label `json:"data"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return l, nil
+	}
+
+	return resp.Data, nil
+}
+
+// LabelChange is a change to the labels of a bot, as planned by
+// PlanLabelSync.
+type LabelChange struct {
+	// From is the label before the change, or nil if it is to be created.
+	From *Label
+	// To is the label after the change.
+	To *Label
+}
+
+func (ch *LabelChange) String() string {
+	switch {
+	case ch.From == nil:
+		return fmt.Sprintf("create %q", ch.To.Text)
+	case ch.From.Text != ch.To.Text:
+		return fmt.Sprintf("rename %q to %q", ch.From.Text, ch.To.Text)
+	default:
+		return fmt.Sprintf("recolor %q from %q to %q", ch.To.Text, ch.From.Color, ch.To.Color)
+	}
+}
+
+// PlanLabelSync returns the changes that give a bot with the labels current
+// every label of want, in the order of want. Labels are matched by text,
+// ignoring case. A label of want missing from current is created, unless
+// renames maps the text of a label in current to it, in which case that
+// label is renamed so chats keep it. Labels with a different color are
+// recolored, and labels of current not in want are kept.
+func PlanLabelSync(current, want []*Label, renames map[string]string) []*LabelChange {
+	byText := make(map[string]*Label, len(current))
+	for _, l := range current {
+		byText[strings.ToLower(l.Text)] = l
+	}
+	renamed := make(map[string]*Label, len(renames))
+	for from, to := range renames {
+		if l, ok := byText[strings.ToLower(from)]; ok {
+			renamed[strings.ToLower(to)] = l
+		}
+	}
+
+	var changes []*LabelChange
+	for _, w := range want {
+		key := strings.ToLower(w.Text)
+		l, ok := byText[key]
+		if !ok {
+			l, ok = renamed[key]
+		}
+		switch {
+		case !ok:
+			changes = append(changes, &LabelChange{To: &Label{Text: w.Text, Color: w.Color}})
+		case l.Text != w.Text || (w.Color != "" && l.Color != w.Color):
+			color := w.Color
+			if color == "" {
+				color = l.Color
+			}
+			changes = append(changes, &LabelChange{From: l, To: &Label{ID: l.ID, Text: w.Text, Color: color}})
+		}
+	}
+
+	return changes
+}
+
+// ApplyLabelChanges makes the changes to the labels of the bot, in order,
+// and stops at the first that fails.
+func (c *Client) ApplyLabelChanges(ctx context.Context, changes []*LabelChange) error {
+	for _, ch := range changes {
+		var err error
+		if ch.From == nil {
+			_, err = c.CreateLabel(ctx, ch.To.Text, ch.To.Color)
+		} else {
+			_, err = c.UpdateLabel(ctx, ch.To)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", ch, err)
+		}
+	}
+
+	return nil
+}
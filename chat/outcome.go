@@ -0,0 +1,102 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// HandoverOutcome is what happened in a chat after handover was requested.
+type HandoverOutcome string
+
+const (
+	// OutcomeResolved is a chat that ended with an agent.
+	OutcomeResolved HandoverOutcome = "resolved"
+	// OutcomeReturned is a chat the user continued with the bot after the
+	// agent left.
+	OutcomeReturned HandoverOutcome = "returned"
+	// OutcomeAbandoned is a chat no agent took over, usually because the
+	// user left before one was available.
+	OutcomeAbandoned HandoverOutcome = "abandoned"
+	// OutcomeOngoing is a chat an agent has taken over and not yet ended.
+	OutcomeOngoing HandoverOutcome = "ongoing"
+)
+
+// HandoverOutcomes are the outcomes, in the order they are reported.
+var HandoverOutcomes = []HandoverOutcome{OutcomeResolved, OutcomeReturned, OutcomeAbandoned, OutcomeOngoing}
+
+// Outcome returns the outcome of a chat where handover was requested. The
+// messages are only needed for chats whose handover ended, to tell resolved
+// chats from those returned to the bot.
+func Outcome(c *Chat, messages []*Message) HandoverOutcome {
+	switch {
+	case !c.Handover.Started && !c.Handover.Ended:
+		return OutcomeAbandoned
+	case !c.Handover.Ended:
+		return OutcomeOngoing
+	}
+
+	lastAgent := -1
+	for i, msg := range messages {
+		if msg.Sender == SenderAgent {
+			lastAgent = i
+		}
+	}
+	// The bot answering the user after the agent's last message means the
+	// chat went back to the bot; a bot goodbye alone does not.
+	user := false
+	for _, msg := range messages[lastAgent+1:] {
+		switch {
+		case msg.Sender == SenderUser:
+			user = true
+		case msg.Sender == SenderBot && user:
+			return OutcomeReturned
+		}
+	}
+
+	return OutcomeResolved
+}
+
+// ChatOutcome is a chat where handover was requested, with its outcome.
+type ChatOutcome struct {
+	*Chat
+	Outcome HandoverOutcome `json:"outcome"`
+}
+
+// HandoverOutcomesBetween returns every chat in [from, to) where handover was
+// requested with its outcome, oldest first. The messages of chats whose
+// handover ended are fetched, one call per chat.
+func (c *Client) HandoverOutcomesBetween(ctx context.Context, from, to time.Time) ([]*ChatOutcome, error) {
+	seen := make(map[string]bool)
+	chats := make([]*ChatOutcome, 0)
+	// Chats are only matched by their latest handover state.
+	for _, status := range []HandoverStatus{HandoverRequested, HandoverStarted, HandoverEnded} {
+		err := c.SearchAll(ctx, &SearchFilter{Handover: status, From: from, To: to}, func(chat *Chat) error {
+			if !seen[chat.ID] {
+				seen[chat.ID] = true
+				chats = append(chats, &ChatOutcome{Chat: chat})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(chats, func(i, j int) bool {
+		return chats[i].Created.Before(chats[j].Created.Time)
+	})
+
+	for _, chat := range chats {
+		var messages []*Message
+		if chat.Handover.Ended {
+			var err error
+			if messages, err = c.Messages(ctx, chat.ID); err != nil {
+				return nil, fmt.Errorf("fetching messages of chat %s: %w", chat.ID, err)
+			}
+		}
+		chat.Outcome = Outcome(chat.Chat, messages)
+	}
+
+	return chats, nil
+}
@@ -0,0 +1,65 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Variables are the context variables of a chat: user attributes set by
+// dialogues and webhooks, such as a customer number, which dialogues can
+// refer to. Values are strings, numbers, booleans, lists or objects.
+type Variables map[string]interface{}
+
+// Variables returns the context variables of the chat.
+func (c *Client) Variables(ctx context.Context, chatID string) (Variables, error) {
+	req, err := c.newRequest(ctx, fmt.Sprintf("chats/%s/context", url.PathEscape(chatID)), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data Variables `json:"data"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		resp.Data = make(Variables)
+	}
+
+	return resp.Data, nil
+}
+
+// SetVariables sets the given context variables of the chat, so backend
+// services can inject customer data into the conversation, and returns all
+// its variables. Variables not in vars are kept.
+func (c *Client) SetVariables(ctx context.Context, chatID string, vars Variables) (Variables, error) {
+	req, err := c.newBodyRequest(ctx, http.MethodPatch, fmt.Sprintf("chats/%s/context", url.PathEscape(chatID)), map[string]Variables{"context": vars})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data Variables `json:"data"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		resp.Data = make(Variables)
+	}
+
+	return resp.Data, nil
+}
+
+// DeleteVariable removes a context variable from the chat.
+func (c *Client) DeleteVariable(ctx context.Context, chatID, key string) error {
+	req, err := c.newBodyRequest(ctx, http.MethodDelete, fmt.Sprintf("chats/%s/context/%s", url.PathEscape(chatID), url.PathEscape(key)), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, nil)
+}
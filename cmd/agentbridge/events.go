@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// replaySize is the number of events kept for subscribers reconnecting
+	// with Last-Event-ID.
+	replaySize = 256
+	// subscriberBuffer is the number of events a slow subscriber may lag
+	// behind before it is disconnected.
+	subscriberBuffer = 64
+	// keepalive is how often an idle stream sends a comment, so proxies do
+	// not close it.
+	keepalive = 15 * time.Second
+)
+
+// handoverEvent is the data of a handover_requested event.
+type handoverEvent struct {
+	// ID identifies the event for Last-Event-ID.
+	ID       string   `json:"id"`
+	ChatID   string   `json:"chat_id"`
+	Source   string   `json:"source"`
+	Language string   `json:"language_code"`
+	LabelIDs []string `json:"label_ids"`
+	// Requested is when the chat was last updated, which is at or after the
+	// handover request since chats do not record it.
+	Requested     time.Time `json:"requested"`
+	TranscriptURL string    `json:"transcript_url"`
+}
+
+// hub fans events out to the subscribers of the stream.
+type hub struct {
+	mu     sync.Mutex
+	subs   map[chan *handoverEvent]bool
+	recent []*handoverEvent
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[chan *handoverEvent]bool)}
+}
+
+// publish sends e to every subscriber. Subscribers too slow to keep up are
+// disconnected, and resume with Last-Event-ID.
+func (h *hub) publish(e *handoverEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.recent = append(h.recent, e)
+	if len(h.recent) > replaySize {
+		h.recent = h.recent[len(h.recent)-replaySize:]
+	}
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns a channel of the events published from now on, preceded
+// by those after the event lastID if it is still kept.
+func (h *hub) subscribe(lastID string) (chan *handoverEvent, []*handoverEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan *handoverEvent, subscriberBuffer)
+	h.subs[ch] = true
+
+	var missed []*handoverEvent
+	for i, e := range h.recent {
+		if e.ID == lastID {
+			missed = append(missed, h.recent[i+1:]...)
+			break
+		}
+	}
+
+	return ch, missed
+}
+
+func (h *hub) unsubscribe(ch chan *handoverEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subs[ch] {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// subscribers returns the number of connected subscribers.
+func (h *hub) subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subs)
+}
+
+// ServeHTTP serves the events as a server-sent events stream.
+func (h *hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, missed := h.subscribe(r.Header.Get("Last-Event-ID"))
+	defer h.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Buffering proxies such as nginx would hold the events back.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 5000\n\n")
+	for _, e := range missed {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	t := time.NewTicker(keepalive)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, e)
+		case <-t.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e *handoverEvent) {
+	b, _ := json.Marshal(e)
+	fmt.Fprintf(w, "event: handover_requested\nid: %s\ndata: %s\n\n", e.ID, b)
+}
+
+// requireToken serves next only to requests with one of tokens as bearer
+// token or access_token query parameter, since EventSource cannot set
+// headers. Requests are not authenticated if there are no tokens.
+func requireToken(tokens []string, next http.Handler) http.Handler {
+	if len(tokens) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("access_token")
+		}
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
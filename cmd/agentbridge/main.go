@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/statistics/auth"
+	"github.com/go-kit/kit/log"
+	"golang.org/x/oauth2"
+)
+
+type config struct {
+	listenPort  string
+	botID       string
+	apiKey      string
+	credentials string
+	interval    time.Duration
+	lookback    time.Duration
+	// tokens are the access tokens of subscribers; none disables
+	// authentication.
+	tokens []string
+}
+
+func main() {
+	listenPortFlag := flag.String("port", "8080", "HTTP listen port")
+	botIDFlag := flag.String("botid", "", "kindly bot ID")
+	apiKeyFlag := flag.String("apikey", "", "kindly API key")
+	credentialsFlag := flag.String("credentials", "", "kindly API key location, e.g. gsm://projects/<p>/secrets/<s> or vault://<mount>/<path>; overrides -apikey")
+	intervalFlag := flag.Duration("interval", 5*time.Second, "how often to look for handover requests")
+	lookbackFlag := flag.Duration("lookback", 10*time.Minute, "how far back each look for handover requests reaches")
+	tokensFlag := flag.String("tokens", os.Getenv("AGENTBRIDGE_TOKENS"), "comma-separated access tokens of subscribers (default: $AGENTBRIDGE_TOKENS); empty disables authentication")
+	versionFlag := flag.Bool("version", false, "print the version and exit")
+	flag.Parse()
+
+	if *versionFlag {
+		fmt.Println(kindly.Build())
+		return
+	}
+
+	var tokens []string
+	for _, t := range strings.Split(*tokensFlag, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := run(ctx, &config{
+		listenPort:  *listenPortFlag,
+		botID:       *botIDFlag,
+		apiKey:      *apiKeyFlag,
+		credentials: *credentialsFlag,
+		interval:    *intervalFlag,
+		lookback:    *lookbackFlag,
+		tokens:      tokens,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, config *config) error {
+	if config.botID == "" {
+		return fmt.Errorf("missing -botid")
+	}
+	if config.interval <= 0 || config.lookback < config.interval {
+		return fmt.Errorf("-interval must be positive and -lookback at least -interval")
+	}
+
+	var creds auth.Credentials = auth.StaticCredentials(config.apiKey)
+	if config.credentials != "" {
+		c, err := auth.ParseCredentials(ctx, config.credentials)
+		if err != nil {
+			return err
+		}
+		creds = c
+	}
+
+	logger := log.NewLogfmtLogger(os.Stdout)
+	client := chat.NewClient(chat.WithDoer(oauth2.NewClient(ctx, &auth.KeySource{Credentials: creds})))
+	client.BotID = config.botID
+
+	events := newHub()
+	p := &poller{client: client, hub: events, logger: logger, interval: config.interval, lookback: config.lookback}
+	go p.run(ctx)
+
+	m := http.NewServeMux()
+	m.Handle("/events/handovers", requireToken(config.tokens, events))
+	m.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok\n")
+	})
+	srv := &http.Server{Addr: ":" + config.listenPort, Handler: m, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		// Streams never finish, so they are closed rather than drained.
+		srv.Close()
+	}()
+
+	logger.Log("msg", "listening", "port", config.listenPort)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
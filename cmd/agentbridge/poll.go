@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/atb-as/kindly/chat"
+	"github.com/go-kit/kit/log"
+)
+
+// poller publishes the chats whose handover was requested, found by
+// searching the chats updated recently.
+type poller struct {
+	client   *chat.Client
+	hub      *hub
+	logger   log.Logger
+	interval time.Duration
+	// lookback is how far back each search reaches, covering chats updated
+	// while a search was failing or slow.
+	lookback time.Duration
+
+	// seen are the chats already published, with when they were last found,
+	// so each handover request is published once.
+	seen map[string]time.Time
+}
+
+// run polls every interval until ctx is done. Chats requesting handover at
+// startup are not published, since subscribers have seen them already or
+// will not act on them.
+func (p *poller) run(ctx context.Context) {
+	p.seen = make(map[string]time.Time)
+	p.poll(ctx, false)
+
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.poll(ctx, true)
+		}
+	}
+}
+
+func (p *poller) poll(ctx context.Context, publish bool) {
+	now := time.Now()
+	err := p.client.SearchAll(ctx, &chat.SearchFilter{
+		Handover: chat.HandoverRequested,
+		From:     now.Add(-p.lookback),
+	}, func(c *chat.Chat) error {
+		_, ok := p.seen[c.ID]
+		p.seen[c.ID] = now
+		if ok || !publish {
+			return nil
+		}
+
+		p.hub.publish(&handoverEvent{
+			ID:            c.ID,
+			ChatID:        c.ID,
+			Source:        c.Source,
+			Language:      c.Language,
+			LabelIDs:      c.LabelIDs,
+			Requested:     c.Updated.Time,
+			TranscriptURL: p.client.TranscriptURL(c.ID),
+		})
+		p.logger.Log("msg", "handover requested", "chat", c.ID, "subscribers", p.hub.subscribers())
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		p.logger.Log("msg", "polling handover requests failed", "err", err)
+		return
+	}
+
+	// Chats no longer found can not be published again, so they are
+	// forgotten.
+	for id, found := range p.seen {
+		if found.Before(now.Add(-2 * p.lookback)) {
+			delete(p.seen, id)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+const (
+	labelWidth  = 14
+	columnWidth = 32
+)
+
+// sparks are the bars of a sparkline, from lowest to highest.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// dashboard is the handovers of today of several bots, polled together.
+type dashboard struct {
+	// bots are the panels in the order of the config, keyed by bot ID in
+	// panels.
+	bots     []*botPanel
+	panels   map[string]*botPanel
+	multi    *statistics.MultiClient
+	loc      *time.Location
+	interval time.Duration
+}
+
+// botPanel is the latest handovers of a bot. After a failed poll the
+// previous numbers are kept and shown with the error.
+type botPanel struct {
+	name string
+
+	totals *statistics.Handovers
+	hourly []*statistics.HandoversTimeSeries
+	// queue is nil where Sage does not expose the handover queue.
+	queue   *statistics.HandoverQueue
+	updated time.Time
+	err     error
+}
+
+// queueService is a Service that also has the handover queue, such as a
+// *statistics.Client.
+type queueService interface {
+	statistics.Service
+	HandoverQueueTotal(ctx context.Context, f *statistics.Filter) (*statistics.HandoverQueue, error)
+}
+
+var _ queueService = (*statistics.Client)(nil)
+
+// handovers is a poll of a bot.
+type handovers struct {
+	totals *statistics.Handovers
+	series []*statistics.HandoversTimeSeries
+	queue  *statistics.HandoverQueue
+}
+
+// poll fetches the handovers of today of every bot, concurrently.
+func (d *dashboard) poll(ctx context.Context) {
+	now := time.Now().In(d.loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, d.loc)
+	f := &statistics.Filter{From: from, To: from.AddDate(0, 0, 1), Timezone: d.loc.String()}
+
+	results := d.multi.Each(ctx, func(ctx context.Context, s statistics.Service) (interface{}, error) {
+		return pollHandovers(ctx, s.(queueService), f)
+	})
+	for _, r := range results {
+		b := d.panels[r.BotID]
+		if b.err = r.Err; r.Err != nil {
+			continue
+		}
+		b.update(r.Value.(*handovers), now)
+	}
+}
+
+func pollHandovers(ctx context.Context, s queueService, f *statistics.Filter) (*handovers, error) {
+	totals, err := s.HandoversTotal(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	hourly := *f
+	hourly.Granularity = statistics.Hour
+	series, err := s.HandoversTimeSeries(ctx, &hourly)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := s.HandoverQueueTotal(ctx, f)
+	var serr *statistics.Error
+	if errors.As(err, &serr) && serr.StatusCode() == http.StatusNotFound {
+		queue, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &handovers{totals: totals, series: series, queue: queue}, nil
+}
+
+func (b *botPanel) update(h *handovers, now time.Time) {
+	b.totals = h.totals
+	b.hourly = b.hourly[:0]
+	for _, s := range h.series {
+		if !s.Date.After(now) {
+			b.hourly = append(b.hourly, s)
+		}
+	}
+	b.queue = h.queue
+	b.updated = now
+}
+
+// render returns the dashboard as text: a row per counter with a column per
+// bot, and a sparkline of today's hours for the counters Sage has series of.
+func (d *dashboard) render(now time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "kindly agentstats  %s  every %s, ctrl-c to quit\n\n", now.In(d.loc).Format("2006-01-02 15:04:05"), d.interval)
+
+	row := func(label string, cell func(b *botPanel) string) {
+		line := fmt.Sprintf("%-*s", labelWidth, label)
+		for _, b := range d.bots {
+			line += fmt.Sprintf("%-*s", columnWidth, truncate(cell(b), columnWidth-2))
+		}
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	counter := func(label string, total func(h *statistics.Handovers) int) {
+		row(label, func(b *botPanel) string {
+			if b.totals == nil {
+				return "-"
+			}
+			values := make([]int, 0, len(b.hourly))
+			for _, h := range b.hourly {
+				values = append(values, total(&h.Handovers))
+			}
+			return fmt.Sprintf("%5d %s", total(b.totals), sparkline(values))
+		})
+	}
+	queue := func(label string, value func(q *statistics.HandoverQueue) string) {
+		row(label, func(b *botPanel) string {
+			if b.queue == nil {
+				return "-"
+			}
+			return value(b.queue)
+		})
+	}
+
+	row("", func(b *botPanel) string { return b.name })
+	counter("requests", func(h *statistics.Handovers) int { return h.Requests })
+	counter("started", func(h *statistics.Handovers) int { return h.Started })
+	counter("ended", func(h *statistics.Handovers) int { return h.Ended })
+	counter("while closed", func(h *statistics.Handovers) int { return h.RequestsWhileClosed })
+	queue("in queue", func(q *statistics.HandoverQueue) string {
+		return fmt.Sprintf("%5d", waiting(q))
+	})
+	queue("average wait", func(q *statistics.HandoverQueue) string {
+		return fmt.Sprintf("%5s", q.Wait().Round(time.Second))
+	})
+	queue("abandoned", func(q *statistics.HandoverQueue) string {
+		return fmt.Sprintf("%5d %.0f%%", q.Abandoned, 100*q.AbandonRate())
+	})
+	row("", func(b *botPanel) string {
+		switch {
+		case b.err != nil && b.updated.IsZero():
+			return "error: " + b.err.Error()
+		case b.err != nil:
+			return fmt.Sprintf("stale since %s: %v", b.updated.Format("15:04:05"), b.err)
+		default:
+			return ""
+		}
+	})
+
+	return sb.String()
+}
+
+// waiting returns the requests of today that are still waiting for an agent.
+func waiting(q *statistics.HandoverQueue) int {
+	if n := q.Requests - q.Joined - q.Abandoned; n > 0 {
+		return n
+	}
+
+	return 0
+}
+
+// sparkline returns a bar per value, scaled from zero to the largest value.
+func sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	ret := make([]rune, 0, len(values))
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = v * (len(sparks) - 1) / max
+		}
+		ret = append(ret, sparks[i])
+	}
+
+	return string(ret)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+
+	return string(r[:n-1]) + "…"
+}
+
+// screen draws frames on a terminal in place, or one after another on
+// anything else, such as a file or pipe.
+type screen struct {
+	w        io.Writer
+	terminal bool
+}
+
+func newScreen(f *os.File) *screen {
+	s := &screen{w: f}
+	if fi, err := f.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		s.terminal = true
+		// Hide the cursor while drawing.
+		fmt.Fprint(f, "\x1b[?25l")
+	}
+
+	return s
+}
+
+func (s *screen) draw(frame string) {
+	if s.terminal {
+		// Move home and clear, then draw.
+		fmt.Fprint(s.w, "\x1b[H\x1b[2J"+frame)
+		return
+	}
+	fmt.Fprintln(s.w, frame)
+}
+
+func (s *screen) close() {
+	if s.terminal {
+		fmt.Fprint(s.w, "\x1b[?25h")
+	}
+}
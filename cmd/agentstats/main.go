@@ -0,0 +1,130 @@
+// Command agentstats is a live terminal dashboard of the handovers of the
+// bots in a kindly config file, side by side, for support team leads.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/atb-as/kindly/cache"
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/statistics/auth"
+	"golang.org/x/oauth2"
+)
+
+func main() {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	pathFlag := flag.String("config", defaultPath, "path of the config file written by kindly init")
+	botsFlag := flag.String("bots", "", "comma-separated bot IDs in the config file to show (default: all)")
+	intervalFlag := flag.Duration("interval", 10*time.Second, "how often to poll the handovers")
+	onceFlag := flag.Bool("once", false, "print the dashboard once and exit")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := run(ctx, *pathFlag, *botsFlag, *intervalFlag, *onceFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, path, botIDs string, interval time.Duration, once bool) error {
+	if interval < time.Second {
+		return fmt.Errorf("-interval must be at least 1s")
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	bots := c.Bots
+	if botIDs != "" {
+		bots = nil
+		for _, id := range strings.Split(botIDs, ",") {
+			b, err := c.Bot(strings.TrimSpace(id))
+			if err != nil {
+				return err
+			}
+			bots = append(bots, b)
+		}
+	}
+	loc, err := (&statistics.Filter{Timezone: c.Timezone}).Location()
+	if err != nil {
+		return err
+	}
+
+	d := &dashboard{panels: make(map[string]*botPanel), loc: loc, interval: interval}
+	clients := make(map[string]statistics.Service, len(bots))
+	for _, b := range bots {
+		if _, ok := d.panels[b.ID]; ok {
+			continue
+		}
+		client, err := newClient(ctx, b)
+		if err != nil {
+			return fmt.Errorf("bot %s: %w", b.ID, err)
+		}
+		clients[b.ID] = client
+		name := b.Name
+		if name == "" {
+			name = "bot " + b.ID
+		}
+		panel := &botPanel{name: name}
+		d.bots = append(d.bots, panel)
+		d.panels[b.ID] = panel
+	}
+	d.multi = statistics.NewMultiClient(clients)
+
+	out := newScreen(os.Stdout)
+	defer out.close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.poll(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		out.draw(d.render(time.Now()))
+		if once {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// newClient returns a statistics client for bot, authorized with its
+// credentials.
+func newClient(ctx context.Context, bot *config.Bot) (*statistics.Client, error) {
+	creds, err := auth.ParseCredentials(ctx, bot.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &auth.TokenSource{Credentials: creds, BotID: bot.ID}
+	// Every poll asks for the same day, so unchanged responses are
+	// revalidated instead of fetched again where Sage supports it.
+	client := statistics.NewClient(
+		statistics.WithDoer(oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, ts))),
+		statistics.WithConditionalRequests(cache.NewMemory(), 24*time.Hour),
+	)
+	client.BotID = bot.ID
+
+	return client, nil
+}
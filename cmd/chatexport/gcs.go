@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+)
+
+const gcsUploadURL = "https://storage.googleapis.com/upload/storage/v1/b"
+
+// gcsUploader uploads files to a Cloud Storage bucket with the JSON API's
+// simple media upload.
+type gcsUploader struct {
+	bucket string
+	prefix string
+	client *http.Client
+}
+
+// newGCSUploader parses a gs://<bucket>[/<prefix>] URI and authenticates
+// with Application Default Credentials.
+func newGCSUploader(ctx context.Context, uri string) (*gcsUploader, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parsing -gcs: %w", err)
+	}
+	if u.Scheme != "gs" || u.Host == "" {
+		return nil, fmt.Errorf("parsing -gcs: want gs://<bucket>[/<prefix>], got %q", uri)
+	}
+
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_write")
+	if err != nil {
+		return nil, fmt.Errorf("gcs: %w", err)
+	}
+
+	return &gcsUploader{bucket: u.Host, prefix: strings.Trim(u.Path, "/"), client: client}, nil
+}
+
+func (g *gcsUploader) upload(ctx context.Context, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name := path.Join(g.prefix, filepath.Base(file))
+	q := url.Values{"uploadType": {"media"}, "name": {name}}
+	endpoint := fmt.Sprintf("%s/%s/o?%s", gcsUploadURL, url.PathEscape(g.bucket), q.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, f)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("gcs: uploading %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("gcs: uploading %s: %s: %s", name, resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/atb-as/kindly/anonymize"
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/manifest"
+	"github.com/atb-as/kindly/sentiment"
+	"github.com/atb-as/kindly/sentiment/googlenl"
+	"github.com/atb-as/kindly/statistics/auth"
+	"github.com/go-kit/kit/log"
+	"golang.org/x/oauth2"
+)
+
+type config struct {
+	botID       string
+	apiKey      string
+	credentials string
+	from        time.Time
+	to          time.Time
+	outDir      string
+	gcs         string
+	handedOver  bool
+	// sentiment scores the sentiment of user messages.
+	sentiment string
+}
+
+func main() {
+	botIDFlag := flag.String("botid", "", "kindly bot ID")
+	apiKeyFlag := flag.String("apikey", "", "kindly API key")
+	credentialsFlag := flag.String("credentials", "", "kindly API key location, e.g. gsm://projects/<p>/secrets/<s> or vault://<mount>/<path>; overrides -apikey")
+	fromFlag := flag.String("from", "", "export chats created from this date (format: 2006-01-02, default: yesterday)")
+	toFlag := flag.String("to", "", "export chats created before this date (format: 2006-01-02, default: today)")
+	outFlag := flag.String("out", ".", "directory to write the transcripts and manifest to")
+	gcsFlag := flag.String("gcs", "", "also upload the files to gs://<bucket>[/<prefix>], using Application Default Credentials")
+	handedOverFlag := flag.Bool("handed-over", false, "only export chats that were handed over to an agent, with the dialogue that requested the handover")
+	sentimentFlag := flag.String("sentiment", "none", "score the sentiment of user messages with a provider: none or googlenl, using Application Default Credentials")
+	flag.Parse()
+
+	to := time.Now().Truncate(24 * time.Hour)
+	if *toFlag != "" {
+		var err error
+		if to, err = time.Parse("2006-01-02", *toFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "parsing -to: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	from := to.AddDate(0, 0, -1)
+	if *fromFlag != "" {
+		var err error
+		if from, err = time.Parse("2006-01-02", *fromFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "parsing -from: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := run(ctx, &config{
+		botID:       *botIDFlag,
+		apiKey:      *apiKeyFlag,
+		credentials: *credentialsFlag,
+		from:        from,
+		to:          to,
+		outDir:      *outFlag,
+		gcs:         *gcsFlag,
+		handedOver:  *handedOverFlag,
+		sentiment:   *sentimentFlag,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// transcript is one line of the exported JSONL file.
+type transcript struct {
+	*chat.Chat
+	Messages []*message `json:"messages"`
+	// DialogueID is the dialogue that requested the handover, with
+	// -handed-over.
+	DialogueID string `json:"dialogue_id,omitempty"`
+}
+
+// message is a message of a transcript, with the sentiment of user messages
+// if scored.
+type message struct {
+	*chat.Message
+	Sentiment *sentiment.Score `json:"sentiment,omitempty"`
+}
+
+// exportManifest lists the chats in an export, so downstream jobs can verify
+// they received all of it, along with the provenance of the export.
+type exportManifest struct {
+	*manifest.Manifest
+	BotID   string   `json:"bot_id"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	File    string   `json:"file"`
+	SHA256  string   `json:"sha256"`
+	Count   int      `json:"count"`
+	ChatIDs []string `json:"chat_ids"`
+}
+
+func run(ctx context.Context, config *config) error {
+	if config.botID == "" {
+		return fmt.Errorf("missing -botid")
+	}
+	if !config.from.Before(config.to) {
+		return fmt.Errorf("-from must be before -to")
+	}
+
+	var creds auth.Credentials = auth.StaticCredentials(config.apiKey)
+	if config.credentials != "" {
+		c, err := auth.ParseCredentials(ctx, config.credentials)
+		if err != nil {
+			return err
+		}
+		creds = c
+	}
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	client := chat.NewClient(
+		chat.WithDoer(oauth2.NewClient(ctx, &auth.KeySource{Credentials: creds})),
+		chat.WithLogger(logger))
+	client.BotID = config.botID
+
+	prefix := "chats"
+	if config.handedOver {
+		prefix = "handovers"
+	}
+	name := fmt.Sprintf("%s-%s-%s-%s", prefix, config.botID, config.from.Format("20060102"), config.to.Format("20060102"))
+	dataPath := filepath.Join(config.outDir, name+".jsonl")
+	manifestPath := filepath.Join(config.outDir, name+".manifest.json")
+
+	provider, err := newSentimentProvider(ctx, config.sentiment)
+	if err != nil {
+		return err
+	}
+
+	m, err := exportChats(ctx, client, anonymize.New(), provider, logger, config, dataPath)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	logger.Log("msg", "exported chats", "count", m.Count, "file", dataPath)
+
+	if config.gcs == "" {
+		return nil
+	}
+
+	uploader, err := newGCSUploader(ctx, config.gcs)
+	if err != nil {
+		return err
+	}
+	// The manifest goes last, so its presence signals a complete upload.
+	for _, path := range []string{dataPath, manifestPath} {
+		if err := uploader.upload(ctx, path); err != nil {
+			return err
+		}
+		logger.Log("msg", "uploaded", "file", path, "bucket", uploader.bucket)
+	}
+
+	return nil
+}
+
+// exportChats writes the redacted transcript of every chat created in
+// [config.from, config.to) to path, one JSON object per line, with the
+// sentiment of its user messages from provider. With config.handedOver only
+// chats handed over to an agent are written. Chats whose sentiment could not
+// be scored are written without it.
+func exportChats(ctx context.Context, client *chat.Client, anon *anonymize.Anonymizer, provider sentiment.Provider, logger log.Logger, config *config, path string) (*exportManifest, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &exportManifest{
+		Manifest: manifest.New("chatexport", map[string]string{
+			"bot_id":      config.botID,
+			"from":        config.from.Format("2006-01-02"),
+			"to":          config.to.Format("2006-01-02"),
+			"handed_over": strconv.FormatBool(config.handedOver),
+			"sentiment":   config.sentiment,
+		}),
+		BotID:   config.botID,
+		From:    config.from.Format("2006-01-02"),
+		To:      config.to.Format("2006-01-02"),
+		File:    filepath.Base(path),
+		ChatIDs: make([]string, 0),
+	}
+	mw := m.NewWriter(filepath.Base(path), f)
+	w := bufio.NewWriter(mw)
+	enc := json.NewEncoder(w)
+
+	write := func(c *chat.Chat, messages []*chat.Message, dialogueID string) error {
+		for _, msg := range messages {
+			msg.Text = anon.String(msg.Text)
+		}
+		// Only redacted texts are sent to the provider.
+		scores, err := sentiment.Annotate(ctx, provider, messages, c.Language)
+		if err != nil {
+			logger.Log("msg", "scoring sentiment failed", "chat", c.ID, "err", err)
+		}
+
+		t := &transcript{Chat: c, Messages: make([]*message, 0, len(messages)), DialogueID: dialogueID}
+		for _, msg := range messages {
+			t.Messages = append(t.Messages, &message{Message: msg, Sentiment: scores[msg.ID]})
+		}
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+		m.ChatIDs = append(m.ChatIDs, t.ID)
+		return nil
+	}
+
+	if config.handedOver {
+		chats, err := client.HandedOverChats(ctx, config.from, config.to, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chats {
+			if err := write(c.Chat, c.Messages, c.DialogueID); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		err = client.SearchAll(ctx, &chat.SearchFilter{From: config.from, To: config.to}, func(c *chat.Chat) error {
+			messages, err := client.Messages(ctx, c.ID)
+			if err != nil {
+				return fmt.Errorf("fetching messages of chat %s: %w", c.ID, err)
+			}
+			return write(c, messages, "")
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	m.Count = len(m.ChatIDs)
+	mw.Rows = m.Count
+	mw.Close()
+	m.Created = time.Now().UTC()
+	m.SHA256 = m.Files[0].SHA256
+	return m, nil
+}
+
+// newSentimentProvider returns the sentiment provider named name.
+func newSentimentProvider(ctx context.Context, name string) (sentiment.Provider, error) {
+	switch name {
+	case "", "none":
+		return sentiment.Nop{}, nil
+	case "googlenl":
+		return googlenl.NewDefaultProvider(ctx)
+	default:
+		return nil, fmt.Errorf("unknown -sentiment %q, want none or googlenl", name)
+	}
+}
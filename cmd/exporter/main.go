@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/export/csvstore"
+	"github.com/atb-as/kindly/export/datadog"
+	"github.com/atb-as/kindly/export/influx"
+	"github.com/atb-as/kindly/export/remotewrite"
+	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/statistics/auth"
+	"github.com/go-kit/kit/log"
+	"golang.org/x/oauth2"
+)
+
+type config struct {
+	botID         string
+	apiKey        string
+	credentials   string
+	caBundle      string
+	baseURLs      []string
+	interval      time.Duration
+	sources       []statistics.Source
+	datadogAPIKey string
+	datadogURL    string
+	remoteWrite   string
+	influxURL     string
+	influxOrg     string
+	influxBucket  string
+	influxToken   string
+	csvDir        string
+	backfillFrom  time.Time
+	backfillTo    time.Time
+	// backfillChunk splits the backfill into queries of this length; 0
+	// queries the whole range at once.
+	backfillChunk time.Duration
+	progress      bool
+	// networkRetries is how often calls failing with a transient network
+	// error are retried; 0 disables it.
+	networkRetries int
+	// maxResponseSize is the largest response body read; negative for no
+	// limit.
+	maxResponseSize int64
+	// timeout and endpointTimeouts limit the time of calls; 0 for none.
+	timeout          time.Duration
+	endpointTimeouts map[string]time.Duration
+	// strictDecoding fails calls whose response has unknown fields.
+	strictDecoding bool
+	// schemaCheck is how often to check the responses of every endpoint
+	// for unknown and missing fields; 0 disables it.
+	schemaCheck time.Duration
+	// staleAfter is the number of failed polls in a row after which the
+	// series of a metric are marked stale; 0 never marks them.
+	staleAfter int
+	// schedule is the cron spec of the exports of the previous day to
+	// bigqueryTable, sheetsID and slackWebhook; empty disables them.
+	schedule      string
+	bigqueryTable string
+	sheetsID      string
+	sheetsRange   string
+	slackWebhook  string
+	// lockDSN and lockDir select where replicas claim scheduled runs; by
+	// default runs are only claimed within the process.
+	lockDSN string
+	lockDir string
+}
+
+func main() {
+	botIDFlag := flag.String("botid", "", "kindly bot ID")
+	apiKeyFlag := flag.String("apikey", "", "kindly API key")
+	credentialsFlag := flag.String("credentials", "", "kindly API key location, e.g. gsm://projects/<p>/secrets/<s> or vault://<mount>/<path>; overrides -apikey")
+	caBundleFlag := flag.String("ca-bundle", "", "PEM file with additional CA certificates to trust for upstream calls, e.g. of an intercepting egress proxy")
+	baseURLsFlag := flag.String("base-urls", "", "comma-separated Sage base URLs to fail over between, in order of preference (default: "+statistics.BaseURL+")")
+	intervalFlag := flag.Duration("interval", 5*time.Minute, "poll interval")
+	sourcesFlag := flag.String("sources", "web,facebook", "comma-separated sources to export per-source metrics for")
+	datadogAPIKeyFlag := flag.String("datadog-apikey", "", "Datadog API key")
+	datadogURLFlag := flag.String("datadog-url", datadog.BaseURL, "Datadog API base URL")
+	remoteWriteFlag := flag.String("remote-write-url", "", "Prometheus remote write endpoint")
+	influxURLFlag := flag.String("influx-url", "", "InfluxDB v2 server URL")
+	influxOrgFlag := flag.String("influx-org", "", "InfluxDB organization")
+	influxBucketFlag := flag.String("influx-bucket", "", "InfluxDB bucket")
+	influxTokenFlag := flag.String("influx-token", os.Getenv("INFLUX_TOKEN"), "InfluxDB API token (default: $INFLUX_TOKEN)")
+	csvDirFlag := flag.String("csv-dir", "", "directory of CSV files, one per metric, to append rows not yet written to")
+	backfillFromFlag := flag.String("backfill-from", "", "export daily history from this date (format: 2006-01-02) once and exit")
+	backfillToFlag := flag.String("backfill-to", "", "end date of the backfill (format: 2006-01-02, default: today)")
+	backfillChunkFlag := flag.Duration("backfill-chunk", 0, "split the backfill into queries of this length, e.g. 720h; 0 queries the whole range at once")
+	networkRetriesFlag := flag.Int("network-retries", 3, "times to retry calls failing with a transient network error, such as a connection reset or a DNS failure; 0 disables it")
+	maxResponseSizeFlag := flag.Int64("max-response-size", statistics.DefaultMaxResponseSize, "largest response body in bytes read from Sage; negative for no limit")
+	timeoutFlag := flag.Duration("timeout", 0, "time limit of every call, including reading the response; 0 for none")
+	endpointTimeoutsFlag := flag.String("endpoint-timeouts", "", "comma-separated time limits overriding -timeout per endpoint, e.g. pages/series=2m,sessions/chats=30s")
+	strictDecodingFlag := flag.Bool("strict-decoding", false, "fail calls whose response has fields the exporter does not know, instead of ignoring them")
+	schemaCheckFlag := flag.Duration("schema-check", 0, "how often to check the responses of every endpoint for unknown and missing fields, logging the differences; 0 disables it")
+	staleAfterFlag := flag.Int("stale-after", 3, "failed polls in a row after which the series of a metric are marked stale in Prometheus, instead of keeping their last value; 0 never marks them")
+	scheduleFlag := flag.String("schedule", "", "cron spec in Europe/Oslo of the export of the previous day to -bigquery-table, -sheets-id and -slack-webhook, e.g. \"30 6 * * *\"")
+	bigqueryTableFlag := flag.String("bigquery-table", "", "BigQuery table to stream the scheduled export into (format: <project>.<dataset>.<table>)")
+	sheetsIDFlag := flag.String("sheets-id", "", "ID of a Google Sheets spreadsheet to append the scheduled export to")
+	sheetsRangeFlag := flag.String("sheets-range", "Kindly", "sheet or A1 range of -sheets-id to append to")
+	slackWebhookFlag := flag.String("slack-webhook", os.Getenv("SLACK_WEBHOOK_URL"), "Slack incoming webhook URL to post a digest of the scheduled export to (default: $SLACK_WEBHOOK_URL)")
+	lockDSNFlag := flag.String("lock-dsn", "", "PostgreSQL connection string of the database in which replicas claim scheduled runs with an advisory lock")
+	lockDirFlag := flag.String("lock-dir", "", "directory on storage shared by the replicas in which they claim scheduled runs with marker files")
+	progressFlag := flag.Bool("progress", isTerminal(os.Stderr), "draw a progress bar of the backfill on stderr (default: when stderr is a terminal)")
+	flag.Parse()
+
+	var backfillFrom, backfillTo time.Time
+	if *backfillFromFlag != "" {
+		var err error
+		if backfillFrom, err = time.Parse("2006-01-02", *backfillFromFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "parsing -backfill-from: %v\n", err)
+			os.Exit(2)
+		}
+		backfillTo = time.Now().Truncate(24 * time.Hour)
+		if *backfillToFlag != "" {
+			if backfillTo, err = time.Parse("2006-01-02", *backfillToFlag); err != nil {
+				fmt.Fprintf(os.Stderr, "parsing -backfill-to: %v\n", err)
+				os.Exit(2)
+			}
+		}
+	}
+
+	endpointTimeouts, err := parseEndpointTimeouts(*endpointTimeoutsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "parsing -endpoint-timeouts: %v\n", err)
+		os.Exit(2)
+	}
+
+	sources, err := statistics.ParseSources(strings.Split(*sourcesFlag, ","))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "parsing -sources: %v\n", err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := run(ctx, &config{
+		botID:            *botIDFlag,
+		apiKey:           *apiKeyFlag,
+		credentials:      *credentialsFlag,
+		caBundle:         *caBundleFlag,
+		baseURLs:         splitList(*baseURLsFlag),
+		interval:         *intervalFlag,
+		sources:          sources,
+		datadogAPIKey:    *datadogAPIKeyFlag,
+		datadogURL:       *datadogURLFlag,
+		remoteWrite:      *remoteWriteFlag,
+		influxURL:        *influxURLFlag,
+		influxOrg:        *influxOrgFlag,
+		influxBucket:     *influxBucketFlag,
+		influxToken:      *influxTokenFlag,
+		csvDir:           *csvDirFlag,
+		backfillFrom:     backfillFrom,
+		backfillTo:       backfillTo,
+		backfillChunk:    *backfillChunkFlag,
+		progress:         *progressFlag,
+		networkRetries:   *networkRetriesFlag,
+		maxResponseSize:  *maxResponseSizeFlag,
+		timeout:          *timeoutFlag,
+		endpointTimeouts: endpointTimeouts,
+		strictDecoding:   *strictDecodingFlag,
+		schemaCheck:      *schemaCheckFlag,
+		staleAfter:       *staleAfterFlag,
+		schedule:         *scheduleFlag,
+		bigqueryTable:    *bigqueryTableFlag,
+		sheetsID:         *sheetsIDFlag,
+		sheetsRange:      *sheetsRangeFlag,
+		slackWebhook:     *slackWebhookFlag,
+		lockDSN:          *lockDSNFlag,
+		lockDir:          *lockDirFlag,
+	}); err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, config *config) error {
+	sinks := export.MultiSink{}
+	if config.datadogAPIKey != "" {
+		sink := datadog.NewSink(config.datadogAPIKey)
+		sink.BaseURL = config.datadogURL
+		sinks = append(sinks, sink)
+	}
+	if config.remoteWrite != "" {
+		sinks = append(sinks, remotewrite.NewSink(config.remoteWrite))
+	}
+	if config.influxURL != "" {
+		if config.influxBucket == "" {
+			return fmt.Errorf("-influx-url requires -influx-bucket")
+		}
+		sinks = append(sinks, influx.NewSink(config.influxURL, config.influxOrg, config.influxBucket, config.influxToken))
+	}
+	if config.csvDir != "" {
+		sinks = append(sinks, csvstore.NewSink(config.csvDir))
+	}
+	logger := log.NewLogfmtLogger(os.Stdout)
+	var creds auth.Credentials
+	if config.credentials != "" {
+		c, err := auth.ParseCredentials(ctx, config.credentials)
+		if err != nil {
+			return err
+		}
+		creds = c
+	}
+
+	var caFiles []string
+	if config.caBundle != "" {
+		caFiles = append(caFiles, config.caBundle)
+	}
+	transport, err := statistics.NewTransport(caFiles...)
+	if err != nil {
+		return err
+	}
+
+	opts := []statistics.ClientOption{
+		statistics.WithDoer(oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, &auth.TokenSource{
+			APIKey:      config.apiKey,
+			Credentials: creds,
+			BotID:       config.botID,
+			Client:      &http.Client{Transport: transport},
+		}))),
+		statistics.WithTransport(transport),
+		statistics.WithLogger(logger),
+		statistics.WithNetworkRetries(config.networkRetries),
+		statistics.WithMaxResponseSize(config.maxResponseSize),
+		statistics.WithTimeout(config.timeout),
+	}
+	for endpoint, d := range config.endpointTimeouts {
+		opts = append(opts, statistics.WithEndpointTimeout(endpoint, d))
+	}
+	if config.strictDecoding {
+		opts = append(opts, statistics.WithStrictDecoding())
+	}
+	if len(config.baseURLs) > 0 {
+		opts = append(opts, statistics.WithFailover(config.baseURLs, 3, time.Minute))
+	}
+	client := statistics.NewClient(opts...)
+	client.BotID = config.botID
+
+	runner, err := newRunner(ctx, config, client, logger)
+	if err != nil {
+		return err
+	}
+	if len(sinks) == 0 {
+		if runner == nil || !config.backfillFrom.IsZero() {
+			return fmt.Errorf("no sink configured: set -datadog-apikey, -remote-write-url, -influx-url or -csv-dir, or -schedule with -bigquery-table, -sheets-id or -slack-webhook")
+		}
+		return runner.Run(ctx)
+	}
+
+	if !config.backfillFrom.IsZero() {
+		if config.datadogAPIKey != "" && config.backfillFrom.Before(time.Now().Add(-datadog.MaxAge)) {
+			return fmt.Errorf("-backfill-from cannot be used with -datadog-apikey: Datadog drops points older than %s", datadog.MaxAge)
+		}
+		chunks := backfillChunks(&statistics.Filter{
+			From:        config.backfillFrom,
+			To:          config.backfillTo,
+			Granularity: statistics.Day,
+			Sources:     config.sources,
+		}, config.backfillChunk)
+
+		// Sessions and messages take a call per source, the fallback rate
+		// and handovers one each, for every chunk.
+		calls := len(chunks) * (2*len(config.sources) + 2)
+		if q, err := client.QuotaStatus(ctx); err == nil && !q.Covers(calls) {
+			logger.Log("msg", "backfill may exhaust the API quota", "calls", calls, "remaining", q.Remaining, "reset", q.Reset)
+		}
+
+		collectors := make([]export.Collector, 0, 4*len(chunks))
+		for _, f := range chunks {
+			collectors = append(collectors,
+				export.SessionsHistory(client, f),
+				export.MessagesHistory(client, f),
+				export.FallbackRateHistory(client, f),
+				export.HandoversHistory(client, f),
+			)
+		}
+		pollerOpts := []export.PollerOption{export.WithLogger(logger)}
+		if config.progress {
+			pollerOpts = append(pollerOpts, export.WithProgress(&progressBar{w: os.Stderr, width: 30}))
+		}
+		return export.NewPoller(config.interval, sinks, collectors, pollerOpts...).Poll(ctx)
+	}
+
+	if config.schemaCheck > 0 {
+		go checkSchema(ctx, client, config.schemaCheck, logger)
+	}
+
+	poller := export.NewPoller(config.interval, sinks, []export.Collector{
+		export.Sessions(client, config.sources...),
+		export.Messages(client, config.sources...),
+		export.FallbackRate(client),
+		export.Handovers(client),
+	}, export.WithLogger(logger), export.WithFreshness(config.staleAfter))
+	if runner != nil {
+		done := make(chan struct{})
+		go func() {
+			runner.Run(ctx)
+			close(done)
+		}()
+		// Let running jobs see the cancellation and return before exiting.
+		defer func() { <-done }()
+	}
+
+	return poller.Run(ctx)
+}
+
+// checkSchema logs how the responses of every endpoint for the previous day
+// differ from the fields the client decodes, right away and then every
+// interval until ctx is done.
+func checkSchema(ctx context.Context, client *statistics.Client, interval time.Duration, logger log.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		to := time.Now().Truncate(24 * time.Hour)
+		drift, err := statistics.CheckSchema(ctx, client, &statistics.Filter{From: to.Add(-24 * time.Hour), To: to, Granularity: statistics.Day, Limit: 10})
+		if err != nil && ctx.Err() == nil {
+			logger.Log("msg", "schema check failed", "err", err)
+		}
+		for _, d := range drift {
+			logger.Log("msg", "schema drift", "endpoint", d.Endpoint, "unknown", strings.Join(d.Unknown, ","), "missing", strings.Join(d.Missing, ","))
+		}
+		if err == nil && len(drift) == 0 {
+			logger.Log("msg", "schema check passed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// backfillChunks splits the range of f into consecutive filters of at most
+// size, or returns f if size is not positive.
+func backfillChunks(f *statistics.Filter, size time.Duration) []*statistics.Filter {
+	if size <= 0 {
+		return []*statistics.Filter{f}
+	}
+
+	var chunks []*statistics.Filter
+	for t := f.From; t.Before(f.To); t = t.Add(size) {
+		chunk := *f
+		chunk.From = t
+		chunk.To = t.Add(size)
+		if chunk.To.After(f.To) {
+			chunk.To = f.To
+		}
+		chunks = append(chunks, &chunk)
+	}
+
+	return chunks
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	ret := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			ret = append(ret, item)
+		}
+	}
+
+	return ret
+}
+
+// parseEndpointTimeouts parses a comma-separated list of endpoint=duration.
+func parseEndpointTimeouts(s string) (map[string]time.Duration, error) {
+	ret := make(map[string]time.Duration)
+	for _, item := range splitList(s) {
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%q is not endpoint=duration", item)
+		}
+		d, err := time.ParseDuration(item[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", item, err)
+		}
+		ret[strings.Trim(item[:i], "/")] = d
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// progressBar draws the progress of a backfill on a single terminal line.
+type progressBar struct {
+	w     io.Writer
+	width int
+}
+
+// Progress implements statistics.Progress.
+func (b *progressBar) Progress(s statistics.ProgressStatus) {
+	filled := 0
+	if s.Total > 0 {
+		filled = b.width * s.Completed / s.Total
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", b.width-filled)
+
+	line := fmt.Sprintf("\r[%s] %d/%d chunks, %d points, %d retries", bar, s.Completed, s.Total, s.Rows, s.Retries)
+	if eta := s.ETA(time.Now()); eta > 0 {
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	// Pad to overwrite a longer previous line.
+	fmt.Fprintf(b.w, "%-100s", line)
+	if s.Total > 0 && s.Completed >= s.Total {
+		fmt.Fprintln(b.w)
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/export/bigquery"
+	"github.com/atb-as/kindly/export/sheets"
+	"github.com/atb-as/kindly/export/slack"
+	"github.com/atb-as/kindly/schedule"
+	"github.com/atb-as/kindly/statistics"
+	"github.com/go-kit/kit/log"
+	_ "github.com/lib/pq"
+)
+
+// newRunner returns a runner exporting the previous day to every scheduled
+// sink of config, or nil if none is configured.
+func newRunner(ctx context.Context, config *config, client *statistics.Client, logger log.Logger) (*schedule.Runner, error) {
+	sinks := make(map[string]export.Sink)
+	if config.bigqueryTable != "" {
+		sink, err := bigquery.NewDefaultSink(ctx, config.bigqueryTable)
+		if err != nil {
+			return nil, err
+		}
+		sinks["bigquery"] = sink
+	}
+	if config.sheetsID != "" {
+		sink, err := sheets.NewDefaultSink(ctx, config.sheetsID, config.sheetsRange)
+		if err != nil {
+			return nil, err
+		}
+		sinks["sheets"] = sink
+	}
+	if config.slackWebhook != "" {
+		sinks["slack"] = slack.NewSink(config.slackWebhook)
+	}
+	if len(sinks) == 0 {
+		if config.schedule != "" {
+			return nil, fmt.Errorf("-schedule requires -bigquery-table, -sheets-id or -slack-webhook")
+		}
+		return nil, nil
+	}
+	if config.schedule == "" {
+		return nil, fmt.Errorf("-bigquery-table, -sheets-id and -slack-webhook require -schedule")
+	}
+
+	loc, err := time.LoadLocation(statistics.DefaultTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	cron, err := schedule.Parse(config.schedule, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var locker schedule.Locker = &schedule.MemoryLocker{}
+	switch {
+	case config.lockDSN != "":
+		db, err := sql.Open("postgres", config.lockDSN)
+		if err != nil {
+			return nil, err
+		}
+		if locker, err = schedule.NewAdvisoryLocker(ctx, db); err != nil {
+			return nil, err
+		}
+	case config.lockDir != "":
+		locker = &schedule.FileLocker{Dir: config.lockDir}
+	}
+
+	runner := schedule.NewRunner(schedule.WithLocker(locker), schedule.WithLogger(logger))
+	for name, sink := range sinks {
+		runner.Register("export-"+name, cron, previousDay(client, config.sources, loc, sink))
+	}
+
+	return runner, nil
+}
+
+// previousDay returns a job writing the sessions and messages per source,
+// fallback rate and handovers of the day before its scheduled time in loc to
+// sink. Nothing is written if any of them fails, so a day is either exported
+// in full or not at all.
+func previousDay(client *statistics.Client, sources []statistics.Source, loc *time.Location, sink export.Sink) schedule.Job {
+	return func(ctx context.Context, at time.Time) error {
+		at = at.In(loc)
+		to := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, loc)
+		f := &statistics.Filter{
+			From:        to.AddDate(0, 0, -1),
+			To:          to,
+			Granularity: statistics.Day,
+			Sources:     sources,
+			Timezone:    loc.String(),
+		}
+
+		points := make([]*export.Point, 0)
+		for _, collect := range []export.Collector{
+			export.SessionsHistory(client, f),
+			export.MessagesHistory(client, f),
+			export.FallbackRateHistory(client, f),
+			export.HandoversHistory(client, f),
+		} {
+			collected, err := collect(ctx)
+			if err != nil {
+				return err
+			}
+			points = append(points, collected...)
+		}
+
+		return sink.Write(ctx, points)
+	}
+}
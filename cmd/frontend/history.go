@@ -0,0 +1,187 @@
+package htmlstats
+
+import (
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	historyCookie   = "htmlstats_history"
+	favoritesCookie = "htmlstats_favorites"
+	// maxHistory and maxFavorites keep the cookies well below the 4 KB
+	// browsers store per cookie.
+	maxHistory   = 10
+	maxFavorites = 10
+	cookieMaxAge = 365 * 24 * time.Hour
+)
+
+// savedQuery is a query of the form, kept as given, so queries by preset
+// keep their relative period.
+type savedQuery struct {
+	Preset string
+	Metric string
+	From   string
+	To     string
+}
+
+// Query returns the query string of q.
+func (q savedQuery) Query() string {
+	v := url.Values{}
+	for k, s := range map[string]string{"preset": q.Preset, "metric": q.Metric, "from": q.From, "to": q.To} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+
+	return v.Encode()
+}
+
+// Label describes q for its quick link.
+func (q savedQuery) Label() string {
+	var parts []string
+	if q.Preset != "" {
+		parts = append(parts, q.Preset)
+	}
+	if q.Metric != "" {
+		parts = append(parts, q.Metric)
+	}
+	if q.From != "" || q.To != "" {
+		parts = append(parts, q.From+" – "+q.To)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func parseSavedQuery(s string) (savedQuery, bool) {
+	v, err := url.ParseQuery(s)
+	if err != nil {
+		return savedQuery{}, false
+	}
+	q := savedQuery{Preset: v.Get("preset"), Metric: v.Get("metric"), From: v.Get("from"), To: v.Get("to")}
+
+	return q, q != savedQuery{}
+}
+
+// readQueries returns the queries stored in the named cookie. Malformed
+// cookies are ignored.
+func readQueries(r *http.Request, name string) []savedQuery {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return nil
+	}
+
+	var ret []savedQuery
+	for _, s := range strings.Split(string(b), "\n") {
+		if q, ok := parseSavedQuery(s); ok {
+			ret = append(ret, q)
+		}
+	}
+
+	return ret
+}
+
+// writeQueries stores qs in the named cookie for a year.
+func writeQueries(w http.ResponseWriter, r *http.Request, name string, qs []savedQuery) {
+	encoded := make([]string, 0, len(qs))
+	for _, q := range qs {
+		encoded = append(encoded, q.Query())
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    base64.RawURLEncoding.EncodeToString([]byte(strings.Join(encoded, "\n"))),
+		Path:     "/",
+		MaxAge:   int(cookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// prepend returns qs with q first, without other copies of q, and at most
+// max queries.
+func prepend(qs []savedQuery, q savedQuery, max int) []savedQuery {
+	ret := []savedQuery{q}
+	for _, other := range qs {
+		if other != q && len(ret) < max {
+			ret = append(ret, other)
+		}
+	}
+
+	return ret
+}
+
+func remove(qs []savedQuery, q savedQuery) []savedQuery {
+	ret := make([]savedQuery, 0, len(qs))
+	for _, other := range qs {
+		if other != q {
+			ret = append(ret, other)
+		}
+	}
+
+	return ret
+}
+
+// handleFavorite pins the query in the pin parameter, or unpins the query in
+// the unpin parameter, and redirects to the page without it. It reports
+// whether the request was handled.
+func handleFavorite(w http.ResponseWriter, r *http.Request) bool {
+	pin, unpin := r.Form.Get("pin"), r.Form.Get("unpin")
+	if pin == "" && unpin == "" {
+		return false
+	}
+
+	favorites := readQueries(r, favoritesCookie)
+	if q, ok := parseSavedQuery(pin); ok {
+		favorites = prepend(favorites, q, maxFavorites)
+	}
+	if q, ok := parseSavedQuery(unpin); ok {
+		favorites = remove(favorites, q)
+	}
+	writeQueries(w, r, favoritesCookie, favorites)
+
+	// Return to the query that was shown, if any.
+	target := r.URL.Path
+	if back := r.Form.Get("back"); back != "" {
+		if q, ok := parseSavedQuery(back); ok {
+			target += "?" + q.Query()
+		}
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+
+	return true
+}
+
+// quickLink is a link to a saved query, with a link to pin or unpin it.
+type quickLink struct {
+	Label  string
+	Href   template.URL
+	Toggle template.URL
+}
+
+// quickLinks returns links to qs; toggle is "pin" or "unpin". Toggling
+// returns to the current query.
+func quickLinks(qs []savedQuery, toggle string, current savedQuery) []quickLink {
+	ret := make([]quickLink, 0, len(qs))
+	for _, q := range qs {
+		v := url.Values{toggle: {q.Query()}}
+		if current != (savedQuery{}) {
+			v.Set("back", current.Query())
+		}
+		ret = append(ret, quickLink{
+			Label:  q.Label(),
+			Href:   template.URL("?" + q.Query()),
+			Toggle: template.URL("?" + v.Encode()),
+		})
+	}
+
+	return ret
+}
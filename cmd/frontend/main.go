@@ -2,21 +2,120 @@ package htmlstats
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
+	"strings"
 
 	"golang.org/x/oauth2"
 
+	"github.com/atb-as/kindly/cache"
 	"github.com/atb-as/kindly/statistics"
 	"github.com/atb-as/kindly/statistics/auth"
+	"github.com/atb-as/kindly/webauth"
+	"github.com/atb-as/kindly/workspace"
 )
 
 func init() {
-	apiKey := os.Getenv("KINDLY_API_KEY")
-	botID := os.Getenv("BOT_ID")
-
-	statsClient = statistics.NewClient(statistics.WithDoer(oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, &auth.TokenSource{
-		APIKey: apiKey,
-		BotID:  botID,
-	}))))
-	statsClient.BotID = botID
+	// BOT_ID is the former name of KINDLY_BOT_ID.
+	if os.Getenv("KINDLY_BOT_ID") == "" {
+		os.Setenv("KINDLY_BOT_ID", os.Getenv("BOT_ID"))
+	}
+	creds, err := auth.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if statsClient, err = statistics.NewClientFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+
+	workspaceClient = workspace.NewClient(workspace.WithDoer(oauth2.NewClient(context.Background(), &auth.KeySource{
+		Credentials: creds,
+	})))
+
+	if err := configureAuth(); err != nil {
+		log.Fatal(err)
+	}
+	if err := configurePresets(); err != nil {
+		log.Fatal(err)
+	}
+	if err := configureQueries(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// configureQueries enables background queries, kept in the Redis server at
+// HTMLSTATS_QUERY_STORE, if set. Without it, queries are answered before the
+// page is served.
+func configureQueries() error {
+	uri := os.Getenv("HTMLSTATS_QUERY_STORE")
+	if uri == "" {
+		return nil
+	}
+
+	store, err := cache.NewRedis(uri)
+	if err != nil {
+		return fmt.Errorf("HTMLSTATS_QUERY_STORE: %w", err)
+	}
+	queryStore = store
+
+	return nil
+}
+
+// configurePresets adds the presets in the JSON file HTMLSTATS_PRESETS, if
+// set, to the default presets.
+func configurePresets() error {
+	name := os.Getenv("HTMLSTATS_PRESETS")
+	if name == "" {
+		ps, err := statistics.NewPresets()
+		presets = ps
+		return err
+	}
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("HTMLSTATS_PRESETS: %w", err)
+	}
+	ps, err := statistics.ParsePresets(b)
+	if err != nil {
+		return fmt.Errorf("HTMLSTATS_PRESETS: %w", err)
+	}
+	presets = ps
+
+	return nil
+}
+
+// configureAuth protects the handler with OIDC if OIDC_ISSUER is set, or with
+// basic authentication if HTMLSTATS_BASIC_AUTH is set to user:password pairs.
+func configureAuth() error {
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		var domains []string
+		if d := os.Getenv("OIDC_ALLOWED_DOMAINS"); d != "" {
+			domains = strings.Split(d, ",")
+		}
+		o, err := webauth.NewOIDC(context.Background(), webauth.OIDCConfig{
+			Issuer:         issuer,
+			ClientID:       os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret:   os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:    os.Getenv("OIDC_REDIRECT_URL"),
+			AllowedDomains: domains,
+			SessionKey:     []byte(os.Getenv("SESSION_KEY")),
+		})
+		if err != nil {
+			return err
+		}
+		handler = o.Middleware(handler)
+		return nil
+	}
+
+	if basic := os.Getenv("HTMLSTATS_BASIC_AUTH"); basic != "" {
+		creds, err := webauth.ParseCredentials(basic)
+		if err != nil {
+			return err
+		}
+		handler = webauth.Basic("kindly.ai Statistics", creds)(handler)
+	}
+
+	return nil
 }
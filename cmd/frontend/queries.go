@@ -0,0 +1,359 @@
+package htmlstats
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/atb-as/kindly/cache"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+const (
+	// queryTimeout limits the time a query runs in the background.
+	queryTimeout = 5 * time.Minute
+	// queryTTL is how long the result of a finished query is kept for the
+	// page that started it to fetch.
+	queryTTL = 10 * time.Minute
+	// progressInterval is how often the progress of a query is sent.
+	progressInterval = 500 * time.Millisecond
+)
+
+// Query states.
+const (
+	queryRunning   = "running"
+	queryDone      = "done"
+	queryFailed    = "failed"
+	queryCancelled = "cancelled"
+)
+
+// queryStore keeps the state and results of background queries, shared by
+// all instances, so any instance can report the progress of a query and
+// serve its result. Background queries are disabled if it is nil, see
+// configureQueries.
+var queryStore cache.Cache
+
+// queryState is the state of a background query as kept in queryStore.
+type queryState struct {
+	Saved    savedQuery `json:"saved"`
+	State    string     `json:"state"`
+	Started  time.Time  `json:"started"`
+	Finished time.Time  `json:"finished"`
+	Retries  int        `json:"retries"`
+	Rows     int        `json:"rows"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// queryStatus is the progress of a query as sent to the page.
+type queryStatus struct {
+	State   string  `json:"state"`
+	Elapsed float64 `json:"elapsed"`
+	Retries int     `json:"retries"`
+	Rows    int     `json:"rows"`
+	Error   string  `json:"error,omitempty"`
+}
+
+func (q *queryState) status(now time.Time) queryStatus {
+	end := now
+	if q.State != queryRunning {
+		end = q.Finished
+	}
+
+	return queryStatus{
+		State:   q.State,
+		Elapsed: end.Sub(q.Started).Round(100 * time.Millisecond).Seconds(),
+		Retries: q.Retries,
+		Rows:    q.Rows,
+		Error:   q.Error,
+	}
+}
+
+func queryKey(id string) string {
+	return "htmlstats|queries|" + id
+}
+
+func resultKey(id string) string {
+	return queryKey(id) + "|result"
+}
+
+// cancelKey is set to ask the instance running a query to cancel it.
+func cancelKey(id string) string {
+	return queryKey(id) + "|cancel"
+}
+
+func saveQuery(ctx context.Context, id string, q *queryState) error {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+
+	return queryStore.Set(ctx, queryKey(id), b, queryTimeout+queryTTL)
+}
+
+// loadQuery returns the query with id, or nil if it does not exist or has
+// expired.
+func loadQuery(ctx context.Context, id string) (*queryState, error) {
+	b, ok, err := queryStore.Get(ctx, queryKey(id))
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var q queryState
+	if err := json.Unmarshal(b, &q); err != nil {
+		return nil, err
+	}
+
+	return &q, nil
+}
+
+// startQuery saves the query of the form as running, runs it in the
+// background and returns its ID.
+func startQuery(ctx context.Context, fq *formQuery, locale derive.Locale) (string, error) {
+	id := newQueryID()
+	q := &queryState{Saved: fq.saved, State: queryRunning, Started: time.Now()}
+	if err := saveQuery(ctx, id, q); err != nil {
+		return "", err
+	}
+
+	go runBackground(id, q, fq, locale)
+
+	return id, nil
+}
+
+// runBackground runs the query with id, saving its progress every
+// progressInterval and cancelling it once cancelKey is set, and saves its
+// result and final state.
+func runBackground(id string, q *queryState, fq *formQuery, locale derive.Locale) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	tracker := statistics.NewProgressTracker(1, nil)
+	var buf bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- runQuery(statistics.WithProgress(ctx, tracker), fq.metric, fq.f, locale, &buf)
+	}()
+
+	// The state is saved from this goroutine only, so progress never
+	// overwrites the final state.
+	t := time.NewTicker(progressInterval)
+	defer t.Stop()
+	cancelled := false
+	var err error
+	for running := true; running; {
+		select {
+		case err = <-done:
+			running = false
+		case <-t.C:
+			// The store is not called with ctx, which is cancelled with
+			// the query.
+			if _, ok, _ := queryStore.Get(context.Background(), cancelKey(id)); ok {
+				cancelled = true
+				cancel()
+			}
+			s := tracker.Status()
+			q.Retries, q.Rows = s.Retries, s.Rows
+			if err := saveQuery(context.Background(), id, q); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	// The final state is saved even if the query timed out.
+	saveCtx, stop := context.WithTimeout(context.Background(), 10*time.Second)
+	defer stop()
+	s := tracker.Status()
+	q.Retries, q.Rows, q.Finished = s.Retries, s.Rows, time.Now()
+	switch {
+	case cancelled:
+		q.State = queryCancelled
+	case err != nil:
+		q.State, q.Error = queryFailed, err.Error()
+		log.Println(err)
+	default:
+		q.Rows = bytes.Count(buf.Bytes(), []byte("\n")) - 1
+		if err := queryStore.Set(saveCtx, resultKey(id), buf.Bytes(), queryTTL); err != nil {
+			q.State, q.Error = queryFailed, "storing the result failed"
+			log.Println(err)
+			break
+		}
+		q.State = queryDone
+	}
+	if err := saveQuery(saveCtx, id, q); err != nil {
+		log.Println(err)
+	}
+}
+
+func newQueryID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// handleQuery serves the background queries for the action parameter:
+// "start" starts the query of the form, "status" streams the progress of the
+// query with the id parameter as server-sent events, "result" returns its
+// CSV and "cancel" cancels it. It reports whether the request was handled.
+func handleQuery(w http.ResponseWriter, r *http.Request) bool {
+	action := r.Form.Get("action")
+	if action == "" {
+		return false
+	}
+	if queryStore == nil {
+		http.Error(w, "background queries are not enabled, use sync=1", http.StatusNotFound)
+		return true
+	}
+
+	if action == "start" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "starting a query requires POST", http.StatusMethodNotAllowed)
+			return true
+		}
+		fq, err := parseQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return true
+		}
+		if fq.f == nil {
+			http.Error(w, "missing metric or period", http.StatusBadRequest)
+			return true
+		}
+		id, err := startQuery(r.Context(), fq, derive.MatchLocale(r.Header.Get("Accept-Language")))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "starting the query failed", http.StatusInternalServerError)
+			return true
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+		return true
+	}
+
+	id := r.Form.Get("id")
+	q, err := loadQuery(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "loading the query failed", http.StatusInternalServerError)
+		return true
+	}
+	if q == nil {
+		http.Error(w, "unknown or expired query", http.StatusNotFound)
+		return true
+	}
+	switch action {
+	case "status":
+		streamStatus(w, r, id, q)
+	case "result":
+		writeResult(w, r, id, q)
+	case "cancel":
+		if r.Method != http.MethodPost {
+			http.Error(w, "cancelling a query requires POST", http.StatusMethodNotAllowed)
+			return true
+		}
+		if q.State == queryRunning {
+			if err := queryStore.Set(r.Context(), cancelKey(id), []byte("1"), queryTimeout); err != nil {
+				log.Println(err)
+				http.Error(w, "cancelling the query failed", http.StatusInternalServerError)
+				return true
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, fmt.Sprintf("unknown action %q, want start, status, result or cancel", action), http.StatusBadRequest)
+	}
+
+	return true
+}
+
+// streamStatus sends a progress event with the status of the query with id
+// every progressInterval, and a done, failed or cancelled event when it
+// finishes. q is its state when the request was received.
+func streamStatus(w http.ResponseWriter, r *http.Request, id string, q *queryState) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Buffering proxies such as nginx would hold the events back.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	t := time.NewTicker(progressInterval)
+	defer t.Stop()
+	for {
+		s := q.status(time.Now())
+		event := "progress"
+		if s.State != queryRunning {
+			event = s.State
+		}
+		b, _ := json.Marshal(s)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+		flusher.Flush()
+		if s.State != queryRunning {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-t.C:
+		}
+		latest, err := loadQuery(r.Context(), id)
+		if err != nil || latest == nil {
+			// The page retries the stream, or reports the query as
+			// expired once it is gone.
+			return
+		}
+		q = latest
+	}
+}
+
+// writeResult writes the CSV of the query with id, and remembers the query
+// in the history if it succeeded. A query still running is answered with
+// 202 Accepted and its status.
+func writeResult(w http.ResponseWriter, r *http.Request, id string, q *queryState) {
+	s := q.status(time.Now())
+	switch s.State {
+	case queryRunning:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(s)
+		return
+	case queryCancelled:
+		http.Error(w, "the query was cancelled", http.StatusConflict)
+		return
+	case queryFailed:
+		http.Error(w, s.Error, http.StatusInternalServerError)
+		return
+	}
+
+	csv, ok, err := queryStore.Get(r.Context(), resultKey(id))
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "loading the result failed", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "the result has expired", http.StatusNotFound)
+		return
+	}
+
+	// Only queries that could be answered are remembered.
+	writeQueries(w, r, historyCookie, prepend(readQueries(r, historyCookie), q.Saved, maxHistory))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("X-Render-Time", time.Duration(s.Elapsed*float64(time.Second)).String())
+	w.Write(csv)
+}
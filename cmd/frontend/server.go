@@ -10,18 +10,27 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/metrics"
 	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/workspace"
 )
 
 var (
-	statsClient *statistics.Client
-	tmpl        = template.Must(template.New("stats").Parse(`
+	statsClient     *statistics.Client
+	workspaceClient *workspace.Client
+	botNames        sync.Map
+	presets         *statistics.Presets
+	handler         http.Handler = http.HandlerFunc(handle)
+	tmpl                         = template.Must(template.New("stats").Parse(`
 <!DOCTYPE html>
 <html>
 <head>
-    <title>kindly.ai Statistics</title>
+    <title>kindly.ai Statistics{{with .BotName}} - {{.}}{{end}}</title>
     <meta charset="utf-8">
     <meta name="viewport" content="width=device-width,minimum-scale=1">
     <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.0.0-beta2/dist/css/bootstrap.min.css"
@@ -31,35 +40,50 @@ var (
 </head>
 <body>
 <div class="container">
-    <h2>kindly.ai Statistics</h2>
+    <h2>kindly.ai Statistics{{with .BotName}} <small class="text-muted">{{.}}</small>{{end}}</h2>
+    {{with .Favorites}}
+    <div class="mb-2">
+        <span class="text-muted">Favorites:</span>
+        {{range .}}
+        <span class="text-nowrap">
+            <a class="btn btn-sm btn-outline-primary" href="{{.Href}}">{{.Label}}</a><a
+                class="btn btn-sm btn-link" href="{{.Toggle}}" title="Unpin">&times;</a>
+        </span>
+        {{end}}
+    </div>
+    {{end}}
+    {{with .History}}
+    <div class="mb-3">
+        <span class="text-muted">Recent:</span>
+        {{range .}}
+        <span class="text-nowrap">
+            <a class="btn btn-sm btn-outline-secondary" href="{{.Href}}">{{.Label}}</a><a
+                class="btn btn-sm btn-link" href="{{.Toggle}}" title="Pin">&#9734;</a>
+        </span>
+        {{end}}
+    </div>
+    {{end}}
     <form method="get">
         <div class="row">
+            <div class="col-auto mb-3">
+                <label class="form-label" for="preset">Preset:</label>
+                <select class="form-select" id="preset" name="preset">
+                    <option value="">None</option>
+                    {{range .Presets}}
+                    <option value="{{.Name}}" title="{{.Description}}"
+                            {{if eq $.Filter.Preset .Name}}selected{{end}}>{{.Name}}
+                    </option>
+                    {{end}}
+                </select>
+            </div>
             <div class="col-auto mb-3">
                 <label class="form-label" for="statistic">Metric:</label>
                 <select class="form-select" id="statistic" name="metric">
-                    <option value="chats"
-                            {{if eq .Filter.Metric "chats"}}selected{{end}}>Chat
-                        sessions
-                    </option>
-                    <option value="messages"
-                            {{if eq .Filter.Metric "messages"}}selected{{end}}>
-                        User
-                        messages
-                    </option>
-                    <option value="pages"
-                            {{if eq .Filter.Metric "pages"}}selected{{end}}>Web
-                        pages
-                        (aggregated)
+                    {{range .Metrics}}
+                    <option value="{{.Name}}" title="{{.Description}}"
+                            {{if eq $.Filter.Metric .Name}}selected{{end}}>{{.Title}}
                     </option>
-                    <option value="feedback"
-                            {{if eq .Filter.Metric "feedback"}}selected{{end}}>
-                        Feedback
-                        (aggregated)
-                    </option>
-					<option value="labels"
-							{{if eq .Filter.Metric "labels"}}selected{{end}}>
-						Labels
-					</option>
+                    {{end}}
                 </select>
             </div>
             <div class="col-auto mb-3">
@@ -81,200 +105,292 @@ var (
         </div>
 
     </form>
-    <textarea class="form-control" readonly rows="20">{{.CSV}}</textarea>
-    <code>Served in {{.RenderTime}}</code>
+    {{if .Start}}
+    <div id="progress" class="mb-2">
+        <span class="spinner-border spinner-border-sm" role="status"></span>
+        <span id="status">Starting…</span>
+        <button id="cancel" class="btn btn-sm btn-outline-danger" type="button">Cancel</button>
+        <noscript><a href="{{.SyncHref}}">Load without JavaScript</a></noscript>
+    </div>
+    {{end}}
+    <textarea id="csv" class="form-control" readonly rows="20">{{.CSV}}</textarea>
+    <code id="served">{{if not .Start}}Served in {{.RenderTime}}{{end}}</code>
 </div>
+{{if .Start}}
+<script>
+(function () {
+    const status = document.getElementById("status");
+    const progress = document.getElementById("progress");
+    const fail = (msg) => {
+        progress.querySelector(".spinner-border").remove();
+        document.getElementById("cancel").remove();
+        status.textContent = msg;
+    };
+    const text = (r) => r.text().then((t) => r.ok ? t : Promise.reject(new Error(t)));
+
+    fetch("?action=start&" + {{.Start}}, {method: "POST"})
+        .then(text)
+        .then((t) => {
+            const id = encodeURIComponent(JSON.parse(t).id);
+            const events = new EventSource("?action=status&id=" + id);
+            document.getElementById("cancel").onclick = () => {
+                events.close();
+                fetch("?action=cancel&id=" + id, {method: "POST"});
+                fail("Cancelled.");
+            };
+            events.addEventListener("progress", (e) => {
+                const s = JSON.parse(e.data);
+                status.textContent = "Running for " + s.elapsed.toFixed(1) + " s" + (s.retries ? ", retried " + s.retries + " times" : "") + "…";
+            });
+            events.addEventListener("failed", (e) => {
+                events.close();
+                fail("Failed: " + JSON.parse(e.data).error);
+            });
+            events.addEventListener("cancelled", () => {
+                events.close();
+                fail("Cancelled.");
+            });
+            events.addEventListener("done", () => {
+                events.close();
+                fetch("?action=result&id=" + id).then((r) => {
+                    const took = r.headers.get("X-Render-Time");
+                    return text(r).then((csv) => {
+                        document.getElementById("csv").value = csv;
+                        document.getElementById("served").textContent = "Served in " + took;
+                        progress.remove();
+                    });
+                }).catch((err) => fail("Failed: " + err.message));
+            });
+        })
+        .catch((err) => fail("Failed: " + err.message));
+})();
+</script>
+{{end}}
 </body>
 </html>
 `))
 )
 
 type filterConfig struct {
+	Preset string
 	Metric string
 	From   string
 	To     string
 }
 
 type pageData struct {
+	BotName    string
 	RenderTime time.Duration
 	Filter     filterConfig
+	Presets    []*statistics.Preset
+	Metrics    []*metrics.Metric
+	Favorites  []quickLink
+	History    []quickLink
 	CSV        string
+	// Start is the query string of a query the page starts in the
+	// background, and SyncHref the page answering it before it is served.
+	Start    string
+	SyncHref template.URL
 }
 
-func userMessages(ctx context.Context, c *statistics.Client, f *statistics.Filter, w io.Writer) error {
-	messages, err := c.UserMessages(ctx, f)
-	if err != nil {
-		return err
-	}
+// botNameRetry is how long a failed bot name lookup is cached before it is
+// retried, so an unavailable workspace API is not called on every page view.
+const botNameRetry = time.Minute
 
-	csvWriter := csv.NewWriter(w)
-	csvWriter.Write([]string{"date", "count"})
-	for _, chat := range messages {
-		csvWriter.Write([]string{chat.Date.Format("2006-01-02"), strconv.Itoa(chat.Count)})
-	}
-	csvWriter.Flush()
-
-	return csvWriter.Error()
+// cachedBotName is a bot name lookup in botNames. Failed lookups have an empty
+// name and expire; successful ones are kept for the lifetime of the process.
+type cachedBotName struct {
+	name    string
+	expires time.Time
 }
 
-func chatSessions(ctx context.Context, c *statistics.Client, f *statistics.Filter, w io.Writer) error {
-	chats, err := c.ChatSessions(ctx, f)
-	if err != nil {
-		return err
+// botName returns the display name of the bot with the given ID, or an empty
+// string if it could not be retrieved.
+func botName(ctx context.Context, botID string) string {
+	if v, ok := botNames.Load(botID); ok {
+		cached := v.(cachedBotName)
+		if cached.expires.IsZero() || time.Now().Before(cached.expires) {
+			return cached.name
+		}
 	}
 
-	csvWriter := csv.NewWriter(w)
-	csvWriter.Write([]string{"date", "count"})
-	for _, chat := range chats {
-		csvWriter.Write([]string{chat.Date.Format("2006-01-02"), strconv.Itoa(chat.Count)})
+	bot, err := workspaceClient.Bot(ctx, botID)
+	if err != nil {
+		log.Println(err)
+		botNames.Store(botID, cachedBotName{expires: time.Now().Add(botNameRetry)})
+		return ""
 	}
-	csvWriter.Flush()
+	botNames.Store(botID, cachedBotName{name: bot.Name})
 
-	return csvWriter.Error()
+	return bot.Name
+}
+
+// Handle is the entry point of the function. It serves the statistics page
+// behind the authentication configured at startup, if any.
+func Handle(w http.ResponseWriter, r *http.Request) {
+	handler.ServeHTTP(w, r)
+}
+
+// formQuery is the query of the form, with the metric and period of its
+// preset filled in.
+type formQuery struct {
+	// saved is the query as given.
+	saved  savedQuery
+	filter filterConfig
+	// metric and f are nil unless the query has a metric and period.
+	metric *metrics.Metric
+	f      *statistics.Filter
 }
 
-func pages(ctx context.Context, c *statistics.Client, f *statistics.Filter, w io.Writer) error {
-	pages, err := c.PageStatistics(ctx, f)
+// parseQuery returns the query of the form of r, which must be parsed.
+func parseQuery(r *http.Request) (*formQuery, error) {
+	from := r.Form.Get("from")
+	to := r.Form.Get("to")
+	metric := r.Form.Get("metric")
+
+	// A preset fills in the metric and period not given in the form.
+	preset := r.Form.Get("preset")
+	q := &formQuery{saved: savedQuery{Preset: preset, Metric: metric, From: from, To: to}}
+	if preset != "" {
+		p, err := presets.Get(preset)
+		if err != nil {
+			return nil, err
+		}
+		if metric == "" {
+			metric = p.Metric
+		}
+		if from == "" && to == "" {
+			f := p.Filter(time.Now())
+			from = f.From.Format("2006-01-02")
+			to = f.To.Format("2006-01-02")
+		}
+	}
+
+	q.filter = filterConfig{Preset: preset}
+	if metric == "" || from == "" || to == "" {
+		return q, nil
+	}
+	m, err := metrics.Lookup(metric)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	q.metric = m
+	q.filter = filterConfig{
+		Preset: preset,
+		Metric: m.Name,
+		From:   from,
+		To:     to,
 	}
 
-	csvWriter := csv.NewWriter(w)
-	csvWriter.Write([]string{"host", "path", "sessions", "messages"})
-	for _, page := range pages {
-		csvWriter.Write([]string{page.Host, page.Path, strconv.Itoa(page.Sessions), strconv.Itoa(page.Messages)})
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, fmt.Errorf("parsing from date: %w", err)
+	}
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, fmt.Errorf("parsing to date: %w", err)
+	}
+	q.f = &statistics.Filter{From: fromDate, To: toDate}
+	if err := q.f.Validate(); err != nil {
+		return nil, err
 	}
-	csvWriter.Flush()
 
-	return csvWriter.Error()
+	return q, nil
 }
 
-func feedback(ctx context.Context, c *statistics.Client, f *statistics.Filter, w io.Writer) error {
-	feedback, err := c.AggregatedFeedback(ctx, f)
+// runQuery writes the CSV of m for f to w. Feedback ratings are labelled in
+// locale.
+func runQuery(ctx context.Context, m *metrics.Metric, f *statistics.Filter, locale derive.Locale, w io.Writer) error {
+	rows, err := m.Fetch(ctx, statsClient, f)
 	if err != nil {
 		return err
 	}
 
-	csvWriter := csv.NewWriter(w)
-	csvWriter.Write([]string{"type", "rating", "count", "ratio"})
-	for _, binaryRating := range feedback.Binary {
-		csvWriter.Write([]string{"binary", strconv.Itoa(binaryRating.Rating), strconv.Itoa(binaryRating.Count), fmt.Sprintf("%.2f", binaryRating.Ratio)})
-	}
-	for _, emojiRating := range feedback.Emojis {
-		csvWriter.Write([]string{"emoji", strconv.Itoa(emojiRating.Rating), strconv.Itoa(emojiRating.Count), fmt.Sprintf("%.2f", emojiRating.Ratio)})
+	hdr := m.Header()
+	if m == metrics.Feedback {
+		hdr = append(hdr, "label")
+		for i, row := range rows {
+			rows[i] = append(row, ratingLabel(row[0], row[1], locale))
+		}
 	}
-	csvWriter.Flush()
+
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Write(hdr)
+	csvWriter.WriteAll(rows)
 
 	return csvWriter.Error()
 }
 
-func labels(ctx context.Context, c *statistics.Client, f *statistics.Filter, w io.Writer) error {
-	labels, err := c.ChatLabels(ctx, f)
+// ratingLabel returns the label of the rating of a row of feedback of type
+// typ in locale.
+func ratingLabel(typ, rating string, locale derive.Locale) string {
+	r, err := strconv.Atoi(rating)
 	if err != nil {
-		return err
+		return ""
 	}
-
-	csvWriter := csv.NewWriter(w)
-	csvWriter.Write([]string{"id", "count", "text"})
-	for _, label := range labels {
-		csvWriter.Write([]string{label.ID, strconv.Itoa(label.Count), label.Text})
+	if typ == "binary" {
+		return derive.BinaryLabel(r, locale)
 	}
-	csvWriter.Flush()
 
-	return csvWriter.Error()
+	return strings.TrimSpace(derive.EmojiGlyph(r) + " " + derive.EmojiLabel(r, locale))
 }
 
-func Handle(w http.ResponseWriter, r *http.Request) {
+// handle serves the page. The query of the page is answered before the page
+// is served, unless background queries are enabled: then the page starts it
+// in the background and shows its progress until the result is ready, see
+// handleQuery, and only the sync parameter, as used without JavaScript,
+// answers it first.
+func handle(w http.ResponseWriter, r *http.Request) {
 	begin := time.Now()
 
 	if err := r.ParseForm(); err != nil {
 		log.Println(err)
 	}
-	from := r.Form.Get("from")
-	to := r.Form.Get("to")
-	metric := r.Form.Get("metric")
-
-	if metric == "" || from == "" || to == "" {
-		if err := tmpl.Execute(w, pageData{
-			Filter: filterConfig{},
-			CSV:    "",
-		}); err != nil {
-			log.Println(err)
-		}
+	if handleFavorite(w, r) || handleQuery(w, r) {
 		return
 	}
+	name := botName(r.Context(), statsClient.BotID)
 
-	filter := filterConfig{
-		Metric: metric,
-		From:   from,
-		To:     to,
-	}
-
-	fromDate, err := time.Parse("2006-01-02", from)
+	q, err := parseQuery(r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("parsing from date: %v", err), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	toDate, err := time.Parse("2006-01-02", to)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("parsing to date: %v", err), http.StatusBadRequest)
+	history := readQueries(r, historyCookie)
+	favorites := quickLinks(readQueries(r, favoritesCookie), "unpin", q.saved)
+	data := pageData{
+		BotName:   name,
+		Filter:    q.filter,
+		Presets:   presets.List(),
+		Metrics:   metrics.All(),
+		Favorites: favorites,
+		History:   quickLinks(history, "pin", q.saved),
+	}
+	if q.f != nil && queryStore != nil && r.Form.Get("sync") == "" {
+		data.Start = q.saved.Query()
+		data.SyncHref = template.URL("?" + q.saved.Query() + "&sync=1")
+	}
+	if q.f == nil || data.Start != "" {
+		if err := tmpl.Execute(w, data); err != nil {
+			log.Println(err)
+		}
 		return
 	}
 
 	var csvBuf bytes.Buffer
-	switch metric {
-	case "chats":
-		err := chatSessions(r.Context(), statsClient, &statistics.Filter{
-			From: fromDate,
-			To:   toDate,
-		}, &csvBuf)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	case "messages":
-		err := userMessages(r.Context(), statsClient, &statistics.Filter{
-			From: fromDate,
-			To:   toDate,
-		}, &csvBuf)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	case "pages":
-		err := pages(r.Context(), statsClient, &statistics.Filter{
-			From: fromDate,
-			To:   toDate,
-		}, &csvBuf)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	case "feedback":
-		err := feedback(r.Context(), statsClient, &statistics.Filter{
-			From: fromDate,
-			To:   toDate,
-		}, &csvBuf)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	case "labels":
-		err := labels(r.Context(), statsClient, &statistics.Filter{
-			From: fromDate,
-			To:   toDate,
-		}, &csvBuf)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if err := runQuery(r.Context(), q.metric, q.f, derive.MatchLocale(r.Header.Get("Accept-Language")), &csvBuf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	if err := tmpl.Execute(w, pageData{
-		Filter:     filter,
-		CSV:        csvBuf.String(),
-		RenderTime: time.Since(begin),
-	}); err != nil {
+	// Only queries that could be answered are remembered.
+	history = prepend(history, q.saved, maxHistory)
+	writeQueries(w, r, historyCookie, history)
+
+	data.History = quickLinks(history, "pin", q.saved)
+	data.CSV = csvBuf.String()
+	data.RenderTime = time.Since(begin)
+	if err := tmpl.Execute(w, data); err != nil {
 		log.Println(err)
 	}
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	nethttp "net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/cmd/frontendcsv/http"
+	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/statistics/auth"
+	"github.com/go-kit/kit/log"
+)
+
+// fileConfig is the file format of the -config flag.
+type fileConfig struct {
+	Tenants []struct {
+		Name       string   `json:"name"`
+		Host       string   `json:"host"`
+		PathPrefix string   `json:"path_prefix"`
+		Tokens     []string `json:"tokens"`
+		Bots       []struct {
+			ID          string `json:"id"`
+			APIKey      string `json:"api_key"`
+			Credentials string `json:"credentials"`
+		} `json:"bots"`
+	} `json:"tenants"`
+	Cache struct {
+		TTL duration `json:"ttl"`
+		// URL is the Redis server to keep the cache in; see -cache-url.
+		URL string `json:"url"`
+		// Stale is how long responses are served stale; see -serve-stale.
+		Stale duration `json:"stale"`
+	} `json:"cache"`
+	// Prewarm lists queries rendered into the cache every interval.
+	Prewarm struct {
+		Interval duration `json:"interval"`
+		Queries  []string `json:"queries"`
+	} `json:"prewarm"`
+	// Routes, if set, are the only routes served, grouped by their access
+	// requirements.
+	Routes []struct {
+		Paths  []string `json:"paths"`
+		Public bool     `json:"public"`
+		Tokens []string `json:"tokens"`
+	} `json:"routes"`
+	// Presets are named queries added to statistics.DefaultPresets.
+	Presets []*statistics.Preset `json:"presets"`
+	// Defaults are the default period and limit of routes by path, such as
+	// "/labels".
+	Defaults map[string]http.RouteDefaults `json:"defaults"`
+	// Limits override -request-budget and -max-upstream-calls.
+	Limits struct {
+		RequestBudget    duration `json:"request_budget"`
+		MaxUpstreamCalls int      `json:"max_upstream_calls"`
+	} `json:"limits"`
+}
+
+// duration is a time.Duration given as a string such as "15m".
+type duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+
+	return nil
+}
+
+// loadConfig reads the config file at path.
+func loadConfig(path string) (*fileConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg fileConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(cfg.Prewarm.Queries) > 0 && cfg.Prewarm.Interval <= 0 {
+		return nil, fmt.Errorf("parsing %s: prewarm: interval is required", path)
+	}
+	seen := make(map[string]bool)
+	for i, g := range cfg.Routes {
+		if len(g.Paths) == 0 {
+			return nil, fmt.Errorf("parsing %s: routes[%d]: paths are required", path, i)
+		}
+		if g.Public && len(g.Tokens) > 0 {
+			return nil, fmt.Errorf("parsing %s: routes[%d]: public routes can not have tokens", path, i)
+		}
+		for _, p := range g.Paths {
+			if !strings.HasPrefix(p, "/") {
+				return nil, fmt.Errorf("parsing %s: routes[%d]: path %q must start with /", path, i, p)
+			}
+			if seen[p] {
+				return nil, fmt.Errorf("parsing %s: routes[%d]: path %s is in several groups", path, i, p)
+			}
+			seen[p] = true
+		}
+	}
+	for p, d := range cfg.Defaults {
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("parsing %s: defaults: path %q must start with /", path, p)
+		}
+		if d.Days < 0 {
+			return nil, fmt.Errorf("parsing %s: defaults: %s: days must not be negative", path, p)
+		}
+		if d.Limit < 0 || d.Limit > statistics.MaxLimit {
+			return nil, fmt.Errorf("parsing %s: defaults: %s: limit must be 0 to %d", path, p, statistics.MaxLimit)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// routeGroups returns the route groups of cfg, or nil if every route is
+// enabled.
+func routeGroups(cfg *fileConfig) []http.RouteGroup {
+	if len(cfg.Routes) == 0 {
+		return nil
+	}
+
+	groups := make([]http.RouteGroup, 0, len(cfg.Routes))
+	for _, g := range cfg.Routes {
+		groups = append(groups, http.RouteGroup{Paths: g.Paths, Public: g.Public, Tokens: g.Tokens})
+	}
+
+	return groups
+}
+
+// newTenants creates a client for every bot configured in cfg, logging to
+// logger, with the extra client options.
+func newTenants(ctx context.Context, cfg *fileConfig, transport nethttp.RoundTripper, timeout, labelRefresh time.Duration, logger log.Logger, extra ...statistics.ClientOption) ([]*http.Tenant, error) {
+	tenants := make([]*http.Tenant, 0, len(cfg.Tenants))
+	for _, t := range cfg.Tenants {
+		tenant := &http.Tenant{
+			Name:       t.Name,
+			Host:       t.Host,
+			PathPrefix: t.PathPrefix,
+			Tokens:     t.Tokens,
+			Clients:    make(map[string]statistics.Service, len(t.Bots)),
+		}
+		for _, bot := range t.Bots {
+			if bot.ID == "" {
+				return nil, fmt.Errorf("tenant %q: bot id is required", t.Name)
+			}
+
+			var creds auth.Credentials
+			if bot.Credentials != "" {
+				c, err := auth.ParseCredentials(ctx, bot.Credentials)
+				if err != nil {
+					return nil, fmt.Errorf("tenant %q: bot %s: %w", t.Name, bot.ID, err)
+				}
+				creds = c
+			}
+
+			client := newClient(bot.ID, bot.APIKey, creds, transport, timeout, labelRefresh, log.With(logger, "tenant", t.Name), extra...)
+			tenant.Clients[bot.ID] = client
+		}
+		tenants = append(tenants, tenant)
+	}
+
+	return tenants, nil
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// afterHoursHandler serves handover requests per weekday, split by the
+// opening hours given in the "hours" query parameter.
+type afterHoursHandler struct {
+	client statistics.Service
+	logger Logger
+}
+
+var afterHoursHeader = []string{"weekday", "requests", "after_hours", "share", "while_closed"}
+
+// ServeHTTP implements http.Handler.
+func (h *afterHoursHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := filterFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	spec := r.Form.Get("hours")
+	if spec == "" {
+		respondErr(w, "parsing query: \"hours\" is required, e.g. mon-fri=08:00-16:00", http.StatusBadRequest)
+		return
+	}
+	hours, err := derive.ParseOpeningHours(spec)
+	if err != nil {
+		respondErr(w, fmt.Sprintf("parsing query: \"hours\": %v", err), http.StatusBadRequest)
+		return
+	}
+
+	format, err := encoding.ParseFormat(r.Form.Get("format"))
+	if err != nil {
+		respondErr(w, fmt.Sprintf("parsing query: \"format\": unknown format %q", r.Form.Get("format")), http.StatusBadRequest)
+		return
+	}
+	timeOpts, err := timeOptionsFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := localeFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	numbers, err := numbersFromRequest(r, locale)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	days, err := derive.AfterHours(r.Context(), h.client, f, hours)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		respondUpstreamErr(r.Context(), w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	enc, err := encoding.NewEncoder(w, format)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw, err := timeOpts.writer(numbersWriter(localizeHeader(enc, locale, format), numbers, format), f)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw.Write(afterHoursHeader)
+	for _, d := range days {
+		rw.Write([]string{
+			d.Weekday.String(),
+			strconv.Itoa(d.Requests),
+			strconv.Itoa(d.AfterHours),
+			formatFloat(d.Share),
+			strconv.Itoa(d.WhileClosed),
+		})
+	}
+	if err := enc.Close(); err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+	}
+}
+
+var splitHeader = []string{"date", "in_hours", "after_hours", "after_hours_share", "source"}
+
+// writeSplit writes the hourly series of every source of f per day, or per
+// week, split by the opening hours.
+func writeSplit(ctx context.Context, fetch seriesFunc, f *statistics.Filter, hours derive.OpeningHours, w rowWriter, errs *partialErrors) error {
+	var out [][]string
+	for _, source := range f.Sources {
+		hourly := *f
+		hourly.Sources = []statistics.Source{source}
+		hourly.Granularity = statistics.Hour
+		series, err := fetch(ctx, &hourly)
+		if !errs.record(source.String(), err) {
+			continue
+		}
+
+		for _, s := range derive.SplitByHours(series, f, hours) {
+			out = append(out, []string{
+				formatTime(s.From, f.Granularity),
+				strconv.Itoa(s.InHours),
+				strconv.Itoa(s.AfterHours),
+				formatFloat(s.AfterHoursShare()),
+				source.String(),
+			})
+		}
+	}
+
+	return w.WriteAll(out)
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/atb-as/kindly/cache"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+	"github.com/gorilla/mux"
+)
+
+// archivePath is the route of archived days, such as
+// /archive/sessions/2024/03/01.csv.
+const archivePath = "/archive/{metric}/{yyyy}/{mm}/{dd}.csv"
+
+// archiveTTL is how long archived days are kept: as good as forever, while
+// still within the range of cache expiry times.
+const archiveTTL = 100 * 365 * 24 * time.Hour
+
+// WithArchive serves /archive/{metric}/{yyyy}/{mm}/{dd}.csv, the CSV of a
+// metric for one day, rendered on the first request and kept in store for
+// good, so the same URL always serves the same bytes. Days are archived once
+// they ended at least delay ago, so late upstream data is included.
+func WithArchive(store cache.Cache, delay time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.archive = &archive{store: store, delay: delay}
+	}
+}
+
+// archive keeps the rendered days of the archive routes.
+type archive struct {
+	store cache.Cache
+	delay time.Duration
+	// namespace separates the days of different bots.
+	namespace string
+	// private is set if the days require access tokens, so shared caches
+	// must not keep them.
+	private bool
+	logger  Logger
+}
+
+// withNamespace returns a copy of a with keys in namespace ns.
+func (a *archive) withNamespace(ns string) *archive {
+	if a == nil {
+		return nil
+	}
+	ret := *a
+	ret.namespace = ns
+
+	return &ret
+}
+
+// withTokens returns a copy of a for days served with the access tokens.
+func (a *archive) withTokens(tokens []string) *archive {
+	if a == nil {
+		return nil
+	}
+	ret := *a
+	ret.private = len(tokens) > 0
+
+	return &ret
+}
+
+func (a *archive) key(metric string, day time.Time) string {
+	return "frontendcsv|archive|" + a.namespace + "|" + metric + "|" + day.Format("2006-01-02")
+}
+
+// archiveHandler serves the archived days of the metrics of handlers.
+type archiveHandler struct {
+	archive  *archive
+	handlers map[string]*csvHandler
+}
+
+// ServeHTTP implements http.Handler.
+func (h *archiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	handler, ok := h.handlers[vars["metric"]]
+	if !ok {
+		respondErr(w, fmt.Sprintf("unknown metric %q", vars["metric"]), http.StatusNotFound)
+		return
+	}
+	day, err := time.Parse("2006/01/02", vars["yyyy"]+"/"+vars["mm"]+"/"+vars["dd"])
+	if err != nil {
+		respondErr(w, fmt.Sprintf("invalid date %s/%s/%s, want yyyy/mm/dd", vars["yyyy"], vars["mm"], vars["dd"]), http.StatusNotFound)
+		return
+	}
+	if ready := day.AddDate(0, 0, 1).Add(h.archive.delay); time.Now().Before(ready) {
+		respondErr(w, fmt.Sprintf("%s is not archived until %s", day.Format("2006-01-02"), ready.UTC().Format(time.RFC3339)), http.StatusNotFound)
+		return
+	}
+
+	key := h.archive.key(vars["metric"], day)
+	resp, err := h.load(r.Context(), key)
+	if err != nil {
+		h.archive.logger.Log("msg", "reading archive failed", "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+	}
+	if resp == nil {
+		resp, err = h.render(r.Context(), handler, day)
+		if err != nil {
+			h.archive.logger.Log("msg", "archiving day failed", "request_id", statistics.RequestIDFromContext(r.Context()), "metric", vars["metric"], "day", day.Format("2006-01-02"), "err", err)
+			respondUpstreamErr(r.Context(), w, err)
+			return
+		}
+		if b, err := json.Marshal(resp); err != nil {
+			h.archive.logger.Log("msg", "encoding archived day failed", "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		} else if err := h.archive.store.Set(r.Context(), key, b, archiveTTL); err != nil {
+			h.archive.logger.Log("msg", "storing archived day failed", "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		}
+	}
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	sum := sha256.Sum256(resp.Body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	if h.archive.private {
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	http.ServeContent(w, r, "", resp.Stored, bytes.NewReader(resp.Body))
+}
+
+// load returns the archived response stored at key, or nil if there is none.
+func (h *archiveHandler) load(ctx context.Context, key string) (*cachedResponse, error) {
+	b, ok, err := h.archive.store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	resp := &cachedResponse{}
+	if err := json.Unmarshal(b, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// render renders the CSV of day with the default sources and options, so the
+// archive does not depend on the query. Days with failed upstream calls or
+// truncated results are not archived, as they would miss rows for good.
+func (h *archiveHandler) render(ctx context.Context, handler *csvHandler, day time.Time) (*cachedResponse, error) {
+	f := &statistics.Filter{
+		From:        day,
+		To:          day.AddDate(0, 0, 1),
+		Limit:       statistics.MaxLimit,
+		Granularity: statistics.Day,
+		Sources:     []statistics.Source{statistics.Facebook, statistics.Web},
+	}
+	opts := &options{format: encoding.CSV, locale: derive.English}
+
+	var buf bytes.Buffer
+	res, err := handler.writeTable(ctx, f, opts, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.errors) > 0 {
+		return nil, fmt.Errorf("upstream calls failed: %s", partialErrorsHeader(res.errors))
+	}
+	if res.truncated {
+		return nil, fmt.Errorf("results were truncated at %d rows", statistics.MaxLimit)
+	}
+
+	resp := &cachedResponse{Header: make(http.Header), Body: buf.Bytes(), Stored: time.Now().UTC().Truncate(time.Second)}
+	resp.Header.Set("Content-Type", encoding.CSV.ContentType())
+
+	return resp, nil
+}
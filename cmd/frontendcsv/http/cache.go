@@ -0,0 +1,292 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/atb-as/kindly/cache"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// WithCache serves successful responses from c for ttl after they were
+// first rendered.
+func WithCache(c cache.Cache, ttl time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.cache = &responseCache{cache: c, ttl: ttl}
+	}
+}
+
+// WithPrewarm renders queries, paths with query strings such as
+// "/sessions?days=30", into the cache every interval, starting right away,
+// so they are served from the cache when users ask for them. It has no
+// effect without WithCache.
+func WithPrewarm(interval time.Duration, queries []string) ServerOption {
+	return func(o *serverOptions) {
+		o.prewarmInterval = interval
+		o.prewarmQueries = queries
+	}
+}
+
+// WithServeStale keeps cached responses for d after their TTL, and serves
+// them, marked with X-Stale and a Warning header, when rendering them anew
+// fails with a 5xx status, such as during an upstream outage. It has no
+// effect without WithCache.
+func WithServeStale(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.serveStale = d
+	}
+}
+
+// responseCache caches rendered responses.
+type responseCache struct {
+	cache cache.Cache
+	ttl   time.Duration
+	// stale is how long responses are kept after ttl to serve when
+	// rendering them anew fails.
+	stale time.Duration
+	// namespace separates the keys of the routes of different bots.
+	namespace string
+	logger    Logger
+}
+
+// cachedResponse is the cached form of a response.
+type cachedResponse struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	Stored time.Time   `json:"stored"`
+}
+
+// withNamespace returns a copy of c with keys in namespace ns.
+func (c *responseCache) withNamespace(ns string) *responseCache {
+	if c == nil {
+		return nil
+	}
+	ret := *c
+	ret.namespace = ns
+
+	return &ret
+}
+
+// key identifies the response to r by its path and query, ignoring the order
+// of the parameters and access tokens. Without a "lang" parameter, the
+// language of the Accept-Language header is part of the key.
+func (c *responseCache) key(r *http.Request) string {
+	q := r.URL.Query()
+	q.Del("access_token")
+	if q.Get("lang") == "" {
+		if l := derive.MatchLocale(r.Header.Get("Accept-Language")); l != derive.English {
+			q.Set("lang", string(l))
+		}
+	}
+
+	return fmt.Sprintf("frontendcsv|%s|%s?%s", c.namespace, r.URL.Path, q.Encode())
+}
+
+// wrap serves GET requests to next from the cache, and caches successful
+// responses that are complete. Pre-warm requests always render the response
+// anew. Cache failures are logged, and the request is served from next.
+// Responses older than the TTL but kept to serve stale are rendered anew, and
+// served in place of a failed render.
+func (c *responseCache) wrap(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		key := c.key(r)
+		var stale *cachedResponse
+		if !isPrewarm(ctx) {
+			if resp, ok := c.get(ctx, key); ok {
+				if time.Since(resp.Stored) <= c.ttl {
+					writeCached(w, resp, "hit")
+					return
+				}
+				stale = resp
+			}
+		}
+
+		w.Header().Set("X-Cache", "miss")
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		if stale != nil {
+			// The response is held back until it is known to have succeeded.
+			rec.held = w.Header().Clone()
+		}
+		next.ServeHTTP(rec, r)
+		if stale != nil && rec.status >= http.StatusInternalServerError {
+			c.logger.Log("msg", "serving stale response", "request_id", statistics.RequestIDFromContext(ctx), "status", rec.status)
+			w.Header().Set("X-Stale", "true")
+			w.Header().Set("Warning", `110 - "Response is Stale"`)
+			writeCached(w, stale, "stale")
+			return
+		}
+		rec.release()
+		if rec.status != http.StatusOK || w.Header().Get("X-Partial-Errors") != "" {
+			return
+		}
+
+		hdr := withoutTrailers(w.Header().Clone())
+		hdr.Del("X-Request-ID")
+		hdr.Del("X-Cache")
+		hdr.Del("X-Upstream-Calls")
+		b, err := json.Marshal(&cachedResponse{Header: hdr, Body: rec.body.Bytes(), Stored: time.Now()})
+		if err == nil {
+			err = c.cache.Set(ctx, key, b, c.ttl+c.stale)
+		}
+		if err != nil {
+			c.logger.Log("msg", "caching response failed", "request_id", statistics.RequestIDFromContext(ctx), "err", err)
+		}
+	})
+}
+
+// writeCached writes resp, marked with X-Cache and its age.
+func writeCached(w http.ResponseWriter, resp *cachedResponse, xcache string) {
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("X-Cache", xcache)
+	w.Header().Set("Age", fmt.Sprintf("%d", int(time.Since(resp.Stored).Seconds())))
+	w.Write(resp.Body)
+}
+
+func (c *responseCache) get(ctx context.Context, key string) (*cachedResponse, bool) {
+	b, ok, err := c.cache.Get(ctx, key)
+	if err != nil {
+		c.logger.Log("msg", "reading cache failed", "request_id", statistics.RequestIDFromContext(ctx), "err", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var resp cachedResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		c.logger.Log("msg", "decoding cached response failed", "request_id", statistics.RequestIDFromContext(ctx), "err", err)
+		return nil, false
+	}
+
+	return &resp, true
+}
+
+// recorder passes a response through while keeping its status and body. A
+// recorder with held headers holds the response back until release.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	// held are the headers of a held back response, nil if it is passed
+	// through.
+	held        http.Header
+	wroteHeader bool
+}
+
+func (r *recorder) Header() http.Header {
+	if r.held != nil {
+		return r.held
+	}
+	return r.ResponseWriter.Header()
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.wroteHeader = true
+	if r.held == nil {
+		r.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	if r.held != nil {
+		return len(b), nil
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// release writes a held back response.
+func (r *recorder) release() {
+	if r.held == nil {
+		return
+	}
+	hdr := r.ResponseWriter.Header()
+	for k := range hdr {
+		delete(hdr, k)
+	}
+	for k, v := range withoutTrailers(r.held) {
+		hdr[k] = v
+	}
+	if r.wroteHeader {
+		r.ResponseWriter.WriteHeader(r.status)
+	}
+	r.ResponseWriter.Write(r.body.Bytes())
+}
+
+type prewarmKey struct{}
+
+// isPrewarm reports whether ctx belongs to a pre-warm request, which can only
+// be made in-process.
+func isPrewarm(ctx context.Context) bool {
+	return ctx.Value(prewarmKey{}) != nil
+}
+
+// prewarm renders queries through h every interval until ctx is done,
+// logging failures to logger.
+func prewarm(ctx context.Context, h http.Handler, interval time.Duration, queries []string, logger Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		for _, q := range queries {
+			prewarmQuery(ctx, h, q, logger)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func prewarmQuery(ctx context.Context, h http.Handler, query string, logger Logger) {
+	u, err := url.Parse(query)
+	if err != nil {
+		logger.Log("msg", "pre-warming failed", "query", query, "err", err)
+		return
+	}
+
+	r, err := http.NewRequestWithContext(context.WithValue(ctx, prewarmKey{}, true), http.MethodGet, u.String(), nil)
+	if err != nil {
+		logger.Log("msg", "pre-warming failed", "query", query, "err", err)
+		return
+	}
+	r.Host = u.Host
+	r.RequestURI = u.RequestURI()
+
+	w := &discardWriter{header: make(http.Header), status: http.StatusOK}
+	h.ServeHTTP(w, r)
+	if w.status != http.StatusOK {
+		logger.Log("msg", "pre-warming failed", "query", query, "request_id", w.header.Get("X-Request-ID"), "status", w.status)
+	}
+}
+
+// discardWriter is the ResponseWriter of pre-warm requests.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(status int)      { w.status = status }
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// withCaller makes the caller of the request available to the audit log of
+// upstream calls through the request context. Callers are identified by a
+// hash of their access token, or by their address if they have none.
+// Pre-warm requests are made by "prewarm".
+func withCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var caller string
+		switch token := accessToken(r); {
+		case isPrewarm(r.Context()):
+			caller = "prewarm"
+		case token != "":
+			caller = "token:" + hashToken(token)[:12]
+		case r.RemoteAddr != "":
+			caller = "addr:" + r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				caller = "addr:" + host
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(statistics.WithCaller(r.Context(), caller)))
+	})
+}
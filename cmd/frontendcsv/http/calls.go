@@ -0,0 +1,137 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// callEstimator returns the number of upstream calls, not counting retries, a
+// request to a route makes for f.
+type callEstimator func(r *http.Request, f *statistics.Filter) int
+
+// perSource estimates routes that make one call per source.
+func perSource(r *http.Request, f *statistics.Filter) int {
+	return len(f.Sources)
+}
+
+// perDay estimates routes that make one call per day of the period.
+func perDay(r *http.Request, f *statistics.Filter) int {
+	return int((f.To.Sub(f.From) + 24*time.Hour - 1) / (24 * time.Hour))
+}
+
+// perDayAndSource estimates routes that make one call per day and source.
+func perDayAndSource(r *http.Request, f *statistics.Filter) int {
+	return perDay(r, f) * len(f.Sources)
+}
+
+// perPeriodIfSynthesized estimates totals routes, which make one call, or
+// one per period of the granularity when synthesizing a series.
+func perPeriodIfSynthesized(r *http.Request, f *statistics.Filter) int {
+	if synthesize, _ := strconv.ParseBool(r.Form.Get("synthesize")); synthesize {
+		return len(f.Chunks(f.Granularity))
+	}
+
+	return 1
+}
+
+// fixedCalls estimates routes that make n calls whatever the filter.
+func fixedCalls(n int) callEstimator {
+	return func(r *http.Request, f *statistics.Filter) int {
+		return n
+	}
+}
+
+// callsKey is the context key of the callCounter of a request.
+type callsKey struct{}
+
+// callCounter counts the upstream calls of a request, and keeps their
+// estimate if the route has one.
+type callCounter struct {
+	budget *statistics.CallBudget
+	// estimate is -1 unless the route estimated the request.
+	estimate int
+}
+
+// estimated estimates the upstream calls of requests to next with estimate,
+// sets the X-Upstream-Calls-Estimate header and refuses with 422 the requests
+// estimated to need more calls than the limit. Requests with an invalid filter
+// are left for next to refuse.
+func (l *limits) estimated(estimate callEstimator, next http.Handler) http.Handler {
+	if estimate == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := filterFromRequest(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		n := estimate(r, f)
+		if c, ok := r.Context().Value(callsKey{}).(*callCounter); ok {
+			c.estimate = n
+		}
+		w.Header().Set("X-Upstream-Calls-Estimate", strconv.Itoa(n))
+		if l.maxCalls > 0 && n > l.maxCalls {
+			respondErr(w, fmt.Sprintf("request needs an estimated %d upstream calls, more than the %d allowed, narrow the period or sources", n, l.maxCalls), http.StatusUnprocessableEntity)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// callsWriter sets the X-Upstream-Calls header to the calls made when the
+// response starts, which are all of them for handlers that buffer their
+// response until their upstream calls are done. Streamed responses declare it
+// as a trailer instead, set by finish.
+type callsWriter struct {
+	http.ResponseWriter
+	budget      *statistics.CallBudget
+	wroteHeader bool
+}
+
+func (w *callsWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if !trailerDeclared(w.Header(), "X-Upstream-Calls") {
+			w.Header().Set("X-Upstream-Calls", strconv.Itoa(w.budget.Calls()))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *callsWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// finish sets the X-Upstream-Calls trailer of a streamed response to all the
+// calls made.
+func (w *callsWriter) finish() {
+	if trailerDeclared(w.Header(), "X-Upstream-Calls") {
+		w.Header().Set("X-Upstream-Calls", strconv.Itoa(w.budget.Calls()))
+	}
+}
+
+// logCalls logs the upstream calls of a request that made any.
+func logCalls(ctx context.Context, logger Logger, r *http.Request, c *callCounter) {
+	calls := c.budget.Calls()
+	if calls == 0 {
+		return
+	}
+
+	estimate := "none"
+	if c.estimate >= 0 {
+		estimate = strconv.Itoa(c.estimate)
+	}
+	logger.Log("request_id", statistics.RequestIDFromContext(ctx), "path", r.URL.Path, "calls", calls, "estimate", estimate)
+}
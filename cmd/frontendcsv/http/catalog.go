@@ -0,0 +1,255 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/atb-as/kindly/metrics"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// route is an entry of the route registry, from which both the router and
+// /metrics-catalog are built.
+type route struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	// Metric is the metric of the registry the route serves, if any.
+	Metric string `json:"metric,omitempty"`
+	// Parameters are the query parameters the route accepts.
+	Parameters []string `json:"parameters"`
+	// Granularities are the accepted values of "granularity". Routes without
+	// them ignore the parameter.
+	Granularities []string `json:"granularities,omitempty"`
+	// Columns are the columns of the long layout, if the route serves a
+	// single table.
+	Columns []column `json:"columns,omitempty"`
+	// Defaults are the configured defaults of the route, if any.
+	Defaults *RouteDefaults `json:"defaults,omitempty"`
+
+	handler http.Handler
+	// estimate estimates the upstream calls of a request, if the route can.
+	estimate callEstimator
+}
+
+// column describes a column of the table served by a route.
+type column struct {
+	Name string `json:"name"`
+	// Type is "date", "integer", "number" or "string".
+	Type string `json:"type"`
+}
+
+var (
+	filterParams = []string{"days", "from", "to", "sources", "preset", "format", "timefmt", "tz", "lang", "precision", "percent", "decimal"}
+	seriesParams = append([]string{"limit", "granularity", "layout", "fill", "split", "hours", "annotate"}, filterParams...)
+	totalsParams = append([]string{"synthesize", "granularity", "annotate"}, filterParams...)
+	listParams   = append([]string{"limit", "annotate"}, filterParams...)
+)
+
+// newRoutes returns the registry of the routes backed by client that are
+// enabled by rp, sorted by path. The archive routes are included if ar is not
+// nil. Failed requests are logged to logger.
+func newRoutes(client statistics.Service, rp *routePolicy, ar *archive, logger Logger) []*route {
+	handlers := newHandlers(client)
+	pageSeries := pageSeriesHandler(client)
+	for _, h := range handlers {
+		h.logger = logger
+	}
+	pageSeries.logger = logger
+
+	routes := make([]*route, 0)
+	for _, m := range metrics.All() {
+		routes = append(routes, metricRoute(m, handlers[m.Name]))
+	}
+	routes = append(routes, []*route{
+		{
+			Path:          "/pages/series",
+			Description:   "Sessions and messages per period of the top \"limit\" pages of the whole period.",
+			Parameters:    append([]string{"limit", "granularity", "annotate"}, filterParams...),
+			Granularities: []string{"day", "week"},
+			Columns:       columnsOf(pageSeries.hdr),
+			handler:       pageSeries,
+			estimate: func(r *http.Request, f *statistics.Filter) int {
+				return 1 + len(f.Chunks(f.Granularity))
+			},
+		},
+		{
+			Path:        "/scorecard",
+			Description: "Key figures for the period compared with the preceding period of equal length.",
+			Parameters:  filterParams,
+			Columns:     columnsOf(scorecardHeader),
+			handler:     &scorecardHandler{client: client, logger: logger},
+			// The five KPIs of the period and of the preceding one.
+			estimate: fixedCalls(10),
+		},
+		{
+			Path:        "/handovers/afterhours",
+			Description: "Handover requests per weekday and the share made outside the opening hours in \"hours\".",
+			Parameters:  append([]string{"hours"}, filterParams...),
+			Columns:     columnsOf(afterHoursHeader),
+			handler:     &afterHoursHandler{client: client, logger: logger},
+		},
+		{
+			Path:          "/feedback/nps",
+			Description:   "Net promoter score per period, with the change from the preceding period.",
+			Parameters:    append([]string{"granularity", "ratings", "nps"}, filterParams...),
+			Granularities: []string{"day", "week"},
+			Columns:       columnsOf(npsHeader),
+			handler:       &npsHandler{client: client, logger: logger},
+		},
+		{
+			Path:          "/share",
+			Description:   "Each source's share of the count of the counted metric in \"metric\", such as sessions, per period, and its count indexed to its first period as 100.",
+			Parameters:    append([]string{"metric", "granularity"}, filterParams...),
+			Granularities: []string{"day", "week", "month"},
+			Columns:       columnsOf(shareHeader),
+			handler:       &shareHandler{client: client, logger: logger},
+		},
+		{
+			Path:        "/compare",
+			Description: "The KPI in \"metric\" for each of the comma-separated \"periods\", such as 2024-01, 2024-W05 or 2024-01-31, with its change from every period.",
+			Parameters:  append([]string{"metric", "periods"}, filterParams...),
+			Columns:     columnsOf(compareHeader),
+			handler:     &compareHandler{client: client, logger: logger},
+		},
+		{
+			Path:          "/feedback/emojis",
+			Description:   "Average emoji rating per period, with the number and share of each rating.",
+			Parameters:    append([]string{"granularity"}, filterParams...),
+			Granularities: []string{"day", "week"},
+			Columns:       columnsOf(emojiHeader()),
+			handler:       &emojiHandler{client: client, logger: logger},
+		},
+	}...)
+
+	ret := make([]*route, 0, len(routes)+2)
+	bundled := make(map[string]*csvHandler, len(handlers))
+	archived := make(map[string]*csvHandler, len(handlers))
+	for _, rt := range routes {
+		if !rp.enabled(rt.Path) {
+			continue
+		}
+		ret = append(ret, rt)
+		name := strings.TrimPrefix(rt.Path, "/")
+		if handlers[name] != nil && rp.bundled("/export.zip", rt.Path) {
+			bundled[name] = handlers[name]
+		}
+		if handlers[name] != nil && rp.bundled("/archive", rt.Path) {
+			archived[name] = handlers[name]
+		}
+	}
+	if rp.enabled("/export.zip") {
+		ret = append(ret, &route{
+			Path:        "/export.zip",
+			Description: "Zip archive with one file per metric in \"metrics\", all for the same period. Files have the columns of the route of their metric.",
+			Parameters:  append([]string{"metrics", "limit", "granularity", "layout", "fill", "annotate"}, filterParams...),
+			handler:     &zipHandler{handlers: bundled, logger: logger},
+		})
+	}
+	if ar != nil && rp.enabled("/archive") {
+		ret = append(ret, &route{
+			Path:        archivePath,
+			Description: "CSV of the metric for one day, with the default sources, rendered on the first request and never changed after. Days are served once they have been over for the archive delay.",
+			Parameters:  []string{},
+			handler:     &archiveHandler{archive: ar, handlers: archived},
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Path < ret[j].Path })
+
+	return ret
+}
+
+// metricRoute returns the route of the metric m served by h. Its parameters
+// and granularities follow from how h fetches the metric.
+func metricRoute(m *metrics.Metric, h *csvHandler) *route {
+	rt := &route{
+		Path:        "/" + m.Name,
+		Description: h.about,
+		Metric:      m.Name,
+		Columns:     columnsOf(h.hdr),
+		handler:     h,
+		estimate:    h.estimate,
+	}
+	switch {
+	case h.series != nil:
+		rt.Parameters = seriesParams
+		rt.Granularities = granularityNames(m.Granularities)
+	case h.totals != nil:
+		rt.Parameters = totalsParams
+		rt.Granularities = []string{"day", "week"}
+	case len(m.Granularities) > 0:
+		rt.Parameters = append([]string{"granularity"}, listParams...)
+		rt.Granularities = granularityNames(m.Granularities)
+	default:
+		rt.Parameters = listParams
+	}
+
+	return rt
+}
+
+func granularityNames(gs []statistics.Granularity) []string {
+	ret := make([]string, 0, len(gs))
+	for _, g := range gs {
+		ret = append(ret, g.String())
+	}
+
+	return ret
+}
+
+// columnsOf describes the columns of hdr, typed by their name.
+func columnsOf(hdr []string) []column {
+	ret := make([]column, 0, len(hdr))
+	for _, name := range hdr {
+		ret = append(ret, column{Name: name, Type: columnType(name)})
+	}
+
+	return ret
+}
+
+// columnType returns the type of the column name: its type in the metric
+// registry, or that of the columns the routes derive.
+func columnType(name string) string {
+	if t, ok := metrics.ColumnTypeOf(name); ok {
+		return string(t)
+	}
+
+	switch {
+	case strings.HasSuffix(name, "_from") || strings.HasSuffix(name, "_to"):
+		return "date"
+	case strings.HasPrefix(name, "count_"):
+		return "integer"
+	case strings.HasPrefix(name, "share"):
+		return "number"
+	}
+
+	switch name {
+	case "promoters", "passives", "detractors", "ratings", "in_hours", "after_hours", "while_closed":
+		return "integer"
+	case "ratio", "after_hours_share", "nps", "delta", "average", "current", "previous", "change", "index", "value", "baseline_value":
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// isRatioColumn reports whether the column name holds ratios, such as
+// shares, which "percent" writes as percentages.
+func isRatioColumn(name string) bool {
+	return strings.HasPrefix(name, "share") || name == "after_hours_share" || name == "ratio" || name == "rate" || name == "change"
+}
+
+// catalogHandler serves the route registry, and the metric registry, as
+// JSON, so clients can discover the routes, their parameters and columns.
+type catalogHandler struct {
+	routes []*route
+}
+
+// ServeHTTP implements http.Handler.
+func (h *catalogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Routes  []*route          `json:"routes"`
+		Metrics []*metrics.Metric `json:"metrics"`
+	}{h.routes, metrics.All()})
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// maxComparePeriods bounds the upstream calls of a comparison.
+const maxComparePeriods = 24
+
+// compareHeader is the header row of a comparison. Every period is compared
+// with every period, itself included, so the rows pivot into a matrix.
+var compareHeader = []string{"period", "value", "baseline", "baseline_value", "change", "period_from", "period_to"}
+
+// compareHandler serves a metric in the periods of the "periods" parameter,
+// such as 2024-01,2024-02,2024-03, with the change between every pair.
+type compareHandler struct {
+	client statistics.Service
+	logger Logger
+}
+
+// ServeHTTP implements http.Handler.
+func (h *compareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := filterFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metric := r.Form.Get("metric")
+	if metric == "" {
+		metric = derive.KPISessions
+	}
+	known := false
+	for _, m := range derive.CompareMetrics {
+		known = known || m == metric
+	}
+	if !known {
+		respondErr(w, fmt.Sprintf("parsing query: \"metric\": unknown metric %q, want one of %s", metric, strings.Join(derive.CompareMetrics, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	loc, err := f.Location()
+	if err != nil {
+		respondErr(w, fmt.Sprintf("parsing query: \"tz\": %v", err), http.StatusBadRequest)
+		return
+	}
+	var periods []derive.NamedPeriod
+	for _, s := range strings.Split(r.Form.Get("periods"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		p, err := derive.ParsePeriod(s, loc)
+		if err != nil {
+			respondErr(w, fmt.Sprintf("parsing query: \"periods\": %v", err), http.StatusBadRequest)
+			return
+		}
+		periods = append(periods, p)
+	}
+	if len(periods) == 0 || len(periods) > maxComparePeriods {
+		respondErr(w, fmt.Sprintf("parsing query: \"periods\": want 1 to %d comma-separated periods, such as 2024-01,2024-02", maxComparePeriods), http.StatusBadRequest)
+		return
+	}
+
+	format, err := encoding.ParseFormat(r.Form.Get("format"))
+	if err != nil {
+		respondErr(w, fmt.Sprintf("parsing query: \"format\": unknown format %q", r.Form.Get("format")), http.StatusBadRequest)
+		return
+	}
+	timeOpts, err := timeOptionsFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := localeFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	numbers, err := numbersFromRequest(r, locale)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c, err := derive.Compare(r.Context(), h.client, f, metric, periods)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		respondUpstreamErr(r.Context(), w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	enc, err := encoding.NewEncoder(w, format)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw, err := timeOpts.writer(numbersWriter(localizeHeader(enc, locale, format), numbers, format), f)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw.Write(compareHeader)
+	for _, p := range c.Periods {
+		for i, base := range c.Periods {
+			rw.Write([]string{
+				p.Name,
+				formatFloat(p.Value),
+				base.Name,
+				formatFloat(base.Value),
+				formatFloat(p.Changes[i]),
+				formatTime(p.From, statistics.Day),
+				formatTime(p.To, statistics.Day),
+			})
+		}
+	}
+	if err := enc.Close(); err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+	}
+}
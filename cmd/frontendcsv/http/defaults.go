@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// RouteDefaults are the period and limit of requests to a route that do not
+// give them, instead of the previous day and a limit of 10.
+type RouteDefaults struct {
+	// Days is the number of days up to today, if neither "from", "to" nor
+	// "days" is given.
+	Days int `json:"days,omitempty"`
+	// Limit is the limit if "limit" is not given.
+	Limit int `json:"limit,omitempty"`
+}
+
+// WithRouteDefaults sets the defaults of the routes by path, such as
+// "/labels". Presets named in requests take precedence over them.
+func WithRouteDefaults(defaults map[string]RouteDefaults) ServerOption {
+	return func(o *serverOptions) {
+		o.defaults = defaults
+	}
+}
+
+// withDefaults sets the query parameters of d not given in requests before
+// passing them to next.
+func withDefaults(d RouteDefaults, next http.Handler) http.Handler {
+	if d == (RouteDefaults{}) {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			respondErr(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if d.Days > 0 && r.Form.Get("from") == "" && r.Form.Get("to") == "" && r.Form.Get("days") == "" {
+			r.Form.Set("days", strconv.Itoa(d.Days))
+		}
+		if d.Limit > 0 && r.Form.Get("limit") == "" {
+			r.Form.Set("limit", strconv.Itoa(d.Limit))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
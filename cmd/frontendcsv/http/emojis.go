@@ -0,0 +1,100 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// emojiHandler serves the average emoji rating and the distribution of the
+// ratings per period.
+type emojiHandler struct {
+	client statistics.Service
+	logger Logger
+}
+
+// emojiHeader returns the header row of the emoji series.
+func emojiHeader() []string {
+	hdr := []string{"date", "ratings", "average"}
+	for rating := 1; rating <= derive.EmojiRatings; rating++ {
+		hdr = append(hdr, fmt.Sprintf("count_%d", rating))
+	}
+	for rating := 1; rating <= derive.EmojiRatings; rating++ {
+		hdr = append(hdr, fmt.Sprintf("share_%d", rating))
+	}
+
+	return hdr
+}
+
+// ServeHTTP implements http.Handler.
+func (h *emojiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := filterFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := encoding.ParseFormat(r.Form.Get("format"))
+	if err != nil {
+		respondErr(w, fmt.Sprintf("parsing query: \"format\": unknown format %q", r.Form.Get("format")), http.StatusBadRequest)
+		return
+	}
+	timeOpts, err := timeOptionsFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := localeFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	numbers, err := numbersFromRequest(r, locale)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	periods, err := derive.EmojiSeries(r.Context(), h.client, f)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		respondUpstreamErr(r.Context(), w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	enc, err := encoding.NewEncoder(w, format)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw, err := timeOpts.writer(numbersWriter(localizeHeader(enc, locale, format), numbers, format), f)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw.Write(emojiHeader())
+	for _, p := range periods {
+		// Periods without ratings have no average, rather than an
+		// average of zero that would drag down trend lines.
+		average := ""
+		if p.Total() > 0 {
+			average = formatFloat(p.Average)
+		}
+		row := []string{formatTime(p.From, f.Granularity), strconv.Itoa(p.Total()), average}
+		for _, c := range p.Counts {
+			row = append(row, strconv.Itoa(c))
+		}
+		for rating := 1; rating <= derive.EmojiRatings; rating++ {
+			row = append(row, formatFloat(p.Share(rating)))
+		}
+		rw.Write(row)
+	}
+	if err := enc.Close(); err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+	}
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// fillZero returns fetch with a zero count added for every bucket of the
+// granularity of the filter that fetch returned no count for, so the series
+// has a row for every hour, day or week of the period.
+func fillZero(fetch seriesFunc) seriesFunc {
+	return func(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+		series, err := fetch(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+
+		step := 24 * time.Hour
+		switch f.Granularity {
+		case statistics.Hour:
+			step = time.Hour
+		case statistics.Week:
+			step = 7 * 24 * time.Hour
+		}
+
+		// Buckets are aligned with those returned, or with days or hours
+		// if there are none.
+		start := f.From.Truncate(step)
+		if f.Granularity == statistics.Week {
+			start = f.From.Truncate(24 * time.Hour)
+		}
+		if len(series) > 0 {
+			start = series[0].Date.Time
+		}
+		for start.After(f.From) {
+			start = start.Add(-step)
+		}
+		for !start.Add(step).After(f.From) {
+			start = start.Add(step)
+		}
+
+		seen := make(map[time.Time]bool, len(series))
+		for _, c := range series {
+			seen[c.Date.Time.UTC()] = true
+		}
+		for t := start; t.Before(f.To); t = t.Add(step) {
+			if !seen[t.UTC()] {
+				series = append(series, &statistics.CountByDate{Date: kindly.Time{Time: t}})
+			}
+		}
+		sort.SliceStable(series, func(i, j int) bool {
+			return series[i].Date.Time.Before(series[j].Date.Time)
+		})
+
+		return series, nil
+	}
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+)
+
+// headerNames are the column names of the tables in Norwegian, for reports
+// read by people rather than scripts. English keeps the column names as
+// they are. Names missing from Nynorsk are taken from Bokmål.
+var headerNames = map[derive.Locale]map[string]string{
+	derive.Bokmal: {
+		"after_hours":           "Utenfor åpningstid",
+		"average":               "Snitt",
+		"baseline":              "Sammenlignet med",
+		"baseline_value":        "Verdi sammenlignet med",
+		"change":                "Endring",
+		"count":                 "Antall",
+		"current":               "Denne perioden",
+		"current_from":          "Denne perioden fra",
+		"current_to":            "Denne perioden til",
+		"date":                  "Dato",
+		"delta":                 "Differanse",
+		"detractors":            "Kritikere",
+		"ended":                 "Avsluttet",
+		"host":                  "Vert",
+		"index":                 "Indeks",
+		"kpi":                   "Nøkkeltall",
+		"label":                 "Betegnelse",
+		"messages":              "Meldinger",
+		"nps":                   "NPS",
+		"passives":              "Passive",
+		"path":                  "Sti",
+		"period":                "Periode",
+		"period_from":           "Periode fra",
+		"period_to":             "Periode til",
+		"previous":              "Forrige periode",
+		"previous_from":         "Forrige periode fra",
+		"previous_to":           "Forrige periode til",
+		"promoters":             "Ambassadører",
+		"rating":                "Vurdering",
+		"ratings":               "Vurderinger",
+		"ratio":                 "Andel",
+		"requests":              "Forespørsler",
+		"requests_while_closed": "Forespørsler mens stengt",
+		"sessions":              "Økter",
+		"share":                 "Andel",
+		"source":                "Kilde",
+		"started":               "Startet",
+		"text":                  "Tekst",
+		"total":                 "Totalt",
+		"type":                  "Type",
+		"tz":                    "Tidssone",
+		"value":                 "Verdi",
+		"weekday":               "Ukedag",
+		"while_closed":          "Mens stengt",
+	},
+	derive.Nynorsk: {
+		"after_hours":           "Utanfor opningstid",
+		"average":               "Snitt",
+		"baseline":              "Samanlikna med",
+		"baseline_value":        "Verdi samanlikna med",
+		"count":                 "Tal",
+		"current":               "Denne perioden",
+		"detractors":            "Kritikarar",
+		"ended":                 "Avslutta",
+		"host":                  "Vert",
+		"kpi":                   "Nøkkeltal",
+		"label":                 "Nemning",
+		"messages":              "Meldingar",
+		"promoters":             "Ambassadørar",
+		"ratings":               "Vurderingar",
+		"requests":              "Førespurnader",
+		"requests_while_closed": "Førespurnader medan stengt",
+		"source":                "Kjelde",
+		"while_closed":          "Medan stengt",
+	},
+}
+
+// headerName returns the name of column name in l. Columns numbered by
+// rating, such as "count_5", are named by their prefix, and columns without
+// a name in l, such as those of sources, are returned as they are.
+func headerName(name string, l derive.Locale) string {
+	if l == derive.English {
+		return name
+	}
+	if i := strings.LastIndexByte(name, '_'); i > 0 && strings.Trim(name[i+1:], "0123456789") == "" && name[i+1:] != "" {
+		if prefix := headerName(name[:i], l); prefix != name[:i] {
+			return prefix + " " + name[i+1:]
+		}
+	}
+	if n, ok := headerNames[l][name]; ok {
+		return n
+	}
+	if l == derive.Nynorsk {
+		return headerName(name, derive.Bokmal)
+	}
+
+	return name
+}
+
+// localizeHeader returns w writing the header, the first row, with the
+// column names in l. Formats with named fields, such as JSON, keep their
+// field names, since their readers look fields up by name.
+func localizeHeader(w rowWriter, l derive.Locale, format encoding.Format) rowWriter {
+	switch {
+	case l == derive.English:
+		return w
+	case format == encoding.JSON || format == encoding.NDJSON || format == encoding.Parquet:
+		return w
+	}
+
+	return &headerWriter{rowWriter: w, locale: l}
+}
+
+// headerWriter translates the header written to a rowWriter.
+type headerWriter struct {
+	rowWriter
+	locale derive.Locale
+	hdr    bool
+}
+
+// Write implements rowWriter.
+func (w *headerWriter) Write(row []string) error {
+	return w.rowWriter.Write(w.translate(row))
+}
+
+// WriteAll implements rowWriter.
+func (w *headerWriter) WriteAll(rows [][]string) error {
+	if len(rows) > 0 && !w.hdr {
+		rows = append([][]string{w.translate(rows[0])}, rows[1:]...)
+	}
+
+	return w.rowWriter.WriteAll(rows)
+}
+
+func (w *headerWriter) translate(row []string) []string {
+	if w.hdr {
+		return row
+	}
+	w.hdr = true
+
+	out := make([]string, 0, len(row))
+	for _, name := range row {
+		out = append(out, headerName(name, w.locale))
+	}
+
+	return out
+}
@@ -0,0 +1,360 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atb-as/kindly/cache"
+	"github.com/atb-as/kindly/statistics"
+	"github.com/gorilla/mux"
+)
+
+// WithJobs serves POST /jobs, which renders any GET endpoint in the
+// background, so exports of long periods do not run into proxy and browser
+// timeouts. Job states and results are kept in store for ttl after the job
+// was started or finished. At most concurrency jobs run at a time; others
+// wait in the queue.
+func WithJobs(store cache.Cache, ttl time.Duration, concurrency int) ServerOption {
+	return func(o *serverOptions) {
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		o.jobs = &jobs{store: store, ttl: ttl, slots: make(chan struct{}, concurrency)}
+	}
+}
+
+type jobStatus string
+
+const (
+	jobQueued  jobStatus = "queued"
+	jobRunning jobStatus = "running"
+	jobDone    jobStatus = "done"
+	jobFailed  jobStatus = "failed"
+)
+
+// job is the state of a job as returned by GET /jobs/{id}.
+type job struct {
+	ID       string     `json:"id"`
+	Path     string     `json:"path"`
+	Status   jobStatus  `json:"status"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	// Error is the response to a failed job.
+	Error     string `json:"error,omitempty"`
+	ResultURL string `json:"result_url,omitempty"`
+	// Progress is set once the endpoint reports progress, which
+	// /export.zip does per metric.
+	Progress *jobProgress `json:"progress,omitempty"`
+	// TokenHash is the hash of the access token the job was started with,
+	// which is required to read it.
+	TokenHash string `json:"token_hash,omitempty"`
+}
+
+type jobProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+	Rows      int `json:"rows"`
+	Retries   int `json:"retries"`
+	// ETA is the estimated time until the job is done, e.g. "1m30s".
+	ETA string `json:"eta,omitempty"`
+}
+
+type jobs struct {
+	store cache.Cache
+	ttl   time.Duration
+	slots chan struct{}
+	// ctx is cancelled when the server shuts down, set by newServer.
+	ctx context.Context
+	// m matches the paths of jobs, and h serves them, set by newServer.
+	m      *mux.Router
+	h      http.Handler
+	logger Logger
+}
+
+// register adds the job routes to m, which also serves the jobs. The routes
+// must be registered before the routes of tenants matched on host alone.
+func (j *jobs) register(m *mux.Router) {
+	if j == nil {
+		return
+	}
+
+	j.m = m
+	m.HandleFunc("/jobs", j.create).Methods(http.MethodPost)
+	m.HandleFunc("/jobs/{id}", j.status).Methods(http.MethodGet)
+	m.HandleFunc("/jobs/{id}/result", j.result).Methods(http.MethodGet)
+}
+
+func (j *jobs) key(id string) string {
+	return "frontendcsv|jobs|" + id
+}
+
+func (j *jobs) resultKey(id string) string {
+	return "frontendcsv|jobs|" + id + "|result"
+}
+
+// create starts a job for the path in the "path" form value or JSON body,
+// such as "/export.zip?days=365", and responds with its state.
+func (j *jobs) create(w http.ResponseWriter, r *http.Request) {
+	path := r.FormValue("path")
+	if path == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var body struct {
+			Path string `json:"path"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			respondErr(w, fmt.Sprintf("parsing body: %v", err), http.StatusBadRequest)
+			return
+		}
+		path = body.Path
+	}
+
+	u, err := url.Parse(path)
+	if err != nil || path == "" || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		respondErr(w, "parsing query: \"path\" must be an absolute path such as /export.zip?days=365", http.StatusBadRequest)
+		return
+	}
+	if u.Path == "/jobs" || strings.HasPrefix(u.Path, "/jobs/") {
+		respondErr(w, "parsing query: \"path\": jobs cannot start jobs", http.StatusBadRequest)
+		return
+	}
+
+	token := accessToken(r)
+	jb := &job{
+		ID:        newRequestID(),
+		Path:      u.RequestURI(),
+		Status:    jobQueued,
+		Created:   time.Now().UTC(),
+		TokenHash: hashToken(token),
+	}
+	// The job is rendered like a request to the path, with the host and
+	// credentials of this request, so tenants and bots are selected the same
+	// way.
+	req, err := http.NewRequestWithContext(j.ctx, http.MethodGet, jb.Path, nil)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Host = r.Host
+	req.RequestURI = jb.Path
+	req.Header.Set("X-Request-ID", jb.ID)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if !j.m.Match(req, &mux.RouteMatch{}) {
+		respondErr(w, fmt.Sprintf("parsing query: \"path\": no endpoint %s", u.Path), http.StatusNotFound)
+		return
+	}
+
+	if err := j.save(r.Context(), jb); err != nil {
+		j.logger.Log("msg", "saving job failed", "job_id", jb.ID, "err", err)
+		respondErr(w, "storing job failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+jb.ID)
+	respondJob(w, jb, http.StatusAccepted)
+	go j.run(jb, req)
+}
+
+func (j *jobs) run(jb *job, req *http.Request) {
+	select {
+	case j.slots <- struct{}{}:
+		defer func() { <-j.slots }()
+	case <-j.ctx.Done():
+		return
+	}
+
+	rj := &runningJob{job: *jb}
+	j.update(rj, func(jb *job) {
+		jb.Status = jobRunning
+	})
+
+	// Progress is reported from the goroutines rendering the job, so the job
+	// is only changed through update.
+	tracker := statistics.NewProgressTracker(0, statistics.ProgressFunc(func(s statistics.ProgressStatus) {
+		j.update(rj, func(jb *job) {
+			jb.Progress = &jobProgress{Completed: s.Completed, Total: s.Total, Rows: s.Rows, Retries: s.Retries}
+			if eta := s.ETA(time.Now()); eta > 0 {
+				jb.Progress.ETA = eta.Round(time.Second).String()
+			}
+		})
+	}))
+
+	rec := &bufferWriter{header: make(http.Header), status: http.StatusOK}
+	j.h.ServeHTTP(rec, req.WithContext(statistics.WithProgress(req.Context(), tracker)))
+
+	finished := time.Now().UTC()
+	var err error
+	if rec.status == http.StatusOK {
+		hdr := withoutTrailers(rec.header.Clone())
+		hdr.Del("X-Request-ID")
+		var b []byte
+		b, err = json.Marshal(&cachedResponse{Header: hdr, Body: rec.body.Bytes(), Stored: finished})
+		if err == nil {
+			err = j.store.Set(j.ctx, j.resultKey(jb.ID), b, j.ttl)
+		}
+		if err != nil {
+			j.logger.Log("msg", "saving job result failed", "job_id", jb.ID, "err", err)
+		}
+	}
+
+	j.update(rj, func(jb *job) {
+		jb.Finished = &finished
+		switch {
+		case rec.status != http.StatusOK:
+			jb.Status = jobFailed
+			jb.Error = strings.TrimSpace(rec.body.String())
+		case err != nil:
+			jb.Status = jobFailed
+			jb.Error = "storing result failed"
+		default:
+			jb.Status = jobDone
+			jb.ResultURL = "/jobs/" + jb.ID + "/result"
+		}
+	})
+}
+
+// runningJob is the state of a job while it runs.
+type runningJob struct {
+	mu  sync.Mutex
+	job job
+}
+
+// update applies fn to the state of rj and saves it.
+func (j *jobs) update(rj *runningJob, fn func(jb *job)) {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+
+	fn(&rj.job)
+	if err := j.save(j.ctx, &rj.job); err != nil {
+		j.logger.Log("msg", "saving job failed", "job_id", rj.job.ID, "err", err)
+	}
+}
+
+func (j *jobs) status(w http.ResponseWriter, r *http.Request) {
+	jb, ok := j.load(w, r)
+	if !ok {
+		return
+	}
+
+	respondJob(w, jb, http.StatusOK)
+}
+
+// result serves the response of a finished job as it would have been
+// served synchronously.
+func (j *jobs) result(w http.ResponseWriter, r *http.Request) {
+	jb, ok := j.load(w, r)
+	if !ok {
+		return
+	}
+	switch jb.Status {
+	case jobFailed:
+		respondErr(w, fmt.Sprintf("job failed: %s", jb.Error), http.StatusConflict)
+		return
+	case jobQueued, jobRunning:
+		respondErr(w, fmt.Sprintf("job is %s", jb.Status), http.StatusConflict)
+		return
+	}
+
+	b, ok, err := j.store.Get(r.Context(), j.resultKey(jb.ID))
+	var resp cachedResponse
+	if err == nil && ok {
+		err = json.Unmarshal(b, &resp)
+	}
+	if err != nil {
+		j.logger.Log("msg", "loading job result failed", "job_id", jb.ID, "err", err)
+		respondErr(w, "loading result failed", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		respondErr(w, "result expired", http.StatusNotFound)
+		return
+	}
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.Write(resp.Body)
+}
+
+// load returns the job in the request path, responding with an error if it
+// does not exist or the request does not carry the token it was started
+// with.
+func (j *jobs) load(w http.ResponseWriter, r *http.Request) (*job, bool) {
+	id := mux.Vars(r)["id"]
+	b, ok, err := j.store.Get(r.Context(), j.key(id))
+	if err != nil {
+		j.logger.Log("msg", "loading job failed", "job_id", id, "err", err)
+		respondErr(w, "loading job failed", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	var jb job
+	if ok {
+		if err := json.Unmarshal(b, &jb); err != nil {
+			j.logger.Log("msg", "decoding job failed", "job_id", id, "err", err)
+			respondErr(w, "loading job failed", http.StatusInternalServerError)
+			return nil, false
+		}
+	}
+	// Jobs of other tenants are reported as missing, like expired ones.
+	if !ok || jb.TokenHash != hashToken(accessToken(r)) {
+		respondErr(w, fmt.Sprintf("job %q not found", id), http.StatusNotFound)
+		return nil, false
+	}
+
+	return &jb, true
+}
+
+func (j *jobs) save(ctx context.Context, jb *job) error {
+	b, err := json.Marshal(jb)
+	if err != nil {
+		return err
+	}
+
+	return j.store.Set(ctx, j.key(jb.ID), b, j.ttl)
+}
+
+func respondJob(w http.ResponseWriter, jb *job, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	ret := *jb
+	ret.TokenHash = ""
+	json.NewEncoder(w).Encode(&ret)
+}
+
+// accessToken returns the access token of r, as accepted by webauth.Token.
+func accessToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	return r.URL.Query().Get("access_token")
+}
+
+func hashToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// bufferWriter is the ResponseWriter of jobs.
+type bufferWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferWriter) Header() http.Header         { return w.header }
+func (w *bufferWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *bufferWriter) WriteHeader(status int)      { w.status = status }
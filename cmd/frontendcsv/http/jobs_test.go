@@ -0,0 +1,159 @@
+package http_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/cache"
+	frontendcsv "github.com/atb-as/kindly/cmd/frontendcsv/http"
+)
+
+type jobState struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+	ResultURL string `json:"result_url"`
+	Progress  *struct {
+		Completed int `json:"completed"`
+		Total     int `json:"total"`
+	} `json:"progress"`
+}
+
+// doJob makes a request to the job routes of ts with token, and returns the
+// status and body of the response.
+func doJob(t *testing.T, ts *httptest.Server, method, path, token string, form url.Values) (int, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatalf("http.NewRequest() err=%v", err)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s err=%v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: err=%v", err)
+	}
+
+	return resp.StatusCode, b
+}
+
+// startJob starts a job for path with token and waits until it is finished.
+func startJob(t *testing.T, ts *httptest.Server, path, token string) *jobState {
+	t.Helper()
+
+	status, b := doJob(t, ts, http.MethodPost, "/jobs", token, url.Values{"path": {path}})
+	if status != http.StatusAccepted {
+		t.Fatalf("POST /jobs: got status %d, want %d: %s", status, http.StatusAccepted, b)
+	}
+	var jb jobState
+	if err := json.Unmarshal(b, &jb); err != nil {
+		t.Fatalf("decoding job: err=%v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for jb.Status == "queued" || jb.Status == "running" {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is still %s", jb.ID, jb.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		status, b := doJob(t, ts, http.MethodGet, "/jobs/"+jb.ID, token, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /jobs/%s: got status %d, want %d: %s", jb.ID, status, http.StatusOK, b)
+		}
+		if err := json.Unmarshal(b, &jb); err != nil {
+			t.Fatalf("decoding job: err=%v", err)
+		}
+	}
+
+	return &jb
+}
+
+func TestJobs(t *testing.T) {
+	ts := newTestServer(t, failing(), frontendcsv.WithJobs(cache.NewMemory(), time.Hour, 1))
+
+	jb := startJob(t, ts, "/sessions?from=2021-03-01&to=2021-03-02", "secret")
+	if jb.Status != "done" {
+		t.Fatalf("got job status %q, want done: %s", jb.Status, jb.Error)
+	}
+	if want := "/jobs/" + jb.ID + "/result"; jb.ResultURL != want {
+		t.Errorf("got result URL %q, want %q", jb.ResultURL, want)
+	}
+
+	status, b := doJob(t, ts, http.MethodGet, jb.ResultURL, "secret", nil)
+	if status != http.StatusOK {
+		t.Fatalf("GET %s: got status %d, want %d: %s", jb.ResultURL, status, http.StatusOK, b)
+	}
+	if got, want := string(b), "date,count,source\n2021-03-01,3,facebook\n2021-03-01,3,web\n"; got != want {
+		t.Errorf("got result %q, want %q", got, want)
+	}
+}
+
+func TestJobs_Progress(t *testing.T) {
+	ts := newTestServer(t, failing(), frontendcsv.WithJobs(cache.NewMemory(), time.Hour, 1))
+
+	jb := startJob(t, ts, "/export.zip?metrics=sessions,messages&from=2021-03-01&to=2021-03-02", "")
+	if jb.Status != "done" {
+		t.Fatalf("got job status %q, want done: %s", jb.Status, jb.Error)
+	}
+	if jb.Progress == nil || jb.Progress.Completed != 2 || jb.Progress.Total != 2 {
+		t.Errorf("got progress %+v, want 2 of 2 metrics completed", jb.Progress)
+	}
+}
+
+func TestJobs_Failed(t *testing.T) {
+	ts := newTestServer(t, failing("sessions/chats"), frontendcsv.WithJobs(cache.NewMemory(), time.Hour, 1))
+
+	jb := startJob(t, ts, "/sessions?from=2021-03-01&to=2021-03-02", "")
+	if jb.Status != "failed" || jb.Error == "" {
+		t.Fatalf("got job status %q with error %q, want failed with an error", jb.Status, jb.Error)
+	}
+	if status, b := doJob(t, ts, http.MethodGet, "/jobs/"+jb.ID+"/result", "", nil); status != http.StatusConflict {
+		t.Errorf("GET result: got status %d, want %d: %s", status, http.StatusConflict, b)
+	}
+}
+
+func TestJobs_ForeignToken(t *testing.T) {
+	ts := newTestServer(t, failing(), frontendcsv.WithJobs(cache.NewMemory(), time.Hour, 1))
+
+	jb := startJob(t, ts, "/sessions?from=2021-03-01&to=2021-03-02", "secret")
+	for _, token := range []string{"other", ""} {
+		for _, path := range []string{"/jobs/" + jb.ID, "/jobs/" + jb.ID + "/result"} {
+			if status, b := doJob(t, ts, http.MethodGet, path, token, nil); status != http.StatusNotFound {
+				t.Errorf("GET %s with token %q: got status %d, want %d: %s", path, token, status, http.StatusNotFound, b)
+			}
+		}
+	}
+}
+
+func TestJobs_Expiry(t *testing.T) {
+	ttl := 500 * time.Millisecond
+	ts := newTestServer(t, failing(), frontendcsv.WithJobs(cache.NewMemory(), ttl, 1))
+
+	jb := startJob(t, ts, "/sessions?from=2021-03-01&to=2021-03-02", "secret")
+	if jb.Status != "done" {
+		t.Fatalf("got job status %q, want done: %s", jb.Status, jb.Error)
+	}
+
+	time.Sleep(ttl + 100*time.Millisecond)
+	for _, path := range []string{"/jobs/" + jb.ID, jb.ResultURL} {
+		if status, b := doJob(t, ts, http.MethodGet, path, "secret", nil); status != http.StatusNotFound {
+			t.Errorf("GET %s after expiry: got status %d, want %d: %s", path, status, http.StatusNotFound, b)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// WithRequestBudget limits the total time spent on upstream calls for a
+// single request.
+func WithRequestBudget(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.limits.budget = d
+	}
+}
+
+// WithMaxUpstreamCalls limits the number of upstream calls, including
+// retries, made for a single request.
+func WithMaxUpstreamCalls(n int) ServerOption {
+	return func(o *serverOptions) {
+		o.limits.maxCalls = n
+	}
+}
+
+// limits are applied to every request. Zero values mean no limit.
+type limits struct {
+	budget   time.Duration
+	maxCalls int
+	logger   Logger
+}
+
+// middleware applies the request budget and upstream call limit to the
+// context of every request, and accounts its upstream calls: they are set in
+// the X-Upstream-Calls header and logged.
+func (l *limits) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if l.budget > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, l.budget)
+			defer cancel()
+		}
+		c := &callCounter{budget: statistics.NewCallBudget(l.maxCalls), estimate: -1}
+		ctx = context.WithValue(statistics.WithCallBudget(ctx, c.budget), callsKey{}, c)
+
+		cw := &callsWriter{ResponseWriter: w, budget: c.budget}
+		next.ServeHTTP(cw, r.WithContext(ctx))
+		cw.finish()
+		logCalls(ctx, l.logger, r, c)
+	})
+}
+
+// respondUpstreamErr responds to a failed upstream call: 400 if the filter
+// was invalid, 504 with a message naming the limit if a limit was exceeded,
+// 502 otherwise.
+func respondUpstreamErr(ctx context.Context, w http.ResponseWriter, err error) {
+	var timeout interface{ Timeout() bool }
+	var invalid *statistics.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		respondErr(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, statistics.ErrCallBudgetExceeded):
+		respondErr(w, "request needs more upstream calls than allowed, narrow the period or sources", http.StatusGatewayTimeout)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		respondErr(w, "request budget exceeded waiting for upstream, narrow the period or sources", http.StatusGatewayTimeout)
+	case errors.As(err, &timeout) && timeout.Timeout():
+		respondErr(w, fmt.Sprintf("upstream call timed out: %v", err), http.StatusGatewayTimeout)
+	default:
+		respondErr(w, err.Error(), http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,21 @@
+package http
+
+// Logger logs key-value pairs, such as a go-kit logger.
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+type nopLogger struct {
+}
+
+func (l *nopLogger) Log(keyvals ...interface{}) error {
+	return nil
+}
+
+// WithLogger logs failed requests, upstream calls, and failures of the cache,
+// jobs and archive to logger. Nothing is logged without it.
+func WithLogger(logger Logger) ServerOption {
+	return func(o *serverOptions) {
+		o.logger = logger
+	}
+}
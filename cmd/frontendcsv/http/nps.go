@@ -0,0 +1,91 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// npsHandler serves a net promoter score per period, computed from the
+// feedback ratings with the mapping in the "ratings" and "nps" query
+// parameters.
+type npsHandler struct {
+	client statistics.Service
+	logger Logger
+}
+
+var npsHeader = []string{"date", "promoters", "passives", "detractors", "ratings", "nps", "delta"}
+
+// ServeHTTP implements http.Handler.
+func (h *npsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := filterFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m, err := derive.ParseNPSMapping(r.Form.Get("ratings"), r.Form.Get("nps"))
+	if err != nil {
+		respondErr(w, fmt.Sprintf("parsing query: \"ratings\" or \"nps\": %v", err), http.StatusBadRequest)
+		return
+	}
+
+	format, err := encoding.ParseFormat(r.Form.Get("format"))
+	if err != nil {
+		respondErr(w, fmt.Sprintf("parsing query: \"format\": unknown format %q", r.Form.Get("format")), http.StatusBadRequest)
+		return
+	}
+	timeOpts, err := timeOptionsFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := localeFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	numbers, err := numbersFromRequest(r, locale)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	periods, err := derive.NPSSeries(r.Context(), h.client, f, m)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		respondUpstreamErr(r.Context(), w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	enc, err := encoding.NewEncoder(w, format)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw, err := timeOpts.writer(numbersWriter(localizeHeader(enc, locale, format), numbers, format), f)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw.Write(npsHeader)
+	for _, p := range periods {
+		rw.Write([]string{
+			formatTime(p.From, f.Granularity),
+			strconv.Itoa(p.Promoters),
+			strconv.Itoa(p.Passives),
+			strconv.Itoa(p.Detractors),
+			strconv.Itoa(p.Total()),
+			formatFloat(p.Score),
+			formatFloat(p.Delta),
+		})
+	}
+	if err := enc.Close(); err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+)
+
+// maxPrecision is the largest number of decimals "precision" accepts.
+const maxPrecision = 10
+
+// numbersFromRequest returns how the numbers of the response to r are
+// written, in locale l, as selected by the "precision", "percent" and
+// "decimal" query parameters. The form of r must be parsed.
+func numbersFromRequest(r *http.Request, l derive.Locale) (encoding.Numbers, error) {
+	var n encoding.Numbers
+	if p := r.Form.Get("precision"); p != "" {
+		decimals, err := strconv.Atoi(p)
+		if err != nil || decimals < 0 || decimals > maxPrecision {
+			return n, fmt.Errorf("parsing query: \"precision\": want 0 to %d decimals, got %q", maxPrecision, p)
+		}
+		n.Round = true
+		n.Decimals = decimals
+	}
+
+	if p := r.Form.Get("percent"); p != "" {
+		percent, err := strconv.ParseBool(p)
+		if err != nil {
+			return n, fmt.Errorf("parsing query: \"percent\": %w", err)
+		}
+		if percent {
+			n.Percent = isRatioColumn
+		}
+	}
+
+	switch d := r.Form.Get("decimal"); d {
+	case "", "point":
+	case "comma":
+		n.DecimalSeparator = ','
+	case "locale":
+		if l == derive.Bokmal || l == derive.Nynorsk {
+			n.DecimalSeparator = ','
+		}
+	default:
+		return n, fmt.Errorf("parsing query: \"decimal\": unknown separator %q, want point, comma or locale", d)
+	}
+
+	return n, nil
+}
+
+// numbersWriter returns w writing the numbers of the rows as selected by n,
+// for the format of the response.
+func numbersWriter(w rowWriter, n encoding.Numbers, format encoding.Format) rowWriter {
+	if !n.Round && n.Percent == nil && n.DecimalSeparator == 0 {
+		return w
+	}
+
+	return &numberWriter{rowWriter: w, nf: n.Formatter(format)}
+}
+
+// numberWriter formats the numbers of rows written to a rowWriter.
+type numberWriter struct {
+	rowWriter
+	nf *encoding.NumberFormatter
+}
+
+// Write implements rowWriter.
+func (w *numberWriter) Write(row []string) error {
+	return w.rowWriter.Write(w.nf.Row(row))
+}
+
+// WriteAll implements rowWriter.
+func (w *numberWriter) WriteAll(rows [][]string) error {
+	out := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, w.nf.Row(row))
+	}
+
+	return w.rowWriter.WriteAll(out)
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+)
+
+type layout int
+
+const (
+	// longLayout writes one row per date and source.
+	longLayout layout = iota
+	// wideLayout writes one row per date with one column per source.
+	wideLayout
+)
+
+// options holds output options for a request that are not part of the
+// upstream filter.
+type options struct {
+	format encoding.Format
+	layout layout
+	// annotate appends a comment row to truncated results.
+	annotate bool
+	// synthesize builds a series for totals-only endpoints by querying
+	// them once per day or week.
+	synthesize bool
+	// fill adds zero counts for the buckets missing from a series.
+	fill bool
+	// split, if set, splits an hourly series by these opening hours.
+	split derive.OpeningHours
+	// locale is the language of the header and of labels, such as those of
+	// ratings.
+	locale  derive.Locale
+	time    timeOptions
+	numbers encoding.Numbers
+}
+
+func optionsFromRequest(r *http.Request) (*options, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	opts := &options{}
+
+	format, err := encoding.ParseFormat(r.Form.Get("format"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing query: \"format\": unknown format %q", r.Form.Get("format"))
+	}
+	opts.format = format
+
+	switch l := r.Form.Get("layout"); l {
+	case "", "long":
+		opts.layout = longLayout
+	case "wide":
+		opts.layout = wideLayout
+	default:
+		return nil, fmt.Errorf("parsing query: \"layout\": unknown layout %q", l)
+	}
+
+	if a := r.Form.Get("annotate"); a != "" {
+		annotate, err := strconv.ParseBool(a)
+		if err != nil {
+			return nil, fmt.Errorf("parsing query: \"annotate\": %w", err)
+		}
+		opts.annotate = annotate
+	}
+
+	if s := r.Form.Get("synthesize"); s != "" {
+		synthesize, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing query: \"synthesize\": %w", err)
+		}
+		opts.synthesize = synthesize
+	}
+
+	switch fill := r.Form.Get("fill"); fill {
+	case "", "none":
+	case "zero":
+		opts.fill = true
+	default:
+		return nil, fmt.Errorf("parsing query: \"fill\": unknown fill %q, want none or zero", fill)
+	}
+
+	switch split := r.Form.Get("split"); split {
+	case "":
+	case "hours":
+		spec := r.Form.Get("hours")
+		if spec == "" {
+			return nil, fmt.Errorf("parsing query: \"split\": splitting by hours requires \"hours\", e.g. mon-fri=08:00-16:00")
+		}
+		hours, err := derive.ParseOpeningHours(spec)
+		if err != nil {
+			return nil, fmt.Errorf("parsing query: \"hours\": %v", err)
+		}
+		opts.split = hours
+	default:
+		return nil, fmt.Errorf("parsing query: \"split\": unknown split %q, want hours", split)
+	}
+
+	locale, err := localeFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	opts.locale = locale
+
+	numbers, err := numbersFromRequest(r, locale)
+	if err != nil {
+		return nil, err
+	}
+	opts.numbers = numbers
+
+	timeOpts, err := timeOptionsFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	opts.time = timeOpts
+
+	return opts, nil
+}
+
+// localeFromRequest returns the language in the "lang" query parameter of r,
+// or else the first supported language of its Accept-Language header. The
+// form of r must be parsed.
+func localeFromRequest(r *http.Request) (derive.Locale, error) {
+	lang := r.Form.Get("lang")
+	if lang == "" {
+		return derive.MatchLocale(r.Header.Get("Accept-Language")), nil
+	}
+
+	locale, err := derive.ParseLocale(lang)
+	if err != nil {
+		return "", fmt.Errorf("parsing query: \"lang\": unknown language %q, want en, nb or nn", lang)
+	}
+
+	return locale, nil
+}
+
+type localeKey struct{}
+
+// withLocale returns a copy of ctx in which rows are labelled in l.
+func withLocale(ctx context.Context, l derive.Locale) context.Context {
+	return context.WithValue(ctx, localeKey{}, l)
+}
+
+// localeFromContext returns the locale of ctx, English by default.
+func localeFromContext(ctx context.Context) derive.Locale {
+	if l, ok := ctx.Value(localeKey{}).(derive.Locale); ok {
+		return l
+	}
+
+	return derive.English
+}
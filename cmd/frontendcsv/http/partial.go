@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// partialErrors collects the errors of the chunks of a fan-out, such as one
+// upstream call per day or source, so the chunks that succeeded can still be
+// served.
+type partialErrors struct {
+	mu     sync.Mutex
+	ok     int
+	failed []string
+	first  error
+	// fatal is an error that fails the request even if other chunks
+	// succeeded.
+	fatal error
+}
+
+// record notes the outcome of the named chunk and reports whether it
+// succeeded.
+func (p *partialErrors) record(chunk string, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		p.ok++
+		return true
+	}
+	if p.first == nil {
+		p.first = err
+	}
+	if p.fatal == nil && errors.Is(err, statistics.ErrCallBudgetExceeded) {
+		p.fatal = err
+	}
+	p.failed = append(p.failed, fmt.Sprintf("%s: %v", chunk, err))
+
+	return false
+}
+
+// err returns the error to fail the whole request with: the context error if
+// the request was cancelled or ran out of time, an exceeded call budget, or
+// the first error if no chunk succeeded. Any other failures are partial and
+// reported by summary.
+func (p *partialErrors) err(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fatal != nil {
+		return p.fatal
+	}
+	if p.ok == 0 && p.first != nil {
+		return p.first
+	}
+
+	return nil
+}
+
+// errors returns a description of every failed chunk.
+func (p *partialErrors) errors() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.failed...)
+}
+
+// partialErrorsHeader formats failures for the X-Partial-Errors header.
+func partialErrorsHeader(failed []string) string {
+	r := strings.NewReplacer("\r", " ", "\n", " ")
+	return r.Replace(strings.Join(failed, "; "))
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// WithPresets lets requests name a preset of presets in the "preset" query
+// parameter instead of giving its period, granularity and sources. Parameters
+// given in the request take precedence over the preset's. The route, not the
+// preset, selects the metric.
+func WithPresets(presets *statistics.Presets) ServerOption {
+	return func(o *serverOptions) {
+		o.presets = presets
+	}
+}
+
+// withPreset expands the "preset" query parameter of requests into the query
+// parameters of the preset before passing them to next.
+func withPreset(presets *statistics.Presets, next http.Handler) http.Handler {
+	if presets == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			respondErr(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := r.Form.Get("preset")
+		if name == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, err := presets.Get(name)
+		if err != nil {
+			respondErr(w, fmt.Sprintf("parsing query: \"preset\": unknown preset %q", name), http.StatusBadRequest)
+			return
+		}
+		_, hasFrom := r.Form["from"]
+		_, hasTo := r.Form["to"]
+		for key, values := range p.Query() {
+			if _, ok := r.Form[key]; ok || key == "days" && (hasFrom || hasTo) {
+				continue
+			}
+			r.Form[key] = values
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// serverHandler is the handler of a server returned by NewServer or
+// NewMultiTenantServer.
+type serverHandler struct {
+	http.Handler
+	// stopPrewarm stops pre-warming the cache, if configured.
+	stopPrewarm context.CancelFunc
+}
+
+// Reloadable is an http.Handler serving the routes of the server most
+// recently passed to Swap, so the configuration of a server can be replaced
+// without a restart. Requests in flight, including running jobs, finish on
+// the configuration they started with.
+type Reloadable struct {
+	current atomic.Value // handlerBox
+
+	mu sync.Mutex
+	// swapped are the servers passed to Swap, shut down by Close.
+	swapped []*http.Server
+}
+
+// NewReloadable returns a Reloadable serving h, such as the handler of a
+// server returned by NewServer. The server keeps serving h, so use the
+// Reloadable as the handler of another server or set it after copying h.
+func NewReloadable(h http.Handler) *Reloadable {
+	rl := &Reloadable{}
+	rl.current.Store(handlerBox{h})
+
+	return rl
+}
+
+// ServeHTTP implements http.Handler.
+func (rl *Reloadable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rl.current.Load().(handlerBox).ServeHTTP(w, r)
+}
+
+// handlerBox gives the handlers stored in an atomic.Value the same type.
+type handlerBox struct {
+	http.Handler
+}
+
+// Swap serves the routes of srv, returned by NewServer or
+// NewMultiTenantServer and not started, from now on. The replaced routes
+// stop pre-warming the cache.
+func (rl *Reloadable) Swap(srv *http.Server) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	old := rl.current.Load().(handlerBox)
+	rl.current.Store(handlerBox{srv.Handler})
+	rl.swapped = append(rl.swapped, srv)
+	if h, ok := old.Handler.(*serverHandler); ok {
+		h.stopPrewarm()
+	}
+}
+
+// Close shuts down the servers passed to Swap, cancelling their running jobs.
+func (rl *Reloadable) Close(ctx context.Context) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for _, srv := range rl.swapped {
+		if err := srv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/atb-as/kindly/webauth"
+)
+
+// RouteGroup is a set of routes sharing an access requirement, such as the
+// metrics that may be published and the ones that stay internal.
+type RouteGroup struct {
+	// Paths are the routes of the group, such as "/sessions", relative to the
+	// path prefix of the tenant.
+	Paths []string
+	// Public routes are served without an access token, even if the tenant
+	// requires one.
+	Public bool
+	// Tokens are the access tokens accepted for the routes instead of the
+	// tokens of the tenant. Without tokens, the routes require the tokens of
+	// the tenant, if any.
+	Tokens []string
+}
+
+// WithRoutes only serves the routes in groups, with the access requirements of
+// their group. Other routes respond with 404. The metrics in /export.zip, and
+// in the archive routes, enabled with "/archive", are limited to the enabled
+// routes in their own group and in public groups.
+func WithRoutes(groups []RouteGroup) ServerOption {
+	return func(o *serverOptions) {
+		o.routes = newRoutePolicy(groups)
+	}
+}
+
+// routePolicy enables routes and selects their access tokens. A nil policy
+// enables every route with the tokens of the tenant.
+type routePolicy struct {
+	groups map[string]*RouteGroup
+}
+
+func newRoutePolicy(groups []RouteGroup) *routePolicy {
+	p := &routePolicy{groups: make(map[string]*RouteGroup)}
+	for i := range groups {
+		for _, path := range groups[i].Paths {
+			p.groups[path] = &groups[i]
+		}
+	}
+
+	return p
+}
+
+// enabled reports whether the route at path is served.
+func (p *routePolicy) enabled(path string) bool {
+	if p == nil {
+		return true
+	}
+	_, ok := p.groups[path]
+
+	return ok
+}
+
+// bundled reports whether the route at path may be included in a bundle of
+// routes, such as /export.zip, served at bundle. Routes are bundled if they
+// are enabled and public or in the same group as the bundle.
+func (p *routePolicy) bundled(bundle, path string) bool {
+	if p == nil {
+		return true
+	}
+	g, ok := p.groups[path]
+	if !ok {
+		return false
+	}
+
+	return g.Public || g == p.groups[bundle]
+}
+
+// tokens returns the access tokens of the route at path, given the tokens of
+// the tenant. No tokens means the route is public. The archive routes share
+// the tokens of /archive.
+func (p *routePolicy) tokens(path string, tenant []string) []string {
+	if p == nil {
+		return tenant
+	}
+	if strings.HasPrefix(path, "/archive/") {
+		path = "/archive"
+	}
+	g, ok := p.groups[path]
+	switch {
+	case !ok:
+		return tenant
+	case g.Public:
+		return nil
+	case len(g.Tokens) > 0:
+		return g.Tokens
+	default:
+		return tenant
+	}
+}
+
+// authenticate requires the access tokens of the route of every request,
+// given the tokens of the tenant, before passing it to next. The route is the
+// request path without prefix. Pre-warm requests are not authenticated.
+func (p *routePolicy) authenticate(prefix string, tenant []string, next http.Handler) http.Handler {
+	if p == nil {
+		if len(tenant) == 0 {
+			return next
+		}
+		return skipForPrewarm(webauth.Token(tenant)(next), next)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens := p.tokens(strings.TrimPrefix(r.URL.Path, prefix), tenant)
+		if len(tokens) == 0 || isPrewarm(r.Context()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		webauth.Token(tokens)(next).ServeHTTP(w, r)
+	})
+}
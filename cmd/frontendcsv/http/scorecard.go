@@ -0,0 +1,130 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// scorecardHandler serves KPIs for the requested period compared with the
+// period before it as a table in the requested format or, with ?format=json,
+// as a JSON document.
+type scorecardHandler struct {
+	client statistics.Service
+	logger Logger
+}
+
+var scorecardHeader = []string{"kpi", "current", "previous", "delta", "change", "current_from", "current_to", "previous_from", "previous_to"}
+
+// ServeHTTP implements http.Handler.
+func (h *scorecardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := filterFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := encoding.ParseFormat(r.Form.Get("format"))
+	if err != nil {
+		respondErr(w, fmt.Sprintf("parsing query: \"format\": unknown format %q", r.Form.Get("format")), http.StatusBadRequest)
+		return
+	}
+	timeOpts, err := timeOptionsFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := localeFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	numbers, err := numbersFromRequest(r, locale)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sc, err := derive.NewScorecard(r.Context(), h.client, f)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		respondUpstreamErr(r.Context(), w, err)
+		return
+	}
+
+	if format == encoding.JSON {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(scorecardJSON(sc)); err != nil {
+			h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	enc, err := encoding.NewEncoder(w, format)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw, err := timeOpts.writer(numbersWriter(localizeHeader(enc, locale, format), numbers, format), f)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw.Write(scorecardHeader)
+	for _, k := range sc.KPIs {
+		rw.Write([]string{
+			k.Name,
+			formatFloat(k.Current),
+			formatFloat(k.Previous),
+			formatFloat(k.Delta),
+			formatFloat(k.Change),
+			formatTime(sc.Current.From, f.Granularity),
+			formatTime(sc.Current.To, f.Granularity),
+			formatTime(sc.Previous.From, f.Granularity),
+			formatTime(sc.Previous.To, f.Granularity),
+		})
+	}
+	if err := enc.Close(); err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+	}
+}
+
+type periodJSON struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type kpiJSON struct {
+	Name     string  `json:"name"`
+	Current  float64 `json:"current"`
+	Previous float64 `json:"previous"`
+	Delta    float64 `json:"delta"`
+	Change   float64 `json:"change"`
+}
+
+func scorecardJSON(sc *derive.Scorecard) interface{} {
+	kpis := make([]kpiJSON, 0, len(sc.KPIs))
+	for _, k := range sc.KPIs {
+		kpis = append(kpis, kpiJSON{Name: k.Name, Current: k.Current, Previous: k.Previous, Delta: k.Delta, Change: k.Change})
+	}
+
+	return struct {
+		Current  periodJSON `json:"current"`
+		Previous periodJSON `json:"previous"`
+		KPIs     []kpiJSON  `json:"kpis"`
+	}{
+		Current:  periodJSON{From: sc.Current.From.Format("2006-01-02"), To: sc.Current.To.Format("2006-01-02")},
+		Previous: periodJSON{From: sc.Previous.From.Format("2006-01-02"), To: sc.Previous.To.Format("2006-01-02")},
+		KPIs:     kpis,
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
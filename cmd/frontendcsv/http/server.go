@@ -2,33 +2,73 @@ package http
 
 import (
 	"context"
-	"encoding/csv"
 	"fmt"
+	"io"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/metrics"
 	"github.com/atb-as/kindly/statistics"
 	"github.com/gorilla/mux"
 )
 
 type rowWriter interface {
+	Write(row []string) error
 	WriteAll(rows [][]string) error
 }
 
+// countingWriter counts the rows written to a rowWriter.
+type countingWriter struct {
+	rowWriter
+	rows int
+}
+
+func (w *countingWriter) Write(row []string) error {
+	w.rows++
+	return w.rowWriter.Write(row)
+}
+
+func (w *countingWriter) WriteAll(rows [][]string) error {
+	w.rows += len(rows)
+	return w.rowWriter.WriteAll(rows)
+}
+
 type csvHandler struct {
 	hdr []string
-	h   func(ctx context.Context, f *statistics.Filter, w rowWriter) error
+	// h writes the rows for f to w. Failed upstream calls are recorded in
+	// errs so the rows of the calls that succeeded are still written.
+	h func(ctx context.Context, f *statistics.Filter, w rowWriter, errs *partialErrors) error
+	// series is set for handlers backed by a per-source time series, which
+	// can also be rendered in the wide layout.
+	series seriesFunc
+	// totals is set instead of h for handlers backed by a totals-only
+	// endpoint, which can be synthesized into a series.
+	totals totalsFunc
+	// about describes the rows in the catalog.
+	about string
+	// estimate estimates the upstream calls of a request.
+	estimate callEstimator
+	logger   Logger
 }
 
-type csvRowWriter struct {
-	*csv.Writer
+// csvResult describes a rendered CSV beyond its rows.
+type csvResult struct {
+	truncated bool
+	// errors describes the upstream calls that failed while others
+	// succeeded, so the CSV is missing their rows.
+	errors []string
+	// rows is the number of rows written below the header.
+	rows int
 }
 
-func (c *csvRowWriter) Write(cols ...string) error {
-	return c.Writer.Write(cols)
-}
+type seriesFunc func(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error)
+
+// totalsFunc returns the rows for the whole period of f, without the date
+// column.
+type totalsFunc func(ctx context.Context, f *statistics.Filter) ([][]string, error)
 
 // ServeHTTP implements http.Handler.
 func (h *csvHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -38,87 +78,406 @@ func (h *csvHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-	cw := csv.NewWriter(w)
-	cw.Write(h.hdr)
-
-	if err := h.h(r.Context(), f, &csvRowWriter{cw}); err != nil {
-		fmt.Fprintf(os.Stderr, "handler: err=%v\n", err)
+	opts, err := optionsFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.layout == wideLayout && h.series == nil {
+		respondErr(w, "parsing query: \"layout\": wide layout is not supported by this endpoint", http.StatusBadRequest)
+		return
+	}
+	if opts.synthesize && h.totals == nil {
+		respondErr(w, "parsing query: \"synthesize\": only supported by totals endpoints", http.StatusBadRequest)
+		return
+	}
+	if opts.fill && h.series == nil {
+		respondErr(w, "parsing query: \"fill\": only supported by series endpoints", http.StatusBadRequest)
+		return
+	}
+	if opts.split != nil && (h.series == nil || opts.layout == wideLayout || f.Granularity == statistics.Hour) {
+		respondErr(w, "parsing query: \"split\": only supported by series endpoints in the long layout by day or week", http.StatusBadRequest)
 		return
 	}
 
-	cw.Flush()
-	if err := cw.Error(); err != nil {
-		fmt.Fprintf(os.Stderr, "handler: flush: err=%v\n", err)
+	// The response is held back until it is complete or exceeds
+	// streamThreshold. A complete response is sent with the headers
+	// describing the result; a streamed one sends them as trailers, as they
+	// are only known once all upstream calls have completed.
+	sw := &streamWriter{start: func(streaming bool) (io.Writer, error) {
+		w.Header().Set("Content-Type", opts.format.ContentType())
+		if opts.synthesize {
+			w.Header().Set("X-Synthesized", "true")
+		}
+		if streaming {
+			declareResultTrailers(w)
+		}
+		w.WriteHeader(http.StatusOK)
+		return w, nil
+	}}
+	res, err := h.writeTable(r.Context(), f, opts, sw)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		if !sw.started() {
+			respondUpstreamErr(r.Context(), w, err)
+			return
+		}
+		// The rows written so far are sent, so the error is reported like
+		// the failed calls of a partial result.
+		setResultHeaders(w, &csvResult{errors: []string{err.Error()}})
 		return
 	}
+
+	if len(res.errors) > 0 {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "partial_errors", partialErrorsHeader(res.errors))
+	}
+	setResultHeaders(w, res)
+	sw.flush()
+}
+
+// writeTable writes the header row followed by the rows produced for f to w,
+// in opts.format. Rows are written for the upstream calls that succeeded; the
+// request only fails if all of them failed. When opts.annotate is set and the
+// format supports comments, trailing comment rows say if the upstream results
+// were truncated at f.Limit and which calls failed.
+func (h *csvHandler) writeTable(ctx context.Context, f *statistics.Filter, opts *options, w io.Writer) (*csvResult, error) {
+	meta := &statistics.ResponseMeta{}
+	ctx = withLocale(statistics.WithResponseMeta(ctx, meta), opts.locale)
+	errs := &partialErrors{}
+	enc, err := encoding.NewEncoder(w, opts.format)
+	if err != nil {
+		return nil, err
+	}
+
+	tw, err := opts.time.writer(numbersWriter(localizeHeader(enc, opts.locale, opts.format), opts.numbers, opts.format), f)
+	if err != nil {
+		return nil, err
+	}
+	cw := &countingWriter{rowWriter: tw}
+	series := h.series
+	if opts.fill && series != nil {
+		series = fillZero(series)
+	}
+	if opts.split != nil && h.series != nil {
+		cw.Write(splitHeader)
+		if err := writeSplit(ctx, h.series, f, opts.split, cw, errs); err != nil {
+			return nil, err
+		}
+	} else if opts.layout == wideLayout && series != nil {
+		if err := writeWide(ctx, series, f, cw, errs); err != nil {
+			return nil, err
+		}
+	} else if series != nil {
+		cw.Write(h.hdr)
+		if err := writeLong(ctx, series, f, cw, errs); err != nil {
+			return nil, err
+		}
+	} else if h.totals != nil {
+		cw.Write(h.hdr)
+		if err := h.writeTotals(ctx, f, opts, cw, errs); err != nil {
+			return nil, err
+		}
+	} else {
+		cw.Write(h.hdr)
+		if err := h.h(ctx, f, cw, errs); err != nil {
+			return nil, err
+		}
+	}
+	if err := errs.err(ctx); err != nil {
+		return nil, err
+	}
+
+	res := &csvResult{truncated: meta.Truncated(), errors: errs.errors(), rows: cw.rows - 1}
+	if c, ok := enc.(encoding.Commenter); ok && opts.annotate {
+		if res.truncated {
+			if err := c.Comment(fmt.Sprintf("truncated: results hit limit %d, raise limit to see more", f.Limit)); err != nil {
+				return nil, err
+			}
+		}
+		for _, e := range res.errors {
+			if err := c.Comment("error: " + e); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// writeTotals writes the totals for the period of f, prefixed with the start
+// of the period. When opts.synthesize is set, the totals are fetched and
+// written once per day, or per week for weekly granularity.
+func (h *csvHandler) writeTotals(ctx context.Context, f *statistics.Filter, opts *options, w rowWriter, errs *partialErrors) error {
+	periods := []*statistics.Filter{f}
+	if opts.synthesize {
+		periods = f.Chunks(f.Granularity)
+	}
+
+	for _, p := range periods {
+		rows, err := h.totals(ctx, p)
+		if !errs.record(formatTime(p.From, f.Granularity), err) {
+			continue
+		}
+
+		out := make([][]string, 0, len(rows))
+		for _, row := range rows {
+			out = append(out, append([]string{formatTime(p.From, f.Granularity)}, row...))
+		}
+		if err := w.WriteAll(out); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // NewServer returns a configured *http.Server that listens on 0.0.0.0:port.
-func NewServer(client *statistics.Client, port string) *http.Server {
+func NewServer(client statistics.Service, port string, opts ...ServerOption) *http.Server {
+	o := newServerOptions(opts)
 	m := mux.NewRouter()
-	m.Handle("/labels", &csvHandler{
-		hdr: []string{"date", "count", "id", "text", "source"},
-		h: func(ctx context.Context, f *statistics.Filter, w rowWriter) error {
-			for t := f.From; t.Before(f.To); t = t.Add(24 * time.Hour) {
-				for _, source := range f.Sources {
+	m.Use(withCaller, o.limits.middleware)
+	m.HandleFunc("/version", serveVersion).Methods(http.MethodGet)
+	o.jobs.register(m)
+
+	// The routes are mounted through a router of their own, so they can be
+	// authenticated per route group while jobs and /version stay open.
+	r := mux.NewRouter()
+	registerRoutes(r, client, o.cache, o.archive.withTokens(o.routes.tokens("/archive", nil)), o)
+	m.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+		return r.Match(req, &mux.RouteMatch{})
+	}).Handler(o.routes.authenticate("", nil, r))
+
+	return newServer(m, port, o)
+}
+
+// ServerOption configures a server returned by NewServer or
+// NewMultiTenantServer.
+type ServerOption func(o *serverOptions)
+
+type serverOptions struct {
+	limits limits
+	// cache is nil unless responses are cached.
+	cache *responseCache
+	// serveStale is how long cached responses are served stale.
+	serveStale      time.Duration
+	prewarmInterval time.Duration
+	prewarmQueries  []string
+	// jobs is nil unless asynchronous jobs are enabled.
+	jobs *jobs
+	// archive is nil unless the archive routes are enabled.
+	archive *archive
+	// routes is nil unless only some routes are enabled.
+	routes *routePolicy
+	// presets is nil unless the "preset" query parameter is accepted.
+	presets *statistics.Presets
+	// defaults are the defaults of routes by path.
+	defaults map[string]RouteDefaults
+	logger   Logger
+}
+
+func newServerOptions(opts []ServerOption) *serverOptions {
+	o := &serverOptions{logger: &nopLogger{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.limits.logger = o.logger
+	if o.cache != nil {
+		o.cache.stale = o.serveStale
+		o.cache.logger = o.logger
+	}
+	if o.jobs != nil {
+		o.jobs.logger = o.logger
+	}
+	if o.archive != nil {
+		o.archive.logger = o.logger
+	}
+
+	return o
+}
+
+// registerRoutes adds every route in the registry of client enabled by the
+// route policy of o to r, served from rc if it is not nil, the archive routes
+// if ar is not nil, and /metrics-catalog describing them. Presets are expanded if o has any, then
+// the defaults of the route are applied, and requested sources are
+// validated if client can discover them.
+func registerRoutes(r *mux.Router, client statistics.Service, rc *responseCache, ar *archive, o *serverOptions) {
+	sv := newSourceValidator(client, o.logger)
+	routes := newRoutes(client, o.routes, ar, o.logger)
+	for _, rt := range routes {
+		if rt.Path == archivePath {
+			// Archived days ignore the query, so they are served as is.
+			r.Handle(rt.Path, rt.handler).Methods(http.MethodGet, http.MethodHead)
+			continue
+		}
+		if d, ok := o.defaults[rt.Path]; ok {
+			rt.Defaults = &d
+		}
+		r.Handle(rt.Path, withPreset(o.presets, withDefaults(o.defaults[rt.Path], rc.wrap(sv.wrap(o.limits.estimated(rt.estimate, rt.handler))))))
+	}
+	r.Handle("/metrics-catalog", &catalogHandler{routes: routes}).Methods(http.MethodGet)
+}
+
+// newServer returns a server for m, and starts pre-warming the cache if
+// configured until the server is shut down or replaced with
+// Reloadable.Swap. Running jobs are cancelled on shutdown.
+func newServer(m *mux.Router, port string, o *serverOptions) *http.Server {
+	// The request ID wraps the router rather than being its middleware, which
+	// does not run for the 404 and 405 responses of the router.
+	h := withRequestID(m)
+	ctx, cancel := context.WithCancel(context.Background())
+	prewarmCtx, stopPrewarm := context.WithCancel(ctx)
+	s := &http.Server{
+		Addr:        ":" + port,
+		ReadTimeout: 5 * time.Second,
+		Handler:     &serverHandler{Handler: h, stopPrewarm: stopPrewarm},
+	}
+
+	s.RegisterOnShutdown(cancel)
+	if o.jobs != nil {
+		o.jobs.ctx = ctx
+		o.jobs.h = h
+	}
+	if o.cache != nil && o.prewarmInterval > 0 && len(o.prewarmQueries) > 0 {
+		go prewarm(prewarmCtx, h, o.prewarmInterval, o.prewarmQueries, o.logger)
+	}
+
+	return s
+}
+
+// newHandlers returns the CSV handlers of the metrics of the registry, keyed
+// by metric name. The name doubles as the route path and as the file name in
+// zip exports.
+func newHandlers(client statistics.Service) map[string]*csvHandler {
+	ret := make(map[string]*csvHandler)
+	for _, m := range metrics.All() {
+		ret[m.Name] = newMetricHandler(client, m)
+	}
+
+	return ret
+}
+
+// newMetricHandler returns the CSV handler of m. Labels and pages are
+// fetched per day, and feedback and handovers as totals of the period. Other
+// metrics are fetched per source, as a series if they are counts.
+func newMetricHandler(client statistics.Service, m *metrics.Metric) *csvHandler {
+	switch m {
+	case metrics.Labels:
+		return &csvHandler{
+			hdr:      headerOf(m, "source"),
+			about:    "Triggered chat labels per day and source.",
+			estimate: perDayAndSource,
+			h: func(ctx context.Context, f *statistics.Filter, w rowWriter, errs *partialErrors) error {
+				daily := *f
+				daily.Granularity = statistics.Day
+				for _, chunk := range statistics.ChatLabelsByChunk(ctx, client, &daily, true) {
+					source := chunk.Filter.Sources[0].String()
+					if !errs.record(formatTime(chunk.Filter.From, f.Granularity)+" "+source, chunk.Err) {
+						continue
+					}
+
+					out := make([][]string, 0, f.Limit)
+					for _, label := range chunk.Labels {
+						out = append(out, []string{formatTime(chunk.Filter.From, f.Granularity), label.ID, strconv.Itoa(label.Count), label.Text, source})
+					}
+					if err := w.WriteAll(out); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}
+	case metrics.Pages:
+		return &csvHandler{
+			hdr:      headerOf(m),
+			about:    "Page statistics per day.",
+			estimate: perDay,
+			h: func(ctx context.Context, f *statistics.Filter, w rowWriter, errs *partialErrors) error {
+				for t := f.From; t.Before(f.To); t = t.Add(24 * time.Hour) {
 					temp := *f
 					temp.From = t
 					temp.To = t.Add(24 * time.Hour)
-					temp.Sources = []string{source}
-					labels, err := client.ChatLabels(ctx, &temp)
-					if err != nil {
-						return err
+					pages, err := client.PageStatistics(ctx, &temp)
+					if !errs.record(formatTime(t, f.Granularity), err) {
+						continue
 					}
-
 					out := make([][]string, 0, f.Limit)
-					for _, label := range labels {
-						out = append(out, []string{formatTime(temp.From, f.Granularity), strconv.Itoa(label.Count), label.ID, label.Text, source})
+					for _, page := range pages {
+						out = append(out, []string{formatTime(temp.From, f.Granularity), page.Host, page.Path, strconv.Itoa(page.Sessions), strconv.Itoa(page.Messages)})
 					}
 					if err := w.WriteAll(out); err != nil {
 						return err
 					}
 				}
-			}
-			return nil
-		},
-	})
-	m.Handle("/messages", &csvHandler{
-		hdr: []string{"date", "count", "source"},
-		h: func(ctx context.Context, f *statistics.Filter, w rowWriter) error {
-			out := make([][]string, 0, f.Limit)
-			for _, source := range f.Sources {
-				temp := *f
-				temp.Sources = []string{source}
-				messages, err := client.UserMessages(ctx, &temp)
-
+				return nil
+			},
+		}
+	case metrics.Feedback:
+		return &csvHandler{
+			hdr:      headerOf(m, "label"),
+			about:    "Feedback ratings for the period (totals only), with their label in \"lang\".",
+			estimate: perPeriodIfSynthesized,
+			totals: func(ctx context.Context, f *statistics.Filter) ([][]string, error) {
+				feedback, err := client.AggregatedFeedback(ctx, f)
 				if err != nil {
-					return err
+					return nil, err
 				}
 
-				for _, msg := range messages {
-					out = append(out, []string{formatTime(msg.Date.Time, f.Granularity), strconv.Itoa(msg.Count), source})
+				locale := localeFromContext(ctx)
+				out := make([][]string, 0, len(feedback.Binary)+len(feedback.Emojis))
+				for _, r := range feedback.Binary {
+					out = append(out, []string{"binary", strconv.Itoa(r.Rating), strconv.Itoa(r.Count), formatFloat(r.Ratio), derive.BinaryLabel(r.Rating, locale)})
+				}
+				for _, r := range feedback.Emojis {
+					out = append(out, []string{"emojis", strconv.Itoa(r.Rating), strconv.Itoa(r.Count), formatFloat(r.Ratio), derive.EmojiLabel(r.Rating, locale)})
+				}
+				return out, nil
+			},
+		}
+	case metrics.Handovers:
+		return &csvHandler{
+			hdr:      headerOf(m),
+			about:    "Handover requests, started and ended handovers for the period (totals only).",
+			estimate: perPeriodIfSynthesized,
+			totals: func(ctx context.Context, f *statistics.Filter) ([][]string, error) {
+				h, err := client.HandoversTotal(ctx, f)
+				if err != nil {
+					return nil, err
 				}
-			}
 
-			return w.WriteAll(out)
-		},
-	})
-	m.Handle("/pages", &csvHandler{
-		hdr: []string{"date", "host", "path", "sessions", "messages"},
-		h: func(ctx context.Context, f *statistics.Filter, w rowWriter) error {
-			for t := f.From; t.Before(f.To); t = t.Add(24 * time.Hour) {
+				return [][]string{{strconv.Itoa(h.Requests), strconv.Itoa(h.RequestsWhileClosed), strconv.Itoa(h.Started), strconv.Itoa(h.Ended)}}, nil
+			},
+		}
+	}
+
+	if m.Counted() && len(m.Columns) == 2 {
+		h := newSeriesHandler(func(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+			return m.Counts(ctx, client, f)
+		})
+		h.hdr = headerOf(m, "source")
+		h.about = m.Title + " per source."
+		return h
+	}
+
+	return &csvHandler{
+		hdr:      headerOf(m, "source"),
+		about:    m.Title + " per source.",
+		estimate: perSource,
+		h: func(ctx context.Context, f *statistics.Filter, w rowWriter, errs *partialErrors) error {
+			for _, source := range f.Sources {
 				temp := *f
-				temp.From = t
-				temp.To = t.Add(24 * time.Hour)
-				pages, err := client.PageStatistics(ctx, &temp)
-				fmt.Println(pages[0].Host)
-				if err != nil {
-					return err
+				temp.Sources = []statistics.Source{source}
+				rows, err := m.Fetch(ctx, client, &temp)
+				if !errs.record(source.String(), err) {
+					continue
 				}
-				out := make([][]string, 0, f.Limit)
-				for _, page := range pages {
-					out = append(out, []string{formatTime(temp.From, f.Granularity), page.Host, page.Path, strconv.Itoa(page.Sessions), strconv.Itoa(page.Messages)})
+
+				out := make([][]string, 0, len(rows))
+				for _, row := range rows {
+					out = append(out, append(row, source.String()))
 				}
 				if err := w.WriteAll(out); err != nil {
 					return err
@@ -126,34 +485,72 @@ func NewServer(client *statistics.Client, port string) *http.Server {
 			}
 			return nil
 		},
-	})
-	m.Handle("/sessions", &csvHandler{
-		hdr: []string{"date", "count", "source"},
-		h: func(ctx context.Context, f *statistics.Filter, w rowWriter) error {
-			out := make([][]string, 0, f.Limit)
-			for _, source := range f.Sources {
-				temp := *f
-				temp.Sources = []string{source}
-				sessions, err := client.ChatSessions(ctx, &temp)
-				if err != nil {
-					return err
-				}
+	}
+}
+
+// headerOf returns the header of the CSV of m: the date, the other columns
+// of m, and extra.
+func headerOf(m *metrics.Metric, extra ...string) []string {
+	ret := []string{"date"}
+	for _, name := range m.Header() {
+		if name != "date" {
+			ret = append(ret, name)
+		}
+	}
+
+	return append(ret, extra...)
+}
+
+// pageSeriesHandler returns a handler writing one row per period and page of
+// the top pages of the whole period.
+func pageSeriesHandler(client statistics.Service) *csvHandler {
+	return &csvHandler{
+		hdr: []string{"date", "host", "path", "sessions", "messages"},
+		h: func(ctx context.Context, f *statistics.Filter, w rowWriter, errs *partialErrors) error {
+			series, err := statistics.PageSeriesOf(ctx, client, f)
+			if err != nil {
+				return err
+			}
 
-				for _, session := range sessions {
-					out = append(out, []string{formatTime(session.Date.Time, f.Granularity), strconv.Itoa(session.Count), source})
+			var out [][]string
+			for _, s := range series {
+				for _, p := range s.Points {
+					out = append(out, []string{formatTime(p.Date, f.Granularity), s.Host, s.Path, strconv.Itoa(p.Sessions), strconv.Itoa(p.Messages)})
 				}
 			}
 			return w.WriteAll(out)
 		},
-	})
+	}
+}
 
-	s := &http.Server{
-		Addr:        ":" + port,
-		ReadTimeout: 5 * time.Second,
-		Handler:     m,
+// newSeriesHandler returns a handler that fetches the series once per source
+// and writes one row per date and source.
+func newSeriesHandler(fetch seriesFunc) *csvHandler {
+	return &csvHandler{
+		hdr:      []string{"date", "count", "source"},
+		series:   fetch,
+		estimate: perSource,
 	}
+}
 
-	return s
+// writeLong fetches the series once per source and writes one row per date
+// and source.
+func writeLong(ctx context.Context, fetch seriesFunc, f *statistics.Filter, w rowWriter, errs *partialErrors) error {
+	out := make([][]string, 0, f.Limit)
+	for _, source := range f.Sources {
+		temp := *f
+		temp.Sources = []statistics.Source{source}
+		series, err := fetch(ctx, &temp)
+		if !errs.record(source.String(), err) {
+			continue
+		}
+
+		for _, c := range series {
+			out = append(out, []string{formatTime(c.Date.Time, f.Granularity), strconv.Itoa(c.Count), source.String()})
+		}
+	}
+
+	return w.WriteAll(out)
 }
 
 func formatTime(t time.Time, g statistics.Granularity) string {
@@ -164,7 +561,12 @@ func formatTime(t time.Time, g statistics.Granularity) string {
 	return t.Format("2006-01-02")
 }
 
+// respondErr writes msg as the response body. The request ID is included so
+// users can refer to it when reporting failed requests.
 func respondErr(w http.ResponseWriter, msg string, code int) {
+	if id := w.Header().Get("X-Request-ID"); id != "" {
+		msg = fmt.Sprintf("%s (request id: %s)", msg, id)
+	}
 	http.Error(w, msg, code)
 }
 
@@ -178,7 +580,16 @@ func filterFromRequest(r *http.Request) (*statistics.Filter, error) {
 		From:        time.Now().Add(-1 * 24 * time.Hour),
 		Limit:       10,
 		Granularity: statistics.Day,
-		Sources:     []string{"facebook", "web"},
+		Sources:     []statistics.Source{statistics.Facebook, statistics.Web},
+	}
+
+	if d := r.Form.Get("days"); d != "" {
+		days, err := strconv.Atoi(d)
+		if err != nil || days < 1 {
+			return nil, fmt.Errorf("parsing query: \"days\": want a positive number of days, got %q", d)
+		}
+		f.To = time.Now().Truncate(24 * time.Hour)
+		f.From = f.To.Add(-time.Duration(days) * 24 * time.Hour)
 	}
 
 	from := r.Form.Get("from")
@@ -217,15 +628,28 @@ func filterFromRequest(r *http.Request) (*statistics.Filter, error) {
 		switch granularity {
 		case "hour":
 			f.Granularity = statistics.Hour
+		case "week":
+			f.Granularity = statistics.Week
 		}
 	}
 
-	if sources, ok := r.Form["sources"]; ok {
+	if names, ok := r.Form["sources"]; ok {
+		sources, err := statistics.ParseSources(names)
+		if err != nil {
+			return nil, fmt.Errorf("parsing query: \"sources\": %w", err)
+		}
 		f.Sources = sources
 	}
 
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("parsing query: %w", err)
+	}
+
 	return f, nil
 }
 
 // ErrServerClosed is aliased to avoid having to import net/http in parent.
 var ErrServerClosed = http.ErrServerClosed
+
+// Server is aliased for the same reason.
+type Server = http.Server
@@ -0,0 +1,261 @@
+package http_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/atb-as/kindly/metrics"
+)
+
+func TestCSVHandler(t *testing.T) {
+	ts := newTestServer(t, failing())
+
+	resp, err := http.Get(ts.URL + "/sessions?from=2021-03-01&to=2021-03-02")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: err=%v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, http.StatusOK, b)
+	}
+	if got, want := string(b), "date,count,source\n2021-03-01,3,facebook\n2021-03-01,3,web\n"; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	if got := resp.Header.Get("Trailer"); got != "" {
+		t.Errorf("got Trailer %q, want the result headers sent as headers", got)
+	}
+	if got := resp.Header.Get("X-Partial-Errors"); got != "" {
+		t.Errorf("got X-Partial-Errors %q, want none", got)
+	}
+	if got := resp.Header.Get("X-Upstream-Calls"); got != "2" {
+		t.Errorf("got X-Upstream-Calls %q, want 2", got)
+	}
+}
+
+func TestCSVHandler_PartialErrors(t *testing.T) {
+	ts := newTestServer(t, func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get("sources[]") == "web" {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(sessionsBody))}, nil
+	})
+
+	resp, err := http.Get(ts.URL + "/sessions?from=2021-03-01&to=2021-03-02")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: err=%v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, http.StatusOK, b)
+	}
+	if got, want := string(b), "date,count,source\n2021-03-01,3,facebook\n"; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	if got := resp.Header.Get("X-Partial-Errors"); !strings.HasPrefix(got, "web: ") {
+		t.Errorf("got X-Partial-Errors %q, want the web error", got)
+	}
+}
+
+func TestCSVHandler_Streamed(t *testing.T) {
+	body := longSessionsBody(2000)
+	ts := newTestServer(t, func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get("sources[]") == "web" {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	resp, err := http.Get(ts.URL + "/sessions?from=2021-03-01&to=2021-03-02")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: err=%v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, http.StatusOK, b)
+	}
+	if got, want := strings.Count(string(b), "\n"), 2001; got != want {
+		t.Errorf("got %d lines, want %d", got, want)
+	}
+	if got := resp.Header.Get("X-Partial-Errors"); got != "" {
+		t.Errorf("got X-Partial-Errors header %q, want it as a trailer", got)
+	}
+	if got := resp.Trailer.Get("X-Partial-Errors"); !strings.HasPrefix(got, "web: ") {
+		t.Errorf("got X-Partial-Errors trailer %q, want the web error", got)
+	}
+	if got := resp.Trailer.Get("X-Upstream-Calls"); got != "2" {
+		t.Errorf("got X-Upstream-Calls trailer %q, want 2", got)
+	}
+}
+
+func TestCSVHandler_AllFailed(t *testing.T) {
+	ts := newTestServer(t, failing("sessions/chats"))
+
+	resp, err := http.Get(ts.URL + "/sessions?from=2021-03-01&to=2021-03-02")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("got status %d, want %d: %s", resp.StatusCode, http.StatusBadGateway, b)
+	}
+}
+
+func TestServer_RequestID(t *testing.T) {
+	ts := newTestServer(t, failing())
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/sessions?from=2021-03-01&to=2021-03-02", http.StatusOK},
+		{http.MethodGet, "/unknown", http.StatusNotFound},
+		{http.MethodPost, "/version", http.StatusMethodNotAllowed},
+	} {
+		req, err := http.NewRequest(tc.method, ts.URL+tc.path, nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest() err=%v", err)
+		}
+		req.Header.Set("X-Request-ID", "test-id")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s err=%v", tc.method, tc.path, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.path, resp.StatusCode, tc.status)
+		}
+		if got := resp.Header.Get("X-Request-ID"); got != "test-id" {
+			t.Errorf("%s %s: got X-Request-ID %q, want test-id", tc.method, tc.path, got)
+		}
+	}
+}
+
+func TestSources_All(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	ts := newTestServer(t, func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Path, "/sources") {
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"data":["web","whatsapp"]}`))}, nil
+		}
+		mu.Lock()
+		requested = append(requested, r.URL.Query().Get("sources[]"))
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(sessionsBody))}, nil
+	})
+
+	resp, err := http.Get(ts.URL + "/sessions?from=2021-03-01&to=2021-03-02&sources=all")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, http.StatusOK, b)
+	}
+	sort.Strings(requested)
+	if got := strings.Join(requested, ","); got != "web,whatsapp" {
+		t.Errorf("got upstream calls for sources %s, want web,whatsapp", got)
+	}
+
+	resp, err = http.Get(ts.URL + "/sessions?from=2021-03-01&to=2021-03-02&sources=Whatsapp")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d for an undiscovered source, want %d", resp.StatusCode, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestCatalog_Metrics(t *testing.T) {
+	ts := newTestServer(t, failing())
+
+	resp, err := http.Get(ts.URL + "/metrics-catalog")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	var catalog struct {
+		Routes []struct {
+			Path    string `json:"path"`
+			Metric  string `json:"metric"`
+			Columns []struct {
+				Name string `json:"name"`
+			} `json:"columns"`
+		} `json:"routes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		t.Fatalf("decoding catalog: err=%v", err)
+	}
+
+	served := make(map[string]bool)
+	for _, rt := range catalog.Routes {
+		if rt.Metric == "" {
+			continue
+		}
+		m := metrics.MustLookup(rt.Metric)
+		if rt.Path != "/"+m.Name {
+			t.Errorf("got metric %s served at %s", m.Name, rt.Path)
+		}
+		for _, c := range m.Columns {
+			found := false
+			for _, rc := range rt.Columns {
+				found = found || rc.Name == c.Name
+			}
+			if !found {
+				t.Errorf("got route %s without column %s of its metric", rt.Path, c.Name)
+			}
+		}
+		served[m.Name] = true
+	}
+	for _, name := range metrics.Names() {
+		if !served[name] {
+			t.Errorf("got no route for metric %s", name)
+		}
+	}
+}
+
+func TestCSVHandler_FallbackRate(t *testing.T) {
+	ts := newTestServer(t, func(r *http.Request) (*http.Response, error) {
+		body := `{"data":[{"date":"2021-03-01T00:00:00.000000","count":3,"rate":0.25}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	resp, err := http.Get(ts.URL + "/fallback_rate?from=2021-03-01&to=2021-03-02")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, http.StatusOK, b)
+	}
+	if got, want := string(b), "date,count,rate,source\n2021-03-01,3,0.25,facebook\n2021-03-01,3,0.25,web\n"; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/metrics"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// shareHeader is the header row of the source shares.
+var shareHeader = []string{"date", "source", "count", "share", "index"}
+
+// shareHandler serves each source's share of a counted metric, such as the
+// sessions, per period, with the trend of each source indexed to its first period.
+type shareHandler struct {
+	client statistics.Service
+	logger Logger
+}
+
+// ServeHTTP implements http.Handler.
+func (h *shareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := filterFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := r.Form.Get("metric")
+	if name == "" {
+		name = metrics.Sessions.Name
+	}
+	m, err := metrics.Lookup(name)
+	if err != nil || !m.Counted() {
+		respondErr(w, fmt.Sprintf("parsing query: \"metric\": unknown metric %q, want %s", name, strings.Join(metrics.Counted(), ", ")), http.StatusBadRequest)
+		return
+	}
+	fetch := func(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+		return m.Counts(ctx, h.client, f)
+	}
+
+	// Sage has no monthly series, so months are summed from days.
+	var bucket derive.Bucket
+	if r.Form.Get("granularity") == "month" {
+		f.Granularity = statistics.Day
+		bucket = derive.Monthly
+	}
+
+	format, err := encoding.ParseFormat(r.Form.Get("format"))
+	if err != nil {
+		respondErr(w, fmt.Sprintf("parsing query: \"format\": unknown format %q", r.Form.Get("format")), http.StatusBadRequest)
+		return
+	}
+	timeOpts, err := timeOptionsFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := localeFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	numbers, err := numbersFromRequest(r, locale)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	periods, err := derive.SourceShares(r.Context(), fetch, f, bucket)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		respondUpstreamErr(r.Context(), w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	enc, err := encoding.NewEncoder(w, format)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw, err := timeOpts.writer(numbersWriter(localizeHeader(enc, locale, format), numbers, format), f)
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+		return
+	}
+	rw.Write(shareHeader)
+	for _, p := range periods {
+		for _, s := range p.Sources {
+			rw.Write([]string{
+				formatTime(p.From, f.Granularity),
+				s.Source.String(),
+				strconv.Itoa(s.Count),
+				formatFloat(s.Share),
+				formatFloat(s.Index),
+			})
+		}
+	}
+	if err := enc.Close(); err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+	}
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// sourcesRefresh is how long the discovered sources of a bot are used before
+// they are discovered again.
+const sourcesRefresh = time.Hour
+
+// sourceLister is implemented by statistics services that can discover the
+// sources of the bot, such as *statistics.Client.
+type sourceLister interface {
+	Sources(ctx context.Context, f *statistics.Filter) ([]statistics.Source, error)
+}
+
+// sourceValidator checks the "sources" query parameter against the sources
+// of the bot, and expands "sources=all" to all of them.
+type sourceValidator struct {
+	client sourceLister
+	logger Logger
+
+	mu      sync.Mutex
+	sources []statistics.Source
+	fetched time.Time
+}
+
+// newSourceValidator returns a validator for the sources of client, or nil if
+// client can not discover them.
+func newSourceValidator(client statistics.Service, logger Logger) *sourceValidator {
+	l, ok := client.(sourceLister)
+	if !ok {
+		return nil
+	}
+
+	return &sourceValidator{client: l, logger: logger}
+}
+
+// wrap validates the sources of requests before passing them to next. A
+// request with unknown sources fails with 422 listing the known ones. If the
+// sources can not be discovered, requests are passed on unvalidated, unless
+// they ask for all sources.
+func (v *sourceValidator) wrap(next http.Handler) http.Handler {
+	if v == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			respondErr(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requested, ok := r.Form["sources"]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		discovered, err := v.list(r.Context())
+		if err != nil {
+			v.logger.Log("msg", "discovering sources failed", "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+			if containsSource(requested, "all") {
+				respondUpstreamErr(r.Context(), w, fmt.Errorf("discovering sources: %w", err))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		known := statistics.SourceStrings(discovered)
+		sources := make([]string, 0, len(requested))
+		for _, s := range requested {
+			switch {
+			case s == "all":
+				sources = append(sources, known...)
+			case containsSource(known, s):
+				sources = append(sources, s)
+			default:
+				respondErr(w, fmt.Sprintf("parsing query: \"sources\": unknown source %q, want one of all, %s", s, strings.Join(known, ", ")), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		r.Form["sources"] = dedupeSources(sources)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// list returns the sources of the bot, discovering them if they are missing
+// or stale. A failed refresh keeps the previous sources until the next
+// refresh is due.
+func (v *sourceValidator) list(ctx context.Context) ([]statistics.Source, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.sources != nil && time.Since(v.fetched) < sourcesRefresh {
+		return v.sources, nil
+	}
+
+	sources, err := v.client.Sources(ctx, nil)
+	if err != nil {
+		if v.sources != nil {
+			v.fetched = time.Now()
+			return v.sources, nil
+		}
+		return nil, err
+	}
+	v.sources = sources
+	v.fetched = time.Now()
+
+	return sources, nil
+}
+
+func containsSource(sources []string, source string) bool {
+	for _, s := range sources {
+		if s == source {
+			return true
+		}
+	}
+
+	return false
+}
+
+func dedupeSources(sources []string) []string {
+	ret := make([]string, 0, len(sources))
+	for _, s := range sources {
+		if !containsSource(ret, s) {
+			ret = append(ret, s)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/atb-as/kindly/statistics"
+	"github.com/gorilla/mux"
+)
+
+// Tenant is a team served by a shared proxy, with its own routes, bots and
+// access tokens.
+type Tenant struct {
+	Name string
+	// Host and PathPrefix select the requests served for the tenant. At
+	// least one of them must be set. Routes are served below PathPrefix.
+	Host       string
+	PathPrefix string
+	// Tokens are the access tokens accepted for the tenant. Requests are not
+	// authenticated if there are none.
+	Tokens []string
+	// Clients are the statistics clients for the tenant's bots, by bot ID.
+	Clients map[string]statistics.Service
+}
+
+// NewMultiTenantServer returns a configured *http.Server that listens on
+// 0.0.0.0:port and serves the same routes as NewServer for every tenant. The
+// bot is selected with the "bot" query parameter, which may be omitted for
+// tenants with a single bot.
+func NewMultiTenantServer(tenants []*Tenant, port string, opts ...ServerOption) (*http.Server, error) {
+	o := newServerOptions(opts)
+	m := mux.NewRouter()
+	m.Use(withCaller, o.limits.middleware)
+	m.HandleFunc("/version", serveVersion).Methods(http.MethodGet)
+	o.jobs.register(m)
+
+	for _, t := range tenants {
+		if t.Host == "" && t.PathPrefix == "" {
+			return nil, fmt.Errorf("tenant %q: host or path prefix is required", t.Name)
+		}
+		if len(t.Clients) == 0 {
+			return nil, fmt.Errorf("tenant %q: no bots configured", t.Name)
+		}
+
+		route := m.NewRoute()
+		if t.Host != "" {
+			route = route.Host(t.Host)
+		}
+		prefix := strings.TrimSuffix(t.PathPrefix, "/")
+		if prefix != "" {
+			route = route.PathPrefix(prefix + "/")
+		}
+
+		sel := &botSelector{bots: make(map[string]http.Handler)}
+		for botID, client := range t.Clients {
+			r := mux.NewRouter()
+			ns := t.Name + "/" + botID
+			registerRoutes(r, client, o.cache.withNamespace(ns), o.archive.withNamespace(ns).withTokens(o.routes.tokens("/archive", t.Tokens)), o)
+			sel.bots[botID] = http.StripPrefix(prefix, r)
+		}
+
+		route.Handler(o.routes.authenticate(prefix, t.Tokens, sel))
+	}
+
+	return newServer(m, port, o), nil
+}
+
+// botSelector dispatches requests to the routes of the bot selected by the
+// "bot" query parameter.
+type botSelector struct {
+	bots map[string]http.Handler
+}
+
+// ServeHTTP implements http.Handler.
+func (s *botSelector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	botID := r.URL.Query().Get("bot")
+	if botID == "" && len(s.bots) == 1 {
+		for id := range s.bots {
+			botID = id
+		}
+	}
+
+	if botID == "" {
+		respondErr(w, "parsing query: \"bot\" is required", http.StatusBadRequest)
+		return
+	}
+	h, ok := s.bots[botID]
+	if !ok {
+		respondErr(w, fmt.Sprintf("bot %q is not allowed", botID), http.StatusForbidden)
+		return
+	}
+
+	h.ServeHTTP(w, r)
+}
+
+// skipForPrewarm serves pre-warm requests, which are made in-process, with
+// unauthenticated and all other requests with authenticated.
+func skipForPrewarm(authenticated, unauthenticated http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPrewarm(r.Context()) {
+			unauthenticated.ServeHTTP(w, r)
+			return
+		}
+		authenticated.ServeHTTP(w, r)
+	})
+}
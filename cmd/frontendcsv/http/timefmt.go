@@ -0,0 +1,127 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// timeOptions select how the date columns of a table are written.
+type timeOptions struct {
+	// iso8601 writes dates as RFC 3339 timestamps with the offset of the
+	// time zone of the filter, instead of bare dates.
+	iso8601 bool
+	// tz appends a column with the name of the time zone of the dates.
+	tz bool
+}
+
+func timeOptionsFromRequest(r *http.Request) (timeOptions, error) {
+	var opts timeOptions
+	switch t := r.Form.Get("timefmt"); t {
+	case "", "date":
+	case "iso8601":
+		opts.iso8601 = true
+	default:
+		return opts, fmt.Errorf("parsing query: \"timefmt\": unknown time format %q, want date or iso8601", t)
+	}
+
+	if tz := r.Form.Get("tz"); tz != "" {
+		v, err := strconv.ParseBool(tz)
+		if err != nil {
+			return opts, fmt.Errorf("parsing query: \"tz\": %w", err)
+		}
+		opts.tz = v
+	}
+
+	return opts, nil
+}
+
+// writer returns w writing the date columns of the rows for f as selected by
+// o. The date columns are found by name in the first row written, the
+// header.
+func (o timeOptions) writer(w rowWriter, f *statistics.Filter) (rowWriter, error) {
+	if !o.iso8601 && !o.tz {
+		return w, nil
+	}
+
+	loc, err := f.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	return &timeWriter{rowWriter: w, opts: o, loc: loc}, nil
+}
+
+// timeWriter rewrites the date columns of rows written to a rowWriter.
+type timeWriter struct {
+	rowWriter
+	opts timeOptions
+	loc  *time.Location
+	// cols are the indexes of the date columns, set once the header is
+	// written.
+	cols []int
+	hdr  bool
+}
+
+// Write implements rowWriter.
+func (w *timeWriter) Write(row []string) error {
+	return w.rowWriter.Write(w.rewrite(row))
+}
+
+// WriteAll implements rowWriter.
+func (w *timeWriter) WriteAll(rows [][]string) error {
+	out := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, w.rewrite(row))
+	}
+
+	return w.rowWriter.WriteAll(out)
+}
+
+func (w *timeWriter) rewrite(row []string) []string {
+	if !w.hdr {
+		w.hdr = true
+		for i, name := range row {
+			if columnType(name) == "date" {
+				w.cols = append(w.cols, i)
+			}
+		}
+		if w.opts.tz && len(w.cols) > 0 {
+			return append(append([]string{}, row...), "tz")
+		}
+		return row
+	}
+	if len(w.cols) == 0 {
+		return row
+	}
+
+	out := append(make([]string, 0, len(row)+1), row...)
+	if w.opts.iso8601 {
+		for _, i := range w.cols {
+			if i < len(out) {
+				out[i] = w.iso8601(out[i])
+			}
+		}
+	}
+	if w.opts.tz {
+		out = append(out, w.loc.String())
+	}
+
+	return out
+}
+
+// iso8601 returns the date or time v, as written by formatTime, as an RFC
+// 3339 timestamp in the time zone of w. Other values are returned as they
+// are.
+func (w *timeWriter) iso8601(v string) string {
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, v, w.loc); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+
+	return v
+}
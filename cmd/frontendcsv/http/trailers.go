@@ -0,0 +1,106 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// resultTrailers are the headers describing the result of a response, which
+// are only known once its body is written. Responses that are streamed before
+// that declare them as trailers; held back responses send them as headers.
+const resultTrailers = "X-Truncated, X-Partial-Errors, X-Upstream-Calls"
+
+// declareResultTrailers declares the result headers as trailers of w. It must
+// be called before the body is written.
+func declareResultTrailers(w http.ResponseWriter) {
+	w.Header().Set("Trailer", resultTrailers)
+}
+
+// setResultHeaders sets the headers describing res. They must be set before
+// a held back response is flushed; for a streamed response they are sent as
+// trailers. X-Upstream-Calls is set by the callsWriter.
+func setResultHeaders(w http.ResponseWriter, res *csvResult) {
+	if res.truncated {
+		w.Header().Set("X-Truncated", "true")
+	}
+	if len(res.errors) > 0 {
+		w.Header().Set("X-Partial-Errors", partialErrorsHeader(res.errors))
+	}
+}
+
+// trailerDeclared reports whether key is declared as a trailer in h.
+func trailerDeclared(h http.Header, key string) bool {
+	for _, v := range h.Values("Trailer") {
+		for _, k := range strings.Split(v, ",") {
+			if http.CanonicalHeaderKey(strings.TrimSpace(k)) == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// withoutTrailers returns h with its trailers sent as headers, for complete
+// responses that are stored, where they are known up front.
+func withoutTrailers(h http.Header) http.Header {
+	h.Del("Trailer")
+	return h
+}
+
+// streamThreshold is the size up to which output is held back, so a response
+// failing before it wrote more than that still responds with an error status
+// and a complete one is sent with its result headers.
+const streamThreshold = 32 << 10
+
+// streamWriter holds back output until it exceeds streamThreshold or is
+// flushed, and passes it through after that. start is called once, before
+// the output is passed on, and returns where to; streaming is false if the
+// output was complete when it was flushed.
+type streamWriter struct {
+	start func(streaming bool) (io.Writer, error)
+	w     io.Writer
+	buf   bytes.Buffer
+}
+
+func (s *streamWriter) Write(b []byte) (int, error) {
+	if s.w != nil {
+		return s.w.Write(b)
+	}
+
+	s.buf.Write(b)
+	if s.buf.Len() > streamThreshold {
+		if err := s.begin(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// started reports whether output was passed on.
+func (s *streamWriter) started() bool {
+	return s.w != nil
+}
+
+// flush passes on the output held back, if it was not passed on yet.
+func (s *streamWriter) flush() error {
+	if s.w != nil {
+		return nil
+	}
+
+	return s.begin(false)
+}
+
+func (s *streamWriter) begin(streaming bool) error {
+	w, err := s.start(streaming)
+	if err != nil {
+		return err
+	}
+	s.w = w
+	_, err = s.buf.WriteTo(w)
+
+	return err
+}
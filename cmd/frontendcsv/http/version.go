@@ -0,0 +1,15 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/atb-as/kindly"
+)
+
+// serveVersion responds with the build info of the server, so deployments
+// can be told apart. It needs no access token.
+func serveVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(kindly.Build())
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// writeWide fetches the series once per source and writes it with one row
+// per date, one column per source and a trailing total column. The columns
+// of sources that could not be fetched are left empty, and the total only
+// covers the sources that were.
+func writeWide(ctx context.Context, fetch seriesFunc, f *statistics.Filter, w rowWriter, errs *partialErrors) error {
+	counts := make(map[time.Time][]int)
+	failed := make([]bool, len(f.Sources))
+	for i, source := range f.Sources {
+		temp := *f
+		temp.Sources = []statistics.Source{source}
+		series, err := fetch(ctx, &temp)
+		if !errs.record(source.String(), err) {
+			failed[i] = true
+			continue
+		}
+
+		for _, c := range series {
+			row, ok := counts[c.Date.Time]
+			if !ok {
+				row = make([]int, len(f.Sources))
+				counts[c.Date.Time] = row
+			}
+			row[i] += c.Count
+		}
+	}
+
+	dates := make([]time.Time, 0, len(counts))
+	for date := range counts {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+
+	hdr := make([]string, 0, len(f.Sources)+2)
+	hdr = append(hdr, "date")
+	hdr = append(hdr, statistics.SourceStrings(f.Sources)...)
+	hdr = append(hdr, "total")
+	if err := w.Write(hdr); err != nil {
+		return err
+	}
+
+	for _, date := range dates {
+		out := make([]string, 0, len(hdr))
+		out = append(out, formatTime(date, f.Granularity))
+		total := 0
+		for i, count := range counts[date] {
+			if failed[i] {
+				out = append(out, "")
+				continue
+			}
+			out = append(out, strconv.Itoa(count))
+			total += count
+		}
+		out = append(out, strconv.Itoa(total))
+		if err := w.Write(out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package http
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/atb-as/kindly/manifest"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// zipHandler serves a zip archive containing one file per requested metric,
+// all fetched with the same filter and in the same format, and a
+// manifest.json listing them with their row counts and checksums.
+type zipHandler struct {
+	handlers map[string]*csvHandler
+	logger   Logger
+}
+
+// ServeHTTP implements http.Handler.
+func (h *zipHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := filterFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := optionsFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metrics, err := h.metricsFromRequest(r)
+	if err != nil {
+		respondErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := manifest.FilterParameters(f)
+	params["metrics"] = strings.Join(metrics, ",")
+	params["format"] = string(opts.format)
+	m := manifest.New("frontendcsv export.zip", params)
+
+	// The archive is held back like a CSV response: a small one is sent
+	// complete with the headers describing the result, a larger one is
+	// streamed with them as trailers. Metrics are rendered one after another,
+	// each straight into its file, so of the archive only the output held
+	// back is in memory. A metric failing before its file is started is left
+	// out and reported as a partial error; the request only fails if all do.
+	sw := &streamWriter{start: func(streaming bool) (io.Writer, error) {
+		w.Header().Set("Content-Type", "application/zip")
+		if opts.synthesize {
+			w.Header().Set("X-Synthesized", "true")
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(f)))
+		if streaming {
+			declareResultTrailers(w)
+		}
+		w.WriteHeader(http.StatusOK)
+		return w, nil
+	}}
+	zw := zip.NewWriter(sw)
+
+	tracker := statistics.ProgressFromContext(r.Context())
+	if tracker != nil {
+		tracker.AddTotal(len(metrics))
+	}
+	var failed []error
+	results := make([]*csvResult, len(metrics))
+	for i, metric := range metrics {
+		res, err := h.writeFile(r.Context(), f, opts, metric, zw, m)
+		if tracker != nil {
+			rows := 0
+			if res != nil {
+				rows = res.rows
+			}
+			tracker.ChunkDone(rows)
+		}
+		if err != nil {
+			h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+			if r.Context().Err() != nil {
+				return
+			}
+			failed = append(failed, err)
+			continue
+		}
+		results[i] = res
+	}
+	if len(metrics) > 0 && len(failed) == len(metrics) && !sw.started() {
+		respondUpstreamErr(r.Context(), w, failed[0])
+		return
+	}
+
+	// The manifest goes last, so its presence signals a complete archive.
+	b, err := m.Marshal()
+	var mw io.Writer
+	if err == nil {
+		mw, err = zw.Create("manifest.json")
+	}
+	if err == nil {
+		_, err = mw.Write(b)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "file", "manifest.json", "err", err)
+		return
+	}
+
+	res := combineResults(metrics, results)
+	for _, err := range failed {
+		res.errors = append(res.errors, err.Error())
+	}
+	if len(res.errors) > 0 {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "partial_errors", partialErrorsHeader(res.errors))
+	}
+	setResultHeaders(w, res)
+	if err := sw.flush(); err != nil {
+		h.logger.Log("path", r.URL.Path, "request_id", statistics.RequestIDFromContext(r.Context()), "err", err)
+	}
+}
+
+// writeFile renders the table of metric into its file in zw, and records
+// the file in m. The file is only created once the table exceeds
+// streamThreshold or is complete, so a metric failing before that leaves no
+// file behind; one failing later leaves an incomplete file, which is not
+// recorded in m. Metrics that do not support the requested layout are
+// rendered in the long layout, and synthesis only applies to totals-only
+// metrics.
+func (h *zipHandler) writeFile(ctx context.Context, f *statistics.Filter, opts *options, metric string, zw *zip.Writer, m *manifest.Manifest) (*csvResult, error) {
+	name := metric + opts.format.Extension()
+	var mw *manifest.Writer
+	fw := &streamWriter{start: func(bool) (io.Writer, error) {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		mw = m.NewWriter(name, w)
+		return mw, nil
+	}}
+
+	temp := *f
+	res, err := h.handlers[metric].writeTable(ctx, &temp, opts, fw)
+	if err == nil {
+		err = fw.flush()
+	}
+	if err != nil {
+		if fw.started() {
+			return nil, fmt.Errorf("%s: %s is incomplete: %w", metric, name, err)
+		}
+		return nil, fmt.Errorf("%s: %w", metric, err)
+	}
+	mw.Rows = res.rows
+
+	return res, mw.Close()
+}
+
+// combineResults returns the result of the metrics of a zip export, with
+// partial errors prefixed by the metric name. Failed metrics have nil results.
+func combineResults(metrics []string, results []*csvResult) *csvResult {
+	res := &csvResult{}
+	for i, r := range results {
+		if r == nil {
+			continue
+		}
+		res.truncated = res.truncated || r.truncated
+		res.rows += r.rows
+		for _, e := range r.errors {
+			res.errors = append(res.errors, metrics[i]+" "+e)
+		}
+	}
+
+	return res
+}
+
+// metricsFromRequest parses the comma-separated "metrics" query parameter.
+// All metrics are exported when the parameter is omitted.
+func (h *zipHandler) metricsFromRequest(r *http.Request) ([]string, error) {
+	param := r.Form.Get("metrics")
+	if param == "" {
+		metrics := make([]string, 0, len(h.handlers))
+		for name := range h.handlers {
+			metrics = append(metrics, name)
+		}
+		sort.Strings(metrics)
+		return metrics, nil
+	}
+
+	seen := make(map[string]bool)
+	metrics := make([]string, 0)
+	for _, metric := range strings.Split(param, ",") {
+		metric = strings.TrimSpace(metric)
+		if _, ok := h.handlers[metric]; !ok {
+			return nil, fmt.Errorf("parsing query: \"metrics\": unknown metric %q", metric)
+		}
+		if seen[metric] {
+			continue
+		}
+		seen[metric] = true
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, nil
+}
+
+func exportFilename(f *statistics.Filter) string {
+	return fmt.Sprintf("kindly-%s-%s.zip", f.From.Format("2006-01-02"), f.To.Format("2006-01-02"))
+}
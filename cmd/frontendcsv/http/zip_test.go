@@ -0,0 +1,242 @@
+package http_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	frontendcsv "github.com/atb-as/kindly/cmd/frontendcsv/http"
+	"github.com/atb-as/kindly/manifest"
+	"github.com/atb-as/kindly/statistics"
+)
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return d(r)
+}
+
+// sessionsBody is a series of one day, as served by Sage.
+const sessionsBody = `{"data":[{"date":"2021-03-01T00:00:00.000000","count":3}]}`
+
+// longSessionsBody is a series of n days, as served by Sage.
+func longSessionsBody(n int) string {
+	var b strings.Builder
+	b.WriteString(`{"data":[`)
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"date":"%s","count":%d}`, day.AddDate(0, 0, i).Format("2006-01-02T15:04:05.000000"), i*7919%10007)
+	}
+	b.WriteString("]}")
+
+	return b.String()
+}
+
+// newTestServer serves NewServer with a client whose upstream calls are
+// answered by upstream.
+func newTestServer(t *testing.T, upstream doerFunc, opts ...frontendcsv.ServerOption) *httptest.Server {
+	t.Helper()
+
+	client := statistics.NewClient(statistics.WithDoer(upstream))
+	client.BotID = "1"
+	ts := httptest.NewServer(frontendcsv.NewServer(client, "0", opts...).Handler)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// failing answers the upstream calls for the endpoints in failed with 500,
+// and all other calls with sessionsBody.
+func failing(failed ...string) doerFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		for _, endpoint := range failed {
+			if strings.HasSuffix(r.URL.Path, "/"+endpoint) {
+				return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			}
+		}
+
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(sessionsBody))}, nil
+	}
+}
+
+// readZip returns the files of the zip archive b by name.
+func readZip(t *testing.T, b []byte) map[string][]byte {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() err=%v", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: err=%v", f.Name, err)
+		}
+		files[f.Name], err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("reading %s: err=%v", f.Name, err)
+		}
+	}
+
+	return files
+}
+
+func TestZip(t *testing.T) {
+	ts := newTestServer(t, failing())
+
+	resp, err := http.Get(ts.URL + "/export.zip?metrics=sessions,messages&from=2021-03-01&to=2021-03-02")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: err=%v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, http.StatusOK, b)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/zip" {
+		t.Errorf("got Content-Type %q, want application/zip", got)
+	}
+	if got := resp.Header.Get("Trailer"); got != "" {
+		t.Errorf("got Trailer %q, want the result headers sent as headers", got)
+	}
+	if got := resp.Header.Get("X-Partial-Errors"); got != "" {
+		t.Errorf("got X-Partial-Errors %q, want none", got)
+	}
+	if got := resp.Header.Get("X-Upstream-Calls"); got != "4" {
+		t.Errorf("got X-Upstream-Calls %q, want 4", got)
+	}
+
+	files := readZip(t, b)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if got, want := strings.Join(names, ","), "manifest.json,messages.csv,sessions.csv"; got != want {
+		t.Fatalf("got files %s, want %s", got, want)
+	}
+	if got, want := string(files["sessions.csv"]), "date,count,source\n2021-03-01,3,facebook\n2021-03-01,3,web\n"; got != want {
+		t.Errorf("got sessions.csv %q, want %q", got, want)
+	}
+
+	var m manifest.Manifest
+	if err := json.Unmarshal(files["manifest.json"], &m); err != nil {
+		t.Fatalf("decoding manifest: err=%v", err)
+	}
+	if len(m.Files) != 2 || m.Files[0].Name != "messages.csv" || m.Files[1].Name != "sessions.csv" || m.Files[1].Rows != 2 {
+		t.Errorf("unexpected manifest files %+v", m.Files)
+	}
+}
+
+func TestZip_PartialErrors(t *testing.T) {
+	ts := newTestServer(t, failing("sessions/messages"))
+
+	resp, err := http.Get(ts.URL + "/export.zip?metrics=sessions,messages&from=2021-03-01&to=2021-03-02")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: err=%v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, http.StatusOK, b)
+	}
+	if got := resp.Header.Get("X-Partial-Errors"); !strings.HasPrefix(got, "messages: ") {
+		t.Errorf("got X-Partial-Errors %q, want the messages error", got)
+	}
+
+	files := readZip(t, b)
+	if _, ok := files["messages.csv"]; ok {
+		t.Errorf("failed metric messages.csv is in the archive")
+	}
+	if _, ok := files["sessions.csv"]; !ok {
+		t.Errorf("sessions.csv is missing from the archive")
+	}
+	if _, ok := files["manifest.json"]; !ok {
+		t.Errorf("manifest.json is missing from the archive")
+	}
+}
+
+func TestZip_Streamed(t *testing.T) {
+	body := longSessionsBody(20000)
+	ts := newTestServer(t, func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Path, "/sessions/messages") {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	resp, err := http.Get(ts.URL + "/export.zip?metrics=sessions,messages&from=2021-03-01&to=2021-03-02")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: err=%v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, http.StatusOK, b)
+	}
+	if got := resp.Header.Get("X-Upstream-Calls"); got != "" {
+		t.Errorf("got X-Upstream-Calls header %q, want it as a trailer", got)
+	}
+	if got := resp.Trailer.Get("X-Upstream-Calls"); got != "4" {
+		t.Errorf("got X-Upstream-Calls trailer %q, want 4", got)
+	}
+	if got := resp.Trailer.Get("X-Partial-Errors"); !strings.HasPrefix(got, "messages: ") {
+		t.Errorf("got X-Partial-Errors trailer %q, want the messages error", got)
+	}
+
+	files := readZip(t, b)
+	if got, want := strings.Count(string(files["sessions.csv"]), "\n"), 40001; got != want {
+		t.Errorf("got %d lines in sessions.csv, want %d", got, want)
+	}
+	if _, ok := files["messages.csv"]; ok {
+		t.Errorf("failed metric messages.csv is in the archive")
+	}
+	var m manifest.Manifest
+	if err := json.Unmarshal(files["manifest.json"], &m); err != nil {
+		t.Fatalf("decoding manifest: err=%v", err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Name != "sessions.csv" || m.Files[0].Rows != 40000 || m.Files[0].Bytes != int64(len(files["sessions.csv"])) {
+		t.Errorf("unexpected manifest files %+v", m.Files)
+	}
+}
+
+func TestZip_AllFailed(t *testing.T) {
+	ts := newTestServer(t, failing("sessions/messages", "sessions/chats"))
+
+	resp, err := http.Get(ts.URL + "/export.zip?metrics=sessions,messages&from=2021-03-01&to=2021-03-02")
+	if err != nil {
+		t.Fatalf("GET err=%v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+}
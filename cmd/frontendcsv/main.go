@@ -2,38 +2,139 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
+	nethttp "net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+	// The time zone database is embedded for ?timefmt=iso8601, since the
+	// image has none.
+	_ "time/tzdata"
 
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/cache"
+	"github.com/atb-as/kindly/chat"
 	"github.com/atb-as/kindly/cmd/frontendcsv/http"
 	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/statistics/audit"
 	"github.com/atb-as/kindly/statistics/auth"
 	"github.com/go-kit/kit/log"
 	"golang.org/x/oauth2"
 )
 
 type config struct {
-	listenPort string
-	botID      string
-	apiKey     string
+	listenPort  string
+	botID       string
+	apiKey      string
+	credentials string
+	configFile  string
+	// configPoll is how often the config file is checked for changes; 0
+	// only reloads it on SIGHUP.
+	configPoll time.Duration
+	caBundle   string
+	// callTimeout, budget and maxCalls limit the upstream calls of a
+	// single request.
+	callTimeout time.Duration
+	budget      time.Duration
+	maxCalls    int
+	cacheTTL    time.Duration
+	cacheURL    string
+	// serveStale is how long cached responses are kept after cacheTTL to
+	// serve when upstream fails; 0 disables it.
+	serveStale time.Duration
+	// conditionalTTL is how long upstream responses are kept to revalidate;
+	// 0 disables conditional requests.
+	conditionalTTL time.Duration
+	drainTimeout   time.Duration
+	// labelRefresh is how often label texts are refreshed; 0 disables
+	// normalizing them.
+	labelRefresh time.Duration
+	// jobTTL is how long jobs are kept; 0 disables them.
+	jobTTL         time.Duration
+	jobDir         string
+	jobConcurrency int
+	// archiveDir, if set, is where archived days are kept.
+	archiveDir   string
+	archiveDelay time.Duration
+	// auditLog, if set, is where every upstream call is recorded.
+	auditLog string
+	tls      tlsConfig
 }
 
 func main() {
 	listenPortFlag := flag.String("port", "8080", "HTTP listen port")
 	botIDFlag := flag.String("botid", "", "kindly bot ID")
 	apiKeyFlag := flag.String("apikey", "", "kindly API key")
+	credentialsFlag := flag.String("credentials", "", "kindly API key location, e.g. gsm://projects/<p>/secrets/<s> or vault://<mount>/<path>; overrides -apikey")
+	configFlag := flag.String("config", "", "path to a JSON config file with tenants served by this instance, which override -botid, -apikey and -credentials, and cache settings")
+	configPollFlag := flag.Duration("config-poll", 30*time.Second, "how often to check -config for changes and reload it; 0 only reloads it on SIGHUP")
+	caBundleFlag := flag.String("ca-bundle", "", "PEM file with additional CA certificates to trust for upstream calls, e.g. of an intercepting egress proxy")
+	callTimeoutFlag := flag.Duration("upstream-timeout", 30*time.Second, "timeout of a single upstream call; 0 disables it")
+	budgetFlag := flag.Duration("request-budget", 0, "total time a request may spend on upstream calls before failing with 504; 0 disables it")
+	maxCallsFlag := flag.Int("max-upstream-calls", 0, "upstream calls a request may make before failing with 504, and above which requests estimated to need more are refused with 422; 0 disables it")
+	cacheTTLFlag := flag.Duration("cache-ttl", 0, "time to serve successful responses from an in-memory cache; 0 disables caching unless set in -config")
+	cacheURLFlag := flag.String("cache-url", "", "redis://[:password@]host:port[/db][?prefix=p] of a Redis server to share the response cache between replicas, or rediss:// for TLS; defaults to memory")
+	serveStaleFlag := flag.Duration("serve-stale", 0, "time to keep cached responses after -cache-ttl, and to serve them marked stale when rendering them anew fails, such as during upstream outages; 0 disables it")
+	conditionalTTLFlag := flag.Duration("conditional-ttl", 0, "time to keep upstream responses with an ETag or Last-Modified in the -cache-url cache, revalidating them with conditional requests; 0 disables them")
+	drainTimeoutFlag := flag.Duration("drain-timeout", 30*time.Second, "time to let in-flight requests finish on shutdown before cancelling them")
+	labelRefreshFlag := flag.Duration("label-refresh", 0, "report labels with their current text, refreshed this often, instead of the text they had when triggered; 0 disables it")
+	jobTTLFlag := flag.Duration("job-ttl", 0, "time to keep the state and result of jobs started with POST /jobs; 0 disables jobs")
+	jobDirFlag := flag.String("job-dir", "", "directory to keep job results in, e.g. a mounted bucket; defaults to memory")
+	jobConcurrencyFlag := flag.Int("job-concurrency", 2, "jobs to run at a time")
+	archiveDirFlag := flag.String("archive-dir", "", "directory to keep the daily CSVs of /archive/{metric}/{yyyy}/{mm}/{dd}.csv in for good, e.g. a mounted bucket; empty disables the archive")
+	archiveDelayFlag := flag.Duration("archive-delay", 6*time.Hour, "time after the end of a day before it is archived, so late upstream data is included")
+	auditLogFlag := flag.String("audit-log", "", "record every upstream call with its caller in a file, or in Cloud Logging with logging://projects/<p>/logs/<log>")
+	tlsCertFlag := flag.String("tls-cert", "", "PEM certificate file to serve HTTPS on -port with; requires -tls-key")
+	tlsKeyFlag := flag.String("tls-key", "", "PEM private key file of -tls-cert")
+	autocertHostsFlag := flag.String("autocert-hosts", "", "comma-separated hosts to obtain certificates for from Let's Encrypt, serving HTTPS on -port; other hosts are refused")
+	autocertCacheFlag := flag.String("autocert-cache", "autocert", "directory to keep certificates obtained with -autocert-hosts in")
+	redirectPortFlag := flag.String("http-redirect-port", "80", "port redirecting HTTP to HTTPS when serving HTTPS, also answering ACME challenges; empty disables it")
+	versionFlag := flag.Bool("version", false, "print the version and exit")
 	flag.Parse()
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	if *versionFlag {
+		fmt.Println(kindly.Build())
+		return
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	if err := run(ctx, &config{
-		listenPort: *listenPortFlag,
-		botID:      *botIDFlag,
-		apiKey:     *apiKeyFlag,
+		listenPort:     *listenPortFlag,
+		botID:          *botIDFlag,
+		apiKey:         *apiKeyFlag,
+		credentials:    *credentialsFlag,
+		configFile:     *configFlag,
+		configPoll:     *configPollFlag,
+		caBundle:       *caBundleFlag,
+		callTimeout:    *callTimeoutFlag,
+		budget:         *budgetFlag,
+		maxCalls:       *maxCallsFlag,
+		cacheTTL:       *cacheTTLFlag,
+		cacheURL:       *cacheURLFlag,
+		serveStale:     *serveStaleFlag,
+		conditionalTTL: *conditionalTTLFlag,
+		drainTimeout:   *drainTimeoutFlag,
+		labelRefresh:   *labelRefreshFlag,
+		jobTTL:         *jobTTLFlag,
+		jobDir:         *jobDirFlag,
+		jobConcurrency: *jobConcurrencyFlag,
+		archiveDir:     *archiveDirFlag,
+		archiveDelay:   *archiveDelayFlag,
+		auditLog:       *auditLogFlag,
+		tls: tlsConfig{
+			certFile:      *tlsCertFlag,
+			keyFile:       *tlsKeyFlag,
+			autocertHosts: parseHosts(*autocertHostsFlag),
+			autocertCache: *autocertCacheFlag,
+			redirectPort:  *redirectPortFlag,
+		},
 	}); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 		os.Exit(1)
@@ -41,26 +142,294 @@ func main() {
 }
 
 func run(ctx context.Context, config *config) error {
-	client := statistics.NewClient(
-		statistics.WithDoer(oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, &auth.TokenSource{
-			APIKey: config.apiKey,
-			BotID:  config.botID,
-		}))),
-		statistics.WithLogger(log.NewLogfmtLogger(os.Stdout)))
-	client.BotID = config.botID
+	if err := config.tls.validate(); err != nil {
+		return err
+	}
+	res, err := newResources(ctx, config)
+	if err != nil {
+		return err
+	}
+	srv, err := newServer(ctx, config, res)
+	if err != nil {
+		return err
+	}
 
-	srv := http.NewServer(client, config.listenPort)
+	// The routes of the config file are replaced when it changes, while
+	// requests in flight finish with the routes they started on.
+	var reload *http.Reloadable
+	if config.configFile != "" {
+		reload = http.NewReloadable(srv.Handler)
+		srv.Handler = reload
+		go watchConfig(ctx, config.configFile, config.configPoll, reload, res.logger, func() (*http.Server, error) {
+			return newServer(ctx, config, res)
+		})
+	}
 
-	go func() {
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
-			fmt.Fprintf(os.Stderr, "srv.ListenAndServe: err=%v\n", err)
+	// Requests derive their context from baseCtx, so cancelling it aborts
+	// in-flight upstream calls once the drain timeout has passed.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv.BaseContext = func(net.Listener) context.Context {
+		return baseCtx
+	}
+
+	if config.tls.enabled() {
+		if redirect := configureTLS(srv, &config.tls); redirect != nil {
+			defer redirect.Close()
+			go listen(redirect, &tlsConfig{})
 		}
-	}()
+	}
+	go listen(srv, &config.tls)
 
 	<-ctx.Done()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.drainTimeout)
 	defer cancel()
-	return srv.Shutdown(ctx)
+	if err = srv.Shutdown(shutdownCtx); err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		res.logger.Log("msg", "drain timeout exceeded, cancelling in-flight requests", "drain_timeout", config.drainTimeout)
+		cancelRequests()
+		err = srv.Close()
+	}
+	if reload != nil {
+		if err := reload.Close(shutdownCtx); err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// newCache returns the Redis cache at uri, or a memory cache if uri is empty.
+func newCache(uri string) (cache.Cache, error) {
+	if uri == "" {
+		return cache.NewMemory(), nil
+	}
+
+	return cache.NewRedis(uri)
+}
+
+// resources are shared by the servers built from the config file as it is
+// reloaded, so cached responses, jobs and the audit log are kept.
+type resources struct {
+	logger     log.Logger
+	transport  nethttp.RoundTripper
+	clientOpts []statistics.ClientOption
+	// jobStore is nil unless jobs are enabled.
+	jobStore cache.Cache
+	// archiveStore is nil unless the archive is enabled.
+	archiveStore cache.Cache
+
+	mu sync.Mutex
+	// caches are the response caches by URL.
+	caches map[string]cache.Cache
+}
 
+func newResources(ctx context.Context, config *config) (*resources, error) {
+	var caFiles []string
+	if config.caBundle != "" {
+		caFiles = append(caFiles, config.caBundle)
+	}
+	transport, err := statistics.NewTransport(caFiles...)
+	if err != nil {
+		return nil, err
+	}
+	res := &resources{logger: log.NewLogfmtLogger(os.Stdout), transport: transport, caches: make(map[string]cache.Cache)}
+
+	if config.auditLog != "" {
+		sink, err := audit.Open(ctx, config.auditLog)
+		if err != nil {
+			return nil, err
+		}
+		res.clientOpts = append(res.clientOpts, statistics.WithAuditSink(sink))
+	}
+
+	if config.conditionalTTL > 0 {
+		c, err := res.cache(config.cacheURL)
+		if err != nil {
+			return nil, err
+		}
+		res.clientOpts = append(res.clientOpts, statistics.WithConditionalRequests(c, config.conditionalTTL))
+	}
+
+	if config.jobTTL > 0 {
+		res.jobStore = cache.NewMemory()
+		if config.jobDir != "" {
+			if res.jobStore, err = cache.NewDir(config.jobDir); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if config.archiveDir != "" {
+		if res.archiveStore, err = cache.NewDir(config.archiveDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// cache returns the response cache at uri, see newCache, opening it once.
+func (res *resources) cache(uri string) (cache.Cache, error) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+
+	if c, ok := res.caches[uri]; ok {
+		return c, nil
+	}
+	c, err := newCache(uri)
+	if err != nil {
+		return nil, err
+	}
+	res.caches[uri] = c
+
+	return c, nil
+}
+
+// newServer returns a server for the flags and the config file, read anew.
+func newServer(ctx context.Context, config *config, res *resources) (*http.Server, error) {
+	cfg := &fileConfig{}
+	if config.configFile != "" {
+		var err error
+		if cfg, err = loadConfig(config.configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	budget := config.budget
+	if cfg.Limits.RequestBudget > 0 {
+		budget = time.Duration(cfg.Limits.RequestBudget)
+	}
+	maxCalls := config.maxCalls
+	if cfg.Limits.MaxUpstreamCalls > 0 {
+		maxCalls = cfg.Limits.MaxUpstreamCalls
+	}
+	opts := []http.ServerOption{
+		http.WithLogger(res.logger),
+		http.WithRequestBudget(budget),
+		http.WithMaxUpstreamCalls(maxCalls),
+	}
+
+	cacheTTL := config.cacheTTL
+	if cfg.Cache.TTL > 0 {
+		cacheTTL = time.Duration(cfg.Cache.TTL)
+	}
+	if cacheTTL > 0 {
+		cacheURL := config.cacheURL
+		if cfg.Cache.URL != "" {
+			cacheURL = cfg.Cache.URL
+		}
+		c, err := res.cache(cacheURL)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, http.WithCache(c, cacheTTL))
+		serveStale := config.serveStale
+		if cfg.Cache.Stale > 0 {
+			serveStale = time.Duration(cfg.Cache.Stale)
+		}
+		opts = append(opts, http.WithServeStale(serveStale))
+	}
+	if len(cfg.Prewarm.Queries) > 0 {
+		if cacheTTL <= 0 {
+			return nil, fmt.Errorf("prewarm requires a cache, set -cache-ttl or cache.ttl in %s", config.configFile)
+		}
+		opts = append(opts, http.WithPrewarm(time.Duration(cfg.Prewarm.Interval), cfg.Prewarm.Queries))
+	}
+
+	if res.jobStore != nil {
+		opts = append(opts, http.WithJobs(res.jobStore, config.jobTTL, config.jobConcurrency))
+	}
+	if res.archiveStore != nil {
+		opts = append(opts, http.WithArchive(res.archiveStore, config.archiveDelay))
+	}
+	presets, err := statistics.NewPresets(cfg.Presets...)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, http.WithPresets(presets))
+	if groups := routeGroups(cfg); groups != nil {
+		opts = append(opts, http.WithRoutes(groups))
+	}
+	if len(cfg.Defaults) > 0 {
+		opts = append(opts, http.WithRouteDefaults(cfg.Defaults))
+	}
+
+	if len(cfg.Tenants) > 0 {
+		tenants, err := newTenants(ctx, cfg, res.transport, config.callTimeout, config.labelRefresh, res.logger, res.clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		return http.NewMultiTenantServer(tenants, config.listenPort, opts...)
+	}
+
+	var creds auth.Credentials
+	if config.credentials != "" {
+		c, err := auth.ParseCredentials(ctx, config.credentials)
+		if err != nil {
+			return nil, err
+		}
+		creds = c
+	}
+
+	client := newClient(config.botID, config.apiKey, creds, res.transport, config.callTimeout, config.labelRefresh, res.logger, res.clientOpts...)
+
+	return http.NewServer(client, config.listenPort, opts...), nil
+}
+
+// newClient returns a statistics client for botID. Token requests and
+// upstream calls both go through transport, and each is limited to timeout
+// unless it is zero. With a labelRefresh, label texts are normalized with the
+// labels of the bot fetched from the chat API. extra options are applied last.
+func newClient(botID, apiKey string, creds auth.Credentials, transport nethttp.RoundTripper, timeout, labelRefresh time.Duration, logger log.Logger, extra ...statistics.ClientOption) *statistics.Client {
+	doer := oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, &auth.TokenSource{
+		APIKey:      apiKey,
+		Credentials: creds,
+		BotID:       botID,
+		Client:      &nethttp.Client{Transport: transport, Timeout: timeout},
+	}))
+	doer.Timeout = timeout
+
+	opts := []statistics.ClientOption{
+		statistics.WithDoer(doer),
+		statistics.WithTransport(transport),
+		statistics.WithLogger(logger),
+	}
+	if labelRefresh > 0 {
+		opts = append(opts, statistics.WithLabelCatalogue(newLabelCatalogue(botID, apiKey, creds, transport, timeout, labelRefresh)))
+	}
+
+	client := statistics.NewClient(append(opts, extra...)...)
+	client.BotID = botID
+
+	return client
+}
+
+// newLabelCatalogue returns a catalogue of the labels of botID. The chat API
+// takes the API key itself as bearer token.
+func newLabelCatalogue(botID, apiKey string, creds auth.Credentials, transport nethttp.RoundTripper, timeout, refresh time.Duration) *statistics.LabelCatalogue {
+	if creds == nil {
+		creds = auth.StaticCredentials(apiKey)
+	}
+	c := chat.NewClient(chat.WithDoer(&nethttp.Client{
+		Transport: &oauth2.Transport{Source: oauth2.ReuseTokenSource(nil, &auth.KeySource{Credentials: creds}), Base: transport},
+		Timeout:   timeout,
+	}))
+	c.BotID = botID
+
+	return statistics.NewLabelCatalogue(func(ctx context.Context) (map[string]string, error) {
+		labels, err := c.Labels(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		texts := make(map[string]string, len(labels))
+		for _, label := range labels {
+			texts[label.ID] = label.Text
+		}
+		return texts, nil
+	}, refresh)
 }
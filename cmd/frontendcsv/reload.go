@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/atb-as/kindly/cmd/frontendcsv/http"
+	"github.com/go-kit/kit/log"
+)
+
+// fileState identifies a version of a file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func (s fileState) same(o fileState) bool {
+	return s.modTime.Equal(o.modTime) && s.size == o.size
+}
+
+func statFile(path string) (fileState, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+
+	return fileState{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// watchConfig serves the server returned by load with rl whenever the config
+// file at path changes, checking it every poll unless poll is zero, or the
+// process receives SIGHUP, until ctx is done. A config that fails to load is
+// logged to logger and the current one kept.
+func watchConfig(ctx context.Context, path string, poll time.Duration, rl *http.Reloadable, logger log.Logger, load func() (*http.Server, error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if poll > 0 {
+		t := time.NewTicker(poll)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	last, _ := statFile(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-tick:
+			// Files being replaced may be missing for a moment, so only an
+			// existing, changed file is loaded.
+			state, err := statFile(path)
+			if err != nil || state.same(last) {
+				continue
+			}
+		}
+
+		last, _ = statFile(path)
+		srv, err := load()
+		if err != nil {
+			logger.Log("msg", "reloading config failed", "config", path, "err", err)
+			continue
+		}
+		rl.Swap(srv)
+		logger.Log("msg", "config reloaded", "config", path)
+	}
+}
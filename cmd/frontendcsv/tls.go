@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/atb-as/kindly/cmd/frontendcsv/http"
+)
+
+// tlsConfig configures serving HTTPS, from a certificate and key or from
+// certificates obtained from Let's Encrypt.
+type tlsConfig struct {
+	certFile string
+	keyFile  string
+	// autocertHosts are the hosts certificates are obtained for. Setting
+	// them enables autocert.
+	autocertHosts []string
+	autocertCache string
+	// redirectPort is the port redirecting plain HTTP to HTTPS, which also
+	// answers ACME challenges. Empty disables it.
+	redirectPort string
+}
+
+func (c *tlsConfig) enabled() bool {
+	return c.certFile != "" || c.keyFile != "" || len(c.autocertHosts) > 0
+}
+
+func (c *tlsConfig) validate() error {
+	switch {
+	case (c.certFile == "") != (c.keyFile == ""):
+		return errors.New("-tls-cert and -tls-key must be set together")
+	case c.certFile != "" && len(c.autocertHosts) > 0:
+		return errors.New("-tls-cert can not be combined with -autocert-hosts")
+	case len(c.autocertHosts) > 0 && c.autocertCache == "":
+		return errors.New("-autocert-cache is required with -autocert-hosts")
+	}
+
+	return nil
+}
+
+// parseHosts splits a comma-separated list of hosts.
+func parseHosts(s string) []string {
+	var ret []string
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			ret = append(ret, h)
+		}
+	}
+
+	return ret
+}
+
+// configureTLS sets up srv to serve HTTPS as configured by c, and returns the
+// server redirecting plain HTTP to srv, or nil if redirecting is disabled.
+func configureTLS(srv *http.Server, c *tlsConfig) *http.Server {
+	redirect := nethttp.Handler(nethttp.HandlerFunc(redirectToHTTPS(srv.Addr)))
+	if len(c.autocertHosts) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(c.autocertHosts...),
+			Cache:      autocert.DirCache(c.autocertCache),
+		}
+		srv.TLSConfig = m.TLSConfig()
+		redirect = m.HTTPHandler(redirect)
+	}
+
+	if c.redirectPort == "" {
+		return nil
+	}
+
+	return &http.Server{
+		Addr:        ":" + c.redirectPort,
+		ReadTimeout: 5 * time.Second,
+		Handler:     redirect,
+	}
+}
+
+// redirectToHTTPS redirects requests to the same URL on HTTPS, at the port
+// of addr unless it is the default port.
+func redirectToHTTPS(addr string) nethttp.HandlerFunc {
+	_, port, _ := net.SplitHostPort(addr)
+
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		u := *r.URL
+		u.Scheme = "https"
+		u.Host = host
+		nethttp.Redirect(w, r, u.String(), nethttp.StatusMovedPermanently)
+	}
+}
+
+// listen serves srv, with HTTPS if c is enabled, until it is closed.
+func listen(srv *http.Server, c *tlsConfig) {
+	var err error
+	if c.enabled() {
+		// The certificate comes from srv.TLSConfig with autocert.
+		err = srv.ListenAndServeTLS(c.certFile, c.keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		fmt.Fprintf(os.Stderr, "srv.ListenAndServe: err=%v\n", err)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/derive/alerts"
+	"github.com/atb-as/kindly/derive/anomaly"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// runAnomalies scans a daily series of a bot in the config file for
+// anomalies and prints them, optionally posting them to Slack.
+func runAnomalies(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("anomalies", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	botIDFlag := fs.String("botid", "", "bot ID in the config file (default: the only bot)")
+	metricFlag := fs.String("metric", string(anomaly.Fallbacks), "metric to scan: fallbacks, sessions or messages")
+	daysFlag := fs.Int("days", 60, "number of days up to today to scan")
+	windowFlag := fs.Int("window", 14, "number of preceding days each day is compared with")
+	thresholdFlag := fs.Float64("threshold", 3.5, "absolute robust z-score at which a day is anomalous")
+	directionFlag := fs.String("direction", "", "up, down or both (default: up for fallbacks, both otherwise)")
+	recentFlag := fs.Int("recent", 0, "only report anomalies in this many most recent days; 0 reports all")
+	slackFlag := fs.String("slack-webhook", "", "Slack incoming webhook URL to post the anomalies to")
+	formatFlag := fs.String("format", string(encoding.Table), "output format: table, csv, tsv, json or ndjson")
+	manifestFlag := fs.String("manifest", "", manifestUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := encoding.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	metric, err := anomaly.ParseMetric(*metricFlag)
+	if err != nil {
+		return err
+	}
+	d := &anomaly.Detector{Window: *windowFlag, Threshold: *thresholdFlag}
+	switch *directionFlag {
+	case "":
+		if metric == anomaly.Fallbacks {
+			d.Direction = anomaly.Up
+		}
+	case "up":
+		d.Direction = anomaly.Up
+	case "down":
+		d.Direction = anomaly.Down
+	case "both":
+	default:
+		return fmt.Errorf("unknown direction %q, want up, down or both", *directionFlag)
+	}
+
+	c, err := config.Load(*pathFlag)
+	if err != nil {
+		return err
+	}
+	bot, err := c.Bot(*botIDFlag)
+	if err != nil {
+		return err
+	}
+	client, err := newStatisticsClient(ctx, bot, c.Store)
+	if err != nil {
+		return err
+	}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	f := &statistics.Filter{
+		From:     to.AddDate(0, 0, -*daysFlag),
+		To:       to,
+		Timezone: c.Timezone,
+		Sources:  c.Output.Sources,
+	}
+	series, err := anomaly.Series(ctx, client, metric, f)
+	if err != nil {
+		return err
+	}
+
+	found := d.Detect(series)
+	if *recentFlag > 0 {
+		since := to.AddDate(0, 0, -*recentFlag)
+		recent := found[:0]
+		for _, a := range found {
+			if !a.Time.Before(since) {
+				recent = append(recent, a)
+			}
+		}
+		found = recent
+	}
+
+	params := map[string]string{
+		"bot_id":    bot.ID,
+		"format":    string(format),
+		"metric":    string(metric),
+		"window":    strconv.Itoa(d.Window),
+		"threshold": strconv.FormatFloat(d.Threshold, 'f', -1, 64),
+		"direction": *directionFlag,
+		"recent":    strconv.Itoa(*recentFlag),
+	}
+	if len(found) == 0 {
+		fmt.Fprintf(os.Stdout, "No anomalies in %s over the last %d days.\n", metric.Title(), *daysFlag)
+		if *manifestFlag != "" {
+			return newManifest("kindly anomalies", f, params).WriteFile(*manifestFlag)
+		}
+		return nil
+	}
+	enc, err := newOutput(format, *manifestFlag, "kindly anomalies", f, params)
+	if err != nil {
+		return err
+	}
+	enc.Write([]string{"date", "value", "median", "mad", "score"})
+	for _, a := range found {
+		enc.Write([]string{
+			a.Time.Format("2006-01-02"),
+			strconv.FormatFloat(a.Value, 'f', 2, 64),
+			strconv.FormatFloat(a.Median, 'f', 2, 64),
+			strconv.FormatFloat(a.MAD, 'f', 2, 64),
+			strconv.FormatFloat(a.Score, 'f', 1, 64),
+		})
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if *slackFlag == "" {
+		return nil
+	}
+
+	return alerts.Notify(ctx, alerts.FromAnomalies(metric, found), &alerts.Slack{WebhookURL: *slackFlag})
+}
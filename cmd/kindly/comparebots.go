@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/metrics"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// runCompareBots prints the total of a counted metric over a period for
+// several bots in the config file, with each bot's share of the total of
+// all, for group reporting across a portfolio of bots.
+func runCompareBots(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("compare-bots", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	botsFlag := fs.String("bots", "", "comma-separated bot IDs in the config file (default: every bot)")
+	metricFlag := fs.String("metric", metrics.Sessions.Name, "counted metric to compare: "+strings.Join(metrics.Counted(), ", "))
+	periodFlag := fs.String("period", "last_30_days", "period to compare: last_<n>_days, or a preset such as weekly-report for its days")
+	formatFlag := fs.String("format", string(encoding.Table), "output format: table, csv, tsv, json or ndjson")
+	manifestFlag := fs.String("manifest", "", manifestUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := encoding.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	metric, err := metrics.Lookup(*metricFlag)
+	if err != nil {
+		return err
+	}
+	if !metric.Counted() {
+		return fmt.Errorf("metric %s is not counted per period, want %s", metric.Name, strings.Join(metrics.Counted(), ", "))
+	}
+
+	c, err := config.Load(*pathFlag)
+	if err != nil {
+		return err
+	}
+	days, err := periodDays(*periodFlag, c.Presets)
+	if err != nil {
+		return err
+	}
+	bots := c.Bots
+	if *botsFlag != "" {
+		bots = nil
+		for _, id := range strings.Split(*botsFlag, ",") {
+			bot, err := c.Bot(strings.TrimSpace(id))
+			if err != nil {
+				return err
+			}
+			bots = append(bots, bot)
+		}
+	}
+	clients := make(map[string]statistics.Service, len(bots))
+	for _, bot := range bots {
+		client, err := newStatisticsClient(ctx, bot, c.Store)
+		if err != nil {
+			return fmt.Errorf("bot %s: %w", bot.ID, err)
+		}
+		clients[bot.ID] = client
+	}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	f := &statistics.Filter{
+		From:        to.AddDate(0, 0, -days),
+		To:          to,
+		Timezone:    c.Timezone,
+		Sources:     c.Output.Sources,
+		Granularity: statistics.Day,
+	}
+	results := statistics.NewMultiClient(clients).Counts(ctx, func(ctx context.Context, s statistics.Service) ([]*statistics.CountByDate, error) {
+		return metric.Counts(ctx, s, f)
+	})
+
+	total := 0
+	var failed []string
+	for _, b := range results {
+		if b.Err != nil {
+			failed = append(failed, fmt.Sprintf("bot %s: %v", b.BotID, b.Err))
+			continue
+		}
+		total += b.Total()
+	}
+
+	params := map[string]string{
+		"bots":   *botsFlag,
+		"format": string(format),
+		"metric": metric.Name,
+		"period": *periodFlag,
+	}
+	enc, err := newOutput(format, *manifestFlag, "kindly compare-bots", f, params)
+	if err != nil {
+		return err
+	}
+	enc.Write([]string{"bot", metric.Name, "share"})
+	share := func(n int) string {
+		if total == 0 {
+			return strconv.FormatFloat(0, 'f', 3, 64)
+		}
+		return strconv.FormatFloat(float64(n)/float64(total), 'f', 3, 64)
+	}
+	for _, b := range results {
+		if b.Err != nil {
+			continue
+		}
+		enc.Write([]string{b.BotID, strconv.Itoa(b.Total()), share(b.Total())})
+	}
+	enc.Write([]string{"total", strconv.Itoa(total), share(total)})
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d bots failed and are not in the total: %s", len(failed), len(results), strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// periodDays returns the number of whole days up to today of period: n for
+// "last_<n>_days", or the days of the preset named period.
+func periodDays(period string, presets []*statistics.Preset) (int, error) {
+	if strings.HasPrefix(period, "last_") && strings.HasSuffix(period, "_days") {
+		days, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(period, "last_"), "_days"))
+		if err != nil || days < 1 {
+			return 0, fmt.Errorf("unknown period %q, want last_<n>_days with a positive n, or a preset", period)
+		}
+		return days, nil
+	}
+
+	registry, err := statistics.NewPresets(presets...)
+	if err != nil {
+		return 0, err
+	}
+	p, err := registry.Get(period)
+	if err != nil {
+		return 0, fmt.Errorf("unknown period %q, want last_<n>_days or a preset", period)
+	}
+
+	return p.Days, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// errEnoughChats stops the search for chats once -max-chats are found.
+var errEnoughChats = errors.New("enough chats")
+
+// runFallbacks prints the dialogues of a bot in the config file that users
+// most often reach right before a fallback, read from the transcripts of the
+// chats of the period.
+func runFallbacks(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("fallbacks", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	botIDFlag := fs.String("botid", "", "bot ID in the config file (default: the only bot)")
+	daysFlag := fs.Int("days", 7, "number of days up to today to read chats of")
+	maxChatsFlag := fs.Int("max-chats", 500, "maximum number of chats to read the transcript of, one call each")
+	limitFlag := fs.Int("limit", 20, "number of dialogues to report; 0 reports all")
+	formatFlag := fs.String("format", string(encoding.Table), "output format: table, csv, tsv, json or ndjson")
+	manifestFlag := fs.String("manifest", "", manifestUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := encoding.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	if *maxChatsFlag < 1 {
+		return fmt.Errorf("-max-chats must be at least 1")
+	}
+
+	c, err := config.Load(*pathFlag)
+	if err != nil {
+		return err
+	}
+	bot, err := c.Bot(*botIDFlag)
+	if err != nil {
+		return err
+	}
+	client, err := newChatClient(ctx, bot, c.Store)
+	if err != nil {
+		return err
+	}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	f := &statistics.Filter{From: to.AddDate(0, 0, -*daysFlag), To: to, Timezone: c.Timezone}
+	var chats []*chat.Chat
+	err = client.SearchAll(ctx, &chat.SearchFilter{From: f.From, To: f.To}, func(ch *chat.Chat) error {
+		chats = append(chats, ch)
+		if len(chats) == *maxChatsFlag {
+			return errEnoughChats
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnoughChats) {
+		return err
+	}
+
+	conversations := make([][]derive.Reply, 0, len(chats))
+	for _, ch := range chats {
+		messages, err := client.Messages(ctx, ch.ID)
+		if err != nil {
+			return fmt.Errorf("fetching messages of chat %s: %w", ch.ID, err)
+		}
+		conversations = append(conversations, derive.RepliesOf(messages))
+	}
+
+	params := map[string]string{
+		"bot_id":    bot.ID,
+		"format":    string(format),
+		"max_chats": strconv.Itoa(*maxChatsFlag),
+		"chats":     strconv.Itoa(len(chats)),
+	}
+	enc, err := newOutput(format, *manifestFlag, "kindly fallbacks", f, params)
+	if err != nil {
+		return err
+	}
+	enc.Write([]string{"dialogue_id", "fallbacks", "share"})
+	for i, d := range derive.FallbackPredecessors(conversations) {
+		if *limitFlag > 0 && i == *limitFlag {
+			break
+		}
+		enc.Write([]string{d.DialogueID, strconv.Itoa(d.Count), strconv.FormatFloat(d.Share, 'f', 3, 64)})
+	}
+
+	return enc.Close()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// runFeedback prints the feedback ratings of the chats of a bot in the config
+// file per chat label, so the ratings of chats about one topic can be
+// compared with those of another. Sage reports feedback and labels
+// separately, so the ratings are read from the chats.
+func runFeedback(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("feedback", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	botIDFlag := fs.String("botid", "", "bot ID in the config file (default: the only bot)")
+	daysFlag := fs.Int("days", 28, "number of days up to today to report")
+	typeFlag := fs.String("type", string(chat.FeedbackBinary), "feedback scale: binary or emojis")
+	minChatsFlag := fs.Int("min-chats", 1, "smallest number of rated chats of a label to report it")
+	formatFlag := fs.String("format", string(encoding.Table), "output format: table, csv, tsv, json or ndjson")
+	manifestFlag := fs.String("manifest", "", manifestUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := encoding.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	typ := chat.FeedbackType(*typeFlag)
+	if typ != chat.FeedbackBinary && typ != chat.FeedbackEmoji {
+		return fmt.Errorf("unknown feedback type %q, want binary or emojis", *typeFlag)
+	}
+
+	c, err := config.Load(*pathFlag)
+	if err != nil {
+		return err
+	}
+	bot, err := c.Bot(*botIDFlag)
+	if err != nil {
+		return err
+	}
+	client, err := newChatClient(ctx, bot, c.Store)
+	if err != nil {
+		return err
+	}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	f := &statistics.Filter{
+		From:     to.AddDate(0, 0, -*daysFlag),
+		To:       to,
+		Timezone: c.Timezone,
+	}
+	chats, err := client.FeedbackChats(ctx, typ, f.From, f.To)
+	if err != nil {
+		return err
+	}
+	labels, err := client.Labels(ctx)
+	if err != nil {
+		return err
+	}
+
+	params := map[string]string{
+		"bot_id":    bot.ID,
+		"format":    string(format),
+		"type":      string(typ),
+		"min_chats": strconv.Itoa(*minChatsFlag),
+	}
+	enc, err := newOutput(format, *manifestFlag, "kindly feedback", f, params)
+	if err != nil {
+		return err
+	}
+	ratings := derive.FeedbackRatings(typ)
+	hdr := []string{"label_id", "label", "chats", "average", "positive_share"}
+	for _, r := range ratings {
+		hdr = append(hdr, "rating_"+strconv.Itoa(r))
+	}
+	enc.Write(hdr)
+	for _, l := range derive.FeedbackByLabel(chats, labels) {
+		if l.Chats < *minChatsFlag {
+			continue
+		}
+		text := l.Text
+		if l.LabelID == "" {
+			text = "(no label)"
+		}
+		row := []string{l.LabelID, text, strconv.Itoa(l.Chats), strconv.FormatFloat(l.Average(), 'f', 2, 64), strconv.FormatFloat(l.PositiveShare(), 'f', 3, 64)}
+		for _, r := range ratings {
+			row = append(row, strconv.Itoa(l.Counts[r]))
+		}
+		enc.Write(row)
+	}
+
+	return enc.Close()
+}
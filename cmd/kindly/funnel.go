@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+	"time"
+
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// runFunnel prints the conversation funnel of a bot in the config file per
+// period, from greeted to resolved sessions.
+func runFunnel(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("funnel", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	botIDFlag := fs.String("botid", "", "bot ID in the config file (default: the only bot)")
+	daysFlag := fs.Int("days", 28, "number of days up to today to report")
+	granularityFlag := fs.String("granularity", "week", "period of each funnel: day or week")
+	formatFlag := fs.String("format", string(encoding.Table), "output format: table, csv, tsv, json or ndjson")
+	manifestFlag := fs.String("manifest", "", manifestUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := encoding.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	granularity, err := statistics.ParseGranularity(*granularityFlag)
+	if err != nil {
+		return err
+	}
+
+	c, err := config.Load(*pathFlag)
+	if err != nil {
+		return err
+	}
+	bot, err := c.Bot(*botIDFlag)
+	if err != nil {
+		return err
+	}
+	client, err := newStatisticsClient(ctx, bot, c.Store)
+	if err != nil {
+		return err
+	}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	f := &statistics.Filter{
+		From:        to.AddDate(0, 0, -*daysFlag),
+		To:          to,
+		Timezone:    c.Timezone,
+		Granularity: granularity,
+		Sources:     c.Output.Sources,
+	}
+	funnels, err := derive.FunnelSeries(ctx, client, f)
+	if err != nil {
+		return err
+	}
+
+	enc, err := newOutput(format, *manifestFlag, "kindly funnel", f, map[string]string{"bot_id": bot.ID, "format": string(format)})
+	if err != nil {
+		return err
+	}
+	enc.Write([]string{"date", "stage", "sessions", "conversion", "estimated"})
+	for _, fn := range funnels {
+		for i, s := range fn.Stages {
+			conversion := ""
+			if i > 0 {
+				conversion = strconv.FormatFloat(s.Conversion, 'f', 3, 64)
+			}
+			enc.Write([]string{
+				fn.From.Format("2006-01-02"),
+				s.Name,
+				strconv.Itoa(s.Count),
+				conversion,
+				strconv.FormatBool(s.Estimated),
+			})
+		}
+	}
+
+	return enc.Close()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+	"time"
+
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/statistics/auth"
+	"golang.org/x/oauth2"
+)
+
+// runHandovers prints what happened after handover was requested in the
+// chats of a bot in the config file per period, with a total row.
+func runHandovers(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("handovers", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	botIDFlag := fs.String("botid", "", "bot ID in the config file (default: the only bot)")
+	daysFlag := fs.Int("days", 28, "number of days up to today to report")
+	granularityFlag := fs.String("granularity", "week", "period of each row: day or week")
+	formatFlag := fs.String("format", string(encoding.Table), "output format: table, csv, tsv, json or ndjson")
+	manifestFlag := fs.String("manifest", "", manifestUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := encoding.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	granularity, err := statistics.ParseGranularity(*granularityFlag)
+	if err != nil {
+		return err
+	}
+
+	c, err := config.Load(*pathFlag)
+	if err != nil {
+		return err
+	}
+	bot, err := c.Bot(*botIDFlag)
+	if err != nil {
+		return err
+	}
+	client, err := newChatClient(ctx, bot, c.Store)
+	if err != nil {
+		return err
+	}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	f := &statistics.Filter{
+		From:        to.AddDate(0, 0, -*daysFlag),
+		To:          to,
+		Timezone:    c.Timezone,
+		Granularity: granularity,
+	}
+	chats, err := client.HandoverOutcomesBetween(ctx, f.From, f.To)
+	if err != nil {
+		return err
+	}
+
+	enc, err := newOutput(format, *manifestFlag, "kindly handovers", f, map[string]string{"bot_id": bot.ID, "format": string(format)})
+	if err != nil {
+		return err
+	}
+	hdr := []string{"date", "requested"}
+	for _, o := range chat.HandoverOutcomes {
+		hdr = append(hdr, string(o), string(o)+"_share")
+	}
+	enc.Write(hdr)
+
+	write := func(date string, counts *derive.OutcomeCounts) {
+		row := []string{date, strconv.Itoa(counts.Requested)}
+		for _, o := range chat.HandoverOutcomes {
+			row = append(row, strconv.Itoa(counts.Counts[o]), strconv.FormatFloat(counts.Share(o), 'f', 3, 64))
+		}
+		enc.Write(row)
+	}
+	for _, counts := range derive.OutcomeSeries(chats, f) {
+		write(counts.From.Format("2006-01-02"), counts)
+	}
+	write("total", derive.CountOutcomes(chats, derive.Period{From: f.From, To: f.To}))
+
+	return enc.Close()
+}
+
+// newChatClient returns a chat client for bot. With a store, responses are
+// kept in and served from it.
+func newChatClient(ctx context.Context, bot *config.Bot, store *config.Store) (*chat.Client, error) {
+	creds, err := auth.ParseCredentials(ctx, bot.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	var doer chat.Doer = oauth2.NewClient(ctx, &auth.KeySource{Credentials: creds})
+	if store != nil {
+		s, err := openStore(store)
+		if err != nil {
+			return nil, err
+		}
+		doer = s.Doer(doer)
+	}
+
+	client := chat.NewClient(chat.WithDoer(doer))
+	client.BotID = bot.ID
+
+	return client, nil
+}
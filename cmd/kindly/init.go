@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/statistics/auth"
+	"golang.org/x/oauth2"
+)
+
+// runInit writes a config file from flags, prompting for the values that
+// were not given unless -no-input is set.
+func runInit(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file to write")
+	botIDsFlag := fs.String("botid", "", "comma-separated kindly bot IDs")
+	credentialsFlag := fs.String("credentials", "", "API key location, e.g. env://KINDLY_API_KEY, gsm://projects/<p>/secrets/<s> or vault://<mount>/<path>")
+	timezoneFlag := fs.String("timezone", "", "time zone statistics are reported in (default: Europe/Oslo)")
+	formatFlag := fs.String("format", "", "output format: csv, tsv, json, ndjson, xlsx or parquet (default: csv)")
+	layoutFlag := fs.String("layout", "", "output layout: long or wide (default: long)")
+	sourcesFlag := fs.String("sources", "", "comma-separated sources (default: web,facebook)")
+	noInputFlag := fs.Bool("no-input", false, "do not prompt for values missing from flags")
+	skipValidateFlag := fs.Bool("skip-validate", false, "do not fetch a token and a metric to validate the credentials")
+	forceFlag := fs.Bool("force", false, "overwrite an existing config file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(*pathFlag); err == nil && !*forceFlag {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", *pathFlag)
+	}
+
+	p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout, disabled: *noInputFlag}
+	botIDs, err := p.ask("Bot IDs (comma-separated)", *botIDsFlag, "")
+	if err != nil {
+		return err
+	}
+	credentials, err := p.ask("API key location", *credentialsFlag, "env://KINDLY_API_KEY")
+	if err != nil {
+		return err
+	}
+	timezone, err := p.ask("Time zone", *timezoneFlag, "Europe/Oslo")
+	if err != nil {
+		return err
+	}
+	format, err := p.ask("Output format (csv, tsv, json, ndjson, xlsx, parquet)", *formatFlag, "csv")
+	if err != nil {
+		return err
+	}
+	layout, err := p.ask("Output layout (long, wide)", *layoutFlag, "long")
+	if err != nil {
+		return err
+	}
+	names, err := p.ask("Sources (comma-separated)", *sourcesFlag, "web,facebook")
+	if err != nil {
+		return err
+	}
+	sources, err := statistics.ParseSources(splitList(names))
+	if err != nil {
+		return err
+	}
+
+	c := &config.Config{
+		Timezone: timezone,
+		Output:   config.Output{Format: format, Layout: layout, Sources: sources},
+	}
+	for _, id := range splitList(botIDs) {
+		c.Bots = append(c.Bots, &config.Bot{ID: id, Credentials: credentials})
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("time zone %q: %w", c.Timezone, err)
+	}
+
+	if !*skipValidateFlag {
+		for _, bot := range c.Bots {
+			fmt.Fprintf(os.Stdout, "Validating bot %s... ", bot.ID)
+			if err := validateBot(ctx, c, bot); err != nil {
+				fmt.Fprintln(os.Stdout, "failed")
+				return fmt.Errorf("bot %s: %w", bot.ID, err)
+			}
+			fmt.Fprintln(os.Stdout, "ok")
+		}
+	}
+
+	if err := c.Save(*pathFlag); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Wrote %s\n", *pathFlag)
+
+	return nil
+}
+
+// validateBot fetches a token for bot and yesterday's sessions.
+func validateBot(ctx context.Context, c *config.Config, bot *config.Bot) error {
+	client, err := newStatisticsClient(ctx, bot, nil)
+	if err != nil {
+		return err
+	}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	_, err = client.ChatSessions(ctx, &statistics.Filter{
+		From:     to.Add(-24 * time.Hour),
+		To:       to,
+		Timezone: c.Timezone,
+	})
+	return err
+}
+
+// newStatisticsClient returns a client for bot, after fetching a token to
+// check its credentials. With a store, responses are kept in and served from
+// it, and offline the credentials are not checked.
+func newStatisticsClient(ctx context.Context, bot *config.Bot, store *config.Store) (*statistics.Client, error) {
+	creds, err := auth.ParseCredentials(ctx, bot.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &auth.TokenSource{Credentials: creds, BotID: bot.ID}
+	if store == nil || !store.Offline {
+		if _, err := ts.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	var doer statistics.Doer = oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, ts))
+	if store != nil {
+		s, err := openStore(store)
+		if err != nil {
+			return nil, err
+		}
+		doer = s.Doer(doer)
+	}
+
+	client := statistics.NewClient(statistics.WithDoer(doer))
+	client.BotID = bot.ID
+
+	return client, nil
+}
+
+// prompter asks for values on in, unless disabled.
+type prompter struct {
+	in       *bufio.Reader
+	out      io.Writer
+	disabled bool
+}
+
+// ask returns value if set. Otherwise it prompts for a value, falling back to
+// def if the answer is empty or prompting is disabled.
+func (p *prompter) ask(question, value, def string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	if p.disabled {
+		if def == "" {
+			return "", fmt.Errorf("%s: a value is required", strings.ToLower(question))
+		}
+		return def, nil
+	}
+
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	answer, err := p.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer, nil
+	}
+	if def == "" {
+		return "", fmt.Errorf("%s: a value is required", strings.ToLower(question))
+	}
+
+	return def, nil
+}
+
+func splitList(s string) []string {
+	var ret []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ret = append(ret, v)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/config"
+	"gopkg.in/yaml.v2"
+)
+
+const labelsUsage = `usage: kindly labels <command> [flags]
+
+commands:
+  export  write the label taxonomy of a bot as YAML
+  import  create the labels of a YAML taxonomy missing from a bot
+`
+
+// taxonomy is the label taxonomy of a bot as written by kindly labels
+// export.
+type taxonomy struct {
+	// Bot is the ID of the bot the taxonomy was exported from.
+	Bot    string          `yaml:"bot,omitempty"`
+	Labels []taxonomyLabel `yaml:"labels"`
+}
+
+type taxonomyLabel struct {
+	Text  string `yaml:"text"`
+	Color string `yaml:"color,omitempty"`
+}
+
+// runLabels manages the chat labels of the bots in the config file.
+func runLabels(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, labelsUsage)
+		return fmt.Errorf("missing command")
+	}
+
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "export":
+		return runLabelsExport(ctx, args)
+	case "import":
+		return runLabelsImport(ctx, args)
+	default:
+		fmt.Fprint(os.Stderr, labelsUsage)
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// runLabelsExport writes the labels of a bot in the config file as YAML, to
+// -o or stdout.
+func runLabelsExport(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("labels export", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	botIDFlag := fs.String("botid", "", "bot ID in the config file (default: the only bot)")
+	outFlag := fs.String("o", "", "file to write the taxonomy to (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := labelsClient(ctx, *pathFlag, *botIDFlag)
+	if err != nil {
+		return err
+	}
+	labels, err := client.Labels(ctx)
+	if err != nil {
+		return err
+	}
+
+	t := &taxonomy{Bot: client.BotID, Labels: make([]taxonomyLabel, 0, len(labels))}
+	for _, l := range labels {
+		t.Labels = append(t.Labels, taxonomyLabel{Text: l.Text, Color: l.Color})
+	}
+	b, err := yaml.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	if *outFlag == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+
+	return ioutil.WriteFile(*outFlag, b, 0o644)
+}
+
+// runLabelsImport gives a bot in the config file every label of the YAML
+// taxonomy in the file argument, or stdin for "-": missing labels are
+// created, renamed with -rename, and recolored. Labels not in the taxonomy
+// are kept.
+func runLabelsImport(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("labels import", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	botIDFlag := fs.String("botid", "", "bot ID in the config file (default: the only bot)")
+	renameFlag := fs.String("rename", "", "comma-separated old=new label texts to rename instead of creating the new label")
+	dryRunFlag := fs.Bool("dry-run", false, "print the changes without making them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("want the taxonomy file, or - for stdin")
+	}
+
+	renames, err := parseRenames(*renameFlag)
+	if err != nil {
+		return err
+	}
+	t, err := readTaxonomy(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	client, err := labelsClient(ctx, *pathFlag, *botIDFlag)
+	if err != nil {
+		return err
+	}
+	current, err := client.Labels(ctx)
+	if err != nil {
+		return err
+	}
+
+	want := make([]*chat.Label, 0, len(t.Labels))
+	for _, l := range t.Labels {
+		want = append(want, &chat.Label{Text: l.Text, Color: l.Color})
+	}
+	changes := chat.PlanLabelSync(current, want, renames)
+	for _, ch := range changes {
+		fmt.Fprintln(os.Stdout, ch)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(os.Stdout, "Labels of bot %s match the taxonomy\n", client.BotID)
+	}
+	if *dryRunFlag {
+		return nil
+	}
+
+	return client.ApplyLabelChanges(ctx, changes)
+}
+
+// labelsClient returns a chat client for the bot in the config file at path.
+// Labels are always read from the API, not the store, so changes are
+// planned from the current labels.
+func labelsClient(ctx context.Context, path, botID string) (*chat.Client, error) {
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	bot, err := c.Bot(botID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newChatClient(ctx, bot, nil)
+}
+
+func readTaxonomy(path string) (*taxonomy, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	t := &taxonomy{}
+	if err := yaml.UnmarshalStrict(b, t); err != nil {
+		return nil, fmt.Errorf("reading taxonomy %s: %w", path, err)
+	}
+	for i, l := range t.Labels {
+		if strings.TrimSpace(l.Text) == "" {
+			return nil, fmt.Errorf("reading taxonomy %s: label %d has no text", path, i+1)
+		}
+	}
+
+	return t, nil
+}
+
+// parseRenames parses comma-separated old=new pairs.
+func parseRenames(s string) (map[string]string, error) {
+	ret := make(map[string]string)
+	for _, pair := range splitList(s) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("parsing -rename: want old=new, got %q", pair)
+		}
+		ret[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return ret, nil
+}
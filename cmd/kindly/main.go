@@ -0,0 +1,82 @@
+// Command kindly is a command line interface to the Kindly APIs.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/atb-as/kindly"
+)
+
+const usage = `usage: kindly <command> [flags]
+
+commands:
+  init          write a config file for the kindly tools
+  anomalies     report anomalous days in a daily series
+  compare-bots  compare a metric across bots, with each bot's share
+  fallbacks     report the dialogues users most often reach right before a fallback
+  feedback      report feedback ratings per chat label
+  funnel        report the conversation funnel from greeted to resolved sessions
+  handovers     report what happened after handover: resolved, returned or abandoned
+  labels        export a bot's chat label taxonomy, or import it into another bot
+  metrics       list the metrics shared by the tools, with their columns
+  presets       list the query presets shared by the tools
+  reconcile     compare daily counts of received events with those reported by Sage
+  store         manage the local store of API responses
+  version       print the version
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "init":
+		err = runInit(ctx, args)
+	case "anomalies":
+		err = runAnomalies(ctx, args)
+	case "compare-bots":
+		err = runCompareBots(ctx, args)
+	case "fallbacks":
+		err = runFallbacks(ctx, args)
+	case "feedback":
+		err = runFeedback(ctx, args)
+	case "funnel":
+		err = runFunnel(ctx, args)
+	case "handovers":
+		err = runHandovers(ctx, args)
+	case "labels":
+		err = runLabels(ctx, args)
+	case "metrics":
+		err = runMetrics(ctx, args)
+	case "presets":
+		err = runPresets(ctx, args)
+	case "reconcile":
+		err = runReconcile(ctx, args)
+	case "store":
+		err = runStore(ctx, args)
+	case "version", "-version", "--version":
+		fmt.Fprintln(os.Stdout, kindly.Build())
+		return
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "kindly: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kindly %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
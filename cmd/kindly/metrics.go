@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/metrics"
+)
+
+// runMetrics lists the metric registry shared by the tools, with the columns
+// and granularities of each metric.
+func runMetrics(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("metrics", flag.ContinueOnError)
+	formatFlag := fs.String("format", string(encoding.Table), "output format: table, csv, tsv, json or ndjson")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := encoding.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	enc, err := encoding.NewEncoder(os.Stdout, format)
+	if err != nil {
+		return err
+	}
+	enc.Write([]string{"name", "title", "endpoint", "granularities", "counted", "columns", "description"})
+	for _, m := range metrics.All() {
+		granularities := make([]string, 0, len(m.Granularities))
+		for _, g := range m.Granularities {
+			granularities = append(granularities, g.String())
+		}
+		enc.Write([]string{
+			m.Name,
+			m.Title,
+			m.Endpoint,
+			strings.Join(granularities, ","),
+			strconv.FormatBool(m.Counted()),
+			strings.Join(m.Header(), ","),
+			m.Description,
+		})
+	}
+
+	return enc.Close()
+}
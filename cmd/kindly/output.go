@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/manifest"
+	"github.com/atb-as/kindly/statistics"
+)
+
+const manifestUsage = "file to write a JSON manifest of the output to, with its parameters, row count and SHA-256 checksum"
+
+// newOutput returns an encoder writing a table to stdout in format. With a
+// manifestPath, closing the encoder also writes the manifest of a run of
+// tool with the parameters of f and params to manifestPath, listing the
+// table as "stdout".
+func newOutput(format encoding.Format, manifestPath, tool string, f *statistics.Filter, params map[string]string) (encoding.RowEncoder, error) {
+	if manifestPath == "" {
+		return encoding.NewEncoder(os.Stdout, format)
+	}
+
+	m := newManifest(tool, f, params)
+	w := m.NewWriter("stdout", os.Stdout)
+	enc, err := encoding.NewEncoder(w, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifestEncoder{RowEncoder: enc, m: m, w: w, path: manifestPath}, nil
+}
+
+// newManifest returns the manifest of a run of tool with the parameters of f
+// and params.
+func newManifest(tool string, f *statistics.Filter, params map[string]string) *manifest.Manifest {
+	all := manifest.FilterParameters(f)
+	for k, v := range params {
+		all[k] = v
+	}
+
+	return manifest.New(tool, all)
+}
+
+// manifestEncoder counts the rows written below the header, and writes the
+// manifest when closed.
+type manifestEncoder struct {
+	encoding.RowEncoder
+	m      *manifest.Manifest
+	w      *manifest.Writer
+	path   string
+	header bool
+}
+
+func (e *manifestEncoder) Write(row []string) error {
+	if e.header {
+		e.w.Rows++
+	}
+	e.header = true
+
+	return e.RowEncoder.Write(row)
+}
+
+func (e *manifestEncoder) WriteAll(rows [][]string) error {
+	for _, row := range rows {
+		if err := e.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *manifestEncoder) Close() error {
+	if err := e.RowEncoder.Close(); err != nil {
+		return err
+	}
+	e.w.Close()
+
+	return e.m.WriteFile(e.path)
+}
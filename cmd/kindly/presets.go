@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// runPresets lists the default presets and the presets of the config file,
+// with the period they cover today and the CSV frontend path that runs them.
+func runPresets(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("presets", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	urlFlag := fs.String("url", "", "base URL of the CSV frontend to prefix the paths with, e.g. https://frontendcsv.example.com")
+	formatFlag := fs.String("format", string(encoding.Table), "output format: table, csv, tsv, json or ndjson")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := encoding.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	// The default presets are listed even before kindly init has run.
+	var presets []*statistics.Preset
+	c, err := config.Load(*pathFlag)
+	switch {
+	case err == nil:
+		presets = c.Presets
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	registry, err := statistics.NewPresets(presets...)
+	if err != nil {
+		return err
+	}
+
+	enc, err := encoding.NewEncoder(os.Stdout, format)
+	if err != nil {
+		return err
+	}
+	enc.Write([]string{"name", "metric", "from", "to", "granularity", "sources", "path", "description"})
+	now := time.Now()
+	for _, p := range registry.List() {
+		f := p.Filter(now)
+		granularity, _ := p.Granularity.MarshalText()
+		enc.Write([]string{
+			p.Name,
+			p.Metric,
+			f.From.Format("2006-01-02"),
+			f.To.Format("2006-01-02"),
+			string(granularity),
+			strings.Join(statistics.SourceStrings(p.Sources), ","),
+			strings.TrimSuffix(*urlFlag, "/") + "/" + p.Metric + "?" + p.Query().Encode(),
+			p.Description,
+		})
+	}
+
+	return enc.Close()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/metrics"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// runReconcile compares the daily counts of events received from a bot in
+// the config file, such as by a webhook receiver, with the totals Sage
+// reports, and fails if any day differs by more than the tolerance.
+func runReconcile(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	botIDFlag := fs.String("botid", "", "bot ID in the config file (default: the only bot)")
+	eventsFlag := fs.String("events", "", "CSV file of the events received per day, with columns date, bot, source and value (required)")
+	metricFlag := fs.String("metric", metrics.Sessions.Name, "counted metric the events count: "+strings.Join(metrics.Counted(), ", "))
+	daysFlag := fs.Int("days", 7, "number of days up to today to reconcile")
+	toleranceFlag := fs.Float64("tolerance", 0.01, "largest difference of a day relative to Sage's count, such as 0.01 for 1%")
+	minDiffFlag := fs.Int("min-diff", 1, "smallest difference in events of a discrepant day")
+	allFlag := fs.Bool("all", false, "print every day, not only the discrepant ones")
+	formatFlag := fs.String("format", string(encoding.Table), "output format: table, csv, tsv, json or ndjson")
+	manifestFlag := fs.String("manifest", "", manifestUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *eventsFlag == "" {
+		return fmt.Errorf("missing -events")
+	}
+	format, err := encoding.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	metric, err := metrics.Lookup(*metricFlag)
+	if err != nil {
+		return err
+	}
+	if !metric.Counted() {
+		return fmt.Errorf("metric %s is not counted per day, want %s", metric.Name, strings.Join(metrics.Counted(), ", "))
+	}
+
+	c, err := config.Load(*pathFlag)
+	if err != nil {
+		return err
+	}
+	bot, err := c.Bot(*botIDFlag)
+	if err != nil {
+		return err
+	}
+	client, err := newStatisticsClient(ctx, bot, c.Store)
+	if err != nil {
+		return err
+	}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	f := &statistics.Filter{
+		From:        to.AddDate(0, 0, -*daysFlag),
+		To:          to,
+		Timezone:    c.Timezone,
+		Sources:     c.Output.Sources,
+		Granularity: statistics.Day,
+	}
+	received, err := readEvents(*eventsFlag, bot.ID, f)
+	if err != nil {
+		return err
+	}
+	reported, err := metric.Counts(ctx, client, f)
+	if err != nil {
+		return err
+	}
+
+	days := derive.Reconcile(received, reported)
+	var discrepant int
+	for _, d := range days {
+		if d.Discrepant(*toleranceFlag, *minDiffFlag) {
+			discrepant++
+		}
+	}
+
+	params := map[string]string{
+		"bot_id":    bot.ID,
+		"format":    string(format),
+		"metric":    metric.Name,
+		"events":    *eventsFlag,
+		"tolerance": strconv.FormatFloat(*toleranceFlag, 'f', -1, 64),
+		"min_diff":  strconv.Itoa(*minDiffFlag),
+	}
+	enc, err := newOutput(format, *manifestFlag, "kindly reconcile", f, params)
+	if err != nil {
+		return err
+	}
+	enc.Write([]string{"date", "received", "reported", "diff", "ratio", "discrepant"})
+	for _, d := range days {
+		ok := !d.Discrepant(*toleranceFlag, *minDiffFlag)
+		if ok && !*allFlag {
+			continue
+		}
+		enc.Write([]string{
+			d.Date.Format("2006-01-02"),
+			strconv.Itoa(d.Received),
+			strconv.Itoa(d.Reported),
+			strconv.Itoa(d.Diff()),
+			strconv.FormatFloat(d.Ratio(), 'f', 4, 64),
+			strconv.FormatBool(!ok),
+		})
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if discrepant > 0 {
+		return fmt.Errorf("%d of %d days differ from Sage by more than %g%%", discrepant, len(days), 100**toleranceFlag)
+	}
+
+	return nil
+}
+
+// readEvents returns the counts per day in the period of f of the events of
+// the bot in the CSV file at path, in the format written by export/csvstore.
+// Rows of other bots, and of other sources if f has any, are skipped; the
+// rest are summed per day.
+func readEvents(path, botID string, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	hdr, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	col := make(map[string]int)
+	for i, name := range hdr {
+		col[name] = i
+	}
+	for _, name := range []string{"date", "value"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("reading %s: missing column %q", path, name)
+		}
+	}
+	sources := make(map[statistics.Source]bool)
+	for _, s := range f.Sources {
+		sources[s] = true
+	}
+
+	byDate := make(map[time.Time]*statistics.CountByDate)
+	var ret []*statistics.CountByDate
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if i, ok := col["bot"]; ok && row[i] != "" && row[i] != botID {
+			continue
+		}
+		if i, ok := col["source"]; ok && len(sources) > 0 && !sources[statistics.Source(row[i])] {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", row[col["date"]])
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if date.Before(f.From) || !date.Before(f.To) {
+			continue
+		}
+		value, err := strconv.ParseFloat(row[col["value"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		c, ok := byDate[date]
+		if !ok {
+			c = &statistics.CountByDate{Date: kindly.Time{Time: date}}
+			byDate[date] = c
+			ret = append(ret, c)
+		}
+		c.Count += int(value)
+	}
+
+	return ret, nil
+}
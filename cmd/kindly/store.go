@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/atb-as/kindly/config"
+	"github.com/atb-as/kindly/store/sqlite"
+)
+
+const storeUsage = `usage: kindly store <command> [flags]
+
+commands:
+  gc  remove old and duplicate responses and compact the store
+`
+
+// runStore manages the store of the config file.
+func runStore(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, storeUsage)
+		return fmt.Errorf("missing command")
+	}
+
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "gc":
+		return runStoreGC(ctx, args)
+	default:
+		fmt.Fprint(os.Stderr, storeUsage)
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// runStoreGC removes the responses of the store older than -keep, except the
+// latest response to each query, and compacts the file.
+func runStoreGC(ctx context.Context, args []string) error {
+	defaultPath, err := config.DefaultPath()
+	if err != nil {
+		defaultPath = "kindly.json"
+	}
+
+	fs := flag.NewFlagSet("store gc", flag.ContinueOnError)
+	pathFlag := fs.String("config", defaultPath, "path of the config file written by kindly init")
+	keepFlag := fs.Duration("keep", 30*24*time.Hour, "how long earlier responses are kept for comparison; the latest response to each query is always kept")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := config.Load(*pathFlag)
+	if err != nil {
+		return err
+	}
+	if c.Store == nil {
+		return fmt.Errorf("no store in %s", *pathFlag)
+	}
+	s, err := openStore(c.Store)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	stats, err := s.GC(ctx, *keepFlag)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Removed %d expired and %d duplicate responses from %s\n", stats.Expired, stats.Duplicates, c.Store.Path)
+
+	return nil
+}
+
+// openStore opens the store configured by c.
+func openStore(c *config.Store) (*sqlite.Store, error) {
+	var opts []sqlite.Option
+	if c.MaxAge != "" {
+		d, err := time.ParseDuration(c.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sqlite.WithMaxAge(d))
+	}
+	if c.Offline {
+		opts = append(opts, sqlite.WithOffline())
+	}
+
+	return sqlite.Open(c.Path, opts...)
+}
@@ -0,0 +1,163 @@
+// Package config reads and writes the shared configuration file of the
+// kindly tools, created with `kindly init`.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/atb-as/kindly/encoding"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// Config is the contents of the configuration file.
+type Config struct {
+	Bots []*Bot `json:"bots"`
+	// Timezone is the IANA time zone statistics are reported in, e.g.
+	// Europe/Oslo.
+	Timezone string `json:"timezone,omitempty"`
+	Output   Output `json:"output"`
+	// Presets are named queries added to statistics.DefaultPresets.
+	Presets []*statistics.Preset `json:"presets,omitempty"`
+	// Store keeps the responses fetched by the CLI in a local file, if set.
+	Store *Store `json:"store,omitempty"`
+}
+
+// Bot is a bot and a reference to its API key.
+type Bot struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Credentials is the location of the API key as accepted by
+	// auth.ParseCredentials, e.g. env://KINDLY_API_KEY. The key itself is
+	// never stored in the file.
+	Credentials string `json:"credentials"`
+}
+
+// Output holds output preferences.
+type Output struct {
+	// Format is one of encoding.Formats.
+	Format string `json:"format,omitempty"`
+	// Layout is long or wide.
+	Layout string `json:"layout,omitempty"`
+	// Sources are the sources statistics are broken down by.
+	Sources []statistics.Source `json:"sources,omitempty"`
+}
+
+// Store configures the local store of API responses of the CLI.
+type Store struct {
+	// Path is the SQLite file the responses are kept in.
+	Path string `json:"path"`
+	// MaxAge is how long a stored response answers repeated queries, as a
+	// duration such as 1h. Defaults to sqlite.DefaultMaxAge.
+	MaxAge string `json:"max_age,omitempty"`
+	// Offline answers queries from stored responses only, never calling the
+	// API.
+	Offline bool `json:"offline,omitempty"`
+}
+
+// DefaultPath returns the path of the configuration file in the user's
+// configuration directory, e.g. ~/.config/kindly/config.json on Linux.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "kindly", "config.json"), nil
+}
+
+// Load reads the configuration file at path.
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return &c, c.Validate()
+}
+
+// Save writes c to path, creating its directory if needed. The file is only
+// readable by the user since it reveals where the API keys are kept.
+func (c *Config) Save(path string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(b, '\n'), 0o600)
+}
+
+// Bot returns the bot with the given ID, or the only bot if id is empty and
+// there is exactly one.
+func (c *Config) Bot(id string) (*Bot, error) {
+	if id == "" && len(c.Bots) == 1 {
+		return c.Bots[0], nil
+	}
+	for _, b := range c.Bots {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("config: bot %q not found", id)
+}
+
+// Validate reports the first invalid value in c.
+func (c *Config) Validate() error {
+	if len(c.Bots) == 0 {
+		return fmt.Errorf("config: no bots")
+	}
+	for _, b := range c.Bots {
+		if b.ID == "" {
+			return fmt.Errorf("config: bot id is required")
+		}
+		if b.Credentials == "" {
+			return fmt.Errorf("config: bot %s: credentials are required", b.ID)
+		}
+	}
+
+	if _, err := encoding.ParseFormat(c.Output.Format); err != nil {
+		return fmt.Errorf("config: unknown output format %q", c.Output.Format)
+	}
+	switch c.Output.Layout {
+	case "", "long", "wide":
+	default:
+		return fmt.Errorf("config: unknown output layout %q", c.Output.Layout)
+	}
+	if _, err := statistics.NewPresets(c.Presets...); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if c.Store != nil {
+		if c.Store.Path == "" {
+			return fmt.Errorf("config: store path is required")
+		}
+		if c.Store.MaxAge != "" {
+			if _, err := time.ParseDuration(c.Store.MaxAge); err != nil {
+				return fmt.Errorf("config: store max age: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PresetRegistry returns the default presets and the presets of c.
+func (c *Config) PresetRegistry() (*statistics.Presets, error) {
+	return statistics.NewPresets(c.Presets...)
+}
@@ -0,0 +1,55 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/atb-as/kindly/config"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kindly", "config.json")
+	c := &config.Config{
+		Bots:     []*config.Bot{{ID: "123", Credentials: "env://KINDLY_API_KEY"}},
+		Timezone: "Europe/Oslo",
+		Output:   config.Output{Format: "csv", Layout: "wide"},
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatalf("c.Save() err=%v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("got mode %o, want 600", perm)
+	}
+
+	got, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("config.Load() err=%v", err)
+	}
+	bot, err := got.Bot("")
+	if err != nil {
+		t.Fatalf("got.Bot() err=%v", err)
+	}
+	if bot.ID != "123" || bot.Credentials != "env://KINDLY_API_KEY" || got.Output.Layout != "wide" {
+		t.Errorf("got %+v, want the saved config", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, c := range map[string]*config.Config{
+		"No bots":        {},
+		"No credentials": {Bots: []*config.Bot{{ID: "123"}}},
+		"Unknown format": {Bots: []*config.Bot{{ID: "123", Credentials: "env://K"}}, Output: config.Output{Format: "xml"}},
+		"No store path":  {Bots: []*config.Bot{{ID: "123", Credentials: "env://K"}}, Store: &config.Store{MaxAge: "1h"}},
+		"Bad max age":    {Bots: []*config.Bot{{ID: "123", Credentials: "env://K"}}, Store: &config.Store{Path: "kindly.db", MaxAge: "1 hour"}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: c.Validate() err=nil, want error", name)
+		}
+	}
+}
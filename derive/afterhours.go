@@ -0,0 +1,228 @@
+package derive
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// HandoverSeriesSource is the subset of *statistics.Client used by
+// AfterHours.
+type HandoverSeriesSource interface {
+	HandoversTimeSeries(ctx context.Context, f *statistics.Filter) ([]*statistics.HandoversTimeSeries, error)
+}
+
+// OpeningHours are the hours handovers are staffed, per weekday. Days
+// without intervals are closed all day.
+type OpeningHours map[time.Weekday][]Interval
+
+// Interval is a half-open range [Open, Close) of time since midnight.
+type Interval struct {
+	Open  time.Duration
+	Close time.Duration
+}
+
+// IsOpen reports whether t falls within the opening hours, by its wall
+// clock.
+func (o OpeningHours) IsOpen(t time.Time) bool {
+	h, m, s := t.Clock()
+	since := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	for _, i := range o[t.Weekday()] {
+		if since >= i.Open && since < i.Close {
+			return true
+		}
+	}
+
+	return false
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseOpeningHours parses a comma-separated list of day=open-close entries,
+// e.g. "mon-fri=08:00-16:00,sat=10:00-14:00". A day may be listed more than
+// once to give it several intervals.
+func ParseOpeningHours(spec string) (OpeningHours, error) {
+	o := make(OpeningHours)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("opening hours %q: want day=open-close", entry)
+		}
+		days, err := parseDays(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("opening hours %q: %w", entry, err)
+		}
+		i, err := parseInterval(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("opening hours %q: %w", entry, err)
+		}
+
+		for _, d := range days {
+			o[d] = append(o[d], i)
+		}
+	}
+
+	return o, nil
+}
+
+func parseDays(s string) ([]time.Weekday, error) {
+	bounds := strings.SplitN(s, "-", 2)
+	first, err := parseWeekday(bounds[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(bounds) == 1 {
+		return []time.Weekday{first}, nil
+	}
+	last, err := parseWeekday(bounds[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var days []time.Weekday
+	for d := first; ; d = (d + 1) % 7 {
+		days = append(days, d)
+		if d == last {
+			return days, nil
+		}
+	}
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for i, d := range weekdays {
+		if strings.EqualFold(s, d) {
+			return time.Weekday(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown day %q", s)
+}
+
+func parseInterval(s string) (Interval, error) {
+	bounds := strings.SplitN(s, "-", 2)
+	if len(bounds) != 2 {
+		return Interval{}, fmt.Errorf("want open-close, got %q", s)
+	}
+	opening, err := parseClock(bounds[0])
+	if err != nil {
+		return Interval{}, err
+	}
+	closing, err := parseClock(bounds[1])
+	if err != nil {
+		return Interval{}, err
+	}
+	if closing <= opening {
+		return Interval{}, fmt.Errorf("closing time %s is not after opening time %s", bounds[1], bounds[0])
+	}
+
+	return Interval{Open: opening, Close: closing}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// AfterHoursDay sums the handover requests made on one weekday.
+type AfterHoursDay struct {
+	Weekday time.Weekday
+	// Requests is all handover requests, whether made while open or closed.
+	Requests int
+	// AfterHours is the requests made outside the opening hours.
+	AfterHours int
+	// Share is AfterHours relative to Requests, or zero if there were no
+	// requests.
+	Share float64
+	// WhileClosed is the requests Kindly reports as made while handovers
+	// were closed, for comparison.
+	WhileClosed int
+}
+
+// AfterHours sums hourly handover requests in the period of f per weekday and
+// splits them by the given opening hours. Each hour is attributed by its
+// start, in the wall clock time of the filter's time zone. Days are ordered
+// from Monday to Sunday.
+func AfterHours(ctx context.Context, src HandoverSeriesSource, f *statistics.Filter, hours OpeningHours) ([]*AfterHoursDay, error) {
+	hourly := *f
+	hourly.Granularity = statistics.Hour
+	series, err := src.HandoversTimeSeries(ctx, &hourly)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]*AfterHoursDay, 7)
+	for i := range days {
+		days[i] = &AfterHoursDay{Weekday: time.Weekday((i + 1) % 7)}
+	}
+
+	for _, s := range series {
+		d := days[(s.Date.Weekday()+6)%7]
+		requests := s.Requests + s.RequestsWhileClosed
+		d.Requests += requests
+		d.WhileClosed += s.RequestsWhileClosed
+		if !hours.IsOpen(s.Date.Time) {
+			d.AfterHours += requests
+		}
+	}
+
+	for _, d := range days {
+		d.Share = ratio(float64(d.AfterHours), float64(d.Requests))
+	}
+
+	return days, nil
+}
+
+// HoursSplit is the count of a period split by the opening hours.
+type HoursSplit struct {
+	Period
+	InHours    int
+	AfterHours int
+}
+
+// AfterHoursShare returns AfterHours relative to the count of the period, or
+// zero if there is none.
+func (s *HoursSplit) AfterHoursShare() float64 {
+	return ratio(float64(s.AfterHours), float64(s.InHours+s.AfterHours))
+}
+
+// SplitByHours sums an hourly series per day, or per week for weekly
+// granularity, in the period of f, split by the opening hours. Each hour is
+// attributed by its start, in its wall clock time, as in AfterHours. Hours
+// outside the period of f are ignored.
+func SplitByHours(series []*statistics.CountByDate, f *statistics.Filter, hours OpeningHours) []*HoursSplit {
+	var ret []*HoursSplit
+	for _, chunk := range f.Chunks(f.Granularity) {
+		ret = append(ret, &HoursSplit{Period: Period{From: chunk.From, To: chunk.To}})
+	}
+
+	for _, c := range series {
+		for _, s := range ret {
+			if c.Date.Before(s.From) || !c.Date.Before(s.To) {
+				continue
+			}
+			if hours.IsOpen(c.Date.Time) {
+				s.InHours += c.Count
+			} else {
+				s.AfterHours += c.Count
+			}
+			break
+		}
+	}
+
+	return ret
+}
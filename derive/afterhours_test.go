@@ -0,0 +1,113 @@
+package derive_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+type handoverSeriesFunc func(ctx context.Context, f *statistics.Filter) ([]*statistics.HandoversTimeSeries, error)
+
+func (fn handoverSeriesFunc) HandoversTimeSeries(ctx context.Context, f *statistics.Filter) ([]*statistics.HandoversTimeSeries, error) {
+	return fn(ctx, f)
+}
+
+func TestParseOpeningHours(t *testing.T) {
+	hours, err := derive.ParseOpeningHours("mon-fri=08:00-16:00, sat=10:00-14:00,sat=18:00-24:00")
+	if err != nil {
+		t.Fatalf("derive.ParseOpeningHours() err=%v", err)
+	}
+
+	for _, tt := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{time.Date(2021, 3, 1, 8, 0, 0, 0, time.UTC), true},   // Monday
+		{time.Date(2021, 3, 1, 16, 0, 0, 0, time.UTC), false}, // Monday
+		{time.Date(2021, 3, 5, 15, 59, 0, 0, time.UTC), true}, // Friday
+		{time.Date(2021, 3, 6, 13, 0, 0, 0, time.UTC), true},  // Saturday
+		{time.Date(2021, 3, 6, 23, 0, 0, 0, time.UTC), true},  // Saturday
+		{time.Date(2021, 3, 6, 15, 0, 0, 0, time.UTC), false}, // Saturday
+		{time.Date(2021, 3, 7, 12, 0, 0, 0, time.UTC), false}, // Sunday
+	} {
+		if got := hours.IsOpen(tt.t); got != tt.want {
+			t.Errorf("IsOpen(%s) = %v, want %v", tt.t.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"mon", "xyz=08:00-16:00", "mon=16:00-08:00", "mon=8-16"} {
+		if _, err := derive.ParseOpeningHours(spec); err == nil {
+			t.Errorf("derive.ParseOpeningHours(%q) err=nil, want error", spec)
+		}
+	}
+}
+
+func TestAfterHours(t *testing.T) {
+	src := handoverSeriesFunc(func(ctx context.Context, f *statistics.Filter) ([]*statistics.HandoversTimeSeries, error) {
+		if f.Granularity != statistics.Hour {
+			t.Errorf("got granularity %s, want hour", f.Granularity)
+		}
+		return []*statistics.HandoversTimeSeries{
+			{Date: kindly.Time{Time: time.Date(2021, 3, 1, 7, 0, 0, 0, time.UTC)}, Handovers: statistics.Handovers{RequestsWhileClosed: 2}},
+			{Date: kindly.Time{Time: time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)}, Handovers: statistics.Handovers{Requests: 5}},
+			{Date: kindly.Time{Time: time.Date(2021, 3, 1, 16, 0, 0, 0, time.UTC)}, Handovers: statistics.Handovers{Requests: 3}},
+			{Date: kindly.Time{Time: time.Date(2021, 3, 7, 12, 0, 0, 0, time.UTC)}, Handovers: statistics.Handovers{RequestsWhileClosed: 4}},
+		}, nil
+	})
+	hours, _ := derive.ParseOpeningHours("mon-fri=08:00-16:00")
+
+	days, err := derive.AfterHours(context.Background(), src, &statistics.Filter{}, hours)
+	if err != nil {
+		t.Fatalf("derive.AfterHours() err=%v", err)
+	}
+	if len(days) != 7 || days[0].Weekday != time.Monday || days[6].Weekday != time.Sunday {
+		t.Fatalf("got %d days, want Monday to Sunday", len(days))
+	}
+
+	mon := days[0]
+	if mon.Requests != 10 || mon.AfterHours != 5 || mon.WhileClosed != 2 || mon.Share != 0.5 {
+		t.Errorf("got Monday %+v, want 10 requests, 5 after hours, 2 while closed", mon)
+	}
+	if sun := days[6]; sun.Requests != 4 || sun.AfterHours != 4 || sun.Share != 1 {
+		t.Errorf("got Sunday %+v, want all 4 requests after hours", sun)
+	}
+}
+
+func TestSplitByHours(t *testing.T) {
+	hours, err := derive.ParseOpeningHours("mon-fri=08:00-16:00")
+	if err != nil {
+		t.Fatalf("derive.ParseOpeningHours() err=%v", err)
+	}
+	count := func(day, hour, n int) *statistics.CountByDate {
+		return &statistics.CountByDate{Date: kindly.Time{Time: time.Date(2021, 3, day, hour, 0, 0, 0, time.UTC)}, Count: n}
+	}
+	series := []*statistics.CountByDate{
+		count(1, 7, 2),  // Monday, before opening
+		count(1, 9, 5),  // Monday
+		count(1, 16, 3), // Monday, at closing
+		count(2, 12, 4), // Tuesday
+		count(6, 12, 6), // Saturday
+		count(8, 12, 1), // next Monday, outside the period
+	}
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	got := derive.SplitByHours(series, &statistics.Filter{From: from, To: from.AddDate(0, 0, 7), Granularity: statistics.Day}, hours)
+	if len(got) != 7 {
+		t.Fatalf("got %d days, want 7", len(got))
+	}
+	if got[0].InHours != 5 || got[0].AfterHours != 5 || got[0].AfterHoursShare() != 0.5 {
+		t.Errorf("got Monday %+v, want 5 in and 5 after hours", got[0])
+	}
+	if got[1].InHours != 4 || got[1].AfterHours != 0 || got[5].AfterHours != 6 || got[6].AfterHoursShare() != 0 {
+		t.Errorf("got Tuesday %+v, Saturday %+v and Sunday %+v", got[1], got[5], got[6])
+	}
+
+	weekly := derive.SplitByHours(series, &statistics.Filter{From: from, To: from.AddDate(0, 0, 7), Granularity: statistics.Week}, hours)
+	if len(weekly) != 1 || weekly[0].InHours != 9 || weekly[0].AfterHours != 11 {
+		t.Errorf("got %+v, want one week with 9 in and 11 after hours", weekly)
+	}
+}
@@ -0,0 +1,143 @@
+// Package alerts detects chat labels whose volume spikes compared to a
+// trailing baseline and notifies about them.
+package alerts
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// LabelSource is the subset of *statistics.Client used by Detector.
+type LabelSource interface {
+	ChatLabels(ctx context.Context, f *statistics.Filter) ([]*statistics.ChatLabel, error)
+}
+
+// Detector compares label counts for the current window against the mean
+// count of the same label in the windows before it.
+type Detector struct {
+	Source LabelSource
+	// Window is the length of the current period and of each baseline
+	// period. Defaults to 24 hours. The statistics API filters on whole
+	// days, so it should be a multiple of 24 hours.
+	Window time.Duration
+	// Baseline is the number of trailing windows the mean is computed over.
+	// Defaults to 7.
+	Baseline int
+	// Threshold is the ratio of the current count to the baseline mean at
+	// which a label is flagged. Defaults to 2.
+	Threshold float64
+	// MinCount is the current count a label must reach to be flagged, which
+	// keeps rarely used labels from alerting on every few chats.
+	MinCount int
+	// Limit is the number of labels fetched per window. Labels outside the
+	// top Limit of a baseline window count as zero in that window.
+	Limit int
+	// Sources, if set, restricts the counts to the given sources.
+	Sources []statistics.Source
+}
+
+// Spike is a label whose count in the current window exceeds the threshold.
+type Spike struct {
+	LabelID string
+	Text    string
+	From    time.Time
+	To      time.Time
+	Count   int
+	// Baseline is the mean count per window in the baseline period.
+	Baseline float64
+	// Ratio is Count relative to Baseline, or +Inf for labels that were not
+	// used in the baseline period.
+	Ratio float64
+}
+
+// Detect returns the labels that spike in the window ending at end, ordered
+// by descending ratio.
+func (d *Detector) Detect(ctx context.Context, end time.Time) ([]*Spike, error) {
+	window := d.Window
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	baseline := d.Baseline
+	if baseline <= 0 {
+		baseline = 7
+	}
+	threshold := d.Threshold
+	if threshold <= 0 {
+		threshold = 2
+	}
+
+	current, err := d.counts(ctx, end.Add(-window), end)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]int)
+	for i := 1; i <= baseline; i++ {
+		to := end.Add(-time.Duration(i) * window)
+		counts, err := d.counts(ctx, to.Add(-window), to)
+		if err != nil {
+			return nil, err
+		}
+		for id, l := range counts {
+			totals[id] += l.Count
+		}
+	}
+
+	var spikes []*Spike
+	for id, l := range current {
+		if l.Count < d.MinCount || l.Count == 0 {
+			continue
+		}
+
+		mean := float64(totals[id]) / float64(baseline)
+		ratio := math.Inf(1)
+		if mean > 0 {
+			ratio = float64(l.Count) / mean
+		}
+		if ratio < threshold {
+			continue
+		}
+
+		spikes = append(spikes, &Spike{
+			LabelID:  id,
+			Text:     l.Text,
+			From:     end.Add(-window),
+			To:       end,
+			Count:    l.Count,
+			Baseline: mean,
+			Ratio:    ratio,
+		})
+	}
+
+	sort.Slice(spikes, func(i, j int) bool {
+		if spikes[i].Ratio != spikes[j].Ratio {
+			return spikes[i].Ratio > spikes[j].Ratio
+		}
+		return spikes[i].LabelID < spikes[j].LabelID
+	})
+
+	return spikes, nil
+}
+
+func (d *Detector) counts(ctx context.Context, from, to time.Time) (map[string]*statistics.ChatLabel, error) {
+	labels, err := d.Source.ChatLabels(ctx, &statistics.Filter{
+		From:    from,
+		To:      to,
+		Limit:   d.Limit,
+		Sources: d.Sources,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*statistics.ChatLabel, len(labels))
+	for _, l := range labels {
+		ret[l.ID] = l
+	}
+
+	return ret, nil
+}
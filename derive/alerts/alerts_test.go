@@ -0,0 +1,131 @@
+package alerts_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/derive/alerts"
+	"github.com/atb-as/kindly/derive/anomaly"
+	"github.com/atb-as/kindly/statistics"
+)
+
+type labelSourceFunc func(ctx context.Context, f *statistics.Filter) ([]*statistics.ChatLabel, error)
+
+func (fn labelSourceFunc) ChatLabels(ctx context.Context, f *statistics.Filter) ([]*statistics.ChatLabel, error) {
+	return fn(ctx, f)
+}
+
+func TestDetector_Detect(t *testing.T) {
+	end := time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)
+	src := labelSourceFunc(func(ctx context.Context, f *statistics.Filter) ([]*statistics.ChatLabel, error) {
+		if f.To.Sub(f.From) != 24*time.Hour {
+			t.Errorf("got window %s, want 24h", f.To.Sub(f.From))
+		}
+		if f.To.Equal(end) {
+			return []*statistics.ChatLabel{
+				{ID: "delay", Text: "Delay", Count: 40},
+				{ID: "refund", Text: "Refund", Count: 12},
+				{ID: "outage", Text: "Outage", Count: 6},
+				{ID: "rare", Text: "Rare", Count: 2},
+			}, nil
+		}
+		return []*statistics.ChatLabel{
+			{ID: "delay", Text: "Delay", Count: 10},
+			{ID: "refund", Text: "Refund", Count: 10},
+		}, nil
+	})
+
+	d := &alerts.Detector{Source: src, Baseline: 3, MinCount: 5}
+	spikes, err := d.Detect(context.Background(), end)
+	if err != nil {
+		t.Fatalf("d.Detect() err=%v", err)
+	}
+
+	if len(spikes) != 2 {
+		t.Fatalf("got %d spikes, want 2", len(spikes))
+	}
+	if spikes[0].LabelID != "outage" || !math.IsInf(spikes[0].Ratio, 1) {
+		t.Errorf("got %+v, want new label outage first", spikes[0])
+	}
+	if s := spikes[1]; s.LabelID != "delay" || s.Baseline != 10 || s.Ratio != 4 {
+		t.Errorf("got %+v, want delay with baseline 10 and ratio 4", s)
+	}
+}
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return d(r)
+}
+
+func TestNotifiers(t *testing.T) {
+	from := time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)
+	spikes := []*alerts.Spike{
+		{LabelID: "outage", Text: "Outage", From: from, To: from.Add(24 * time.Hour), Count: 6, Ratio: math.Inf(1)},
+		{LabelID: "delay", Text: "Delay", From: from, To: from.Add(24 * time.Hour), Count: 40, Baseline: 10, Ratio: 4},
+	}
+
+	var bodies []string
+	doer := doerFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	err := alerts.Notify(context.Background(), spikes,
+		&alerts.Webhook{URL: "https://example.com/hook", Doer: doer},
+		&alerts.Slack{WebhookURL: "https://hooks.slack.com/services/x", Doer: doer})
+	if err != nil {
+		t.Fatalf("alerts.Notify() err=%v", err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("got %d requests, want 2", len(bodies))
+	}
+
+	var payload []map[string]interface{}
+	if err := json.Unmarshal([]byte(bodies[0]), &payload); err != nil {
+		t.Fatalf("webhook body: %v", err)
+	}
+	if _, ok := payload[0]["ratio"]; ok {
+		t.Errorf("got ratio for new label, want none")
+	}
+	if got := payload[1]["ratio"]; got != 4.0 {
+		t.Errorf("got ratio %v, want 4", got)
+	}
+
+	var msg struct{ Text string }
+	if err := json.Unmarshal([]byte(bodies[1]), &msg); err != nil {
+		t.Fatalf("slack body: %v", err)
+	}
+	for _, want := range []string{"*Outage*: 6 (new)", "*Delay*: 40 (4.0x the usual 10.0)"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("got %q, want it to contain %q", msg.Text, want)
+		}
+	}
+
+	bodies = nil
+	if err := alerts.Notify(context.Background(), nil, &alerts.Webhook{URL: "https://example.com/hook", Doer: doer}); err != nil || len(bodies) != 0 {
+		t.Errorf("got %d requests, err=%v for no spikes, want none", len(bodies), err)
+	}
+}
+
+func TestFromAnomalies(t *testing.T) {
+	day := time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)
+	got := alerts.FromAnomalies(anomaly.Fallbacks, []*anomaly.Anomaly{
+		{Point: anomaly.Point{Time: day, Value: 24.4}, Median: 8},
+	})
+
+	if len(got) != 1 {
+		t.Fatalf("got %d spikes, want 1", len(got))
+	}
+	if s := got[0]; s.Text != "Fallback rate (%)" || s.Count != 24 || s.Ratio != 24.4/8 || !s.To.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("got %+v, want a day's fallback rate of 24%% against 8%%", s)
+	}
+}
@@ -0,0 +1,32 @@
+package alerts
+
+import (
+	"math"
+
+	"github.com/atb-as/kindly/derive/anomaly"
+)
+
+// FromAnomalies returns anomalies of metric as spikes, so they can be passed
+// to the same notifiers. The label of each spike is the metric, and its
+// baseline the median of the anomaly's window. Values are rounded to whole
+// counts, or whole percentage points for the fallback rate.
+func FromAnomalies(metric anomaly.Metric, anomalies []*anomaly.Anomaly) []*Spike {
+	ret := make([]*Spike, 0, len(anomalies))
+	for _, a := range anomalies {
+		ratio := math.Inf(1)
+		if a.Median > 0 {
+			ratio = a.Value / a.Median
+		}
+		ret = append(ret, &Spike{
+			LabelID:  "anomaly:" + string(metric),
+			Text:     metric.Title(),
+			From:     a.Time,
+			To:       a.Time.AddDate(0, 0, 1),
+			Count:    int(math.Round(a.Value)),
+			Baseline: a.Median,
+			Ratio:    ratio,
+		})
+	}
+
+	return ret
+}
@@ -0,0 +1,141 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// Notifier is told about the spikes found by a Detector.
+type Notifier interface {
+	Notify(ctx context.Context, spikes []*Spike) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, spikes []*Spike) error
+
+// Notify implements Notifier.
+func (f NotifierFunc) Notify(ctx context.Context, spikes []*Spike) error {
+	return f(ctx, spikes)
+}
+
+// Notify passes spikes to every notifier and returns the first error. Nothing
+// is sent if there are no spikes.
+func Notify(ctx context.Context, spikes []*Spike, notifiers ...Notifier) error {
+	if len(spikes) == 0 {
+		return nil
+	}
+
+	var first error
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, spikes); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+// Webhook posts spikes as a JSON array to URL.
+type Webhook struct {
+	URL string
+	// Doer defaults to http.DefaultClient.
+	Doer Doer
+}
+
+type webhookSpike struct {
+	LabelID  string  `json:"label_id"`
+	Text     string  `json:"label_text"`
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Count    int     `json:"count"`
+	Baseline float64 `json:"baseline"`
+	// Ratio is omitted for new labels, as JSON has no infinity.
+	Ratio *float64 `json:"ratio,omitempty"`
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, spikes []*Spike) error {
+	payload := make([]webhookSpike, 0, len(spikes))
+	for _, s := range spikes {
+		ws := webhookSpike{
+			LabelID:  s.LabelID,
+			Text:     s.Text,
+			From:     s.From.Format(timeLayout),
+			To:       s.To.Format(timeLayout),
+			Count:    s.Count,
+			Baseline: s.Baseline,
+		}
+		if !math.IsInf(s.Ratio, 1) {
+			ratio := s.Ratio
+			ws.Ratio = &ratio
+		}
+		payload = append(payload, ws)
+	}
+
+	return post(ctx, w.Doer, w.URL, payload)
+}
+
+// Slack posts a message listing the spikes to a Slack incoming webhook.
+type Slack struct {
+	WebhookURL string
+	// Doer defaults to http.DefaultClient.
+	Doer Doer
+}
+
+// Notify implements Notifier.
+func (s *Slack) Notify(ctx context.Context, spikes []*Spike) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d chat label(s) spiking since %s:", len(spikes), spikes[0].From.Format(timeLayout))
+	for _, spike := range spikes {
+		if math.IsInf(spike.Ratio, 1) {
+			fmt.Fprintf(&b, "\n• *%s*: %d (new)", spike.Text, spike.Count)
+			continue
+		}
+		fmt.Fprintf(&b, "\n• *%s*: %d (%.1fx the usual %.1f)", spike.Text, spike.Count, spike.Ratio, spike.Baseline)
+	}
+
+	return post(ctx, s.Doer, s.WebhookURL, map[string]string{"text": b.String()})
+}
+
+const timeLayout = "2006-01-02 15:04"
+
+func post(ctx context.Context, doer Doer, url string, v interface{}) error {
+	if doer == nil {
+		doer = http.DefaultClient
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("alerts: unexpected status %q: %s", resp.Status, msg)
+	}
+
+	return nil
+}
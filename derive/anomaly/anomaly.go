@@ -0,0 +1,209 @@
+// Package anomaly finds anomalous points in daily series, such as a spike in
+// the fallback rate after a content deploy, by comparing every point with the
+// median and median absolute deviation (MAD) of the points before it.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// Point is the value of a series on one day.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Anomaly is a point that deviates from the points before it.
+type Anomaly struct {
+	Point
+	// Median and MAD are of the trailing window of the point.
+	Median float64
+	MAD    float64
+	// Score is the robust z-score of the point, 0.6745 * (Value - Median) /
+	// MAD. It is ±Inf if the window has no spread.
+	Score float64
+}
+
+// Direction selects which deviations are anomalous.
+type Direction int
+
+const (
+	// Both reports points above and below the median.
+	Both Direction = iota
+	// Up only reports points above the median, e.g. fallback spikes.
+	Up
+	// Down only reports points below the median, e.g. a drop in sessions.
+	Down
+)
+
+// Detector finds anomalies with a rolling median and MAD.
+type Detector struct {
+	// Window is the number of preceding points each point is compared with.
+	// Points with fewer preceding points are not scored. Defaults to 14.
+	Window int
+	// Threshold is the absolute score at which a point is anomalous.
+	// Defaults to 3.5.
+	Threshold float64
+	Direction Direction
+}
+
+// Detect returns the anomalous points of series, which must be ordered by
+// time, in order.
+func (d *Detector) Detect(series []Point) []*Anomaly {
+	window := d.Window
+	if window <= 0 {
+		window = 14
+	}
+	threshold := d.Threshold
+	if threshold <= 0 {
+		threshold = 3.5
+	}
+
+	var ret []*Anomaly
+	for i := window; i < len(series); i++ {
+		values := make([]float64, 0, window)
+		for _, p := range series[i-window : i] {
+			values = append(values, p.Value)
+		}
+		med := median(values)
+		for j, v := range values {
+			values[j] = math.Abs(v - med)
+		}
+		mad := median(values)
+
+		a := &Anomaly{Point: series[i], Median: med, MAD: mad, Score: score(series[i].Value, med, mad)}
+		switch {
+		case d.Direction == Up && a.Score <= 0, d.Direction == Down && a.Score >= 0:
+			continue
+		case math.Abs(a.Score) >= threshold:
+			ret = append(ret, a)
+		}
+	}
+
+	return ret
+}
+
+func score(v, median, mad float64) float64 {
+	if mad == 0 {
+		switch {
+		case v > median:
+			return math.Inf(1)
+		case v < median:
+			return math.Inf(-1)
+		default:
+			return 0
+		}
+	}
+
+	return 0.6745 * (v - median) / mad
+}
+
+// median returns the median of values, reordering them.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// Source is the subset of *statistics.Client used by Series.
+type Source interface {
+	FallbackRateTimeSeries(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDateWithRate, error)
+	ChatSessions(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error)
+	UserMessages(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error)
+}
+
+// Metric is a daily series that can be scanned for anomalies.
+type Metric string
+
+const (
+	// Fallbacks is the fallback rate in percent.
+	Fallbacks Metric = "fallbacks"
+	Sessions  Metric = "sessions"
+	Messages  Metric = "messages"
+)
+
+// Metrics are the supported metrics.
+var Metrics = []Metric{Fallbacks, Sessions, Messages}
+
+// ParseMetric returns the metric named s.
+func ParseMetric(s string) (Metric, error) {
+	for _, m := range Metrics {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown metric %q, want fallbacks, sessions or messages", s)
+}
+
+// Title returns a human readable name of the metric, with its unit.
+func (m Metric) Title() string {
+	switch m {
+	case Fallbacks:
+		return "Fallback rate (%)"
+	case Sessions:
+		return "Sessions"
+	case Messages:
+		return "Messages"
+	default:
+		return string(m)
+	}
+}
+
+// Series fetches the daily series of the metric in the period of f, summed
+// over the sources of f.
+func Series(ctx context.Context, src Source, m Metric, f *statistics.Filter) ([]Point, error) {
+	temp := *f
+	temp.Granularity = statistics.Day
+
+	var counts []*statistics.CountByDate
+	switch m {
+	case Fallbacks:
+		rates, err := src.FallbackRateTimeSeries(ctx, &temp)
+		if err != nil {
+			return nil, err
+		}
+		ret := make([]Point, 0, len(rates))
+		for _, r := range rates {
+			ret = append(ret, Point{Time: r.Date.Time, Value: 100 * r.Rate})
+		}
+		return ret, nil
+	case Sessions:
+		var err error
+		if counts, err = src.ChatSessions(ctx, &temp); err != nil {
+			return nil, err
+		}
+	case Messages:
+		var err error
+		if counts, err = src.UserMessages(ctx, &temp); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown metric %q", m)
+	}
+
+	// Series of several sources may repeat dates.
+	ret := make([]Point, 0, len(counts))
+	index := make(map[time.Time]int)
+	for _, c := range counts {
+		if i, ok := index[c.Date.Time]; ok {
+			ret[i].Value += float64(c.Count)
+			continue
+		}
+		index[c.Date.Time] = len(ret)
+		ret = append(ret, Point{Time: c.Date.Time, Value: float64(c.Count)})
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Time.Before(ret[j].Time) })
+
+	return ret, nil
+}
@@ -0,0 +1,77 @@
+package anomaly_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/derive/anomaly"
+	"github.com/atb-as/kindly/statistics"
+)
+
+func series(values ...float64) []anomaly.Point {
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	ret := make([]anomaly.Point, 0, len(values))
+	for i, v := range values {
+		ret = append(ret, anomaly.Point{Time: start.AddDate(0, 0, i), Value: v})
+	}
+
+	return ret
+}
+
+func TestDetector_Detect(t *testing.T) {
+	s := series(10, 12, 11, 9, 10, 11, 30, 10, 2)
+	d := &anomaly.Detector{Window: 5}
+
+	got := d.Detect(s)
+	if len(got) != 2 {
+		t.Fatalf("got %d anomalies, want 2", len(got))
+	}
+	if a := got[0]; a.Value != 30 || a.Median != 11 || a.MAD != 1 || a.Score != 0.6745*19 {
+		t.Errorf("got %+v, want the spike to 30 with median 11 and MAD 1", a)
+	}
+	if got[1].Value != 2 || got[1].Score >= 0 {
+		t.Errorf("got %+v, want the drop to 2", got[1])
+	}
+
+	d.Direction = anomaly.Up
+	if got := d.Detect(s); len(got) != 1 || got[0].Value != 30 {
+		t.Errorf("got %+v upwards, want only the spike", got)
+	}
+
+	flat := (&anomaly.Detector{Window: 3}).Detect(series(5, 5, 5, 6, 5))
+	if len(flat) != 1 || !math.IsInf(flat[0].Score, 1) {
+		t.Errorf("got %+v for a flat series, want an infinite score for 6", flat)
+	}
+}
+
+type fakeSource struct{}
+
+func (fakeSource) FallbackRateTimeSeries(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDateWithRate, error) {
+	return []*statistics.CountByDateWithRate{{Rate: 0.125}}, nil
+}
+
+func (fakeSource) ChatSessions(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+	day := kindly.Time{Time: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}
+	return []*statistics.CountByDate{{Date: day, Count: 2}, {Date: day, Count: 3}}, nil
+}
+
+func (fakeSource) UserMessages(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+	return nil, nil
+}
+
+func TestSeries(t *testing.T) {
+	f := &statistics.Filter{Sources: []statistics.Source{statistics.Web, statistics.App}}
+
+	got, err := anomaly.Series(context.Background(), fakeSource{}, anomaly.Fallbacks, f)
+	if err != nil || len(got) != 1 || got[0].Value != 12.5 {
+		t.Errorf("Series(fallbacks) = %+v, %v, want 12.5 percent", got, err)
+	}
+
+	got, err = anomaly.Series(context.Background(), fakeSource{}, anomaly.Sessions, f)
+	if err != nil || len(got) != 1 || got[0].Value != 5 {
+		t.Errorf("Series(sessions) = %+v, %v, want the sources summed to 5", got, err)
+	}
+}
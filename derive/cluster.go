@@ -0,0 +1,127 @@
+package derive
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultClusterSimilarity is the similarity at which ClusterFallbacks groups
+// two phrasings unless given another.
+const DefaultClusterSimilarity = 0.5
+
+// Phrasing is an utterance, normalized, and the number of times it was seen.
+type Phrasing struct {
+	Text  string
+	Count int
+}
+
+// FallbackCluster is a group of similar utterances the bot fell back on,
+// likely asking for the same missing intent.
+type FallbackCluster struct {
+	// Example is the most frequent phrasing of the cluster.
+	Example string
+	// Size is the number of utterances in the cluster.
+	Size int
+	// Share is Size as a fraction of all utterances.
+	Share float64
+	// Phrasings are the phrasings of the cluster, most frequent first.
+	Phrasings []*Phrasing
+}
+
+// ClusterFallbacks groups utterances, such as the user messages answered with
+// a fallback, by text similarity and returns the clusters ordered by size, so
+// trainers can start with the intents missed most often.
+//
+// Utterances are compared after lowercasing them and removing punctuation, by
+// the Jaccard similarity of their character trigrams, which tolerates typos
+// and reordered words. A phrasing joins the cluster of the first more frequent
+// phrasing it is at least similarity similar to, or DefaultClusterSimilarity
+// if similarity is zero.
+func ClusterFallbacks(utterances []string, similarity float64) []*FallbackCluster {
+	if similarity <= 0 {
+		similarity = DefaultClusterSimilarity
+	}
+
+	counts := make(map[string]int)
+	total := 0
+	for _, u := range utterances {
+		if n := normalizeUtterance(u); n != "" {
+			counts[n]++
+			total++
+		}
+	}
+
+	phrasings := make([]*Phrasing, 0, len(counts))
+	for text, n := range counts {
+		phrasings = append(phrasings, &Phrasing{Text: text, Count: n})
+	}
+	sort.Slice(phrasings, func(i, j int) bool {
+		if phrasings[i].Count != phrasings[j].Count {
+			return phrasings[i].Count > phrasings[j].Count
+		}
+		return phrasings[i].Text < phrasings[j].Text
+	})
+
+	var clusters []*FallbackCluster
+	var examples []map[string]bool
+	for _, p := range phrasings {
+		grams := trigrams(p.Text)
+		var cluster *FallbackCluster
+		for i, c := range clusters {
+			if jaccard(grams, examples[i]) >= similarity {
+				cluster = c
+				break
+			}
+		}
+		if cluster == nil {
+			cluster = &FallbackCluster{Example: p.Text}
+			clusters = append(clusters, cluster)
+			examples = append(examples, grams)
+		}
+		cluster.Size += p.Count
+		cluster.Phrasings = append(cluster.Phrasings, p)
+	}
+
+	for _, c := range clusters {
+		c.Share = ratio(float64(c.Size), float64(total))
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Size > clusters[j].Size
+	})
+
+	return clusters
+}
+
+// normalizeUtterance lowercases s and reduces it to its words separated by
+// single spaces.
+func normalizeUtterance(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// trigrams returns the character trigrams of the words of s, padded with
+// spaces so short words have trigrams too.
+func trigrams(s string) map[string]bool {
+	grams := make(map[string]bool)
+	for _, word := range strings.Fields(s) {
+		r := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			grams[string(r[i:i+3])] = true
+		}
+	}
+
+	return grams
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	shared := 0
+	for g := range a {
+		if b[g] {
+			shared++
+		}
+	}
+
+	return ratio(float64(shared), float64(len(a)+len(b)-shared))
+}
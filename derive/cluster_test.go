@@ -0,0 +1,38 @@
+package derive_test
+
+import (
+	"testing"
+
+	"github.com/atb-as/kindly/derive"
+)
+
+func TestClusterFallbacks(t *testing.T) {
+	got := derive.ClusterFallbacks([]string{
+		"Can I bring my bike on the bus?",
+		"can i bring my bike on the bus",
+		"can I bring a bike on the bus",
+		"Kan jeg ta med sykkel på bussen?",
+		"bike on bus?",
+		"",
+		"...",
+		"where is my lost umbrella",
+		"Where is my lost umbrella?!",
+		"can I bring my bike on the bus",
+	}, 0)
+
+	if len(got) != 4 {
+		for _, c := range got {
+			t.Logf("%+v", c)
+		}
+		t.Fatalf("got %d clusters, want 4", len(got))
+	}
+	if got[0].Example != "can i bring my bike on the bus" || got[0].Size != 4 || len(got[0].Phrasings) != 2 {
+		t.Errorf("got first cluster %+v, want the bike question with 4 utterances in 2 phrasings", got[0])
+	}
+	if got[1].Example != "where is my lost umbrella" || got[1].Size != 2 {
+		t.Errorf("got second cluster %+v, want the umbrella question with 2 utterances", got[1])
+	}
+	if want := 0.5; got[0].Share != want {
+		t.Errorf("got Share %v, want %v", got[0].Share, want)
+	}
+}
@@ -0,0 +1,174 @@
+package derive
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// MetricMessages is the number of user messages, a metric of Compare besides
+// the KPIs of a Scorecard.
+const MetricMessages = "messages"
+
+// CompareMetrics are the metrics Compare accepts.
+var CompareMetrics = []string{
+	KPISessions,
+	MetricMessages,
+	KPIMessagesPerSession,
+	KPIFallbackRate,
+	KPIContainmentRate,
+	KPIHandoverRate,
+	KPIPositiveFeedbackShare,
+}
+
+// NamedPeriod is a period named as it was given to ParsePeriod.
+type NamedPeriod struct {
+	Name string
+	Period
+}
+
+// ParsePeriod parses a year such as "2024", a month such as "2024-01", an ISO
+// week such as "2024-W05" or a day such as "2024-01-31" into the period it
+// covers in loc.
+func ParsePeriod(s string, loc *time.Location) (NamedPeriod, error) {
+	s = strings.TrimSpace(s)
+	p := NamedPeriod{Name: s}
+	switch {
+	case len(s) == 4:
+		year, err := strconv.Atoi(s)
+		if err != nil {
+			break
+		}
+		p.From = time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+		p.To = p.From.AddDate(1, 0, 0)
+		return p, nil
+	case len(s) == 8 && s[4:6] == "-W":
+		year, err := strconv.Atoi(s[:4])
+		if err != nil {
+			break
+		}
+		week, err := strconv.Atoi(s[6:])
+		if err != nil || week < 1 || week > 53 {
+			break
+		}
+		// Week 1 is the week with the year's first Thursday, so it holds
+		// January 4th.
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+		monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+		p.From = monday.AddDate(0, 0, 7*(week-1))
+		if y, _ := p.From.ISOWeek(); y != year {
+			break
+		}
+		p.To = p.From.AddDate(0, 0, 7)
+		return p, nil
+	case len(s) == 7:
+		from, err := time.ParseInLocation("2006-01", s, loc)
+		if err != nil {
+			break
+		}
+		p.From, p.To = from, from.AddDate(0, 1, 0)
+		return p, nil
+	case len(s) == 10:
+		from, err := time.ParseInLocation("2006-01-02", s, loc)
+		if err != nil {
+			break
+		}
+		p.From, p.To = from, from.AddDate(0, 0, 1)
+		return p, nil
+	}
+
+	return NamedPeriod{}, fmt.Errorf("derive: period %q is not a year, month, ISO week or day, such as 2024, 2024-01, 2024-W05 or 2024-01-31", s)
+}
+
+// Comparison is the value of a metric in several periods, with the change
+// between every pair of them.
+type Comparison struct {
+	Metric  string
+	Periods []*ComparedPeriod
+}
+
+// ComparedPeriod is the value of the metric of a Comparison in a period.
+type ComparedPeriod struct {
+	NamedPeriod
+	Value float64
+	// Changes are the changes of Value relative to the value of each period
+	// of the comparison, in order, or zero where that value is zero.
+	Changes []float64
+}
+
+// Compare computes metric, one of CompareMetrics, for each period with the
+// other criteria of f, and the change between every pair of periods.
+func Compare(ctx context.Context, src ScorecardSource, f *statistics.Filter, metric string, periods []NamedPeriod) (*Comparison, error) {
+	c := &Comparison{Metric: metric, Periods: make([]*ComparedPeriod, 0, len(periods))}
+	for _, p := range periods {
+		pf := *f
+		pf.From, pf.To = p.From, p.To
+		v, err := metricValue(ctx, src, &pf, metric)
+		if err != nil {
+			return nil, err
+		}
+		c.Periods = append(c.Periods, &ComparedPeriod{NamedPeriod: p, Value: v})
+	}
+
+	for _, p := range c.Periods {
+		p.Changes = make([]float64, len(c.Periods))
+		for i, base := range c.Periods {
+			p.Changes[i] = ratio(p.Value-base.Value, base.Value)
+		}
+	}
+
+	return c, nil
+}
+
+// metricValue computes metric for f, calling only the endpoints it needs.
+func metricValue(ctx context.Context, src ScorecardSource, f *statistics.Filter, metric string) (float64, error) {
+	switch metric {
+	case KPISessions, MetricMessages, KPIMessagesPerSession:
+		sessions, err := src.ChatSessions(ctx, f)
+		if err != nil {
+			return 0, err
+		}
+		if metric == KPISessions {
+			return float64(sum(sessions)), nil
+		}
+		messages, err := src.UserMessages(ctx, f)
+		if err != nil {
+			return 0, err
+		}
+		if metric == MetricMessages {
+			return float64(sum(messages)), nil
+		}
+		return ratio(float64(sum(messages)), float64(sum(sessions))), nil
+	case KPIFallbackRate:
+		fallbacks, err := src.FallbackRateTotal(ctx, f)
+		if err != nil {
+			return 0, err
+		}
+		return fallbacks.Rate, nil
+	case KPIContainmentRate, KPIHandoverRate:
+		sessions, err := src.ChatSessions(ctx, f)
+		if err != nil {
+			return 0, err
+		}
+		handovers, err := src.HandoversTotal(ctx, f)
+		if err != nil {
+			return 0, err
+		}
+		if metric == KPIContainmentRate {
+			return 1 - ratio(float64(handovers.Started), float64(sum(sessions))), nil
+		}
+		return ratio(float64(handovers.Requests+handovers.RequestsWhileClosed), float64(sum(sessions))), nil
+	case KPIPositiveFeedbackShare:
+		feedback, err := src.AggregatedFeedback(ctx, f)
+		if err != nil {
+			return 0, err
+		}
+		return positiveShare(feedback.Binary), nil
+	default:
+		return 0, fmt.Errorf("derive: unknown metric %q, want one of %s", metric, strings.Join(CompareMetrics, ", "))
+	}
+}
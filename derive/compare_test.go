@@ -0,0 +1,67 @@
+package derive_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		in       string
+		from, to time.Time
+	}{
+		{"2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-02", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-W01", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{"2021-W01", time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"2020-W53", time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"2024-02-29", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		p, err := derive.ParsePeriod(tt.in, time.UTC)
+		if err != nil {
+			t.Errorf("ParsePeriod(%q) err=%v", tt.in, err)
+			continue
+		}
+		if p.Name != tt.in || !p.From.Equal(tt.from) || !p.To.Equal(tt.to) {
+			t.Errorf("ParsePeriod(%q) = %v - %v, want %v - %v", tt.in, p.From, p.To, tt.from, tt.to)
+		}
+	}
+
+	for _, in := range []string{"2021-W53", "2024-13", "24-01", "last week"} {
+		if _, err := derive.ParsePeriod(in, time.UTC); err == nil {
+			t.Errorf("ParsePeriod(%q) err=nil, want error", in)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	jan := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	periods := []derive.NamedPeriod{
+		{Name: "2021-01", Period: derive.Period{From: jan, To: feb}},
+		{Name: "2021-02", Period: derive.Period{From: feb, To: feb.AddDate(0, 1, 0)}},
+	}
+
+	c, err := derive.Compare(context.Background(), &fakeSource{split: feb}, &statistics.Filter{}, derive.KPISessions, periods)
+	if err != nil {
+		t.Fatalf("Compare() err=%v", err)
+	}
+	if len(c.Periods) != 2 || c.Periods[0].Value != 100 || c.Periods[1].Value != 200 {
+		t.Fatalf("got %+v, want 100 and 200 sessions", c.Periods)
+	}
+	if got := c.Periods[1].Changes; got[0] != 1 || got[1] != 0 {
+		t.Errorf("got February changes %v, want 1 from January and 0 from itself", got)
+	}
+	if got := c.Periods[0].Changes[1]; got != -0.5 {
+		t.Errorf("got January change from February %v, want -0.5", got)
+	}
+
+	if _, err := derive.Compare(context.Background(), &fakeSource{}, &statistics.Filter{}, "nps", periods); err == nil {
+		t.Error("Compare() err=nil for an unknown metric, want error")
+	}
+}
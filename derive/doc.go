@@ -0,0 +1,3 @@
+// Package derive computes reports that can not be read directly from the
+// Kindly APIs, but must be derived from one or more upstream responses.
+package derive
@@ -0,0 +1,84 @@
+package derive
+
+import (
+	"context"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// EmojiRatings is the number of emoji ratings, from 1 to 5.
+const EmojiRatings = 5
+
+// BinaryPositive is the positive rating of the binary scale, where 0 is
+// negative.
+const BinaryPositive = 1
+
+// EmojiStats summarizes the emoji ratings of a period.
+type EmojiStats struct {
+	// Counts is the number of ratings of 1 to 5, at index 0 to 4.
+	Counts [EmojiRatings]int
+	// Average is the mean rating, from 1 to 5. It is zero if there are no
+	// ratings.
+	Average float64
+}
+
+// Total returns the number of ratings.
+func (s *EmojiStats) Total() int {
+	total := 0
+	for _, c := range s.Counts {
+		total += c
+	}
+
+	return total
+}
+
+// Share returns the share of the ratings that are rating, from 0 to 1.
+func (s *EmojiStats) Share(rating int) float64 {
+	total := s.Total()
+	if total == 0 || rating < 1 || rating > EmojiRatings {
+		return 0
+	}
+
+	return float64(s.Counts[rating-1]) / float64(total)
+}
+
+// NewEmojiStats counts the emoji ratings of fb. Ratings outside 1 to 5 are
+// ignored.
+func NewEmojiStats(fb *statistics.Feedback) *EmojiStats {
+	s := &EmojiStats{}
+	sum := 0
+	for _, r := range fb.Emojis {
+		if r.Rating < 1 || r.Rating > EmojiRatings {
+			continue
+		}
+		s.Counts[r.Rating-1] += r.Count
+		sum += r.Rating * r.Count
+	}
+	if total := s.Total(); total > 0 {
+		s.Average = float64(sum) / float64(total)
+	}
+
+	return s
+}
+
+// EmojiPeriod is the emoji ratings of one period of an emoji series.
+type EmojiPeriod struct {
+	Period
+	EmojiStats
+}
+
+// EmojiSeries computes the average and distribution of the emoji ratings per
+// day, or per week for weekly granularity, in the period of f, fetching the
+// feedback once per period.
+func EmojiSeries(ctx context.Context, src FeedbackSource, f *statistics.Filter) ([]*EmojiPeriod, error) {
+	var ret []*EmojiPeriod
+	for _, chunk := range f.Chunks(f.Granularity) {
+		fb, err := src.AggregatedFeedback(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &EmojiPeriod{Period: Period{From: chunk.From, To: chunk.To}, EmojiStats: *NewEmojiStats(fb)})
+	}
+
+	return ret, nil
+}
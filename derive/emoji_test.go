@@ -0,0 +1,64 @@
+package derive_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+type feedbackFunc func(ctx context.Context, f *statistics.Filter) (*statistics.Feedback, error)
+
+func (fn feedbackFunc) AggregatedFeedback(ctx context.Context, f *statistics.Filter) (*statistics.Feedback, error) {
+	return fn(ctx, f)
+}
+
+func TestNewEmojiStats(t *testing.T) {
+	s := derive.NewEmojiStats(&statistics.Feedback{
+		Emojis: []*statistics.Rating{
+			{Rating: 1, Count: 1},
+			{Rating: 4, Count: 2},
+			{Rating: 5, Count: 1},
+			{Rating: 6, Count: 9},
+		},
+	})
+
+	if s.Total() != 4 || s.Counts != [5]int{1, 0, 0, 2, 1} {
+		t.Errorf("got counts %v, want [1 0 0 2 1]", s.Counts)
+	}
+	if s.Average != 3.5 {
+		t.Errorf("got average %v, want 3.5", s.Average)
+	}
+	if s.Share(4) != 0.5 || s.Share(2) != 0 || s.Share(0) != 0 {
+		t.Errorf("got shares %v, %v, %v, want 0.5, 0 and 0", s.Share(4), s.Share(2), s.Share(0))
+	}
+
+	if s := derive.NewEmojiStats(&statistics.Feedback{}); s.Average != 0 || s.Share(5) != 0 {
+		t.Errorf("got %+v without ratings, want zero", s)
+	}
+}
+
+func TestEmojiSeries(t *testing.T) {
+	from := time.Date(2021, 2, 8, 0, 0, 0, 0, time.UTC)
+	f := &statistics.Filter{From: from, To: from.AddDate(0, 0, 14), Granularity: statistics.Week}
+	src := feedbackFunc(func(ctx context.Context, f *statistics.Filter) (*statistics.Feedback, error) {
+		rating := 5
+		if f.From.Equal(from) {
+			rating = 2
+		}
+		return &statistics.Feedback{Emojis: []*statistics.Rating{{Rating: rating, Count: 3}}}, nil
+	})
+
+	got, err := derive.EmojiSeries(context.Background(), src, f)
+	if err != nil {
+		t.Fatalf("EmojiSeries() err=%v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d periods, want 2", len(got))
+	}
+	if got[0].Average != 2 || got[1].Average != 5 || got[1].From != from.AddDate(0, 0, 7) {
+		t.Errorf("got %+v and %+v, want averages 2 and 5 a week apart", got[0], got[1])
+	}
+}
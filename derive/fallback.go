@@ -0,0 +1,88 @@
+package derive
+
+import (
+	"sort"
+
+	"github.com/atb-as/kindly/chat"
+)
+
+// Reply is a single bot reply in a conversation, in the order it was sent.
+type Reply struct {
+	DialogueID    string
+	DialogueTitle string
+	Fallback      bool
+}
+
+// RepliesOf returns the bot replies of a chat transcript, such as from
+// chat.Client.Messages, as a conversation for FallbackPredecessors. Messages
+// of users and agents are left out. Messages have no dialogue titles, so
+// DialogueTitle is empty.
+func RepliesOf(messages []*chat.Message) []Reply {
+	ret := make([]Reply, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Sender != chat.SenderBot {
+			continue
+		}
+		ret = append(ret, Reply{DialogueID: msg.DialogueID, Fallback: msg.Fallback})
+	}
+
+	return ret
+}
+
+// DialogueCount is the number of times a dialogue was matched right before
+// a fallback.
+type DialogueCount struct {
+	DialogueID    string
+	DialogueTitle string
+	Count         int
+	// Share is Count as a fraction of all fallbacks with a preceding
+	// dialogue.
+	Share float64
+}
+
+// FallbackPredecessors correlates fallbacks with the dialogue matched
+// immediately before them in the same conversation, and returns the dialogues
+// ordered by how often users reach them right before a fallback.
+//
+// Consecutive fallbacks are attributed to the last matched dialogue, and
+// fallbacks at the start of a conversation are ignored.
+func FallbackPredecessors(conversations [][]Reply) []*DialogueCount {
+	counts := make(map[string]*DialogueCount)
+	total := 0
+	for _, replies := range conversations {
+		var prev *Reply
+		for i := range replies {
+			reply := &replies[i]
+			if !reply.Fallback {
+				prev = reply
+				continue
+			}
+			if prev == nil {
+				continue
+			}
+
+			c, ok := counts[prev.DialogueID]
+			if !ok {
+				c = &DialogueCount{DialogueID: prev.DialogueID}
+				counts[prev.DialogueID] = c
+			}
+			c.DialogueTitle = prev.DialogueTitle
+			c.Count++
+			total++
+		}
+	}
+
+	ret := make([]*DialogueCount, 0, len(counts))
+	for _, c := range counts {
+		c.Share = float64(c.Count) / float64(total)
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count != ret[j].Count {
+			return ret[i].Count > ret[j].Count
+		}
+		return ret[i].DialogueID < ret[j].DialogueID
+	})
+
+	return ret
+}
@@ -0,0 +1,64 @@
+package derive_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/derive"
+)
+
+func TestFallbackPredecessors(t *testing.T) {
+	conversations := [][]derive.Reply{
+		{
+			{Fallback: true},
+			{DialogueID: "tickets"},
+			{Fallback: true},
+			{Fallback: true},
+		},
+		{
+			{DialogueID: "greeting"},
+			{DialogueID: "refund"},
+			{Fallback: true},
+		},
+		{
+			{DialogueID: "tickets"},
+		},
+	}
+
+	got := derive.FallbackPredecessors(conversations)
+	if len(got) != 2 {
+		t.Fatalf("got %d dialogues, want 2", len(got))
+	}
+
+	if got[0].DialogueID != "tickets" || got[0].Count != 2 {
+		t.Errorf("got %+v, want tickets with count 2", got[0])
+	}
+	if got[1].DialogueID != "refund" || got[1].Count != 1 {
+		t.Errorf("got %+v, want refund with count 1", got[1])
+	}
+	if want := 2.0 / 3.0; got[0].Share != want {
+		t.Errorf("got Share %v, want %v", got[0].Share, want)
+	}
+}
+
+func TestRepliesOf(t *testing.T) {
+	messages := []*chat.Message{
+		{Sender: chat.SenderUser, Text: "hi"},
+		{Sender: chat.SenderBot, DialogueID: "greeting"},
+		{Sender: chat.SenderUser, Text: "refnud"},
+		{Sender: chat.SenderBot, Fallback: true},
+		{Sender: chat.SenderAgent, Text: "Let me help"},
+	}
+
+	got := derive.RepliesOf(messages)
+	want := []derive.Reply{{DialogueID: "greeting"}, {Fallback: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	counts := derive.FallbackPredecessors([][]derive.Reply{got})
+	if len(counts) != 1 || counts[0].DialogueID != "greeting" {
+		t.Errorf("got %+v, want the fallback after greeting", counts)
+	}
+}
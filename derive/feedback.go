@@ -0,0 +1,86 @@
+package derive
+
+import (
+	"math"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// Feedback rating types as named in statistics.Feedback.
+const (
+	FeedbackBinary = "binary"
+	FeedbackEmojis = "emojis"
+)
+
+// RecomputeRatios returns a copy of fb where the Ratio of every rating is its
+// Count relative to the total count of ratings of the same type.
+func RecomputeRatios(fb *statistics.Feedback) *statistics.Feedback {
+	return &statistics.Feedback{
+		Binary: recompute(fb.Binary),
+		Emojis: recompute(fb.Emojis),
+	}
+}
+
+func recompute(ratings []*statistics.Rating) []*statistics.Rating {
+	total := 0
+	for _, r := range ratings {
+		total += r.Count
+	}
+
+	ret := make([]*statistics.Rating, 0, len(ratings))
+	for _, r := range ratings {
+		ret = append(ret, &statistics.Rating{
+			Count:  r.Count,
+			Rating: r.Rating,
+			Ratio:  ratio(float64(r.Count), float64(total)),
+		})
+	}
+
+	return ret
+}
+
+// RatioDiscrepancy is a rating whose upstream Ratio differs from the ratio
+// computed from the counts.
+type RatioDiscrepancy struct {
+	Type   string
+	Rating int
+	Count  int
+	// Ratio is the upstream ratio and Want the one computed from counts.
+	Ratio float64
+	Want  float64
+}
+
+// Diff returns the absolute difference between Ratio and Want.
+func (d *RatioDiscrepancy) Diff() float64 {
+	return math.Abs(d.Ratio - d.Want)
+}
+
+// CheckRatios recomputes the ratios of fb from its counts and returns the
+// ratings whose upstream Ratio is off by more than tolerance. A tolerance of
+// 0.001 allows for ratios rounded to three decimals.
+func CheckRatios(fb *statistics.Feedback, tolerance float64) []*RatioDiscrepancy {
+	want := RecomputeRatios(fb)
+
+	var ret []*RatioDiscrepancy
+	ret = appendDiscrepancies(ret, FeedbackBinary, fb.Binary, want.Binary, tolerance)
+	ret = appendDiscrepancies(ret, FeedbackEmojis, fb.Emojis, want.Emojis, tolerance)
+
+	return ret
+}
+
+func appendDiscrepancies(ret []*RatioDiscrepancy, typ string, got, want []*statistics.Rating, tolerance float64) []*RatioDiscrepancy {
+	for i, r := range got {
+		d := &RatioDiscrepancy{
+			Type:   typ,
+			Rating: r.Rating,
+			Count:  r.Count,
+			Ratio:  r.Ratio,
+			Want:   want[i].Ratio,
+		}
+		if d.Diff() > tolerance {
+			ret = append(ret, d)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,42 @@
+package derive_test
+
+import (
+	"testing"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestCheckRatios(t *testing.T) {
+	fb := &statistics.Feedback{
+		Binary: []*statistics.Rating{
+			{Rating: 0, Count: 1, Ratio: 0.333},
+			{Rating: 1, Count: 2, Ratio: 0.667},
+		},
+		Emojis: []*statistics.Rating{
+			{Rating: 1, Count: 1, Ratio: 0.5},
+			{Rating: 5, Count: 3, Ratio: 0.5},
+		},
+	}
+
+	if got := derive.RecomputeRatios(fb); got.Binary[1].Ratio != 2.0/3.0 || got.Emojis[1].Ratio != 0.75 {
+		t.Errorf("got ratios %v and %v, want 2/3 and 0.75", got.Binary[1].Ratio, got.Emojis[1].Ratio)
+	}
+
+	got := derive.CheckRatios(fb, 0.001)
+	if len(got) != 2 {
+		t.Fatalf("got %d discrepancies, want 2", len(got))
+	}
+	for _, d := range got {
+		if d.Type != derive.FeedbackEmojis {
+			t.Errorf("got discrepancy %+v, want only emojis", d)
+		}
+	}
+	if d := got[1]; d.Rating != 5 || d.Want != 0.75 || d.Diff() != 0.25 {
+		t.Errorf("got %+v, want rating 5 off by 0.25", d)
+	}
+
+	if got := derive.CheckRatios(fb, 0); len(got) != 4 {
+		t.Errorf("got %d discrepancies without tolerance, want 4", len(got))
+	}
+}
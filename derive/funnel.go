@@ -0,0 +1,132 @@
+package derive
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// FunnelSource is the subset of *statistics.Client used by the funnel.
+type FunnelSource interface {
+	GreetingStatistics(ctx context.Context, f *statistics.Filter) (*statistics.Greetings, error)
+	ChatSessions(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error)
+	FallbackRateTotal(ctx context.Context, f *statistics.Filter) (*statistics.RateTotal, error)
+	HandoversTotal(ctx context.Context, f *statistics.Filter) (*statistics.Handovers, error)
+}
+
+// Funnel stage names in the order they appear in a Funnel.
+const (
+	StageGreeted      = "greeted"
+	StageEngaged      = "engaged"
+	StageFallbackFree = "fallback_free"
+	StageResolved     = "resolved"
+)
+
+// FunnelStages are the names of the stages of a Funnel, in order.
+var FunnelStages = []string{StageGreeted, StageEngaged, StageFallbackFree, StageResolved}
+
+// Funnel is the number of sessions reaching each stage of a conversation in
+// a period, from being greeted to being resolved by the bot.
+type Funnel struct {
+	Period
+	Stages []*FunnelStage
+}
+
+// FunnelStage is the number of sessions reaching a stage of a Funnel.
+type FunnelStage struct {
+	Name  string
+	Count int
+	// Conversion is Count relative to the preceding stage, or zero for the
+	// first stage and if the preceding stage is zero.
+	Conversion float64
+	// Estimated is set if Count is estimated from rates, not counted.
+	Estimated bool
+}
+
+// NewFunnel returns the funnel of the period of f:
+//
+//	greeted        greetings shown by the chat bubble
+//	engaged        sessions where users engaged with the bot
+//	fallback_free  engaged sessions without a fallback
+//	resolved       fallback-free sessions without a started handover
+//
+// Sage does not count sessions without fallbacks or handovers, so these are
+// estimated by applying the fallback rate and the share of sessions with a
+// started handover independently. Bots without greetings, where Sage has no
+// greeting statistics, have a greeted stage of zero.
+func NewFunnel(ctx context.Context, src FunnelSource, f *statistics.Filter) (*Funnel, error) {
+	greeted, err := greetingsShown(ctx, src, f)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := src.ChatSessions(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	fallbacks, err := src.FallbackRateTotal(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	handovers, err := src.HandoversTotal(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	engaged := float64(sum(sessions))
+	fallbackFree := engaged * (1 - clamp(fallbacks.Rate))
+	resolved := fallbackFree * (1 - clamp(ratio(float64(handovers.Started), engaged)))
+
+	fn := &Funnel{Period: Period{From: f.From, To: f.To}}
+	for i, count := range []float64{float64(greeted), engaged, fallbackFree, resolved} {
+		stage := &FunnelStage{
+			Name:      FunnelStages[i],
+			Count:     int(math.Round(count)),
+			Estimated: i >= 2,
+		}
+		if i > 0 {
+			stage.Conversion = ratio(float64(stage.Count), float64(fn.Stages[i-1].Count))
+		}
+		fn.Stages = append(fn.Stages, stage)
+	}
+
+	return fn, nil
+}
+
+// FunnelSeries returns the funnel per day, or per week for Week granularity,
+// of the period of f. See NewFunnel.
+func FunnelSeries(ctx context.Context, src FunnelSource, f *statistics.Filter) ([]*Funnel, error) {
+	chunks := f.Chunks(f.Granularity)
+	ret := make([]*Funnel, 0, len(chunks))
+	for _, chunk := range chunks {
+		fn, err := NewFunnel(ctx, src, chunk)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, fn)
+	}
+
+	return ret, nil
+}
+
+// greetingsShown returns the greetings shown in the period of f, or zero if
+// Sage has no greeting statistics for the bot.
+func greetingsShown(ctx context.Context, src FunnelSource, f *statistics.Filter) (int, error) {
+	g, err := src.GreetingStatistics(ctx, f)
+	var serr *statistics.Error
+	if errors.As(err, &serr) && serr.StatusCode() == http.StatusNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return g.Shown, nil
+}
+
+// clamp limits a rate to [0, 1].
+func clamp(rate float64) float64 {
+	return math.Max(0, math.Min(1, rate))
+}
@@ -0,0 +1,43 @@
+package derive_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+func (s *fakeSource) GreetingStatistics(ctx context.Context, f *statistics.Filter) (*statistics.Greetings, error) {
+	return &statistics.Greetings{Shown: 400 * s.factor(f), Opened: 40}, nil
+}
+
+func TestFunnelSeries(t *testing.T) {
+	from := time.Date(2021, 2, 8, 0, 0, 0, 0, time.UTC)
+	f := &statistics.Filter{From: from, To: from.AddDate(0, 0, 2), Granularity: statistics.Day}
+
+	funnels, err := derive.FunnelSeries(context.Background(), &fakeSource{split: from.AddDate(0, 0, 1)}, f)
+	if err != nil {
+		t.Fatalf("FunnelSeries() err=%v", err)
+	}
+	if len(funnels) != 2 || !funnels[1].From.Equal(from.AddDate(0, 0, 1)) {
+		t.Fatalf("got %d funnels, want 2 days", len(funnels))
+	}
+
+	for i, want := range [][]int{{400, 100, 90, 86}, {800, 200, 160, 156}} {
+		for j, stage := range funnels[i].Stages {
+			if stage.Name != derive.FunnelStages[j] || stage.Count != want[j] {
+				t.Errorf("day %d: got %s=%d, want %s=%d", i, stage.Name, stage.Count, derive.FunnelStages[j], want[j])
+			}
+		}
+	}
+
+	stages := funnels[0].Stages
+	if stages[0].Conversion != 0 || stages[1].Conversion != 0.25 || stages[2].Conversion != 0.9 {
+		t.Errorf("got conversions %v %v %v, want 0 0.25 0.9", stages[0].Conversion, stages[1].Conversion, stages[2].Conversion)
+	}
+	if stages[1].Estimated || !stages[3].Estimated {
+		t.Errorf("got engaged estimated=%v, resolved estimated=%v", stages[1].Estimated, stages[3].Estimated)
+	}
+}
@@ -0,0 +1,128 @@
+package derive
+
+import (
+	"sort"
+
+	"github.com/atb-as/kindly/chat"
+)
+
+// LabelFeedback is the feedback given on one scale in the chats with a
+// label.
+type LabelFeedback struct {
+	// LabelID is empty for the chats without labels.
+	LabelID string
+	// Text is the current text of the label, or its ID if it was deleted.
+	Text string
+	Type chat.FeedbackType
+	// Chats is the number of rated chats with the label.
+	Chats int
+	// Counts are the chats by rating.
+	Counts map[int]int
+}
+
+// Share returns the share of the rated chats with the label given rating,
+// from 0 to 1.
+func (l *LabelFeedback) Share(rating int) float64 {
+	return ratio(float64(l.Counts[rating]), float64(l.Chats))
+}
+
+// Average returns the average rating of the chats with the label.
+func (l *LabelFeedback) Average() float64 {
+	sum := 0
+	for rating, n := range l.Counts {
+		sum += rating * n
+	}
+
+	return ratio(float64(sum), float64(l.Chats))
+}
+
+// PositiveShare returns the share of the rated chats with the label that
+// were rated positive: 1 on the binary scale, and satisfied or very
+// satisfied on the emoji scale.
+func (l *LabelFeedback) PositiveShare() float64 {
+	positive := 0
+	for rating, n := range l.Counts {
+		if isPositive(l.Type, rating) {
+			positive += n
+		}
+	}
+
+	return ratio(float64(positive), float64(l.Chats))
+}
+
+func isPositive(typ chat.FeedbackType, rating int) bool {
+	if typ == chat.FeedbackEmoji {
+		return rating >= EmojiRatings-1
+	}
+
+	return rating == BinaryPositive
+}
+
+// FeedbackRatings returns the ratings of the scale typ, lowest first.
+func FeedbackRatings(typ chat.FeedbackType) []int {
+	if typ == chat.FeedbackEmoji {
+		ret := make([]int, 0, EmojiRatings)
+		for r := 1; r <= EmojiRatings; r++ {
+			ret = append(ret, r)
+		}
+		return ret
+	}
+
+	return []int{0, 1}
+}
+
+// FeedbackByLabel counts the ratings of the rated chats per label and scale,
+// with the text of the label in labels, so the ratings of chats about one
+// topic can be compared with those of another. Chats with several labels
+// count for each, and chats without labels are counted under an empty label.
+// The result is sorted by scale, then by number of chats, most first.
+func FeedbackByLabel(chats []*chat.Chat, labels []*chat.Label) []*LabelFeedback {
+	texts := make(map[string]string, len(labels))
+	for _, l := range labels {
+		texts[l.ID] = l.Text
+	}
+
+	type key struct {
+		id  string
+		typ chat.FeedbackType
+	}
+	byKey := make(map[key]*LabelFeedback)
+	var ret []*LabelFeedback
+	add := func(id string, fb *chat.Feedback) {
+		k := key{id, fb.Type}
+		l, ok := byKey[k]
+		if !ok {
+			l = &LabelFeedback{LabelID: id, Text: texts[id], Type: fb.Type, Counts: make(map[int]int)}
+			if l.Text == "" {
+				l.Text = id
+			}
+			byKey[k] = l
+			ret = append(ret, l)
+		}
+		l.Chats++
+		l.Counts[fb.Rating]++
+	}
+	for _, c := range chats {
+		if c.Feedback == nil {
+			continue
+		}
+		if len(c.LabelIDs) == 0 {
+			add("", c.Feedback)
+		}
+		for _, id := range c.LabelIDs {
+			add(id, c.Feedback)
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Type != ret[j].Type {
+			return ret[i].Type < ret[j].Type
+		}
+		if ret[i].Chats != ret[j].Chats {
+			return ret[i].Chats > ret[j].Chats
+		}
+		return ret[i].Text < ret[j].Text
+	})
+
+	return ret
+}
@@ -0,0 +1,46 @@
+package derive_test
+
+import (
+	"testing"
+
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/derive"
+)
+
+func TestFeedbackByLabel(t *testing.T) {
+	rated := func(typ chat.FeedbackType, rating int, labels ...string) *chat.Chat {
+		return &chat.Chat{LabelIDs: labels, Feedback: &chat.Feedback{Type: typ, Rating: rating}}
+	}
+	chats := []*chat.Chat{
+		rated(chat.FeedbackBinary, 0, "billing"),
+		rated(chat.FeedbackBinary, 0, "billing", "timetable"),
+		rated(chat.FeedbackBinary, 1, "billing"),
+		rated(chat.FeedbackBinary, 1, "timetable"),
+		rated(chat.FeedbackBinary, 1),
+		rated(chat.FeedbackEmoji, 5, "timetable"),
+		rated(chat.FeedbackEmoji, 2, "deleted"),
+		{LabelIDs: []string{"billing"}},
+	}
+	labels := []*chat.Label{{ID: "billing", Text: "Billing"}, {ID: "timetable", Text: "Timetable"}}
+
+	got := derive.FeedbackByLabel(chats, labels)
+	if len(got) != 5 {
+		t.Fatalf("got %d rows, want 5", len(got))
+	}
+	billing, timetable := got[0], got[1]
+	if billing.Text != "Billing" || billing.Chats != 3 || billing.Counts[0] != 2 || billing.PositiveShare() != 1.0/3 {
+		t.Errorf("got billing %+v, want 3 chats, 2 of them negative", billing)
+	}
+	if timetable.Text != "Timetable" || timetable.Chats != 2 || timetable.Average() != 0.5 {
+		t.Errorf("got timetable %+v, want 2 chats averaging 0.5", timetable)
+	}
+	if got[2].LabelID != "" || got[2].Chats != 1 {
+		t.Errorf("got %+v, want the unlabeled chat", got[2])
+	}
+	if got[4].Type != chat.FeedbackEmoji || got[4].Text != "deleted" || got[4].PositiveShare() != 0 {
+		t.Errorf("got %+v, want the deleted label named by its ID", got[4])
+	}
+	if got[3].Text != "Timetable" || got[3].Share(5) != 1 || got[3].PositiveShare() != 1 {
+		t.Errorf("got %+v, want the very satisfied timetable chat", got[3])
+	}
+}
@@ -0,0 +1,199 @@
+package derive
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// FeedbackSource is the subset of *statistics.Client used by NPSSeries.
+type FeedbackSource interface {
+	AggregatedFeedback(ctx context.Context, f *statistics.Filter) (*statistics.Feedback, error)
+}
+
+// NPSMapping maps the ratings of one type to promoters and detractors.
+// Ratings in neither are passives.
+type NPSMapping struct {
+	// Ratings is FeedbackBinary or FeedbackEmojis.
+	Ratings    string
+	Promoters  []int
+	Detractors []int
+}
+
+// DefaultNPSMapping counts the highest of five emojis as promoters and the
+// lowest three as detractors, like the 9-10 and 0-6 bands of an NPS survey.
+var DefaultNPSMapping = &NPSMapping{
+	Ratings:    FeedbackEmojis,
+	Promoters:  []int{5},
+	Detractors: []int{1, 2, 3},
+}
+
+// BinaryNPSMapping counts thumbs up as promoters and thumbs down as
+// detractors.
+var BinaryNPSMapping = &NPSMapping{
+	Ratings:    FeedbackBinary,
+	Promoters:  []int{1},
+	Detractors: []int{0},
+}
+
+// ParseNPSMapping parses the mapping of ratings, FeedbackBinary or
+// FeedbackEmojis, from spec, a comma-separated list of promoters and
+// detractors entries with a rating or an inclusive range of ratings, e.g.
+// "promoters=4-5,detractors=1-2". An empty spec returns the default mapping
+// of ratings.
+func ParseNPSMapping(ratings, spec string) (*NPSMapping, error) {
+	var m NPSMapping
+	switch ratings {
+	case "", FeedbackEmojis:
+		m = *DefaultNPSMapping
+	case FeedbackBinary:
+		m = *BinaryNPSMapping
+	default:
+		return nil, fmt.Errorf("unknown ratings %q, want %s or %s", ratings, FeedbackEmojis, FeedbackBinary)
+	}
+	if strings.TrimSpace(spec) == "" {
+		return &m, nil
+	}
+
+	m.Promoters, m.Detractors = nil, nil
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("nps mapping %q: want promoters=ratings or detractors=ratings", entry)
+		}
+		values, err := parseRatings(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("nps mapping %q: %v", entry, err)
+		}
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "promoters":
+			m.Promoters = append(m.Promoters, values...)
+		case "detractors":
+			m.Detractors = append(m.Detractors, values...)
+		default:
+			return nil, fmt.Errorf("nps mapping %q: want promoters or detractors", entry)
+		}
+	}
+
+	for _, p := range m.Promoters {
+		if containsRating(m.Detractors, p) {
+			return nil, fmt.Errorf("nps mapping: rating %d is both a promoter and a detractor", p)
+		}
+	}
+
+	return &m, nil
+}
+
+func parseRatings(s string) ([]int, error) {
+	bounds := strings.SplitN(strings.TrimSpace(s), "-", 2)
+	first, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid rating %q", bounds[0])
+	}
+	if len(bounds) == 1 {
+		return []int{first}, nil
+	}
+	last, err := strconv.Atoi(bounds[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid rating %q", bounds[1])
+	}
+	if last < first {
+		return nil, fmt.Errorf("range %q ends before it starts", s)
+	}
+
+	var ret []int
+	for r := first; r <= last; r++ {
+		ret = append(ret, r)
+	}
+
+	return ret, nil
+}
+
+func containsRating(ratings []int, r int) bool {
+	for _, v := range ratings {
+		if v == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NPS is a net promoter score computed from feedback ratings.
+type NPS struct {
+	Promoters  int
+	Passives   int
+	Detractors int
+	// Score is the share of promoters minus the share of detractors, from
+	// -100 to 100. It is zero if there are no ratings.
+	Score float64
+}
+
+// Total returns the number of ratings.
+func (n *NPS) Total() int {
+	return n.Promoters + n.Passives + n.Detractors
+}
+
+// NewNPS computes the score of the ratings of fb selected by m.
+func NewNPS(fb *statistics.Feedback, m *NPSMapping) *NPS {
+	ratings := fb.Emojis
+	if m.Ratings == FeedbackBinary {
+		ratings = fb.Binary
+	}
+
+	n := &NPS{}
+	for _, r := range ratings {
+		switch {
+		case containsRating(m.Promoters, r.Rating):
+			n.Promoters += r.Count
+		case containsRating(m.Detractors, r.Rating):
+			n.Detractors += r.Count
+		default:
+			n.Passives += r.Count
+		}
+	}
+	if total := n.Total(); total > 0 {
+		n.Score = 100 * float64(n.Promoters-n.Detractors) / float64(total)
+	}
+
+	return n
+}
+
+// NPSPeriod is the score of one period of an NPS series.
+type NPSPeriod struct {
+	Period
+	NPS
+	// Delta is Score minus the score of the preceding period. It is zero for
+	// the first period and when either period has no ratings.
+	Delta float64
+}
+
+// NPSSeries computes the score per day, or per week for weekly granularity,
+// in the period of f, fetching the feedback once per period.
+func NPSSeries(ctx context.Context, src FeedbackSource, f *statistics.Filter, m *NPSMapping) ([]*NPSPeriod, error) {
+	var ret []*NPSPeriod
+	for _, chunk := range f.Chunks(f.Granularity) {
+		fb, err := src.AggregatedFeedback(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		p := &NPSPeriod{Period: Period{From: chunk.From, To: chunk.To}, NPS: *NewNPS(fb, m)}
+		if len(ret) > 0 {
+			if prev := ret[len(ret)-1]; prev.Total() > 0 && p.Total() > 0 {
+				p.Delta = p.Score - prev.Score
+			}
+		}
+		ret = append(ret, p)
+	}
+
+	return ret, nil
+}
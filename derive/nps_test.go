@@ -0,0 +1,70 @@
+package derive_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestNewNPS(t *testing.T) {
+	fb := &statistics.Feedback{
+		Emojis: []*statistics.Rating{
+			{Rating: 1, Count: 2},
+			{Rating: 3, Count: 2},
+			{Rating: 4, Count: 2},
+			{Rating: 5, Count: 4},
+		},
+	}
+
+	n := derive.NewNPS(fb, derive.DefaultNPSMapping)
+	if n.Promoters != 4 || n.Passives != 2 || n.Detractors != 4 || n.Score != 0 {
+		t.Errorf("got %+v, want 4 promoters, 2 passives, 4 detractors and score 0", n)
+	}
+
+	m, err := derive.ParseNPSMapping("emojis", "promoters=4-5,detractors=1-2")
+	if err != nil {
+		t.Fatalf("ParseNPSMapping() err=%v", err)
+	}
+	if n := derive.NewNPS(fb, m); n.Score != 40 {
+		t.Errorf("got score %v, want 40", n.Score)
+	}
+
+	if n := derive.NewNPS(&statistics.Feedback{}, m); n.Score != 0 || n.Total() != 0 {
+		t.Errorf("got %+v without ratings, want zero", n)
+	}
+}
+
+func TestParseNPSMapping(t *testing.T) {
+	for _, spec := range []string{"promoters", "fans=5", "promoters=5-4", "promoters=x", "promoters=3-5,detractors=1-3"} {
+		if _, err := derive.ParseNPSMapping("emojis", spec); err == nil {
+			t.Errorf("ParseNPSMapping(%q) err=nil, want error", spec)
+		}
+	}
+	if _, err := derive.ParseNPSMapping("stars", ""); err == nil {
+		t.Error("ParseNPSMapping(\"stars\") err=nil, want error")
+	}
+
+	m, err := derive.ParseNPSMapping("binary", "")
+	if err != nil || m != nil && m.Promoters[0] != 1 {
+		t.Errorf("ParseNPSMapping(\"binary\") = %+v, %v, want the binary mapping", m, err)
+	}
+}
+
+func TestNPSSeries(t *testing.T) {
+	from := time.Date(2021, 2, 8, 0, 0, 0, 0, time.UTC)
+	f := &statistics.Filter{From: from, To: from.Add(48 * time.Hour), Granularity: statistics.Day}
+
+	got, err := derive.NPSSeries(context.Background(), &fakeSource{split: from}, f, derive.BinaryNPSMapping)
+	if err != nil {
+		t.Fatalf("NPSSeries() err=%v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d periods, want 2", len(got))
+	}
+	if got[1].From != from.Add(24*time.Hour) || got[1].Score != 50 || got[1].Delta != 0 {
+		t.Errorf("got second period %+v, want score 50 without change", got[1])
+	}
+}
@@ -0,0 +1,44 @@
+package derive
+
+import (
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// OutcomeCounts is the number of handover requests of a period by outcome.
+type OutcomeCounts struct {
+	Period
+	Requested int
+	Counts    map[chat.HandoverOutcome]int
+}
+
+// Share returns the share of the requests of the period with outcome o, from
+// 0 to 1.
+func (c *OutcomeCounts) Share(o chat.HandoverOutcome) float64 {
+	return ratio(float64(c.Counts[o]), float64(c.Requested))
+}
+
+// CountOutcomes counts the chats created in p by outcome.
+func CountOutcomes(chats []*chat.ChatOutcome, p Period) *OutcomeCounts {
+	c := &OutcomeCounts{Period: p, Counts: make(map[chat.HandoverOutcome]int)}
+	for _, ch := range chats {
+		if ch.Created.Before(p.From) || !ch.Created.Before(p.To) {
+			continue
+		}
+		c.Requested++
+		c.Counts[ch.Outcome]++
+	}
+
+	return c
+}
+
+// OutcomeSeries counts the chats by outcome per day, or per week for weekly
+// granularity, in the period of f, by when they were created.
+func OutcomeSeries(chats []*chat.ChatOutcome, f *statistics.Filter) []*OutcomeCounts {
+	var ret []*OutcomeCounts
+	for _, chunk := range f.Chunks(f.Granularity) {
+		ret = append(ret, CountOutcomes(chats, Period{From: chunk.From, To: chunk.To}))
+	}
+
+	return ret
+}
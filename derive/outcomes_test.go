@@ -0,0 +1,37 @@
+package derive_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestOutcomeSeries(t *testing.T) {
+	day := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	outcome := func(days int, o chat.HandoverOutcome) *chat.ChatOutcome {
+		return &chat.ChatOutcome{Chat: &chat.Chat{Created: kindly.Time{Time: day.AddDate(0, 0, days).Add(time.Hour)}}, Outcome: o}
+	}
+	chats := []*chat.ChatOutcome{
+		outcome(0, chat.OutcomeResolved),
+		outcome(0, chat.OutcomeResolved),
+		outcome(0, chat.OutcomeAbandoned),
+		outcome(0, chat.OutcomeReturned),
+		outcome(1, chat.OutcomeOngoing),
+		outcome(2, chat.OutcomeResolved),
+	}
+
+	got := derive.OutcomeSeries(chats, &statistics.Filter{From: day, To: day.AddDate(0, 0, 2), Granularity: statistics.Day})
+	if len(got) != 2 {
+		t.Fatalf("got %d periods, want 2", len(got))
+	}
+	if got[0].Requested != 4 || got[0].Counts[chat.OutcomeResolved] != 2 || got[0].Share(chat.OutcomeResolved) != 0.5 {
+		t.Errorf("got %+v, want 4 requests, half of them resolved", got[0])
+	}
+	if got[1].Requested != 1 || got[1].Counts[chat.OutcomeOngoing] != 1 {
+		t.Errorf("got %+v, want 1 ongoing request", got[1])
+	}
+}
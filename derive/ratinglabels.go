@@ -0,0 +1,103 @@
+package derive
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Locale is a language reports are written in.
+type Locale string
+
+const (
+	English Locale = "en"
+	// Bokmal is Norwegian Bokmål.
+	Bokmal Locale = "nb"
+	// Nynorsk is Norwegian Nynorsk.
+	Nynorsk Locale = "nn"
+)
+
+// Locales are the supported locales.
+var Locales = []Locale{English, Bokmal, Nynorsk}
+
+// ParseLocale parses a locale such as "nb". The empty string is English, and
+// "no" is Bokmål.
+func ParseLocale(s string) (Locale, error) {
+	switch l := Locale(strings.ToLower(strings.TrimSpace(s))); l {
+	case "":
+		return English, nil
+	case "no":
+		return Bokmal, nil
+	case English, Bokmal, Nynorsk:
+		return l, nil
+	default:
+		return "", fmt.Errorf("derive: unknown locale %q, want en, nb or nn", s)
+	}
+}
+
+// MatchLocale returns the first supported locale of an Accept-Language
+// header, such as "nn-NO,nn;q=0.9,en;q=0.8", or English if there is none.
+// Quality values are ignored, since browsers list languages in order.
+func MatchLocale(acceptLanguage string) Locale {
+	for _, tag := range strings.Split(acceptLanguage, ",") {
+		tag = strings.TrimSpace(strings.SplitN(strings.SplitN(tag, ";", 2)[0], "-", 2)[0])
+		if l, err := ParseLocale(tag); err == nil && tag != "" {
+			return l
+		}
+	}
+
+	return English
+}
+
+var emojiLabels = map[Locale][EmojiRatings]string{
+	English: {"Very dissatisfied", "Dissatisfied", "Neutral", "Satisfied", "Very satisfied"},
+	Bokmal:  {"Svært misfornøyd", "Misfornøyd", "Nøytral", "Fornøyd", "Svært fornøyd"},
+	Nynorsk: {"Svært misnøgd", "Misnøgd", "Nøytral", "Nøgd", "Svært nøgd"},
+}
+
+// emojiGlyphs are the emojis of the ratings, as shown in the chat.
+var emojiGlyphs = [EmojiRatings]string{"😠", "🙁", "😐", "🙂", "😍"}
+
+var binaryLabels = map[Locale][2]string{
+	English: {"Negative", "Positive"},
+	Bokmal:  {"Negativ", "Positiv"},
+	Nynorsk: {"Negativ", "Positiv"},
+}
+
+// EmojiLabel returns the name of an emoji rating from 1 to 5 in l, such as
+// "Very satisfied" for 5. Other ratings are returned as numbers.
+func EmojiLabel(rating int, l Locale) string {
+	labels, ok := emojiLabels[l]
+	if !ok {
+		labels = emojiLabels[English]
+	}
+	if rating < 1 || rating > EmojiRatings {
+		return strconv.Itoa(rating)
+	}
+
+	return labels[rating-1]
+}
+
+// EmojiGlyph returns the emoji of a rating from 1 to 5, or the empty string
+// for other ratings.
+func EmojiGlyph(rating int) string {
+	if rating < 1 || rating > EmojiRatings {
+		return ""
+	}
+
+	return emojiGlyphs[rating-1]
+}
+
+// BinaryLabel returns the name of a binary rating, 0 or 1, in l. Other
+// ratings are returned as numbers.
+func BinaryLabel(rating int, l Locale) string {
+	labels, ok := binaryLabels[l]
+	if !ok {
+		labels = binaryLabels[English]
+	}
+	if rating != 0 && rating != 1 {
+		return strconv.Itoa(rating)
+	}
+
+	return labels[rating]
+}
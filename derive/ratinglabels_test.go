@@ -0,0 +1,50 @@
+package derive_test
+
+import (
+	"testing"
+
+	"github.com/atb-as/kindly/derive"
+)
+
+func TestEmojiLabel(t *testing.T) {
+	for _, tt := range []struct {
+		rating int
+		locale derive.Locale
+		want   string
+	}{
+		{5, derive.English, "Very satisfied"},
+		{1, derive.Bokmal, "Svært misfornøyd"},
+		{4, derive.Nynorsk, "Nøgd"},
+		{3, "sv", "Neutral"},
+		{7, derive.Bokmal, "7"},
+	} {
+		if got := derive.EmojiLabel(tt.rating, tt.locale); got != tt.want {
+			t.Errorf("EmojiLabel(%d, %q) = %q, want %q", tt.rating, tt.locale, got, tt.want)
+		}
+	}
+
+	if got := derive.EmojiGlyph(5); got != "😍" {
+		t.Errorf("EmojiGlyph(5) = %q, want 😍", got)
+	}
+	if got := derive.BinaryLabel(1, derive.Bokmal); got != "Positiv" {
+		t.Errorf("BinaryLabel(1, nb) = %q, want Positiv", got)
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	for header, want := range map[string]derive.Locale{
+		"":                          derive.English,
+		"nn-NO,nn;q=0.9,en;q=0.8":   derive.Nynorsk,
+		"sv-SE,no;q=0.9":            derive.Bokmal,
+		"de, en-GB;q=0.8, nb;q=0.5": derive.English,
+		"fr-CA":                     derive.English,
+	} {
+		if got := derive.MatchLocale(header); got != want {
+			t.Errorf("MatchLocale(%q) = %q, want %q", header, got, want)
+		}
+	}
+
+	if _, err := derive.ParseLocale("sv"); err == nil {
+		t.Errorf("ParseLocale(sv) err=nil, want error")
+	}
+}
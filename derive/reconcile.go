@@ -0,0 +1,79 @@
+package derive
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// ReconciledDay compares the events of a day counted by a pipeline of our
+// own, such as a webhook receiver, with the count Sage reports for the day.
+type ReconciledDay struct {
+	Date     time.Time
+	Received int
+	Reported int
+}
+
+// Diff returns how many more events were received than reported; negative
+// if events were dropped.
+func (d *ReconciledDay) Diff() int {
+	return d.Received - d.Reported
+}
+
+// Ratio returns Diff relative to Reported, or 1 if none were reported but
+// some were received.
+func (d *ReconciledDay) Ratio() float64 {
+	switch {
+	case d.Reported != 0:
+		return float64(d.Diff()) / float64(d.Reported)
+	case d.Received != 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Discrepant reports whether the counts of the day differ by more than
+// tolerance, relative to the reported count, and by at least minDiff events,
+// which keeps quiet days from being flagged for a single event.
+func (d *ReconciledDay) Discrepant(tolerance float64, minDiff int) bool {
+	diff := d.Diff()
+	if diff < 0 {
+		diff = -diff
+	}
+
+	return diff >= minDiff && math.Abs(d.Ratio()) > tolerance
+}
+
+// Reconcile pairs the daily counts received with those reported by Sage by
+// date, ordered by date. Days missing from either series count as zero.
+func Reconcile(received, reported []*statistics.CountByDate) []*ReconciledDay {
+	byDate := make(map[string]*ReconciledDay)
+	day := func(c *statistics.CountByDate) *ReconciledDay {
+		key := c.Date.Format("2006-01-02")
+		d, ok := byDate[key]
+		if !ok {
+			d = &ReconciledDay{Date: c.Date.Time}
+			byDate[key] = d
+		}
+		return d
+	}
+	for _, c := range received {
+		day(c).Received += c.Count
+	}
+	for _, c := range reported {
+		day(c).Reported += c.Count
+	}
+
+	ret := make([]*ReconciledDay, 0, len(byDate))
+	for _, d := range byDate {
+		ret = append(ret, d)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Date.Before(ret[j].Date)
+	})
+
+	return ret
+}
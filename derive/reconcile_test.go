@@ -0,0 +1,43 @@
+package derive_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestReconcile(t *testing.T) {
+	day := func(d, count int) *statistics.CountByDate {
+		return &statistics.CountByDate{Date: kindly.Time{Time: time.Date(2021, 3, d, 0, 0, 0, 0, time.UTC)}, Count: count}
+	}
+	received := []*statistics.CountByDate{day(2, 95), day(1, 1000), day(3, 2), day(4, 5)}
+	reported := []*statistics.CountByDate{day(1, 1005), day(2, 100), day(3, 1)}
+
+	got := derive.Reconcile(received, reported)
+	if len(got) != 4 {
+		t.Fatalf("got %d days, want 4", len(got))
+	}
+	for i, want := range []struct {
+		received, reported, diff int
+		discrepant               bool
+	}{
+		{1000, 1005, -5, false},
+		{95, 100, -5, true},
+		{2, 1, 1, false},
+		{5, 0, 5, true},
+	} {
+		d := got[i]
+		if d.Date.Day() != i+1 || d.Received != want.received || d.Reported != want.reported || d.Diff() != want.diff {
+			t.Errorf("day %d: got %+v, want received %d and reported %d", i+1, d, want.received, want.reported)
+		}
+		if discrepant := d.Discrepant(0.01, 2); discrepant != want.discrepant {
+			t.Errorf("day %d: got discrepant %v, want %v", i+1, discrepant, want.discrepant)
+		}
+	}
+	if r := got[3].Ratio(); r != 1 {
+		t.Errorf("got ratio %v of a day none were reported, want 1", r)
+	}
+}
@@ -0,0 +1,161 @@
+package derive
+
+import (
+	"context"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// ScorecardSource is the subset of *statistics.Client used by Scorecard.
+type ScorecardSource interface {
+	ChatSessions(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error)
+	UserMessages(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error)
+	FallbackRateTotal(ctx context.Context, f *statistics.Filter) (*statistics.RateTotal, error)
+	HandoversTotal(ctx context.Context, f *statistics.Filter) (*statistics.Handovers, error)
+	AggregatedFeedback(ctx context.Context, f *statistics.Filter) (*statistics.Feedback, error)
+}
+
+// KPI names in the order they appear in a Scorecard.
+const (
+	KPISessions              = "sessions"
+	KPIMessagesPerSession    = "messages_per_session"
+	KPIFallbackRate          = "fallback_rate"
+	KPIContainmentRate       = "containment_rate"
+	KPIHandoverRate          = "handover_rate"
+	KPIPositiveFeedbackShare = "positive_feedback_share"
+)
+
+// Scorecard compares a fixed set of KPIs between two periods of equal
+// length.
+type Scorecard struct {
+	Current  Period
+	Previous Period
+	KPIs     []*KPI
+}
+
+// Period is a half-open time range [From, To).
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// KPI is a key performance indicator for the current and previous period.
+type KPI struct {
+	Name     string
+	Current  float64
+	Previous float64
+	// Delta is Current - Previous.
+	Delta float64
+	// Change is Delta relative to Previous, or zero if Previous is zero.
+	Change float64
+}
+
+// NewScorecard computes the scorecard for the period of f and the period of
+// the same length immediately before it.
+//
+// The handover rate is handover requests, including those made while closed,
+// per session. The containment rate is the share of sessions where no
+// handover was started. The positive feedback share is the share of binary
+// ratings with the highest rating value.
+func NewScorecard(ctx context.Context, src ScorecardSource, f *statistics.Filter) (*Scorecard, error) {
+	current := *f
+	previous := *f
+	previous.To = f.From
+	previous.From = f.From.Add(-f.To.Sub(f.From))
+
+	cur, err := kpis(ctx, src, &current)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := kpis(ctx, src, &previous)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &Scorecard{
+		Current:  Period{From: current.From, To: current.To},
+		Previous: Period{From: previous.From, To: previous.To},
+		KPIs:     make([]*KPI, 0, len(cur)),
+	}
+	for i := range cur {
+		k := &KPI{Name: cur[i].name, Current: cur[i].value, Previous: prev[i].value}
+		k.Delta = k.Current - k.Previous
+		if k.Previous != 0 {
+			k.Change = k.Delta / k.Previous
+		}
+		sc.KPIs = append(sc.KPIs, k)
+	}
+
+	return sc, nil
+}
+
+type kpiValue struct {
+	name  string
+	value float64
+}
+
+func kpis(ctx context.Context, src ScorecardSource, f *statistics.Filter) ([]kpiValue, error) {
+	sessions, err := src.ChatSessions(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := src.UserMessages(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	fallbacks, err := src.FallbackRateTotal(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	handovers, err := src.HandoversTotal(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	feedback, err := src.AggregatedFeedback(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	nSessions := float64(sum(sessions))
+
+	return []kpiValue{
+		{KPISessions, nSessions},
+		{KPIMessagesPerSession, ratio(float64(sum(messages)), nSessions)},
+		{KPIFallbackRate, fallbacks.Rate},
+		{KPIContainmentRate, 1 - ratio(float64(handovers.Started), nSessions)},
+		{KPIHandoverRate, ratio(float64(handovers.Requests+handovers.RequestsWhileClosed), nSessions)},
+		{KPIPositiveFeedbackShare, positiveShare(feedback.Binary)},
+	}, nil
+}
+
+func sum(counts []*statistics.CountByDate) int {
+	total := 0
+	for _, c := range counts {
+		total += c.Count
+	}
+
+	return total
+}
+
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+
+	return a / b
+}
+
+// positiveShare returns the share of the binary ratings that are
+// BinaryPositive.
+func positiveShare(ratings []*statistics.Rating) float64 {
+	total, positive := 0, 0
+	for _, r := range ratings {
+		total += r.Count
+		if r.Rating == BinaryPositive {
+			positive += r.Count
+		}
+	}
+
+	return ratio(float64(positive), float64(total))
+}
@@ -0,0 +1,104 @@
+package derive_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// fakeSource returns fixed statistics, doubled for periods starting at or
+// after split.
+type fakeSource struct {
+	split time.Time
+	// feedback, if set, replaces the binary ratings.
+	feedback []*statistics.Rating
+}
+
+func (s *fakeSource) factor(f *statistics.Filter) int {
+	if f.From.Before(s.split) {
+		return 1
+	}
+	return 2
+}
+
+func (s *fakeSource) ChatSessions(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+	return []*statistics.CountByDate{{Count: 50 * s.factor(f)}, {Count: 50 * s.factor(f)}}, nil
+}
+
+func (s *fakeSource) UserMessages(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+	return []*statistics.CountByDate{{Count: 300 * s.factor(f)}}, nil
+}
+
+func (s *fakeSource) FallbackRateTotal(ctx context.Context, f *statistics.Filter) (*statistics.RateTotal, error) {
+	return &statistics.RateTotal{Rate: 0.1 * float64(s.factor(f))}, nil
+}
+
+func (s *fakeSource) HandoversTotal(ctx context.Context, f *statistics.Filter) (*statistics.Handovers, error) {
+	return &statistics.Handovers{Requests: 8, RequestsWhileClosed: 2, Started: 5}, nil
+}
+
+func (s *fakeSource) AggregatedFeedback(ctx context.Context, f *statistics.Filter) (*statistics.Feedback, error) {
+	if s.feedback != nil {
+		return &statistics.Feedback{Binary: s.feedback}, nil
+	}
+	return &statistics.Feedback{Binary: []*statistics.Rating{{Rating: 0, Count: 1}, {Rating: 1, Count: 3}}}, nil
+}
+
+func TestNewScorecard(t *testing.T) {
+	from := time.Date(2021, 2, 8, 0, 0, 0, 0, time.UTC)
+	f := &statistics.Filter{From: from, To: from.Add(7 * 24 * time.Hour)}
+
+	sc, err := derive.NewScorecard(context.Background(), &fakeSource{split: from}, f)
+	if err != nil {
+		t.Fatalf("NewScorecard() err=%v", err)
+	}
+
+	if want := from.Add(-7 * 24 * time.Hour); !sc.Previous.From.Equal(want) || !sc.Previous.To.Equal(from) {
+		t.Errorf("got previous period %v, want %v - %v", sc.Previous, want, from)
+	}
+
+	want := map[string][2]float64{
+		derive.KPISessions:              {200, 100},
+		derive.KPIMessagesPerSession:    {3, 3},
+		derive.KPIFallbackRate:          {0.2, 0.1},
+		derive.KPIContainmentRate:       {0.975, 0.95},
+		derive.KPIHandoverRate:          {0.05, 0.1},
+		derive.KPIPositiveFeedbackShare: {0.75, 0.75},
+	}
+	if len(sc.KPIs) != len(want) {
+		t.Fatalf("got %d KPIs, want %d", len(sc.KPIs), len(want))
+	}
+	for _, k := range sc.KPIs {
+		w := want[k.Name]
+		if !approx(k.Current, w[0]) || !approx(k.Previous, w[1]) {
+			t.Errorf("%s: got %v/%v, want %v/%v", k.Name, k.Current, k.Previous, w[0], w[1])
+		}
+	}
+	if sc.KPIs[0].Delta != 100 || sc.KPIs[0].Change != 1 {
+		t.Errorf("got sessions delta %v change %v, want 100 and 1", sc.KPIs[0].Delta, sc.KPIs[0].Change)
+	}
+}
+
+func TestNewScorecard_OnlyNegativeFeedback(t *testing.T) {
+	from := time.Date(2021, 2, 8, 0, 0, 0, 0, time.UTC)
+	f := &statistics.Filter{From: from, To: from.Add(7 * 24 * time.Hour)}
+	src := &fakeSource{split: from, feedback: []*statistics.Rating{{Rating: 0, Count: 4}}}
+
+	sc, err := derive.NewScorecard(context.Background(), src, f)
+	if err != nil {
+		t.Fatalf("NewScorecard() err=%v", err)
+	}
+	for _, k := range sc.KPIs {
+		if k.Name == derive.KPIPositiveFeedbackShare && (k.Current != 0 || k.Previous != 0) {
+			t.Errorf("got positive share %v/%v, want 0 with only negative ratings", k.Current, k.Previous)
+		}
+	}
+}
+
+func approx(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
@@ -0,0 +1,109 @@
+package derive
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// SeriesFunc fetches a count series, such as (*statistics.Client).ChatSessions
+// or UserMessages.
+type SeriesFunc func(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error)
+
+// Bucket returns the period that the point of a series at t is counted in.
+type Bucket func(t time.Time) Period
+
+// Monthly counts points in calendar months.
+func Monthly(t time.Time) Period {
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return Period{From: from, To: from.AddDate(0, 1, 0)}
+}
+
+// granularityBucket counts points in the period of their date at g.
+func granularityBucket(g statistics.Granularity) Bucket {
+	return func(t time.Time) Period {
+		switch g {
+		case statistics.Hour:
+			return Period{From: t, To: t.Add(time.Hour)}
+		case statistics.Week:
+			return Period{From: t, To: t.AddDate(0, 0, 7)}
+		default:
+			return Period{From: t, To: t.AddDate(0, 0, 1)}
+		}
+	}
+}
+
+// SharePeriod is the volume of each source in a period.
+type SharePeriod struct {
+	Period
+	// Total is the count of all sources.
+	Total   int
+	Sources []*SourceShare
+}
+
+// SourceShare is the volume of a source in a period.
+type SourceShare struct {
+	Source statistics.Source
+	Count  int
+	// Share is Count relative to the total of the period, from 0 to 1.
+	Share float64
+	// Index is Count relative to the count of the source in the first
+	// period it had any, as 100. It is zero before that period.
+	Index float64
+}
+
+// SourceShares computes each source's share of the volume of fetch per
+// period, fetching the series once per source of f. Points are counted in
+// the periods of bucket, or in their period at the granularity of f if
+// bucket is nil. Periods are in order and list the sources in the order of
+// f.
+func SourceShares(ctx context.Context, fetch SeriesFunc, f *statistics.Filter, bucket Bucket) ([]*SharePeriod, error) {
+	if bucket == nil {
+		bucket = granularityBucket(f.Granularity)
+	}
+
+	periods := make(map[time.Time]*SharePeriod)
+	for i, source := range f.Sources {
+		temp := *f
+		temp.Sources = []statistics.Source{source}
+		series, err := fetch(ctx, &temp)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range series {
+			period := bucket(c.Date.Time)
+			p, ok := periods[period.From]
+			if !ok {
+				p = &SharePeriod{Period: period, Sources: make([]*SourceShare, len(f.Sources))}
+				for j, s := range f.Sources {
+					p.Sources[j] = &SourceShare{Source: s}
+				}
+				periods[period.From] = p
+			}
+			p.Sources[i].Count += c.Count
+			p.Total += c.Count
+		}
+	}
+
+	ret := make([]*SharePeriod, 0, len(periods))
+	for _, p := range periods {
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].From.Before(ret[j].From) })
+
+	base := make([]int, len(f.Sources))
+	for _, p := range ret {
+		for i, s := range p.Sources {
+			s.Share = ratio(float64(s.Count), float64(p.Total))
+			if base[i] == 0 {
+				base[i] = s.Count
+			}
+			s.Index = 100 * ratio(float64(s.Count), float64(base[i]))
+		}
+	}
+
+	return ret, nil
+}
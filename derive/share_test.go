@@ -0,0 +1,49 @@
+package derive_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/derive"
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestSourceShares(t *testing.T) {
+	jan := time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	counts := map[statistics.Source][]*statistics.CountByDate{
+		"web":      {{Date: kindly.Time{Time: jan}, Count: 30}, {Date: kindly.Time{Time: feb}, Count: 30}, {Date: kindly.Time{Time: feb.AddDate(0, 0, 1)}, Count: 30}},
+		"facebook": {{Date: kindly.Time{Time: jan}, Count: 0}, {Date: kindly.Time{Time: feb}, Count: 40}},
+	}
+	fetch := func(ctx context.Context, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+		return counts[f.Sources[0]], nil
+	}
+	f := &statistics.Filter{From: jan, To: feb.AddDate(0, 0, 2), Sources: []statistics.Source{statistics.Web, statistics.Facebook}}
+
+	daily, err := derive.SourceShares(context.Background(), fetch, f, nil)
+	if err != nil {
+		t.Fatalf("SourceShares() err=%v", err)
+	}
+	if len(daily) != 3 || !daily[1].From.Equal(feb) || !daily[1].To.Equal(feb.AddDate(0, 0, 1)) {
+		t.Fatalf("got %d periods, want 3 days", len(daily))
+	}
+	if web := daily[1].Sources[0]; web.Share != 30.0/70 || web.Index != 100 {
+		t.Errorf("got web on Feb 1 %+v, want share 3/7 and index 100", web)
+	}
+	if fb := daily[0].Sources[1]; fb.Index != 0 {
+		t.Errorf("got facebook index %v before its first sessions, want 0", fb.Index)
+	}
+
+	monthly, err := derive.SourceShares(context.Background(), fetch, f, derive.Monthly)
+	if err != nil {
+		t.Fatalf("SourceShares() err=%v", err)
+	}
+	if len(monthly) != 2 || monthly[1].Total != 100 || !monthly[1].To.Equal(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got %+v, want January and February", monthly)
+	}
+	if web := monthly[1].Sources[0]; web.Count != 60 || web.Share != 0.6 || web.Index != 200 {
+		t.Errorf("got web in February %+v, want 60 sessions, share 0.6 and index 200", web)
+	}
+}
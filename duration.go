@@ -0,0 +1,156 @@
+package kindly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a convenience type to work with durations in the Kindly API,
+// which are given in seconds or as ISO 8601 durations.
+type Duration struct {
+	time.Duration
+}
+
+// Seconds returns a Duration of s seconds.
+func Seconds(s float64) Duration {
+	return Duration{time.Duration(math.Round(s * float64(time.Second)))}
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts a number of seconds,
+// a string of a number of seconds, an ISO 8601 duration such as "PT1M30S" or
+// a Go duration such as "1m30s". Null is zero.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		d.Duration = 0
+		return nil
+	}
+
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+
+	parsed, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, as a number of seconds.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(d.Seconds(), 'f', -1, 64)), nil
+}
+
+// ISO8601 returns d as an ISO 8601 duration, such as "PT1M30S".
+func (d Duration) ISO8601() string {
+	if d.Duration == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	rest := d.Duration
+	if rest < 0 {
+		b.WriteByte('-')
+		rest = -rest
+	}
+	b.WriteString("PT")
+	if h := rest / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+		rest -= h * time.Hour
+	}
+	if m := rest / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+		rest -= m * time.Minute
+	}
+	if rest > 0 {
+		b.WriteString(strconv.FormatFloat(rest.Seconds(), 'f', -1, 64) + "S")
+	}
+
+	return b.String()
+}
+
+// ParseDuration parses a number of seconds, an ISO 8601 duration or a Go
+// duration. ISO 8601 durations may have days and weeks, taken as 24 hours and
+// 7 days, but not months or years, whose length varies.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(seconds) && !math.IsInf(seconds, 0) {
+		return Seconds(seconds).Duration, nil
+	}
+
+	iso := strings.TrimPrefix(s, "-")
+	if strings.HasPrefix(iso, "P") {
+		d, err := parseISO8601(iso)
+		if err != nil {
+			return 0, fmt.Errorf("kindly: parsing duration %q: %w", s, err)
+		}
+		if strings.HasPrefix(s, "-") {
+			d = -d
+		}
+		return d, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("kindly: parsing duration %q: not seconds, ISO 8601 or a Go duration", s)
+	}
+
+	return d, nil
+}
+
+// parseISO8601 parses an ISO 8601 duration without a sign.
+func parseISO8601(s string) (time.Duration, error) {
+	units := map[bool]map[byte]time.Duration{
+		false: {'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour},
+		true:  {'H': time.Hour, 'M': time.Minute, 'S': time.Second},
+	}
+
+	var d time.Duration
+	inTime := false
+	number := ""
+	seen := false
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 'T' && !inTime && number == "":
+			inTime = true
+		case c >= '0' && c <= '9' || c == '.' || c == ',':
+			if c == ',' {
+				c = '.'
+			}
+			number += string(c)
+		default:
+			unit, ok := units[inTime][c]
+			if !ok {
+				return 0, fmt.Errorf("unsupported designator %q", c)
+			}
+			if number == "" {
+				return 0, fmt.Errorf("designator %q without a number", c)
+			}
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, err
+			}
+			d += time.Duration(math.Round(n * float64(unit)))
+			number = ""
+			seen = true
+		}
+	}
+	if number != "" || !seen {
+		return 0, fmt.Errorf("incomplete duration")
+	}
+
+	return d, nil
+}
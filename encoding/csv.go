@@ -0,0 +1,51 @@
+package encoding
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+type csvEncoder struct {
+	w  io.Writer
+	cw *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer, comma rune) *csvEncoder {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &csvEncoder{w: w, cw: cw}
+}
+
+func (c *csvEncoder) Write(row []string) error {
+	return c.cw.Write(row)
+}
+
+func (c *csvEncoder) WriteAll(rows [][]string) error {
+	for _, row := range rows {
+		if err := c.cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Comment implements Commenter by writing a line starting with "# ".
+func (c *csvEncoder) Comment(text string) error {
+	if err := c.Close(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(c.w, "# %s\n", text)
+	return err
+}
+
+func (c *csvEncoder) Close() error {
+	c.cw.Flush()
+	if err := c.cw.Error(); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+
+	return nil
+}
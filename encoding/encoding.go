@@ -0,0 +1,158 @@
+// Package encoding writes tables of string cells, as produced by the tools in
+// this module, in the output formats they support.
+package encoding
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// RowEncoder writes a table row by row. The first row is the header; formats
+// with named fields take the field names from it.
+type RowEncoder interface {
+	Write(row []string) error
+	WriteAll(rows [][]string) error
+	// Close writes anything still buffered. Formats that can only be
+	// written as a whole, such as XLSX and Parquet, buffer every row until
+	// Close. It does not close the underlying writer.
+	Close() error
+}
+
+// Commenter is implemented by encoders for formats that can carry comment
+// lines after the rows, such as CSV.
+type Commenter interface {
+	Comment(text string) error
+}
+
+// Format is an output format.
+type Format string
+
+const (
+	CSV     Format = "csv"
+	TSV     Format = "tsv"
+	JSON    Format = "json"
+	NDJSON  Format = "ndjson"
+	XLSX    Format = "xlsx"
+	Parquet Format = "parquet"
+	// Table is a fixed-width table for terminals.
+	Table Format = "table"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{CSV, TSV, JSON, NDJSON, XLSX, Parquet, Table}
+
+// ParseFormat returns the format named s. An empty s is CSV.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return CSV, nil
+	}
+
+	for _, f := range Formats {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("encoding: unknown format %q", s)
+}
+
+// ContentType returns the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case TSV:
+		return "text/tab-separated-values; charset=utf-8"
+	case JSON:
+		return "application/json"
+	case NDJSON:
+		return "application/x-ndjson"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	case Table:
+		return "text/plain; charset=utf-8"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Extension returns the file name extension of the format, including the dot.
+func (f Format) Extension() string {
+	if f == Table {
+		return ".txt"
+	}
+
+	return "." + string(f)
+}
+
+// NewEncoder returns an encoder writing the format to w.
+func NewEncoder(w io.Writer, f Format, opts ...EncoderOption) (RowEncoder, error) {
+	var enc RowEncoder
+	switch f {
+	case CSV:
+		enc = newCSVEncoder(w, ',')
+	case TSV:
+		enc = newCSVEncoder(w, '\t')
+	case JSON:
+		enc = &jsonEncoder{w: w}
+	case NDJSON:
+		enc = &jsonEncoder{w: w, lines: true}
+	case XLSX:
+		enc = &xlsxEncoder{w: w}
+	case Parquet:
+		enc = &parquetEncoder{w: w}
+	case Table:
+		enc = &tableEncoder{w: w}
+	default:
+		return nil, fmt.Errorf("encoding: unknown format %q", f)
+	}
+
+	return applyOptions(enc, f, opts), nil
+}
+
+var (
+	numberRe  = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+	integerRe = regexp.MustCompile(`^-?(0|[1-9][0-9]{0,17})$`)
+)
+
+// isNumber reports whether the cell is a number as JSON writes them, so it
+// can be written as a number by typed formats.
+func isNumber(cell string) bool {
+	return numberRe.MatchString(cell)
+}
+
+// isInteger reports whether the cell is an integer that fits in an int64.
+func isInteger(cell string) bool {
+	return integerRe.MatchString(cell)
+}
+
+// table buffers rows for formats that are written on Close.
+type table struct {
+	hdr  []string
+	rows [][]string
+}
+
+func (t *table) Write(row []string) error {
+	if t.hdr == nil {
+		t.hdr = append([]string{}, row...)
+		return nil
+	}
+	if len(row) != len(t.hdr) {
+		return fmt.Errorf("encoding: row has %d cells, header has %d", len(row), len(t.hdr))
+	}
+
+	t.rows = append(t.rows, append([]string{}, row...))
+	return nil
+}
+
+func (t *table) WriteAll(rows [][]string) error {
+	for _, row := range rows {
+		if err := t.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package encoding_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/atb-as/kindly/encoding"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+var rows = [][]string{
+	{"date", "source", "count"},
+	{"2021-03-01", "web", "12"},
+	{"2021-03-02", "facebook, messenger", ""},
+}
+
+func encode(t *testing.T, f encoding.Format, rows [][]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := encoding.NewEncoder(&buf, f)
+	if err != nil {
+		t.Fatalf("NewEncoder(%q) err=%v", f, err)
+	}
+	if err := enc.WriteAll(rows); err != nil {
+		t.Fatalf("WriteAll() err=%v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() err=%v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestEncoder_Text(t *testing.T) {
+	tests := []struct {
+		format encoding.Format
+		want   string
+	}{
+		{encoding.CSV, "date,source,count\n2021-03-01,web,12\n2021-03-02,\"facebook, messenger\",\n"},
+		{encoding.TSV, "date\tsource\tcount\n2021-03-01\tweb\t12\n2021-03-02\tfacebook, messenger\t\n"},
+		{encoding.JSON, `[{"date":"2021-03-01","source":"web","count":12},{"date":"2021-03-02","source":"facebook, messenger","count":null}]` + "\n"},
+		{encoding.NDJSON, `{"date":"2021-03-01","source":"web","count":12}` + "\n" + `{"date":"2021-03-02","source":"facebook, messenger","count":null}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		if got := string(encode(t, tt.format, rows)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestEncoder_JSONEmpty(t *testing.T) {
+	if got := string(encode(t, encoding.JSON, rows[:1])); got != "[]\n" {
+		t.Errorf("got %q, want []", got)
+	}
+}
+
+func TestEncoder_RowLength(t *testing.T) {
+	enc, _ := encoding.NewEncoder(io.Discard, encoding.JSON)
+	if err := enc.WriteAll([][]string{{"a", "b"}, {"1"}}); err == nil {
+		t.Error("WriteAll() err=nil, want error for short row")
+	}
+}
+
+func TestCSV_Comment(t *testing.T) {
+	var buf bytes.Buffer
+	enc, _ := encoding.NewEncoder(&buf, encoding.CSV)
+	enc.Write([]string{"a"})
+	if err := enc.(encoding.Commenter).Comment("truncated"); err != nil {
+		t.Fatalf("Comment() err=%v", err)
+	}
+	enc.Close()
+
+	if got, want := buf.String(), "a\n# truncated\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := encoding.ParseFormat(""); err != nil || f != encoding.CSV {
+		t.Errorf("ParseFormat(\"\") = %q, %v, want csv", f, err)
+	}
+	if f, err := encoding.ParseFormat("XLSX"); err != nil || f != encoding.XLSX {
+		t.Errorf("ParseFormat(\"XLSX\") = %q, %v, want xlsx", f, err)
+	}
+	if _, err := encoding.ParseFormat("yaml"); err == nil {
+		t.Error("ParseFormat(\"yaml\") err=nil, want error")
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	b := encode(t, encoding.XLSX, rows)
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() err=%v", err)
+	}
+
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			body, _ := io.ReadAll(rc)
+			rc.Close()
+			sheet = string(body)
+		}
+	}
+
+	for _, want := range []string{
+		`<c r="C1" t="inlineStr"><is><t xml:space="preserve">count</t></is></c>`,
+		`<c r="C2"><v>12</v></c>`,
+		`<c r="B3" t="inlineStr"><is><t xml:space="preserve">facebook, messenger</t></is></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet1.xml does not contain %s:\n%s", want, sheet)
+		}
+	}
+}
+
+func TestParquet(t *testing.T) {
+	b := encode(t, encoding.Parquet, rows)
+
+	// The file is read back with an independent implementation of Parquet.
+	pr, err := reader.NewParquetColumnReader(newParquetFile(b), 1)
+	if err != nil {
+		t.Fatalf("NewParquetColumnReader() err=%v", err)
+	}
+	defer pr.ReadStop()
+	if got := pr.GetNumRows(); got != 2 {
+		t.Errorf("got %d rows, want 2", got)
+	}
+
+	want := map[string][]interface{}{
+		"date":   {"2021-03-01", "2021-03-02"},
+		"source": {"web", "facebook, messenger"},
+		"count":  {int64(12), nil},
+	}
+	columns := pr.SchemaHandler.ValueColumns
+	if len(columns) != len(want) {
+		t.Fatalf("got columns %v, want %d", columns, len(want))
+	}
+	for i, path := range columns {
+		name := rows[0][i]
+		values, _, _, err := pr.ReadColumnByPath(path, 2)
+		if err != nil {
+			t.Fatalf("reading column %s: err=%v", name, err)
+		}
+		if !reflect.DeepEqual(values, want[name]) {
+			t.Errorf("column %s: got %#v, want %#v", name, values, want[name])
+		}
+	}
+}
+
+// parquetFile is a source.ParquetFile reading b.
+type parquetFile struct {
+	*bytes.Reader
+	b []byte
+}
+
+func newParquetFile(b []byte) *parquetFile {
+	return &parquetFile{Reader: bytes.NewReader(b), b: b}
+}
+
+// Open returns another reader of the file, as the reader opens the file once
+// per column.
+func (f *parquetFile) Open(name string) (source.ParquetFile, error) {
+	return newParquetFile(f.b), nil
+}
+
+func (f *parquetFile) Create(name string) (source.ParquetFile, error) {
+	return nil, errors.New("read only")
+}
+
+func (f *parquetFile) Write(b []byte) (int, error) {
+	return 0, errors.New("read only")
+}
+
+func (f *parquetFile) Close() error {
+	return nil
+}
+
+func TestTable(t *testing.T) {
+	var buf bytes.Buffer
+	enc, _ := encoding.NewEncoder(&buf, encoding.Table)
+	enc.WriteAll(rows)
+	if err := enc.(encoding.Commenter).Comment("truncated"); err != nil {
+		t.Fatalf("Comment() err=%v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() err=%v", err)
+	}
+
+	want := "date        source               count\n" +
+		"----------  -------------------  -----\n" +
+		"2021-03-01  web                     12\n" +
+		"2021-03-02  facebook, messenger\n" +
+		"# truncated\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package encoding
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonEncoder writes every row after the header as an object keyed by the
+// header, either in a JSON array or, for NDJSON, one object per line.
+// Numeric cells are written as numbers and empty cells as null.
+type jsonEncoder struct {
+	w     io.Writer
+	bw    *bufio.Writer
+	lines bool
+	hdr   [][]byte
+	rows  int
+}
+
+func (j *jsonEncoder) Write(row []string) error {
+	if j.bw == nil {
+		j.bw = bufio.NewWriter(j.w)
+	}
+
+	if j.hdr == nil {
+		j.hdr = make([][]byte, len(row))
+		for i, name := range row {
+			b, err := json.Marshal(name)
+			if err != nil {
+				return err
+			}
+			j.hdr[i] = b
+		}
+		return nil
+	}
+	if len(row) != len(j.hdr) {
+		return fmt.Errorf("encoding: row has %d cells, header has %d", len(row), len(j.hdr))
+	}
+
+	switch {
+	case j.lines:
+	case j.rows == 0:
+		j.bw.WriteByte('[')
+	default:
+		j.bw.WriteByte(',')
+	}
+	j.rows++
+
+	j.bw.WriteByte('{')
+	for i, cell := range row {
+		if i > 0 {
+			j.bw.WriteByte(',')
+		}
+		j.bw.Write(j.hdr[i])
+		j.bw.WriteByte(':')
+		switch {
+		case cell == "":
+			j.bw.WriteString("null")
+		case isNumber(cell):
+			j.bw.WriteString(cell)
+		default:
+			b, err := json.Marshal(cell)
+			if err != nil {
+				return err
+			}
+			j.bw.Write(b)
+		}
+	}
+	j.bw.WriteByte('}')
+	if j.lines {
+		j.bw.WriteByte('\n')
+	}
+
+	return nil
+}
+
+func (j *jsonEncoder) WriteAll(rows [][]string) error {
+	for _, row := range rows {
+		if err := j.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j *jsonEncoder) Close() error {
+	if j.bw == nil {
+		j.bw = bufio.NewWriter(j.w)
+	}
+
+	if !j.lines {
+		if j.rows == 0 {
+			j.bw.WriteString("[]\n")
+		} else {
+			j.bw.WriteString("]\n")
+		}
+	}
+
+	return j.bw.Flush()
+}
@@ -0,0 +1,211 @@
+package encoding
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Numbers selects how the decimal numbers of a table are written. The zero
+// value writes them as they are.
+type Numbers struct {
+	// Round writes decimal numbers with Decimals decimals. Integers are
+	// kept as they are, outside Percent columns.
+	Round    bool
+	Decimals int
+	// Percent reports whether the column with the header name holds
+	// ratios to write as percentages: 0.1234 as 12.34% in text formats,
+	// and as 12.34 in formats with typed numbers, such as JSON.
+	Percent func(name string) bool
+	// DecimalSeparator replaces the decimal point of numbers in text
+	// formats, such as ',' for Norwegian. Formats with typed numbers always
+	// write a point. Zero keeps the point.
+	DecimalSeparator rune
+}
+
+// Typed reports whether the format writes numbers as typed values rather
+// than text: JSON, NDJSON, XLSX and Parquet.
+func (f Format) Typed() bool {
+	switch f {
+	case JSON, NDJSON, XLSX, Parquet:
+		return true
+	default:
+		return false
+	}
+}
+
+// NumberFormatter formats the numbers of the rows of a table as selected by
+// Numbers. See Numbers.Formatter.
+type NumberFormatter struct {
+	n     Numbers
+	typed bool
+	// percent flags the Percent columns, set once the header is formatted.
+	percent []bool
+	hdr     bool
+}
+
+// Formatter returns a formatter of the rows of a table in format f. The
+// first row formatted is the header.
+func (n Numbers) Formatter(f Format) *NumberFormatter {
+	return &NumberFormatter{n: n, typed: f.Typed()}
+}
+
+// Row returns row with its numbers formatted. The header is returned as it
+// is.
+func (nf *NumberFormatter) Row(row []string) []string {
+	if !nf.hdr {
+		nf.hdr = true
+		if nf.n.Percent != nil {
+			nf.percent = make([]bool, len(row))
+			for i, name := range row {
+				nf.percent[i] = nf.n.Percent(name)
+			}
+		}
+		return row
+	}
+
+	var out []string
+	for i, cell := range row {
+		v := nf.cell(cell, i < len(nf.percent) && nf.percent[i])
+		if v == cell {
+			continue
+		}
+		if out == nil {
+			out = append([]string{}, row...)
+		}
+		out[i] = v
+	}
+	if out == nil {
+		return row
+	}
+
+	return out
+}
+
+func (nf *NumberFormatter) cell(cell string, percent bool) string {
+	if !isNumber(cell) || (isInteger(cell) && !percent) {
+		return cell
+	}
+
+	v := cell
+	if percent {
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return cell
+		}
+		decimals := nf.n.Decimals
+		if !nf.n.Round {
+			// Keep the digits of the ratio: 0.1234 is 12.34.
+			decimals = decimalPlaces(cell) - 2
+			if decimals < 0 {
+				decimals = 0
+			}
+		}
+		v = strconv.FormatFloat(round(f*100, decimals), 'f', decimals, 64)
+	} else if nf.n.Round {
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return cell
+		}
+		v = strconv.FormatFloat(round(f, nf.n.Decimals), 'f', nf.n.Decimals, 64)
+	}
+	if v == "-0" || strings.HasPrefix(v, "-0.") && strings.Trim(v[3:], "0") == "" {
+		v = v[1:]
+	}
+	if nf.typed {
+		return v
+	}
+
+	if nf.n.DecimalSeparator != 0 && nf.n.DecimalSeparator != '.' {
+		v = strings.Replace(v, ".", string(nf.n.DecimalSeparator), 1)
+	}
+	if percent {
+		v += "%"
+	}
+
+	return v
+}
+
+// decimalPlaces returns the number of digits after the point of the number
+// cell.
+func decimalPlaces(cell string) int {
+	i := strings.IndexByte(cell, '.')
+	if i < 0 {
+		return 0
+	}
+	n := len(cell) - i - 1
+	if e := strings.IndexAny(cell, "eE"); e > i {
+		n = e - i - 1
+		exp, _ := strconv.Atoi(cell[e+1:])
+		n -= exp
+	}
+
+	return n
+}
+
+// round rounds v half away from zero to decimals decimals, so that ratios
+// such as 0.125 are not rounded down by their binary representation.
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
+
+// numberEncoder formats the numbers of the rows written to a RowEncoder.
+type numberEncoder struct {
+	RowEncoder
+	nf *NumberFormatter
+}
+
+func (e *numberEncoder) Write(row []string) error {
+	return e.RowEncoder.Write(e.nf.Row(row))
+}
+
+func (e *numberEncoder) WriteAll(rows [][]string) error {
+	for _, row := range rows {
+		if err := e.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// numberCommenter is a numberEncoder of an encoder that can write comments.
+type numberCommenter struct {
+	numberEncoder
+}
+
+func (e *numberCommenter) Comment(text string) error {
+	return e.RowEncoder.(Commenter).Comment(text)
+}
+
+// EncoderOption configures an encoder returned by NewEncoder.
+type EncoderOption func(o *encoderOptions)
+
+type encoderOptions struct {
+	numbers *Numbers
+}
+
+// WithNumbers formats the numbers of the rows as selected by n.
+func WithNumbers(n Numbers) EncoderOption {
+	return func(o *encoderOptions) {
+		o.numbers = &n
+	}
+}
+
+func applyOptions(enc RowEncoder, f Format, opts []EncoderOption) RowEncoder {
+	var o encoderOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.numbers == nil {
+		return enc
+	}
+
+	ne := numberEncoder{RowEncoder: enc, nf: o.numbers.Formatter(f)}
+	if _, ok := enc.(Commenter); ok {
+		return &numberCommenter{ne}
+	}
+
+	return &ne
+}
@@ -0,0 +1,56 @@
+package encoding_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/atb-as/kindly/encoding"
+)
+
+var ratioRows = [][]string{
+	{"date", "count", "share", "average"},
+	{"2021-03-01", "12", "0.1234", "3.14159"},
+	{"2021-03-02", "3", "1", "-0.0001"},
+}
+
+func TestWithNumbers(t *testing.T) {
+	isShare := func(name string) bool { return name == "share" }
+	tests := []struct {
+		format  encoding.Format
+		numbers encoding.Numbers
+		want    string
+	}{
+		{encoding.CSV, encoding.Numbers{}, "date,count,share,average\n2021-03-01,12,0.1234,3.14159\n2021-03-02,3,1,-0.0001\n"},
+		{encoding.CSV, encoding.Numbers{Round: true, Decimals: 2}, "date,count,share,average\n2021-03-01,12,0.12,3.14\n2021-03-02,3,1,0.00\n"},
+		{encoding.CSV, encoding.Numbers{Percent: isShare}, "date,count,share,average\n2021-03-01,12,12.34%,3.14159\n2021-03-02,3,100%,-0.0001\n"},
+		{encoding.CSV, encoding.Numbers{Round: true, Decimals: 1, Percent: isShare, DecimalSeparator: ','}, "date,count,share,average\n2021-03-01,12,\"12,3%\",\"3,1\"\n2021-03-02,3,\"100,0%\",\"0,0\"\n"},
+		{encoding.NDJSON, encoding.Numbers{Round: true, Decimals: 1, Percent: isShare, DecimalSeparator: ','}, `{"date":"2021-03-01","count":12,"share":12.3,"average":3.1}` + "\n" + `{"date":"2021-03-02","count":3,"share":100.0,"average":0.0}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc, err := encoding.NewEncoder(&buf, tt.format, encoding.WithNumbers(tt.numbers))
+		if err != nil {
+			t.Fatalf("NewEncoder(%q) err=%v", tt.format, err)
+		}
+		enc.WriteAll(ratioRows)
+		if err := enc.Close(); err != nil {
+			t.Fatalf("Close() err=%v", err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s %+v: got %q, want %q", tt.format, tt.numbers, got, tt.want)
+		}
+	}
+}
+
+func TestWithNumbers_Commenter(t *testing.T) {
+	var buf bytes.Buffer
+	enc, _ := encoding.NewEncoder(&buf, encoding.CSV, encoding.WithNumbers(encoding.Numbers{Round: true}))
+	if _, ok := enc.(encoding.Commenter); !ok {
+		t.Errorf("got %T, want CSV to keep writing comments", enc)
+	}
+	enc, _ = encoding.NewEncoder(&buf, encoding.JSON, encoding.WithNumbers(encoding.Numbers{Round: true}))
+	if _, ok := enc.(encoding.Commenter); ok {
+		t.Errorf("got %T, want JSON not to write comments", enc)
+	}
+}
@@ -0,0 +1,222 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Parquet physical types, repetition types, encodings and page types from
+// parquet.thrift.
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional = 1
+	parquetUTF8     = 0
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+)
+
+// parquetEncoder writes a Parquet file with a single row group and one
+// uncompressed, PLAIN encoded data page per column. Every column is
+// optional, with empty cells written as nulls. A column is INT64 if all its
+// cells are integers, DOUBLE if they are all numbers and a UTF-8 string
+// otherwise.
+type parquetEncoder struct {
+	table
+	w io.Writer
+}
+
+type parquetColumn struct {
+	name   string
+	typ    int32
+	offset int64
+	size   int64
+}
+
+func (p *parquetEncoder) Close() error {
+	var out bytes.Buffer
+	out.WriteString("PAR1")
+
+	columns := make([]*parquetColumn, len(p.hdr))
+	names := make(map[string]int)
+	for i, name := range p.hdr {
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		// Field names must be unique within the schema.
+		if n := names[name]; n > 0 {
+			names[name]++
+			name = fmt.Sprintf("%s_%d", name, n+1)
+		} else {
+			names[name] = 1
+		}
+
+		col := &parquetColumn{name: name, typ: p.columnType(i), offset: int64(out.Len())}
+		page, err := p.page(i, col.typ)
+		if err != nil {
+			return err
+		}
+
+		t := &thriftWriter{}
+		t.begin()
+		t.i32(1, parquetDataPage)
+		t.i32(2, int32(len(page)))
+		t.i32(3, int32(len(page)))
+		t.structBegin(5)
+		t.i32(1, int32(len(p.rows)))
+		t.i32(2, parquetPlain)
+		t.i32(3, parquetRLE)
+		t.i32(4, parquetRLE)
+		t.end()
+		t.end()
+
+		out.Write(t.buf.Bytes())
+		out.Write(page)
+		col.size = int64(out.Len()) - col.offset
+		columns[i] = col
+	}
+
+	meta := p.metadata(columns)
+	out.Write(meta)
+	binary.Write(&out, binary.LittleEndian, uint32(len(meta)))
+	out.WriteString("PAR1")
+
+	_, err := out.WriteTo(p.w)
+	return err
+}
+
+func (p *parquetEncoder) columnType(i int) int32 {
+	typ := int32(parquetInt64)
+	empty := true
+	for _, row := range p.rows {
+		cell := row[i]
+		if cell == "" {
+			continue
+		}
+		empty = false
+		switch {
+		case isInteger(cell):
+		case isNumber(cell):
+			typ = parquetDouble
+		default:
+			return parquetByteArray
+		}
+	}
+	if empty {
+		return parquetByteArray
+	}
+
+	return typ
+}
+
+// page returns the definition levels and values of column i.
+func (p *parquetEncoder) page(i int, typ int32) ([]byte, error) {
+	// Definition levels have a bit width of 1 and are written as a single
+	// bit-packed run of the RLE/bit-packing hybrid encoding.
+	groups := (len(p.rows) + 7) / 8
+	levels := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+groups)
+	levels = append(levels[:binary.PutUvarint(levels, uint64(groups)<<1|1)], make([]byte, groups)...)
+	header := len(levels) - groups
+
+	var values bytes.Buffer
+	for j, row := range p.rows {
+		cell := row[i]
+		if cell == "" {
+			continue
+		}
+		levels[header+j/8] |= 1 << (j % 8)
+
+		switch typ {
+		case parquetInt64:
+			v, err := strconv.ParseInt(cell, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			binary.Write(&values, binary.LittleEndian, v)
+		case parquetDouble:
+			v, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return nil, err
+			}
+			binary.Write(&values, binary.LittleEndian, math.Float64bits(v))
+		default:
+			binary.Write(&values, binary.LittleEndian, uint32(len(cell)))
+			values.WriteString(cell)
+		}
+	}
+
+	page := make([]byte, 4, 4+len(levels)+values.Len())
+	binary.LittleEndian.PutUint32(page, uint32(len(levels)))
+	page = append(page, levels...)
+	return append(page, values.Bytes()...), nil
+}
+
+// metadata returns the FileMetaData of the file.
+func (p *parquetEncoder) metadata(columns []*parquetColumn) []byte {
+	t := &thriftWriter{}
+	t.begin()
+	t.i32(1, 1)
+
+	t.list(2, thriftStruct, len(columns)+1)
+	t.begin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(columns)))
+	t.end()
+	for _, col := range columns {
+		t.begin()
+		t.i32(1, col.typ)
+		t.i32(3, parquetOptional)
+		t.binary(4, col.name)
+		if col.typ == parquetByteArray {
+			t.i32(6, parquetUTF8)
+		}
+		t.end()
+	}
+
+	t.i64(3, int64(len(p.rows)))
+
+	if len(columns) == 0 {
+		t.list(4, thriftStruct, 0)
+	} else {
+		var total int64
+		t.list(4, thriftStruct, 1)
+		t.begin()
+		t.list(1, thriftStruct, len(columns))
+		for _, col := range columns {
+			total += col.size
+			t.begin()
+			t.i64(2, col.offset)
+			t.structBegin(3)
+			t.i32(1, col.typ)
+			t.list(2, thriftI32, 2)
+			t.varint(parquetPlain)
+			t.varint(parquetRLE)
+			t.list(3, thriftBinary, 1)
+			t.bytes(col.name)
+			t.i32(4, 0)
+			t.i64(5, int64(len(p.rows)))
+			t.i64(6, col.size)
+			t.i64(7, col.size)
+			t.i64(9, col.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, total)
+		t.i64(3, int64(len(p.rows)))
+		t.end()
+	}
+
+	t.binary(6, "github.com/atb-as/kindly")
+	t.end()
+
+	return t.buf.Bytes()
+}
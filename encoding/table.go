@@ -0,0 +1,86 @@
+package encoding
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// tableEncoder writes a fixed-width table for reading in a terminal. Columns
+// are padded to their widest cell, and numeric columns are right-aligned.
+type tableEncoder struct {
+	table
+	w        io.Writer
+	comments []string
+}
+
+// Comment implements Commenter. Comments are written below the table, as
+// lines starting with "# ".
+func (t *tableEncoder) Comment(text string) error {
+	t.comments = append(t.comments, text)
+	return nil
+}
+
+func (t *tableEncoder) Close() error {
+	if t.hdr == nil {
+		return nil
+	}
+
+	widths := make([]int, len(t.hdr))
+	numeric := make([]bool, len(t.hdr))
+	for i, cell := range t.hdr {
+		widths[i] = utf8.RuneCountInString(cell)
+		numeric[i] = len(t.rows) > 0
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+			if cell != "" && !isFormattedNumber(cell) {
+				numeric[i] = false
+			}
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		var line strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			if numeric[i] {
+				line.WriteString(pad + cell)
+			} else {
+				line.WriteString(cell + pad)
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteByte('\n')
+	}
+
+	writeRow(t.hdr)
+	rule := make([]string, len(widths))
+	for i, w := range widths {
+		rule[i] = strings.Repeat("-", w)
+	}
+	writeRow(rule)
+	for _, row := range t.rows {
+		writeRow(row)
+	}
+	for _, c := range t.comments {
+		fmt.Fprintf(&b, "# %s\n", c)
+	}
+
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+// isFormattedNumber reports whether the cell is a number, possibly written
+// as a percentage or with a decimal comma by Numbers.
+func isFormattedNumber(cell string) bool {
+	return isNumber(strings.Replace(strings.TrimSuffix(cell, "%"), ",", ".", 1))
+}
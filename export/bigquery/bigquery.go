@@ -0,0 +1,164 @@
+// Package bigquery streams collected Kindly statistics into a BigQuery table.
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"golang.org/x/oauth2/google"
+)
+
+const BaseURL = "https://bigquery.googleapis.com/bigquery/v2"
+
+// Sink implements export.Sink by streaming points into a table with the
+// tabledata.insertAll method. The table must have the columns
+//
+//	metric STRING, time TIMESTAMP, value FLOAT64,
+//	tags ARRAY<STRUCT<key STRING, value STRING>>
+type Sink struct {
+	BaseURL string
+	Project string
+	Dataset string
+	Table   string
+	doer    Doer
+}
+
+// NewSink returns a sink writing to table, given as <project>.<dataset>.<table>,
+// using doer, which is responsible for authenticating requests, such as an
+// *http.Client from google.DefaultClient.
+func NewSink(table string, doer Doer) (*Sink, error) {
+	parts := strings.Split(table, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("bigquery: want <project>.<dataset>.<table>, got %q", table)
+	}
+
+	return &Sink{BaseURL: BaseURL, Project: parts[0], Dataset: parts[1], Table: parts[2], doer: doer}, nil
+}
+
+// NewDefaultSink returns a sink writing to table, authenticated with
+// Application Default Credentials.
+func NewDefaultSink(ctx context.Context, table string) (*Sink, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/bigquery.insertdata")
+	if err != nil {
+		return nil, fmt.Errorf("bigquery: %w", err)
+	}
+
+	return NewSink(table, client)
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+type tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type row struct {
+	Metric string  `json:"metric"`
+	Time   string  `json:"time"`
+	Value  float64 `json:"value"`
+	Tags   []tag   `json:"tags"`
+}
+
+type insertRow struct {
+	InsertID string `json:"insertId"`
+	JSON     *row   `json:"json"`
+}
+
+type insertResponse struct {
+	InsertErrors []struct {
+		Index  int `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+// Write implements export.Sink. Every point is inserted with an ID derived
+// from its metric, time and tags, so BigQuery drops a point written again
+// shortly after, e.g. by a retried run.
+func (s *Sink) Write(ctx context.Context, points []*export.Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	rows := make([]insertRow, 0, len(points))
+	for _, point := range points {
+		r := &row{Metric: point.Metric, Time: point.Time.UTC().Format(time.RFC3339Nano), Value: point.Value, Tags: sortedTags(point.Tags)}
+		rows = append(rows, insertRow{InsertID: insertID(r), JSON: r})
+	}
+	body, err := json.Marshal(map[string]interface{}{"rows": rows})
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/projects/%s/datasets/%s/tables/%s/insertAll", s.BaseURL, url.PathEscape(s.Project), url.PathEscape(s.Dataset), url.PathEscape(s.Table))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("bigquery: unexpected status %q: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result insertResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("bigquery: decoding response: %w", err)
+	}
+	if len(result.InsertErrors) > 0 {
+		e := result.InsertErrors[0]
+		msg := "unknown error"
+		if len(e.Errors) > 0 {
+			msg = e.Errors[0].Reason + ": " + e.Errors[0].Message
+		}
+		return fmt.Errorf("bigquery: %d of %d rows failed, row %d: %s", len(result.InsertErrors), len(rows), e.Index, msg)
+	}
+
+	return nil
+}
+
+func sortedTags(tags map[string]string) []tag {
+	ret := make([]tag, 0, len(tags))
+	for k, v := range tags {
+		ret = append(ret, tag{Key: k, Value: v})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Key < ret[j].Key
+	})
+
+	return ret
+}
+
+func insertID(r *row) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s", r.Metric, r.Time)
+	for _, t := range r.Tags {
+		fmt.Fprintf(h, "\x00%s=%s", t.Key, t.Value)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
@@ -0,0 +1,91 @@
+package bigquery_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/export/bigquery"
+)
+
+func TestSink_Write(t *testing.T) {
+	var got struct {
+		Rows []struct {
+			InsertID string
+			JSON     struct {
+				Metric string
+				Time   string
+				Value  float64
+				Tags   []struct{ Key, Value string }
+			}
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/projects/p/datasets/d/tables/t/insertAll"; r.URL.Path != want {
+			t.Errorf("got path %q, want %q", r.URL.Path, want)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: err=%v", err)
+		}
+		w.Write([]byte(`{"kind":"bigquery#tableDataInsertAllResponse"}`))
+	}))
+	defer srv.Close()
+
+	sink, err := bigquery.NewSink("p.d.t", http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewSink() err=%v", err)
+	}
+	sink.BaseURL = srv.URL
+
+	ts := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	points := []*export.Point{
+		{Metric: "kindly.sessions", Time: ts, Value: 42, Tags: map[string]string{"source": "web", "bot": "1"}},
+		{Metric: "kindly.sessions", Time: ts, Value: 7, Tags: map[string]string{"source": "facebook", "bot": "1"}},
+	}
+	if err := sink.Write(context.Background(), points); err != nil {
+		t.Fatalf("Write() err=%v", err)
+	}
+
+	if len(got.Rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(got.Rows))
+	}
+	r := got.Rows[0].JSON
+	if r.Metric != "kindly.sessions" || r.Time != "2021-02-01T00:00:00Z" || r.Value != 42 {
+		t.Errorf("unexpected row: %+v", r)
+	}
+	if len(r.Tags) != 2 || r.Tags[0].Key != "bot" || r.Tags[1].Key != "source" || r.Tags[1].Value != "web" {
+		t.Errorf("unexpected tags: %+v", r.Tags)
+	}
+	if got.Rows[0].InsertID == "" || got.Rows[0].InsertID == got.Rows[1].InsertID {
+		t.Errorf("want distinct insert IDs, got %q and %q", got.Rows[0].InsertID, got.Rows[1].InsertID)
+	}
+}
+
+func TestSink_WriteInsertErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"insertErrors":[{"index":0,"errors":[{"reason":"invalid","message":"no such field: tags"}]}]}`))
+	}))
+	defer srv.Close()
+
+	sink, err := bigquery.NewSink("p.d.t", http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewSink() err=%v", err)
+	}
+	sink.BaseURL = srv.URL
+
+	if err := sink.Write(context.Background(), []*export.Point{{Metric: "m"}}); err == nil {
+		t.Errorf("expected err, got nil")
+	}
+}
+
+func TestNewSink_InvalidTable(t *testing.T) {
+	for _, table := range []string{"", "d.t", "p..t", "p.d.t.x"} {
+		if _, err := bigquery.NewSink(table, http.DefaultClient); err == nil {
+			t.Errorf("NewSink(%q) expected err", table)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Package csvstore keeps collected Kindly statistics in growing CSV files,
+// one per metric, so incremental syncs only append the rows they have not
+// written before.
+package csvstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/atb-as/kindly/export"
+)
+
+// Header is the header of every file.
+var Header = []string{"date", "bot", "source", "value"}
+
+// Sink implements export.Sink by appending points to <metric>.csv in Dir.
+// A point whose date, bot and source are already in the file of its metric is
+// skipped, so overlapping syncs can be re-run safely. Existing rows are
+// never changed. It is safe for concurrent use.
+type Sink struct {
+	Dir string
+	// Layout formats the time of points in the date column. Points with
+	// the same formatted time are duplicates. Defaults to "2006-01-02".
+	Layout string
+
+	mu sync.Mutex
+	// keys are the rows of the files read or written so far, by file name.
+	keys map[string]map[string]bool
+}
+
+func NewSink(dir string) *Sink {
+	return &Sink{Dir: dir, Layout: "2006-01-02", keys: make(map[string]map[string]bool)}
+}
+
+// Write implements export.Sink.
+func (s *Sink) Write(ctx context.Context, points []*export.Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byMetric := make(map[string][][]string)
+	for _, p := range points {
+		row := []string{p.Time.Format(s.layout()), p.Tags["bot"], p.Tags["source"], strconv.FormatFloat(p.Value, 'f', -1, 64)}
+		byMetric[p.Metric] = append(byMetric[p.Metric], row)
+	}
+
+	metrics := make([]string, 0, len(byMetric))
+	for metric := range byMetric {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	for _, metric := range metrics {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.append(metric+".csv", byMetric[metric]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Sink) layout() string {
+	if s.Layout == "" {
+		return "2006-01-02"
+	}
+
+	return s.Layout
+}
+
+// append writes the rows that are not yet in the file to its end, in order
+// of date, bot and source.
+func (s *Sink) append(name string, rows [][]string) error {
+	path := filepath.Join(s.Dir, name)
+	keys, err := s.load(name, path)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for k := 0; k < 3; k++ {
+			if rows[i][k] != rows[j][k] {
+				return rows[i][k] < rows[j][k]
+			}
+		}
+		return false
+	})
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if fi, err := os.Stat(path); errors.Is(err, os.ErrNotExist) || err == nil && fi.Size() == 0 {
+		w.Write(Header)
+	}
+	added := make([]string, 0, len(rows))
+	for _, row := range rows {
+		key := rowKey(row)
+		if keys[key] {
+			continue
+		}
+		keys[key] = true
+		added = append(added, key)
+		w.Write(row)
+	}
+	w.Flush()
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err == nil {
+		_, err = f.Write(buf.Bytes())
+		if err == nil {
+			err = f.Sync()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		// The rows may be written by the next sync.
+		for _, key := range added {
+			delete(keys, key)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// load returns the keys of the rows in the file, reading them the first time.
+// A last line cut off by an interrupted write is removed.
+func (s *Sink) load(name, path string) (map[string]bool, error) {
+	if keys, ok := s.keys[name]; ok {
+		return keys, nil
+	}
+
+	keys := make(map[string]bool)
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		s.keys[name] = keys
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	complete, err := completeLength(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(complete); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(bufio.NewReader(f))
+	r.FieldsPerRecord = len(Header)
+	if _, err := r.Read(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys[rowKey(row)] = true
+	}
+
+	s.keys[name] = keys
+	return keys, nil
+}
+
+// completeLength returns the length of f up to and including its last
+// newline.
+func completeLength(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	size := fi.Size()
+	buf := make([]byte, 4096)
+	for end := size; end > 0; {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		n, err := f.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+
+	return 0, nil
+}
+
+func rowKey(row []string) string {
+	return row[0] + "\x00" + row[1] + "\x00" + row[2]
+}
@@ -0,0 +1,51 @@
+package csvstore_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/export/csvstore"
+)
+
+func TestSink_Write(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	point := func(d int, source string, v float64) *export.Point {
+		return &export.Point{Metric: "kindly.sessions", Time: day.AddDate(0, 0, d), Value: v, Tags: map[string]string{"bot": "1", "source": source}}
+	}
+
+	if err := csvstore.NewSink(dir).Write(context.Background(), []*export.Point{point(1, "web", 2), point(0, "web", 1)}); err != nil {
+		t.Fatalf("Write() err=%v", err)
+	}
+
+	// A new sink, like the next run of a cron job, skips the rows already
+	// written and drops a line cut off by an interrupted write.
+	path := filepath.Join(dir, "kindly.sessions.csv")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("2021-02-03,1,w")
+	f.Close()
+
+	if err := csvstore.NewSink(dir).Write(context.Background(), []*export.Point{point(1, "web", 5), point(1, "app", 3), point(2, "web", 4)}); err != nil {
+		t.Fatalf("Write() err=%v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "date,bot,source,value\n" +
+		"2021-02-01,1,web,1\n" +
+		"2021-02-02,1,web,2\n" +
+		"2021-02-02,1,app,3\n" +
+		"2021-02-03,1,web,4\n"
+	if got := string(b); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
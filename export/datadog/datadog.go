@@ -0,0 +1,116 @@
+// Package datadog submits collected Kindly statistics to Datadog as custom
+// metrics.
+package datadog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+)
+
+const BaseURL = "https://api.datadoghq.com"
+
+// MaxAge is how old points may be. Datadog accepts older points, but drops
+// them without an error, so history cannot be backfilled into Datadog.
+const MaxAge = time.Hour
+
+// Sink implements export.Sink by posting points to the Datadog series API.
+type Sink struct {
+	APIKey string
+	// BaseURL selects the Datadog site, e.g. https://api.datadoghq.eu.
+	BaseURL string
+	doer    Doer
+}
+
+func NewSink(apiKey string, opts ...SinkOption) *Sink {
+	s := &Sink{APIKey: apiKey, doer: http.DefaultClient}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type SinkOption func(s *Sink)
+
+func WithDoer(doer Doer) SinkOption {
+	return func(s *Sink) {
+		s.doer = doer
+	}
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+type series struct {
+	Metric string       `json:"metric"`
+	Points [][2]float64 `json:"points"`
+	Type   string       `json:"type"`
+	Tags   []string     `json:"tags,omitempty"`
+}
+
+type payload struct {
+	Series []series `json:"series"`
+}
+
+// Write implements export.Sink. Points are submitted as gauges.
+func (s *Sink) Write(ctx context.Context, points []*export.Point) error {
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+
+	p := payload{Series: make([]series, 0, len(points))}
+	for _, point := range points {
+		p.Series = append(p.Series, series{
+			Metric: point.Metric,
+			Points: [][2]float64{{float64(point.Time.Unix()), point.Value}},
+			Type:   "gauge",
+			Tags:   formatTags(point.Tags),
+		})
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/v1/series", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", s.APIKey)
+
+	resp, err := s.doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("datadog: unexpected status %q: %s", resp.Status, msg)
+	}
+
+	return nil
+}
+
+func formatTags(tags map[string]string) []string {
+	ret := make([]string, 0, len(tags))
+	for k, v := range tags {
+		ret = append(ret, k+":"+v)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
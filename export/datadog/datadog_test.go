@@ -0,0 +1,76 @@
+package datadog_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/export/datadog"
+)
+
+func TestSink_Write(t *testing.T) {
+	var got struct {
+		Series []struct {
+			Metric string
+			Points [][2]float64
+			Type   string
+			Tags   []string
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/series" {
+			t.Errorf("got path %q, want %q", r.URL.Path, "/api/v1/series")
+		}
+		if key := r.Header.Get("DD-API-KEY"); key != "key" {
+			t.Errorf("got DD-API-KEY %q, want %q", key, "key")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: err=%v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	sink := datadog.NewSink("key")
+	sink.BaseURL = srv.URL
+
+	ts := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	err := sink.Write(context.Background(), []*export.Point{
+		{Metric: "kindly.sessions", Time: ts, Value: 42, Tags: map[string]string{"source": "web", "bot": "1"}},
+	})
+	if err != nil {
+		t.Fatalf("Write() err=%v", err)
+	}
+
+	if len(got.Series) != 1 {
+		t.Fatalf("got %d series, want 1", len(got.Series))
+	}
+	s := got.Series[0]
+	if s.Metric != "kindly.sessions" || s.Type != "gauge" {
+		t.Errorf("unexpected series: %+v", s)
+	}
+	if s.Points[0][0] != float64(ts.Unix()) || s.Points[0][1] != 42 {
+		t.Errorf("unexpected points: %v", s.Points)
+	}
+	if len(s.Tags) != 2 || s.Tags[0] != "bot:1" || s.Tags[1] != "source:web" {
+		t.Errorf("unexpected tags: %v", s.Tags)
+	}
+}
+
+func TestSink_WriteError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	sink := datadog.NewSink("key")
+	sink.BaseURL = srv.URL
+
+	if err := sink.Write(context.Background(), []*export.Point{{Metric: "m"}}); err == nil {
+		t.Errorf("expected err, got nil")
+	}
+}
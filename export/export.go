@@ -0,0 +1,251 @@
+// Package export periodically collects Kindly statistics and hands them to
+// sinks for external monitoring systems.
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/atb-as/kindly/metrics"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// Point is a single metric value observed at a point in time.
+type Point struct {
+	Metric string
+	Time   time.Time
+	Value  float64
+	Tags   map[string]string
+}
+
+// Collector fetches the current points for one or more metrics.
+type Collector func(ctx context.Context) ([]*Point, error)
+
+// Sink receives collected points.
+type Sink interface {
+	Write(ctx context.Context, points []*Point) error
+}
+
+// MultiSink writes points to every sink, continuing past failures. The
+// first error is returned.
+type MultiSink []Sink
+
+// Write implements Sink.
+func (m MultiSink) Write(ctx context.Context, points []*Point) error {
+	var firstErr error
+	for _, sink := range m {
+		if err := sink.Write(ctx, points); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+type nopLogger struct {
+}
+
+func (l *nopLogger) Log(keyvals ...interface{}) error {
+	return nil
+}
+
+// today returns a filter covering the current day in the Europe/Oslo
+// timezone, which is what Sage uses when no timezone is given.
+func today(sources ...statistics.Source) *statistics.Filter {
+	loc, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	return &statistics.Filter{
+		From:    from,
+		To:      from.Add(24 * time.Hour),
+		Sources: sources,
+	}
+}
+
+// pointName returns the name of the points of the metric m.
+func pointName(m *metrics.Metric) string {
+	return "kindly." + m.Name
+}
+
+func tags(botID string, kv ...string) map[string]string {
+	t := map[string]string{"bot": botID}
+	for i := 0; i+1 < len(kv); i += 2 {
+		t[kv[i]] = kv[i+1]
+	}
+
+	return t
+}
+
+// Sessions collects today's number of chat sessions per source as
+// "kindly.sessions".
+func Sessions(c *statistics.Client, sources ...statistics.Source) Collector {
+	return countsBySource(c, metrics.Sessions, sources)
+}
+
+// Messages collects today's number of user messages per source as
+// "kindly.messages".
+func Messages(c *statistics.Client, sources ...statistics.Source) Collector {
+	return countsBySource(c, metrics.Messages, sources)
+}
+
+// countsBySource collects today's count of the counted metric m per source
+// as "kindly.<name>".
+func countsBySource(c *statistics.Client, m *metrics.Metric, sources []statistics.Source) Collector {
+	metric := pointName(m)
+	return func(ctx context.Context) ([]*Point, error) {
+		now := time.Now()
+		points := make([]*Point, 0, len(sources))
+		for _, source := range sources {
+			series, err := m.Counts(ctx, c, today(source))
+			if err != nil {
+				return nil, err
+			}
+
+			sum := 0
+			for _, count := range series {
+				sum += count.Count
+			}
+			points = append(points, &Point{Metric: metric, Time: now, Value: float64(sum), Tags: tags(c.BotID, "source", source.String())})
+		}
+
+		return points, nil
+	}
+}
+
+// FallbackRate collects today's fallback rate as "kindly.fallback_rate".
+func FallbackRate(c *statistics.Client) Collector {
+	return func(ctx context.Context) ([]*Point, error) {
+		total, err := c.FallbackRateTotal(ctx, today())
+		if err != nil {
+			return nil, err
+		}
+
+		return []*Point{{Metric: pointName(metrics.FallbackRate), Time: time.Now(), Value: total.Rate, Tags: tags(c.BotID)}}, nil
+	}
+}
+
+// Handovers collects today's handover totals as "kindly.handovers.requests",
+// "kindly.handovers.requests_while_closed", "kindly.handovers.started" and
+// "kindly.handovers.ended".
+func Handovers(c *statistics.Client) Collector {
+	return func(ctx context.Context) ([]*Point, error) {
+		total, err := c.HandoversTotal(ctx, today())
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		return []*Point{
+			{Metric: "kindly.handovers.requests", Time: now, Value: float64(total.Requests), Tags: tags(c.BotID)},
+			{Metric: "kindly.handovers.requests_while_closed", Time: now, Value: float64(total.RequestsWhileClosed), Tags: tags(c.BotID)},
+			{Metric: "kindly.handovers.started", Time: now, Value: float64(total.Started), Tags: tags(c.BotID)},
+			{Metric: "kindly.handovers.ended", Time: now, Value: float64(total.Ended), Tags: tags(c.BotID)},
+		}, nil
+	}
+}
+
+// SessionsHistory collects the number of chat sessions per source for every
+// day or hour in the range of f, timestamped at the start of each bucket in
+// the time zone of f.
+func SessionsHistory(c *statistics.Client, f *statistics.Filter) Collector {
+	return historyBySource(c, metrics.Sessions, f)
+}
+
+// MessagesHistory collects the number of user messages per source for every
+// day or hour in the range of f, timestamped at the start of each bucket in
+// the time zone of f.
+func MessagesHistory(c *statistics.Client, f *statistics.Filter) Collector {
+	return historyBySource(c, metrics.Messages, f)
+}
+
+// historyBySource collects the count of the counted metric m per source for
+// every bucket in the range of f as "kindly.<name>".
+func historyBySource(c *statistics.Client, m *metrics.Metric, f *statistics.Filter) Collector {
+	metric := pointName(m)
+	return func(ctx context.Context) ([]*Point, error) {
+		loc, err := f.Location()
+		if err != nil {
+			return nil, err
+		}
+
+		points := make([]*Point, 0)
+		for _, source := range f.Sources {
+			temp := *f
+			temp.Sources = []statistics.Source{source}
+			series, err := m.Counts(ctx, c, &temp)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, count := range series {
+				points = append(points, &Point{Metric: metric, Time: inLocation(count.Date.Time, loc), Value: float64(count.Count), Tags: tags(c.BotID, "source", source.String())})
+			}
+		}
+
+		return points, nil
+	}
+}
+
+// FallbackRateHistory collects the fallback rate for every day or hour in
+// the range of f.
+func FallbackRateHistory(c *statistics.Client, f *statistics.Filter) Collector {
+	return func(ctx context.Context) ([]*Point, error) {
+		loc, err := f.Location()
+		if err != nil {
+			return nil, err
+		}
+		series, err := c.FallbackRateTimeSeries(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+
+		points := make([]*Point, 0, len(series))
+		for _, rate := range series {
+			points = append(points, &Point{Metric: pointName(metrics.FallbackRate), Time: inLocation(rate.Date.Time, loc), Value: rate.Rate, Tags: tags(c.BotID)})
+		}
+
+		return points, nil
+	}
+}
+
+// HandoversHistory collects handover counts for every day or hour in the
+// range of f, using the same metric names as Handovers.
+func HandoversHistory(c *statistics.Client, f *statistics.Filter) Collector {
+	return func(ctx context.Context) ([]*Point, error) {
+		loc, err := f.Location()
+		if err != nil {
+			return nil, err
+		}
+		series, err := c.HandoversTimeSeries(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+
+		points := make([]*Point, 0, 4*len(series))
+		for _, h := range series {
+			t := inLocation(h.Date.Time, loc)
+			points = append(points,
+				&Point{Metric: "kindly.handovers.requests", Time: t, Value: float64(h.Requests), Tags: tags(c.BotID)},
+				&Point{Metric: "kindly.handovers.requests_while_closed", Time: t, Value: float64(h.RequestsWhileClosed), Tags: tags(c.BotID)},
+				&Point{Metric: "kindly.handovers.started", Time: t, Value: float64(h.Started), Tags: tags(c.BotID)},
+				&Point{Metric: "kindly.handovers.ended", Time: t, Value: float64(h.Ended), Tags: tags(c.BotID)},
+			)
+		}
+
+		return points, nil
+	}
+}
+
+// inLocation returns the wall clock time of t, a date of a series as reported
+// by Sage, in loc, the time zone of the filter of the series.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
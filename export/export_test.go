@@ -0,0 +1,44 @@
+package export_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/statistics"
+)
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return d(r)
+}
+
+func TestSessionsHistory_Timestamps(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"data":[{"date":"2021-03-01T00:00:00.000000","count":3}]}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	})))
+	f := &statistics.Filter{
+		From:        time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC),
+		Granularity: statistics.Day,
+		Sources:     []statistics.Source{statistics.Web},
+	}
+
+	points, err := export.SessionsHistory(c, f)(context.Background())
+	if err != nil {
+		t.Fatalf("SessionsHistory() err=%v", err)
+	}
+
+	// Sage reports the day in Europe/Oslo, where it starts at 23:00 UTC the
+	// day before.
+	want := time.Date(2021, 2, 28, 23, 0, 0, 0, time.UTC)
+	if len(points) != 1 || !points[0].Time.Equal(want) {
+		t.Fatalf("got points %v, want one at %s", points, want)
+	}
+}
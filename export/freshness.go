@@ -0,0 +1,112 @@
+package export
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// LastSuccessMetric is the metric of the time, in Unix seconds, each metric
+// was last collected, tagged with the collected metric as CollectedMetricTag.
+const LastSuccessMetric = "kindly.exporter.last_success_timestamp_seconds"
+
+// CollectedMetricTag is the tag of LastSuccessMetric points naming the
+// collected metric. It is not "metric", which sinks such as InfluxDB use for
+// the name of the point itself.
+const CollectedMetricTag = "collected_metric"
+
+// StaleMarker is implemented by sinks that can mark series as stale, such as
+// Prometheus remote write, so dashboards and alerts see missing data rather
+// than the last value.
+type StaleMarker interface {
+	// MarkStale marks the series of points, by metric and tags, as stale
+	// at the time of each point. The values of points are ignored.
+	MarkStale(ctx context.Context, points []*Point) error
+}
+
+// MarkStale implements StaleMarker by marking the series stale in every sink
+// that implements it, continuing past failures. The first error is
+// returned.
+func (m MultiSink) MarkStale(ctx context.Context, points []*Point) error {
+	var firstErr error
+	for _, sink := range m {
+		if s, ok := sink.(StaleMarker); ok {
+			if err := s.MarkStale(ctx, points); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// WithFreshness makes the poller write LastSuccessMetric for every metric
+// collected so far with each poll, and mark the series of a collector stale
+// after it failed staleAfter polls in a row, if the sink is a StaleMarker.
+// A staleAfter of 0 never marks series stale.
+func WithFreshness(staleAfter int) PollerOption {
+	return func(p *Poller) {
+		p.freshness = &freshness{
+			staleAfter:  staleAfter,
+			series:      make(map[int][]*Point),
+			failures:    make(map[int]int),
+			lastSuccess: make(map[string]*Point),
+		}
+	}
+}
+
+// freshness tracks when the metrics of the collectors of a poller were last
+// collected.
+type freshness struct {
+	staleAfter int
+	// series are the points of the last successful collection of each
+	// collector, by collector index.
+	series   map[int][]*Point
+	failures map[int]int
+	// lastSuccess are the LastSuccessMetric points by metric.
+	lastSuccess map[string]*Point
+}
+
+// succeeded records that collector i collected points at now.
+func (f *freshness) succeeded(i int, points []*Point, now time.Time) {
+	f.series[i] = points
+	f.failures[i] = 0
+
+	for _, p := range points {
+		t := map[string]string{CollectedMetricTag: p.Metric}
+		if bot, ok := p.Tags["bot"]; ok {
+			t["bot"] = bot
+		}
+		f.lastSuccess[p.Metric] = &Point{Metric: LastSuccessMetric, Time: now, Value: float64(now.Unix()), Tags: t}
+	}
+}
+
+// failed records that collector i failed at now, and returns the series to
+// mark stale, if it has now failed staleAfter times in a row.
+func (f *freshness) failed(i int, now time.Time) []*Point {
+	f.failures[i]++
+	if f.staleAfter <= 0 || f.failures[i] != f.staleAfter {
+		return nil
+	}
+
+	stale := make([]*Point, 0, len(f.series[i]))
+	for _, p := range f.series[i] {
+		stale = append(stale, &Point{Metric: p.Metric, Time: now, Tags: p.Tags})
+	}
+
+	return stale
+}
+
+// points returns the LastSuccessMetric points, timestamped at now, ordered
+// by metric.
+func (f *freshness) points(now time.Time) []*Point {
+	ret := make([]*Point, 0, len(f.lastSuccess))
+	for _, p := range f.lastSuccess {
+		ret = append(ret, &Point{Metric: p.Metric, Time: now, Value: p.Value, Tags: p.Tags})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Tags[CollectedMetricTag] < ret[j].Tags[CollectedMetricTag]
+	})
+
+	return ret
+}
@@ -0,0 +1,128 @@
+// Package influx writes collected Kindly statistics to an InfluxDB v2 bucket
+// in line protocol.
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atb-as/kindly/export"
+)
+
+// Measurement is the measurement all points are written to. The metric name
+// is a tag, so every Kindly KPI can be queried from one measurement.
+const Measurement = "kindly"
+
+// Sink implements export.Sink by writing points to the InfluxDB v2 write API.
+type Sink struct {
+	// BaseURL is the URL of the InfluxDB server, e.g.
+	// https://eu-central-1-1.aws.cloud2.influxdata.com.
+	BaseURL string
+	Org     string
+	Bucket  string
+	Token   string
+	doer    Doer
+}
+
+func NewSink(baseURL, org, bucket, token string, opts ...SinkOption) *Sink {
+	s := &Sink{BaseURL: baseURL, Org: org, Bucket: bucket, Token: token, doer: http.DefaultClient}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type SinkOption func(s *Sink)
+
+func WithDoer(doer Doer) SinkOption {
+	return func(s *Sink) {
+		s.doer = doer
+	}
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+// Write implements export.Sink. Timestamps are written with second precision.
+func (s *Sink) Write(ctx context.Context, points []*export.Point) error {
+	var body bytes.Buffer
+	if err := Encode(&body, points); err != nil {
+		return err
+	}
+
+	q := url.Values{"org": {s.Org}, "bucket": {s.Bucket}, "precision": {"s"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.BaseURL, "/")+"/api/v2/write?"+q.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Authorization", "Token "+s.Token)
+
+	resp, err := s.doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("influx: unexpected status %q: %s", resp.Status, msg)
+	}
+
+	return nil
+}
+
+// Encode writes points in line protocol, one line per point in the Measurement
+// measurement. The metric name and the tags of the point, such as bot and
+// source, become tags, and the value the "value" field.
+func Encode(w io.Writer, points []*export.Point) error {
+	for _, point := range points {
+		var line strings.Builder
+		line.WriteString(Measurement)
+		line.WriteString(",metric=")
+		line.WriteString(escape(point.Metric))
+
+		keys := make([]string, 0, len(point.Tags))
+		for k := range point.Tags {
+			if k != "metric" && point.Tags[k] != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			line.WriteString(",")
+			line.WriteString(escape(k))
+			line.WriteString("=")
+			line.WriteString(escape(point.Tags[k]))
+		}
+
+		line.WriteString(" value=")
+		line.WriteString(strconv.FormatFloat(point.Value, 'f', -1, 64))
+		line.WriteString(" ")
+		line.WriteString(strconv.FormatInt(point.Time.Unix(), 10))
+		line.WriteString("\n")
+
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+
+// escape escapes a tag key or value.
+func escape(s string) string {
+	return tagEscaper.Replace(s)
+}
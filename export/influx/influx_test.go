@@ -0,0 +1,93 @@
+package influx_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/export/influx"
+)
+
+func TestEncode(t *testing.T) {
+	ts := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err := influx.Encode(&buf, []*export.Point{
+		{Metric: "kindly.sessions", Time: ts, Value: 42, Tags: map[string]string{"source": "web", "bot": "1"}},
+		{Metric: "kindly.fallback_rate", Time: ts, Value: 0.125, Tags: map[string]string{"bot": "1", "source": "facebook messenger"}},
+	})
+	if err != nil {
+		t.Fatalf("Encode() err=%v", err)
+	}
+
+	want := "kindly,metric=kindly.sessions,bot=1,source=web value=42 1612180800\n" +
+		"kindly,metric=kindly.fallback_rate,bot=1,source=facebook\\ messenger value=0.125 1612180800\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncode_LastSuccess(t *testing.T) {
+	ts := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err := influx.Encode(&buf, []*export.Point{
+		{Metric: export.LastSuccessMetric, Time: ts, Value: 1612180800, Tags: map[string]string{export.CollectedMetricTag: "kindly.sessions", "bot": "1"}},
+		{Metric: export.LastSuccessMetric, Time: ts, Value: 1612180740, Tags: map[string]string{export.CollectedMetricTag: "kindly.messages", "bot": "1"}},
+	})
+	if err != nil {
+		t.Fatalf("Encode() err=%v", err)
+	}
+
+	// The points are of different series, so neither overwrites the other.
+	want := "kindly,metric=kindly.exporter.last_success_timestamp_seconds,bot=1,collected_metric=kindly.sessions value=1612180800 1612180800\n" +
+		"kindly,metric=kindly.exporter.last_success_timestamp_seconds,bot=1,collected_metric=kindly.messages value=1612180740 1612180800\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSink_Write(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" {
+			t.Errorf("got path %q, want %q", r.URL.Path, "/api/v2/write")
+		}
+		if q := r.URL.Query(); q.Get("org") != "ops" || q.Get("bucket") != "kpi" || q.Get("precision") != "s" {
+			t.Errorf("unexpected query: %v", q)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Token secret" {
+			t.Errorf("got Authorization %q, want %q", auth, "Token secret")
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		got = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink := influx.NewSink(srv.URL, "ops", "kpi", "secret")
+	err := sink.Write(context.Background(), []*export.Point{
+		{Metric: "kindly.sessions", Time: time.Unix(1612180800, 0), Value: 42, Tags: map[string]string{"bot": "1"}},
+	})
+	if err != nil {
+		t.Fatalf("Write() err=%v", err)
+	}
+	if want := "kindly,metric=kindly.sessions,bot=1 value=42 1612180800\n"; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestSink_WriteError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	sink := influx.NewSink(srv.URL, "ops", "kpi", "secret")
+	if err := sink.Write(context.Background(), []*export.Point{{Metric: "m"}}); err == nil {
+		t.Errorf("expected err, got nil")
+	}
+}
@@ -0,0 +1,120 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// Poller runs its collectors on a fixed interval and writes the collected
+// points to a sink.
+type Poller struct {
+	interval   time.Duration
+	collectors []Collector
+	sink       Sink
+	logger     Logger
+	progress   statistics.Progress
+	// freshness, if set, tracks when metrics were last collected.
+	freshness *freshness
+}
+
+func NewPoller(interval time.Duration, sink Sink, collectors []Collector, opts ...PollerOption) *Poller {
+	p := &Poller{interval: interval, sink: sink, collectors: collectors, logger: &nopLogger{}}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+type PollerOption func(p *Poller)
+
+func WithLogger(logger Logger) PollerOption {
+	return func(p *Poller) {
+		p.logger = logger
+	}
+}
+
+// WithProgress makes Poll report its progress to p, counting every collector
+// as a chunk and the points it collected as rows.
+func WithProgress(p statistics.Progress) PollerOption {
+	return func(pl *Poller) {
+		pl.progress = p
+	}
+}
+
+// Run polls immediately and then once every interval until ctx is done. A
+// failing collector or sink is logged and does not stop the poller.
+func (p *Poller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.Poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll runs every collector once and writes the collected points to the sink,
+// with the freshness of every metric if enabled by WithFreshness. Failures
+// are logged, and the first one is returned after the points from the
+// remaining collectors have been written.
+func (p *Poller) Poll(ctx context.Context) error {
+	var tracker *statistics.ProgressTracker
+	if p.progress != nil {
+		tracker = statistics.NewProgressTracker(len(p.collectors), p.progress)
+		ctx = statistics.WithProgress(ctx, tracker)
+	}
+
+	var firstErr error
+	now := time.Now()
+	points := make([]*Point, 0)
+	stale := make([]*Point, 0)
+	for i, collect := range p.collectors {
+		collected, err := collect(ctx)
+		if tracker != nil {
+			tracker.ChunkDone(len(collected))
+		}
+		if err != nil {
+			p.logger.Log("msg", "collect failed", "err", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if p.freshness != nil {
+				stale = append(stale, p.freshness.failed(i, now)...)
+			}
+			continue
+		}
+		if p.freshness != nil {
+			p.freshness.succeeded(i, collected, now)
+		}
+		points = append(points, collected...)
+	}
+	if p.freshness != nil {
+		points = append(points, p.freshness.points(now)...)
+	}
+
+	if m, ok := p.sink.(StaleMarker); ok && len(stale) > 0 {
+		p.logger.Log("msg", "marking series stale", "series", len(stale))
+		if err := m.MarkStale(ctx, stale); err != nil {
+			p.logger.Log("msg", "marking stale failed", "err", err)
+		}
+	}
+
+	if len(points) == 0 {
+		return firstErr
+	}
+
+	if err := p.sink.Write(ctx, points); err != nil {
+		p.logger.Log("msg", "write failed", "err", err)
+		return err
+	}
+
+	return firstErr
+}
@@ -0,0 +1,109 @@
+package export_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/statistics"
+)
+
+type sinkFunc func(ctx context.Context, points []*export.Point) error
+
+func (s sinkFunc) Write(ctx context.Context, points []*export.Point) error {
+	return s(ctx, points)
+}
+
+func TestPoller_Poll(t *testing.T) {
+	var got []*export.Point
+	sink := sinkFunc(func(ctx context.Context, points []*export.Point) error {
+		got = points
+		return nil
+	})
+
+	p := export.NewPoller(time.Minute, sink, []export.Collector{
+		func(ctx context.Context) ([]*export.Point, error) {
+			return nil, errors.New("upstream down")
+		},
+		func(ctx context.Context) ([]*export.Point, error) {
+			return []*export.Point{{Metric: "kindly.sessions", Value: 1}}, nil
+		},
+	})
+	p.Poll(context.Background())
+
+	if len(got) != 1 || got[0].Metric != "kindly.sessions" {
+		t.Errorf("expected points from healthy collector to be written, got %v", got)
+	}
+}
+
+func TestPoller_Progress(t *testing.T) {
+	var last statistics.ProgressStatus
+	p := export.NewPoller(time.Minute, sinkFunc(func(ctx context.Context, points []*export.Point) error {
+		return nil
+	}), []export.Collector{
+		func(ctx context.Context) ([]*export.Point, error) {
+			return nil, errors.New("upstream down")
+		},
+		func(ctx context.Context) ([]*export.Point, error) {
+			return []*export.Point{{Metric: "kindly.sessions"}, {Metric: "kindly.sessions"}}, nil
+		},
+	}, export.WithProgress(statistics.ProgressFunc(func(s statistics.ProgressStatus) {
+		last = s
+	})))
+	p.Poll(context.Background())
+
+	if last.Completed != 2 || last.Total != 2 || last.Rows != 2 {
+		t.Errorf("got progress %+v, want 2 of 2 chunks and 2 rows", last)
+	}
+}
+
+type staleSink struct {
+	written [][]*export.Point
+	stale   [][]*export.Point
+}
+
+func (s *staleSink) Write(ctx context.Context, points []*export.Point) error {
+	s.written = append(s.written, points)
+	return nil
+}
+
+func (s *staleSink) MarkStale(ctx context.Context, points []*export.Point) error {
+	s.stale = append(s.stale, points)
+	return nil
+}
+
+func TestPoller_Freshness(t *testing.T) {
+	fail := false
+	sink := &staleSink{}
+	p := export.NewPoller(time.Minute, export.MultiSink{sink}, []export.Collector{
+		func(ctx context.Context) ([]*export.Point, error) {
+			if fail {
+				return nil, errors.New("upstream down")
+			}
+			return []*export.Point{{Metric: "kindly.sessions", Value: 1, Tags: map[string]string{"bot": "1", "source": "web"}}}, nil
+		},
+	}, export.WithFreshness(2))
+
+	p.Poll(context.Background())
+	fail = true
+	p.Poll(context.Background())
+	if len(sink.stale) != 0 {
+		t.Fatalf("got series marked stale after 1 failure, want 2")
+	}
+	p.Poll(context.Background())
+	p.Poll(context.Background())
+
+	if len(sink.stale) != 1 || len(sink.stale[0]) != 1 || sink.stale[0][0].Metric != "kindly.sessions" || sink.stale[0][0].Tags["source"] != "web" {
+		t.Errorf("got stale %v, want kindly.sessions for web marked stale once", sink.stale)
+	}
+
+	last := sink.written[len(sink.written)-1]
+	if len(last) != 1 || last[0].Metric != export.LastSuccessMetric || last[0].Tags[export.CollectedMetricTag] != "kindly.sessions" {
+		t.Fatalf("got %v, want only the freshness of kindly.sessions while failing", last)
+	}
+	if first := sink.written[0]; len(first) != 2 || first[1].Value != last[0].Value {
+		t.Errorf("got last success %v, want the time of the first poll %v", last[0].Value, first[1].Value)
+	}
+}
@@ -0,0 +1,68 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// The remote write payload is a prometheus.WriteRequest protobuf message. It
+// is small enough to encode by hand, which avoids depending on the Prometheus
+// module for its generated types:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func encodeWriteRequest(series []*timeSeries) []byte {
+	var buf []byte
+	for _, ts := range series {
+		buf = appendBytes(buf, 1, encodeTimeSeries(ts))
+	}
+
+	return buf
+}
+
+func encodeTimeSeries(ts *timeSeries) []byte {
+	var buf []byte
+	for _, l := range ts.labels {
+		var lb []byte
+		lb = appendBytes(lb, 1, []byte(l.name))
+		lb = appendBytes(lb, 2, []byte(l.value))
+		buf = appendBytes(buf, 1, lb)
+	}
+	for _, s := range ts.samples {
+		var sb []byte
+		sb = appendTag(sb, 1, wireFixed64)
+		var fixed [8]byte
+		binary.LittleEndian.PutUint64(fixed[:], math.Float64bits(s.value))
+		sb = append(sb, fixed[:]...)
+		sb = appendTag(sb, 2, wireVarint)
+		sb = appendUvarint(sb, uint64(s.timestamp))
+		buf = appendBytes(buf, 2, sb)
+	}
+
+	return buf
+}
+
+func appendTag(buf []byte, field int, wireType int) []byte {
+	return appendUvarint(buf, uint64(field<<3|wireType))
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+func appendBytes(buf []byte, field int, b []byte) []byte {
+	buf = appendTag(buf, field, wireBytes)
+	buf = appendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
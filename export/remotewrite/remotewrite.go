@@ -0,0 +1,160 @@
+// Package remotewrite pushes collected Kindly statistics to Prometheus
+// compatible storage, such as Mimir or Cortex, using the remote write
+// protocol. Unlike scraping, this preserves the timestamps of historical
+// points, so daily series can be backfilled.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/golang/snappy"
+)
+
+// Sink implements export.Sink by sending points to a remote write endpoint.
+type Sink struct {
+	URL string
+	// Headers are added to every request, e.g. Authorization or
+	// X-Scope-OrgID for multi-tenant Mimir.
+	Headers http.Header
+	doer    Doer
+}
+
+func NewSink(url string, opts ...SinkOption) *Sink {
+	s := &Sink{URL: url, Headers: http.Header{}, doer: http.DefaultClient}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type SinkOption func(s *Sink)
+
+func WithDoer(doer Doer) SinkOption {
+	return func(s *Sink) {
+		s.doer = doer
+	}
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+// staleNaN is the value Prometheus uses to mark a series stale, so queries
+// stop returning its last value.
+var staleNaN = math.Float64frombits(0x7ff0000000000002)
+
+// Write implements export.Sink.
+func (s *Sink) Write(ctx context.Context, points []*export.Point) error {
+	return s.send(ctx, toTimeSeries(points))
+}
+
+// MarkStale implements export.StaleMarker by writing a staleness marker for
+// the series of every point.
+func (s *Sink) MarkStale(ctx context.Context, points []*export.Point) error {
+	series := toTimeSeries(points)
+	for _, ts := range series {
+		for i := range ts.samples {
+			ts.samples[i].value = staleNaN
+		}
+	}
+
+	return s.send(ctx, series)
+}
+
+func (s *Sink) send(ctx context.Context, series []*timeSeries) error {
+	body := snappy.Encode(nil, encodeWriteRequest(series))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := s.doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("remotewrite: unexpected status %q: %s", resp.Status, msg)
+	}
+
+	return nil
+}
+
+type label struct {
+	name, value string
+}
+
+type sample struct {
+	value     float64
+	timestamp int64
+}
+
+type timeSeries struct {
+	labels  []label
+	samples []sample
+}
+
+// toTimeSeries groups points into series by metric name and tags. Samples are
+// sorted by time, as required by the protocol.
+func toTimeSeries(points []*export.Point) []*timeSeries {
+	series := make(map[string]*timeSeries)
+	keys := make([]string, 0)
+	for _, p := range points {
+		labels := []label{{"__name__", metricName(p.Metric)}}
+		for k, v := range p.Tags {
+			labels = append(labels, label{k, v})
+		}
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].name < labels[j].name
+		})
+
+		var key strings.Builder
+		for _, l := range labels {
+			key.WriteString(l.name + "=" + l.value + ",")
+		}
+
+		ts, ok := series[key.String()]
+		if !ok {
+			ts = &timeSeries{labels: labels}
+			series[key.String()] = ts
+			keys = append(keys, key.String())
+		}
+		ts.samples = append(ts.samples, sample{value: p.Value, timestamp: p.Time.UnixNano() / 1e6})
+	}
+
+	ret := make([]*timeSeries, 0, len(keys))
+	for _, key := range keys {
+		ts := series[key]
+		sort.Slice(ts.samples, func(i, j int) bool {
+			return ts.samples[i].timestamp < ts.samples[j].timestamp
+		})
+		ret = append(ret, ts)
+	}
+
+	return ret
+}
+
+// metricName converts the dotted export metric names to valid Prometheus
+// metric names.
+func metricName(metric string) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(metric)
+}
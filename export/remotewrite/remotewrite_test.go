@@ -0,0 +1,96 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/golang/snappy"
+)
+
+func TestSink_Write(t *testing.T) {
+	day := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	points := []*export.Point{
+		{Metric: "kindly.sessions", Time: day.Add(24 * time.Hour), Value: 2, Tags: map[string]string{"bot": "1", "source": "web"}},
+		{Metric: "kindly.sessions", Time: day, Value: 1, Tags: map[string]string{"source": "web", "bot": "1"}},
+	}
+
+	var got []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" {
+			t.Errorf("expected snappy encoding")
+		}
+		if r.Header.Get("X-Scope-OrgID") != "kindly" {
+			t.Errorf("expected configured headers to be sent")
+		}
+		body, _ := io.ReadAll(r.Body)
+		var err error
+		if got, err = snappy.Decode(nil, body); err != nil {
+			t.Errorf("snappy.Decode() err=%v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink := NewSink(srv.URL)
+	sink.Headers.Set("X-Scope-OrgID", "kindly")
+	if err := sink.Write(context.Background(), points); err != nil {
+		t.Fatalf("Write() err=%v", err)
+	}
+
+	want := encodeWriteRequest([]*timeSeries{{
+		labels: []label{{"__name__", "kindly_sessions"}, {"bot", "1"}, {"source", "web"}},
+		samples: []sample{
+			{value: 1, timestamp: day.UnixNano() / 1e6},
+			{value: 2, timestamp: day.Add(24*time.Hour).UnixNano() / 1e6},
+		},
+	}})
+	if string(got) != string(want) {
+		t.Errorf("got payload %x, want %x", got, want)
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	// Reference encoding of a single series with label a="b" and one sample
+	// of 1.0 at timestamp 1, as produced by the generated protobuf types.
+	want := []byte{
+		0x0a, 0x15,
+		0x0a, 0x06, 0x0a, 0x01, 'a', 0x12, 0x01, 'b',
+		0x12, 0x0b, 0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0x10, 0x01,
+	}
+	got := encodeWriteRequest([]*timeSeries{{labels: []label{{"a", "b"}}, samples: []sample{{value: 1, timestamp: 1}}}})
+	if string(got) != string(want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
+
+func TestSink_MarkStale(t *testing.T) {
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	var got []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got, _ = snappy.Decode(nil, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	err := NewSink(srv.URL).MarkStale(context.Background(), []*export.Point{
+		{Metric: "kindly.fallback_rate", Time: now, Value: 0.2, Tags: map[string]string{"bot": "1"}},
+	})
+	if err != nil {
+		t.Fatalf("MarkStale() err=%v", err)
+	}
+
+	want := encodeWriteRequest([]*timeSeries{{
+		labels:  []label{{"__name__", "kindly_fallback_rate"}, {"bot", "1"}},
+		samples: []sample{{value: staleNaN, timestamp: now.UnixNano() / 1e6}},
+	}})
+	if string(got) != string(want) {
+		t.Errorf("got payload %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+// Package sheets appends collected Kindly statistics to a Google Sheets
+// spreadsheet.
+package sheets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"golang.org/x/oauth2/google"
+)
+
+const BaseURL = "https://sheets.googleapis.com/v4"
+
+// Sink implements export.Sink by appending a row per point, with the
+// columns time, metric, value and tags, after the table in Range of the
+// spreadsheet.
+type Sink struct {
+	BaseURL       string
+	SpreadsheetID string
+	// Range is the A1 notation of the table to append to, such as
+	// "Kindly!A:D" or just the sheet name.
+	Range string
+	doer  Doer
+}
+
+// NewSink returns a sink appending to the range of the spreadsheet with the
+// ID spreadsheetID using doer, which is responsible for authenticating
+// requests, such as an *http.Client from google.DefaultClient.
+func NewSink(spreadsheetID, rng string, doer Doer) *Sink {
+	return &Sink{BaseURL: BaseURL, SpreadsheetID: spreadsheetID, Range: rng, doer: doer}
+}
+
+// NewDefaultSink returns a sink appending to the range of the spreadsheet,
+// authenticated with Application Default Credentials.
+func NewDefaultSink(ctx context.Context, spreadsheetID, rng string) (*Sink, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/spreadsheets")
+	if err != nil {
+		return nil, fmt.Errorf("sheets: %w", err)
+	}
+
+	return NewSink(spreadsheetID, rng, client), nil
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+// Write implements export.Sink. Times are written in RFC 3339 and tags as
+// comma-separated key=value pairs.
+func (s *Sink) Write(ctx context.Context, points []*export.Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	values := make([][]interface{}, 0, len(points))
+	for _, point := range points {
+		values = append(values, []interface{}{point.Time.Format(time.RFC3339), point.Metric, point.Value, formatTags(point.Tags)})
+	}
+	body, err := json.Marshal(map[string]interface{}{"values": values})
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	q.Set("valueInputOption", "RAW")
+	q.Set("insertDataOption", "INSERT_ROWS")
+	u := fmt.Sprintf("%s/spreadsheets/%s/values/%s:append?%s", s.BaseURL, url.PathEscape(s.SpreadsheetID), url.PathEscape(s.Range), q.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("sheets: unexpected status %q: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+func formatTags(tags map[string]string) string {
+	ret := make([]string, 0, len(tags))
+	for k, v := range tags {
+		ret = append(ret, k+"="+v)
+	}
+	sort.Strings(ret)
+
+	return strings.Join(ret, ",")
+}
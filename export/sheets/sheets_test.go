@@ -0,0 +1,65 @@
+package sheets_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/export/sheets"
+)
+
+func TestSink_Write(t *testing.T) {
+	var got struct {
+		Values [][]interface{}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/spreadsheets/sheet-id/values/Kindly!A:D:append"; r.URL.Path != want {
+			t.Errorf("got path %q, want %q", r.URL.Path, want)
+		}
+		if got := r.URL.Query().Get("valueInputOption"); got != "RAW" {
+			t.Errorf("got valueInputOption %q, want RAW", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: err=%v", err)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	sink := sheets.NewSink("sheet-id", "Kindly!A:D", http.DefaultClient)
+	sink.BaseURL = srv.URL
+
+	ts := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	err := sink.Write(context.Background(), []*export.Point{
+		{Metric: "kindly.sessions", Time: ts, Value: 42, Tags: map[string]string{"source": "web", "bot": "1"}},
+	})
+	if err != nil {
+		t.Fatalf("Write() err=%v", err)
+	}
+
+	if len(got.Values) != 1 {
+		t.Fatalf("got %d rows, want 1", len(got.Values))
+	}
+	row := got.Values[0]
+	if len(row) != 4 || row[0] != "2021-02-01T00:00:00Z" || row[1] != "kindly.sessions" || row[2] != 42.0 || row[3] != "bot=1,source=web" {
+		t.Errorf("unexpected row: %v", row)
+	}
+}
+
+func TestSink_WriteError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	sink := sheets.NewSink("sheet-id", "Kindly", http.DefaultClient)
+	sink.BaseURL = srv.URL
+
+	if err := sink.Write(context.Background(), []*export.Point{{Metric: "m"}}); err == nil {
+		t.Errorf("expected err, got nil")
+	}
+}
@@ -0,0 +1,144 @@
+// Package slack posts a digest of collected Kindly statistics to a Slack
+// channel.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atb-as/kindly/export"
+)
+
+// Sink implements export.Sink by posting the points as a single message to
+// a Slack incoming webhook.
+type Sink struct {
+	WebhookURL string
+	// Title is the first line of the message.
+	Title string
+	doer  Doer
+}
+
+func NewSink(webhookURL string, opts ...SinkOption) *Sink {
+	s := &Sink{WebhookURL: webhookURL, Title: "Kindly statistics", doer: http.DefaultClient}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type SinkOption func(s *Sink)
+
+func WithDoer(doer Doer) SinkOption {
+	return func(s *Sink) {
+		s.doer = doer
+	}
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+// Write implements export.Sink. The message has the title and the date of
+// the earliest point, followed by a line per metric and tags, with the sum
+// of their values; metrics that are rates, such as the fallback rate, are
+// averaged instead.
+func (s *Sink) Write(ctx context.Context, points []*export.Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{"text": digest(s.Title, points)})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("slack: unexpected status %q: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+type line struct {
+	label string
+	sum   float64
+	n     int
+	rate  bool
+}
+
+func digest(title string, points []*export.Point) string {
+	first := points[0].Time
+	lines := make(map[string]*line)
+	for _, p := range points {
+		if p.Time.Before(first) {
+			first = p.Time
+		}
+
+		label := "`" + p.Metric + "`"
+		if tags := formatTags(p.Tags); tags != "" {
+			label += " " + tags
+		}
+		l, ok := lines[label]
+		if !ok {
+			l = &line{label: label, rate: strings.HasSuffix(p.Metric, "_rate")}
+			lines[label] = l
+		}
+		l.sum += p.Value
+		l.n++
+	}
+
+	labels := make([]string, 0, len(lines))
+	for label := range lines {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s %s*\n", title, first.Format("2006-01-02"))
+	for _, label := range labels {
+		l := lines[label]
+		v := strconv.FormatFloat(l.sum, 'f', -1, 64)
+		if l.rate {
+			v = strconv.FormatFloat(l.sum/float64(l.n), 'f', 2, 64)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", l.label, v)
+	}
+
+	return b.String()
+}
+
+// formatTags formats tags other than the bot, which all points share.
+func formatTags(tags map[string]string) string {
+	ret := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if k == "bot" {
+			continue
+		}
+		ret = append(ret, k+"="+v)
+	}
+	sort.Strings(ret)
+
+	return strings.Join(ret, " ")
+}
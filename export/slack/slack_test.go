@@ -0,0 +1,59 @@
+package slack_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/export"
+	"github.com/atb-as/kindly/export/slack"
+)
+
+func TestSink_Write(t *testing.T) {
+	var got struct {
+		Text string
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: err=%v", err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	sink := slack.NewSink(srv.URL)
+
+	day := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	err := sink.Write(context.Background(), []*export.Point{
+		{Metric: "kindly.sessions", Time: day, Value: 42, Tags: map[string]string{"source": "web", "bot": "1"}},
+		{Metric: "kindly.sessions", Time: day, Value: 7, Tags: map[string]string{"source": "facebook", "bot": "1"}},
+		{Metric: "kindly.fallback_rate", Time: day, Value: 12.5, Tags: map[string]string{"bot": "1"}},
+		{Metric: "kindly.handovers.started", Time: day, Value: 3, Tags: map[string]string{"bot": "1"}},
+	})
+	if err != nil {
+		t.Fatalf("Write() err=%v", err)
+	}
+
+	want := "*Kindly statistics 2021-02-01*\n" +
+		"`kindly.fallback_rate`: 12.50\n" +
+		"`kindly.handovers.started`: 3\n" +
+		"`kindly.sessions` source=facebook: 7\n" +
+		"`kindly.sessions` source=web: 42\n"
+	if got.Text != want {
+		t.Errorf("got text\n%s\nwant\n%s", got.Text, want)
+	}
+}
+
+func TestSink_WriteError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	if err := slack.NewSink(srv.URL).Write(context.Background(), []*export.Point{{Metric: "m"}}); err == nil {
+		t.Errorf("expected err, got nil")
+	}
+}
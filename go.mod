@@ -3,7 +3,14 @@ module github.com/atb-as/kindly
 go 1.15
 
 require (
+	github.com/coreos/go-oidc/v3 v3.4.0
 	github.com/go-kit/kit v0.10.0
+	github.com/golang/snappy v0.0.4
 	github.com/gorilla/mux v1.8.0
-	golang.org/x/oauth2 v0.0.0-20210313182246-cd4f82c27b84
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.10
+	github.com/xitongsys/parquet-go v1.6.2
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	golang.org/x/oauth2 v0.0.0-20220822191816-0ebed06d0094
+	gopkg.in/yaml.v2 v2.4.0
 )
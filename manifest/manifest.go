@@ -0,0 +1,157 @@
+// Package manifest describes the files written by an export run, for the
+// provenance of statistics used in official reporting: which tool and build
+// wrote them, with which parameters, and their row counts and SHA-256
+// checksums.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/statistics"
+)
+
+// Manifest describes the files written by a run of a tool.
+type Manifest struct {
+	// Tool names the tool and command that wrote the files, e.g.
+	// "kindly funnel".
+	Tool  string           `json:"tool"`
+	Build kindly.BuildInfo `json:"build"`
+	// Created is when the run finished writing the files.
+	Created time.Time `json:"created"`
+	// Parameters are the filter and flags the files were produced with.
+	Parameters map[string]string `json:"parameters"`
+	Files      []*File           `json:"files"`
+}
+
+// File is a file written by a run.
+type File struct {
+	Name string `json:"name"`
+	// Rows is the number of rows below the header, or of records in
+	// formats without one.
+	Rows   int    `json:"rows"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// New returns an empty manifest of a run of tool with params, of the running
+// build.
+func New(tool string, params map[string]string) *Manifest {
+	if params == nil {
+		params = make(map[string]string)
+	}
+
+	return &Manifest{Tool: tool, Build: kindly.Build(), Parameters: params, Files: make([]*File, 0)}
+}
+
+// FilterParameters returns the parameters of f as manifest parameters. Zero
+// fields are left out.
+func FilterParameters(f *statistics.Filter) map[string]string {
+	params := make(map[string]string)
+	if f == nil {
+		return params
+	}
+
+	if !f.From.IsZero() {
+		params["from"] = f.From.Format("2006-01-02")
+	}
+	if !f.To.IsZero() {
+		params["to"] = f.To.Format("2006-01-02")
+	}
+	if loc, err := f.Location(); err == nil {
+		params["timezone"] = loc.String()
+	}
+	if f.Granularity != statistics.Unspecified {
+		params["granularity"] = f.Granularity.String()
+	}
+	if f.Limit != 0 {
+		params["limit"] = strconv.Itoa(f.Limit)
+	}
+	if len(f.Sources) > 0 {
+		params["sources"] = strings.Join(statistics.SourceStrings(f.Sources), ",")
+	}
+	if len(f.LanguageCodes) > 0 {
+		params["language_codes"] = strings.Join(f.LanguageCodes, ",")
+	}
+
+	return params
+}
+
+// Add records the file name with the contents b and rows rows.
+func (m *Manifest) Add(name string, b []byte, rows int) {
+	sum := sha256.Sum256(b)
+	m.Files = append(m.Files, &File{Name: name, Rows: rows, Bytes: int64(len(b)), SHA256: hex.EncodeToString(sum[:])})
+}
+
+// NewWriter returns a writer that writes to w and records what is written as
+// the file name when closed.
+func (m *Manifest) NewWriter(name string, w io.Writer) *Writer {
+	return &Writer{m: m, name: name, w: w, h: sha256.New()}
+}
+
+// Writer checksums a file as it is written. See Manifest.NewWriter.
+type Writer struct {
+	m    *Manifest
+	name string
+	w    io.Writer
+	h    hash.Hash
+	n    int64
+	// Rows is the number of rows recorded for the file; writers of
+	// tables set it before Close.
+	Rows int
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.h.Write(p[:n])
+	w.n += int64(n)
+
+	return n, err
+}
+
+// Close records the file in the manifest. It does not close the underlying
+// writer.
+func (w *Writer) Close() error {
+	w.m.Files = append(w.m.Files, &File{Name: w.name, Rows: w.Rows, Bytes: w.n, SHA256: hex.EncodeToString(w.h.Sum(nil))})
+
+	return nil
+}
+
+// Marshal returns the manifest as indented JSON, with Created set to now
+// unless already set and the files ordered by name.
+func (m *Manifest) Marshal() ([]byte, error) {
+	if m.Created.IsZero() {
+		m.Created = time.Now().UTC()
+	}
+	sort.SliceStable(m.Files, func(i, j int) bool {
+		return m.Files[i].Name < m.Files[j].Name
+	})
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// WriteFile writes the manifest to path. It is written last, so its
+// presence signals a complete run.
+func (m *Manifest) WriteFile(path string) error {
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0o644)
+}
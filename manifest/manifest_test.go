@@ -0,0 +1,53 @@
+package manifest_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/manifest"
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestManifest(t *testing.T) {
+	day := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	m := manifest.New("kindly funnel", manifest.FilterParameters(&statistics.Filter{
+		From:        day,
+		To:          day.AddDate(0, 0, 7),
+		Granularity: statistics.Week,
+		Sources:     []statistics.Source{statistics.Web, statistics.App},
+	}))
+
+	var buf bytes.Buffer
+	w := m.NewWriter("sessions.csv", &buf)
+	w.Write([]byte("date,count\n2021-02-01,3\n"))
+	w.Rows = 1
+	w.Close()
+	m.Add("labels.csv", []byte("date,count\n"), 0)
+
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() err=%v", err)
+	}
+	var got manifest.Manifest
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal() err=%v", err)
+	}
+
+	if got.Tool != "kindly funnel" || got.Build.Version == "" || got.Created.IsZero() {
+		t.Errorf("got tool %q, build %+v, created %s, want all set", got.Tool, got.Build, got.Created)
+	}
+	if p := got.Parameters; p["from"] != "2021-02-01" || p["to"] != "2021-02-08" || p["granularity"] != "week" || p["sources"] != "web,app" || p["timezone"] != statistics.DefaultTimezone {
+		t.Errorf("got parameters %v", p)
+	}
+	if len(got.Files) != 2 || got.Files[0].Name != "labels.csv" {
+		t.Fatalf("got files %+v, want labels.csv and sessions.csv by name", got.Files)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	if f := got.Files[1]; f.Rows != 1 || f.Bytes != int64(buf.Len()) || f.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("got %+v, want 1 row, %d bytes and the checksum of what was written", f, buf.Len())
+	}
+}
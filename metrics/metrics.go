@@ -0,0 +1,362 @@
+// Package metrics is the registry of the metrics of the Kindly Statistics
+// API shared by the tools: their names, the columns they are written with,
+// the granularities Sage serves them by and how to fetch them, so every tool
+// names and shapes a metric the same way.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// ErrNotCounted is returned by Metric.Counts for metrics that are not a
+// count per period, such as feedback.
+var ErrNotCounted = errors.New("metrics: not a count per period")
+
+// ColumnType is the type of the values of a column.
+type ColumnType string
+
+const (
+	Date    ColumnType = "date"
+	Integer ColumnType = "integer"
+	Number  ColumnType = "number"
+	String  ColumnType = "string"
+)
+
+// Column describes a column of the rows of a metric.
+type Column struct {
+	Name string     `json:"name"`
+	Type ColumnType `json:"type"`
+}
+
+// Metric is a metric of the registry.
+type Metric struct {
+	// Name is the name of the metric in every tool, such as "sessions".
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Endpoint is the endpoint of Sage the metric is fetched from.
+	Endpoint string `json:"endpoint"`
+	// Columns are the columns of the rows returned by Fetch. Metrics with
+	// granularities start with a date column.
+	Columns []Column `json:"columns"`
+	// Granularities are the granularities Sage serves the metric by. Metrics
+	// without them are totals of the period.
+	Granularities []statistics.Granularity `json:"granularities,omitempty"`
+
+	fetch  func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([][]string, error)
+	counts func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([]*statistics.CountByDate, error)
+}
+
+// Fetch returns the rows of the metric for f, with the columns in Columns.
+func (m *Metric) Fetch(ctx context.Context, s statistics.Service, f *statistics.Filter) ([][]string, error) {
+	return m.fetch(ctx, s, f)
+}
+
+// Counted reports whether the metric is a count per period, see Counts.
+func (m *Metric) Counted() bool {
+	return m.counts != nil
+}
+
+// Counts returns the count of the metric per period of f, such as the
+// sessions of each day, or ErrNotCounted. Series of several sources may
+// repeat dates.
+func (m *Metric) Counts(ctx context.Context, s statistics.Service, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+	if m.counts == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotCounted, m.Name)
+	}
+
+	return m.counts(ctx, s, f)
+}
+
+// Supports reports whether Sage serves the metric by g.
+func (m *Metric) Supports(g statistics.Granularity) bool {
+	for _, supported := range m.Granularities {
+		if supported == g {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Header returns the names of Columns.
+func (m *Metric) Header() []string {
+	ret := make([]string, 0, len(m.Columns))
+	for _, c := range m.Columns {
+		ret = append(ret, c.Name)
+	}
+
+	return ret
+}
+
+var (
+	registry = make(map[string]*Metric)
+	// aliases are the names some tools used before the registry.
+	aliases = map[string]string{
+		"chats":     "sessions",
+		"fallbacks": "fallback_rate",
+	}
+)
+
+func register(m *Metric) *Metric {
+	if m.fetch == nil {
+		m.fetch = countRows(m.counts)
+	}
+	m.Granularities = statistics.EndpointGranularities(m.Endpoint)
+	registry[m.Name] = m
+
+	return m
+}
+
+// Lookup returns the metric named name, or known by one of the names the
+// tools used before: "chats" for sessions and "fallbacks" for fallback_rate.
+func Lookup(name string) (*Metric, error) {
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	if m, ok := registry[name]; ok {
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("metrics: unknown metric %q, want %s", name, strings.Join(Names(), ", "))
+}
+
+// MustLookup is like Lookup but panics if there is no metric named name. It
+// is for names known at compile time.
+func MustLookup(name string) *Metric {
+	m, err := Lookup(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// All returns the metrics, sorted by name.
+func All() []*Metric {
+	ret := make([]*Metric, 0, len(registry))
+	for _, m := range registry {
+		ret = append(ret, m)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+
+	return ret
+}
+
+// Names returns the names of the metrics, sorted.
+func Names() []string {
+	ret := make([]string, 0, len(registry))
+	for _, m := range All() {
+		ret = append(ret, m.Name)
+	}
+
+	return ret
+}
+
+// Counted returns the names of the metrics that are counts per period.
+func Counted() []string {
+	var ret []string
+	for _, m := range All() {
+		if m.Counted() {
+			ret = append(ret, m.Name)
+		}
+	}
+
+	return ret
+}
+
+// ColumnTypeOf returns the type of the column name in the metrics that have
+// it, or false if none has it.
+func ColumnTypeOf(name string) (ColumnType, bool) {
+	for _, m := range All() {
+		for _, c := range m.Columns {
+			if c.Name == name {
+				return c.Type, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// FormatDate formats the date of a row of a metric by g.
+func FormatDate(t time.Time, g statistics.Granularity) string {
+	if g == statistics.Hour {
+		return t.Format("2006-01-02 15:04")
+	}
+
+	return t.Format("2006-01-02")
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// The metrics.
+var (
+	Sessions = register(&Metric{
+		Name:        "sessions",
+		Title:       "Chat sessions",
+		Description: "Chat sessions per period.",
+		Endpoint:    "sessions/chats",
+		Columns:     []Column{{"date", Date}, {"count", Integer}},
+		counts: func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+			return s.ChatSessions(ctx, f)
+		},
+	})
+	Messages = register(&Metric{
+		Name:        "messages",
+		Title:       "User messages",
+		Description: "Messages from users per period.",
+		Endpoint:    "sessions/messages",
+		Columns:     []Column{{"date", Date}, {"count", Integer}},
+		counts: func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+			return s.UserMessages(ctx, f)
+		},
+	})
+	FallbackRate = register(&Metric{
+		Name:        "fallback_rate",
+		Title:       "Fallback rate",
+		Description: "Messages the bot had no answer to per period, and their share of all messages.",
+		Endpoint:    "fallbacks/series",
+		Columns:     []Column{{"date", Date}, {"count", Integer}, {"rate", Number}},
+		fetch: func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([][]string, error) {
+			series, err := s.FallbackRateTimeSeries(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			rows := make([][]string, 0, len(series))
+			for _, r := range series {
+				rows = append(rows, []string{FormatDate(r.Date.Time, f.Granularity), strconv.Itoa(r.Count), formatFloat(r.Rate)})
+			}
+			return rows, nil
+		},
+		counts: func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+			series, err := s.FallbackRateTimeSeries(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			ret := make([]*statistics.CountByDate, 0, len(series))
+			for _, r := range series {
+				c := r.CountByDate
+				ret = append(ret, &c)
+			}
+			return ret, nil
+		},
+	})
+	Handovers = register(&Metric{
+		Name:        "handovers",
+		Title:       "Handovers",
+		Description: "Handover requests, requests while closed, and started and ended handovers per period. Counts are of requests.",
+		Endpoint:    "takeovers/series",
+		Columns:     []Column{{"date", Date}, {"requests", Integer}, {"requests_while_closed", Integer}, {"started", Integer}, {"ended", Integer}},
+		fetch: func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([][]string, error) {
+			series, err := s.HandoversTimeSeries(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			rows := make([][]string, 0, len(series))
+			for _, h := range series {
+				rows = append(rows, []string{
+					FormatDate(h.Date.Time, f.Granularity),
+					strconv.Itoa(h.Requests),
+					strconv.Itoa(h.RequestsWhileClosed),
+					strconv.Itoa(h.Started),
+					strconv.Itoa(h.Ended),
+				})
+			}
+			return rows, nil
+		},
+		counts: func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([]*statistics.CountByDate, error) {
+			series, err := s.HandoversTimeSeries(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			ret := make([]*statistics.CountByDate, 0, len(series))
+			for _, h := range series {
+				ret = append(ret, &statistics.CountByDate{Date: h.Date, Count: h.Requests})
+			}
+			return ret, nil
+		},
+	})
+	Labels = register(&Metric{
+		Name:        "labels",
+		Title:       "Labels",
+		Description: "Chat labels added in the period, with how often.",
+		Endpoint:    "chatlabels/added",
+		Columns:     []Column{{"id", String}, {"count", Integer}, {"text", String}},
+		fetch: func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([][]string, error) {
+			labels, err := s.ChatLabels(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			rows := make([][]string, 0, len(labels))
+			for _, l := range labels {
+				rows = append(rows, []string{l.ID, strconv.Itoa(l.Count), l.Text})
+			}
+			return rows, nil
+		},
+	})
+	Pages = register(&Metric{
+		Name:        "pages",
+		Title:       "Web pages",
+		Description: "Sessions and messages in the period per web page the chat bubble was on.",
+		Endpoint:    "chatbubble/pages",
+		Columns:     []Column{{"host", String}, {"path", String}, {"sessions", Integer}, {"messages", Integer}},
+		fetch: func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([][]string, error) {
+			pages, err := s.PageStatistics(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			rows := make([][]string, 0, len(pages))
+			for _, p := range pages {
+				rows = append(rows, []string{p.Host, p.Path, strconv.Itoa(p.Sessions), strconv.Itoa(p.Messages)})
+			}
+			return rows, nil
+		},
+	})
+	Feedback = register(&Metric{
+		Name:        "feedback",
+		Title:       "Feedback",
+		Description: "Binary and emoji feedback ratings in the period, with their count and share of the ratings of their type.",
+		Endpoint:    "feedback/summary",
+		Columns:     []Column{{"type", String}, {"rating", Integer}, {"count", Integer}, {"ratio", Number}},
+		fetch: func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([][]string, error) {
+			fb, err := s.AggregatedFeedback(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			rows := make([][]string, 0, len(fb.Binary)+len(fb.Emojis))
+			for _, r := range fb.Binary {
+				rows = append(rows, []string{"binary", strconv.Itoa(r.Rating), strconv.Itoa(r.Count), formatFloat(r.Ratio)})
+			}
+			for _, r := range fb.Emojis {
+				rows = append(rows, []string{"emoji", strconv.Itoa(r.Rating), strconv.Itoa(r.Count), formatFloat(r.Ratio)})
+			}
+			return rows, nil
+		},
+	})
+)
+
+// countRows returns the rows of a count per period fetched with counts.
+func countRows(counts func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([]*statistics.CountByDate, error)) func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([][]string, error) {
+	return func(ctx context.Context, s statistics.Service, f *statistics.Filter) ([][]string, error) {
+		series, err := counts(ctx, s, f)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([][]string, 0, len(series))
+		for _, c := range series {
+			rows = append(rows, []string{FormatDate(c.Date.Time, f.Granularity), strconv.Itoa(c.Count)})
+		}
+		return rows, nil
+	}
+}
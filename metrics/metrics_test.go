@@ -0,0 +1,78 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/metrics"
+	"github.com/atb-as/kindly/statistics"
+)
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return d(r)
+}
+
+func TestLookup(t *testing.T) {
+	for name, want := range map[string]string{"sessions": "sessions", "chats": "sessions", "fallbacks": "fallback_rate", "feedback": "feedback"} {
+		m, err := metrics.Lookup(name)
+		if err != nil || m.Name != want {
+			t.Errorf("Lookup(%q) = %v, %v, want %s", name, m, err, want)
+		}
+	}
+	if _, err := metrics.Lookup("visits"); err == nil || !strings.Contains(err.Error(), "fallback_rate, feedback, handovers, labels, messages, pages, sessions") {
+		t.Errorf("got err=%v, want the known metrics", err)
+	}
+
+	if !metrics.Sessions.Supports(statistics.Hour) || metrics.FallbackRate.Supports(statistics.Hour) || len(metrics.Feedback.Granularities) != 0 {
+		t.Errorf("got granularities %v, %v and %v, want those of the endpoints", metrics.Sessions.Granularities, metrics.FallbackRate.Granularities, metrics.Feedback.Granularities)
+	}
+	if got, want := metrics.Counted(), []string{"fallback_rate", "handovers", "messages", "sessions"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got counted %v, want %v", got, want)
+	}
+}
+
+func TestMetric_Fetch(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		var body string
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/takeovers/series"):
+			body = `{"data":[{"date":"2021-02-01T00:00:00.000000","requests":4,"requests_while_closed":1,"started":3,"ended":2}]}`
+		case strings.HasSuffix(r.URL.Path, "/feedback/summary"):
+			body = `{"data":{"binary":[{"rating":1,"count":3,"ratio":0.75}],"emojis":[]}}`
+		default:
+			t.Errorf("unexpected path %q", r.URL.Path)
+			body = `{"data":[]}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	ctx := context.Background()
+	f := &statistics.Filter{From: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2021, 2, 2, 0, 0, 0, 0, time.UTC)}
+
+	rows, err := metrics.Handovers.Fetch(ctx, c, f)
+	if want := [][]string{{"2021-02-01", "4", "1", "3", "2"}}; err != nil || !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, %v, want %v", rows, err, want)
+	}
+	if len(metrics.Handovers.Header()) != len(rows[0]) {
+		t.Errorf("got header %v, want a column per cell", metrics.Handovers.Header())
+	}
+	counts, err := metrics.Handovers.Counts(ctx, c, f)
+	if err != nil || len(counts) != 1 || counts[0].Count != 4 {
+		t.Errorf("got counts %v, %v, want the 4 requests", counts, err)
+	}
+
+	rows, err = metrics.Feedback.Fetch(ctx, c, f)
+	if want := [][]string{{"binary", "1", "3", "0.75"}}; err != nil || !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, %v, want %v", rows, err, want)
+	}
+	if _, err := metrics.Feedback.Counts(ctx, c, f); !errors.Is(err, metrics.ErrNotCounted) {
+		t.Errorf("got err=%v, want ErrNotCounted", err)
+	}
+}
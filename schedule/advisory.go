@@ -0,0 +1,101 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+// AdvisoryLocker claims runs in a PostgreSQL database shared by all replicas.
+// A claim takes a transaction-level advisory lock on the run, so concurrent
+// claims of the same run wait for each other, and records the run in the
+// schedule_runs table, so a run is never repeated once claimed.
+type AdvisoryLocker struct {
+	db *sql.DB
+}
+
+// NewAdvisoryLocker returns a locker claiming runs in db, which must be a
+// PostgreSQL database, and creates the schedule_runs table if needed.
+func NewAdvisoryLocker(ctx context.Context, db *sql.DB) (*AdvisoryLocker, error) {
+	l := &AdvisoryLocker{db: db}
+
+	// Replicas starting together would race to create the table, so the
+	// creation is serialized like a claim.
+	err := l.locked(ctx, "schedule_runs", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS schedule_runs (
+	key text PRIMARY KEY,
+	host text NOT NULL,
+	claimed timestamptz NOT NULL
+)`)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("schedule: creating schedule_runs: %w", err)
+	}
+
+	return l, nil
+}
+
+// Acquire implements Locker.
+func (l *AdvisoryLocker) Acquire(ctx context.Context, key string) (bool, error) {
+	claimed := false
+	err := l.locked(ctx, key, func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRowContext(ctx, "SELECT count(*) FROM schedule_runs WHERE key = $1", key).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+
+		host, _ := os.Hostname()
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schedule_runs (key, host, claimed) VALUES ($1, $2, $3)", key, host, time.Now()); err != nil {
+			return err
+		}
+		claimed = true
+
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("schedule: acquire %q: %w", key, err)
+	}
+
+	return claimed, nil
+}
+
+// Prune removes the records of runs claimed more than maxAge ago.
+func (l *AdvisoryLocker) Prune(ctx context.Context, maxAge time.Duration) error {
+	_, err := l.db.ExecContext(ctx, "DELETE FROM schedule_runs WHERE claimed < $1", time.Now().Add(-maxAge))
+	return err
+}
+
+// locked calls fn in a transaction holding the advisory lock of key, which
+// is released when the transaction ends.
+func (l *AdvisoryLocker) locked(ctx context.Context, key string, fn func(tx *sql.Tx) error) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", lockID(key)); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockID maps key to the 64-bit ID of its advisory lock.
+func lockID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("kindly/schedule/" + key))
+
+	return int64(h.Sum64())
+}
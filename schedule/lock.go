@@ -0,0 +1,101 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Locker claims job runs so that a run is executed by at most one of several
+// runners sharing the Locker.
+type Locker interface {
+	// Acquire claims the run identified by key. It returns false if the run
+	// has already been claimed.
+	Acquire(ctx context.Context, key string) (bool, error)
+}
+
+// MemoryLocker is a Locker for runners within a single process.
+type MemoryLocker struct {
+	mu      sync.Mutex
+	claimed map[string]bool
+}
+
+// Acquire implements Locker.
+func (l *MemoryLocker) Acquire(ctx context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.claimed == nil {
+		l.claimed = make(map[string]bool)
+	}
+	if l.claimed[key] {
+		return false, nil
+	}
+	l.claimed[key] = true
+
+	return true, nil
+}
+
+// FileLocker claims runs by exclusively creating a marker file per run in
+// Dir, which should be on storage shared by all replicas. Markers are left
+// behind so that a run is never repeated; use Prune to remove old ones.
+type FileLocker struct {
+	Dir string
+}
+
+// Acquire implements Locker.
+func (l *FileLocker) Acquire(ctx context.Context, key string) (bool, error) {
+	f, err := os.OpenFile(l.path(key), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("schedule: acquire %q: %w", key, err)
+	}
+	defer f.Close()
+
+	host, _ := os.Hostname()
+	fmt.Fprintf(f, "%s %d %s\n", host, os.Getpid(), time.Now().Format(time.RFC3339))
+
+	return true, nil
+}
+
+// Prune removes markers older than maxAge.
+func (l *FileLocker) Prune(maxAge time.Duration) error {
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".lock") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > maxAge {
+			os.Remove(filepath.Join(l.Dir, e.Name()))
+		}
+	}
+
+	return nil
+}
+
+func (l *FileLocker) path(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+
+	return filepath.Join(l.Dir, name+".lock")
+}
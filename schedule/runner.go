@@ -0,0 +1,147 @@
+// Package schedule runs registered jobs at configured times. Jobs are claimed
+// through a Locker before running, so several replicas can run the same
+// Runner without executing a job twice for the same scheduled time.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job is a unit of scheduled work. at is the scheduled time of the run; jobs
+// exporting a period should derive it from at rather than the current time,
+// so a delayed run exports the same data.
+type Job func(ctx context.Context, at time.Time) error
+
+type entry struct {
+	name     string
+	schedule Schedule
+	job      Job
+}
+
+type Runner struct {
+	mu      sync.Mutex
+	entries []*entry
+	locker  Locker
+	logger  Logger
+}
+
+func NewRunner(opts ...RunnerOption) *Runner {
+	r := &Runner{locker: &MemoryLocker{}, logger: &nopLogger{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+type RunnerOption func(r *Runner)
+
+// WithLocker sets the Locker used to claim runs. The default MemoryLocker
+// only deduplicates runs within the process.
+func WithLocker(l Locker) RunnerOption {
+	return func(r *Runner) {
+		r.locker = l
+	}
+}
+
+func WithLogger(logger Logger) RunnerOption {
+	return func(r *Runner) {
+		r.logger = logger
+	}
+}
+
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+type nopLogger struct {
+}
+
+func (l *nopLogger) Log(keyvals ...interface{}) error {
+	return nil
+}
+
+// Register adds a job. The name must be unique, as it identifies the job's
+// runs to the Locker.
+func (r *Runner) Register(name string, s Schedule, job Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, &entry{name: name, schedule: s, job: job})
+}
+
+// Run executes jobs as they become due until ctx is done. Jobs run
+// concurrently with each other; a failing job is logged and retried at its
+// next scheduled time.
+func (r *Runner) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	r.mu.Lock()
+	entries := append([]*entry(nil), r.entries...)
+	r.mu.Unlock()
+
+	next := make([]time.Time, len(entries))
+	now := time.Now()
+	for i, e := range entries {
+		next[i] = e.schedule.Next(now)
+	}
+
+	for {
+		var earliest time.Time
+		for _, t := range next {
+			if !t.IsZero() && (earliest.IsZero() || t.Before(earliest)) {
+				earliest = t
+			}
+		}
+		if earliest.IsZero() {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(time.Until(earliest))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		for i, e := range entries {
+			if next[i].IsZero() || next[i].After(earliest) {
+				continue
+			}
+
+			wg.Add(1)
+			go func(e *entry, at time.Time) {
+				defer wg.Done()
+				r.run(ctx, e, at)
+			}(e, next[i])
+			next[i] = e.schedule.Next(next[i])
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, e *entry, at time.Time) {
+	key := fmt.Sprintf("%s@%s", e.name, at.UTC().Format("20060102T150405Z"))
+
+	ok, err := r.locker.Acquire(ctx, key)
+	if err != nil {
+		r.logger.Log("job", e.name, "at", at, "msg", "acquire failed", "err", err)
+		return
+	}
+	if !ok {
+		r.logger.Log("job", e.name, "at", at, "msg", "already claimed")
+		return
+	}
+
+	begin := time.Now()
+	if err := e.job(ctx, at); err != nil {
+		r.logger.Log("job", e.name, "at", at, "msg", "failed", "err", err, "took", time.Since(begin))
+		return
+	}
+	r.logger.Log("job", e.name, "at", at, "msg", "done", "took", time.Since(begin))
+}
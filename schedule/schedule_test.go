@@ -0,0 +1,147 @@
+package schedule_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/schedule"
+	_ "github.com/lib/pq"
+)
+
+func TestCron_Next(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"30 6 * * *", time.Date(2021, 2, 1, 5, 0, 0, 0, oslo), time.Date(2021, 2, 1, 6, 30, 0, 0, oslo)},
+		{"30 6 * * *", time.Date(2021, 2, 1, 6, 30, 0, 0, oslo), time.Date(2021, 2, 2, 6, 30, 0, 0, oslo)},
+		{"*/15 * * * *", time.Date(2021, 2, 1, 6, 7, 0, 0, oslo), time.Date(2021, 2, 1, 6, 15, 0, 0, oslo)},
+		{"0 7 * * 1-5", time.Date(2021, 2, 5, 8, 0, 0, 0, oslo), time.Date(2021, 2, 8, 7, 0, 0, 0, oslo)},
+		{"0 0 1 * *", time.Date(2021, 12, 15, 0, 0, 0, 0, oslo), time.Date(2022, 1, 1, 0, 0, 0, 0, oslo)},
+	}
+	for _, tt := range tests {
+		c, err := schedule.Parse(tt.spec, oslo)
+		if err != nil {
+			t.Fatalf("Parse(%q) err=%v", tt.spec, err)
+		}
+		if got := c.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * * 7", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := schedule.Parse(spec, time.UTC); err == nil {
+			t.Errorf("Parse(%q) expected err", spec)
+		}
+	}
+}
+
+func TestRunner_SharedLocker(t *testing.T) {
+	locker := &schedule.MemoryLocker{}
+	var runs int32
+	job := func(ctx context.Context, at time.Time) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}
+
+	// Start right after a tick so every replica waits for the same next tick.
+	tick := time.Now().Truncate(50 * time.Millisecond).Add(50 * time.Millisecond)
+	time.Sleep(time.Until(tick.Add(5 * time.Millisecond)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		r := schedule.NewRunner(schedule.WithLocker(locker))
+		r.Register("export", schedule.Every(50*time.Millisecond), job)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Run(ctx)
+		}()
+	}
+
+	// Cancel between the first and second activation.
+	time.Sleep(time.Until(tick.Add(75 * time.Millisecond)))
+	cancel()
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&runs); got != 1 {
+		t.Errorf("job ran %d times, want 1", got)
+	}
+}
+
+func TestFileLocker(t *testing.T) {
+	l := &schedule.FileLocker{Dir: t.TempDir()}
+
+	ok, err := l.Acquire(context.Background(), "export@20210201T060000Z")
+	if err != nil || !ok {
+		t.Fatalf("first Acquire() = %v, %v, want true, nil", ok, err)
+	}
+
+	ok, err = l.Acquire(context.Background(), "export@20210201T060000Z")
+	if err != nil || ok {
+		t.Errorf("second Acquire() = %v, %v, want false, nil", ok, err)
+	}
+}
+
+// TestAdvisoryLocker runs against the PostgreSQL database given by
+// KINDLY_TEST_POSTGRES, e.g. postgres://localhost/test?sslmode=disable.
+func TestAdvisoryLocker(t *testing.T) {
+	dsn := os.Getenv("KINDLY_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("KINDLY_TEST_POSTGRES not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open() err=%v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	l, err := schedule.NewAdvisoryLocker(ctx, db)
+	if err != nil {
+		t.Fatalf("NewAdvisoryLocker() err=%v", err)
+	}
+	key := "export@" + time.Now().UTC().Format("20060102T150405.000000000Z")
+
+	// Replicas claiming the same run concurrently get it once.
+	var claimed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := l.Acquire(ctx, key)
+			if err != nil {
+				t.Errorf("Acquire() err=%v", err)
+			}
+			if ok {
+				atomic.AddInt32(&claimed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Errorf("run claimed %d times, want 1", claimed)
+	}
+
+	if err := l.Prune(ctx, 0); err != nil {
+		t.Fatalf("Prune() err=%v", err)
+	}
+	if ok, err := l.Acquire(ctx, key); err != nil || !ok {
+		t.Errorf("Acquire() after Prune() = %v, %v, want true, nil", ok, err)
+	}
+}
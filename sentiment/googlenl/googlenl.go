@@ -0,0 +1,91 @@
+// Package googlenl scores sentiment with the Google Cloud Natural Language
+// API.
+package googlenl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/atb-as/kindly/sentiment"
+	"golang.org/x/oauth2/google"
+)
+
+const BaseURL = "https://language.googleapis.com/v1"
+
+// Provider implements sentiment.Provider with the analyzeSentiment method
+// of the Natural Language API.
+type Provider struct {
+	BaseURL string
+	doer    Doer
+}
+
+// NewProvider returns a provider using doer, which is responsible for
+// authenticating requests, such as an *http.Client from
+// google.DefaultClient.
+func NewProvider(doer Doer) *Provider {
+	return &Provider{BaseURL: BaseURL, doer: doer}
+}
+
+// NewDefaultProvider returns a provider authenticated with Application
+// Default Credentials.
+func NewDefaultProvider(ctx context.Context) (*Provider, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloud-language")
+	if err != nil {
+		return nil, fmt.Errorf("googlenl: %w", err)
+	}
+
+	return NewProvider(client), nil
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+type document struct {
+	Type     string `json:"type"`
+	Content  string `json:"content"`
+	Language string `json:"language,omitempty"`
+}
+
+// Sentiment implements sentiment.Provider.
+func (p *Provider) Sentiment(ctx context.Context, text, language string) (*sentiment.Score, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"document":     &document{Type: "PLAIN_TEXT", Content: text, Language: language},
+		"encodingType": "UTF8",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/documents:analyzeSentiment", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return nil, fmt.Errorf("googlenl: unexpected status %q: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		DocumentSentiment *sentiment.Score `json:"documentSentiment"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.DocumentSentiment, nil
+}
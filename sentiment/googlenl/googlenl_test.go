@@ -0,0 +1,54 @@
+package googlenl_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/atb-as/kindly/sentiment/googlenl"
+)
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestProvider_Sentiment(t *testing.T) {
+	p := googlenl.NewProvider(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if want := "/v1/documents:analyzeSentiment"; r.URL.Path != want {
+			t.Errorf("got path %q, want %q", r.URL.Path, want)
+		}
+		var body struct {
+			Document struct {
+				Content  string `json:"content"`
+				Language string `json:"language"`
+			} `json:"document"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Document.Content != "Thanks!" || body.Document.Language != "en" {
+			t.Errorf("got body %+v, err=%v", body, err)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"documentSentiment":{"magnitude":0.8,"score":0.8},"language":"en","sentences":[]}`))}, nil
+	}))
+
+	s, err := p.Sentiment(context.Background(), "Thanks!", "en")
+	if err != nil {
+		t.Fatalf("Sentiment() err=%v", err)
+	}
+	if s.Score != 0.8 || s.Magnitude != 0.8 {
+		t.Errorf("got %+v, want score and magnitude 0.8", s)
+	}
+}
+
+func TestProvider_SentimentError(t *testing.T) {
+	p := googlenl.NewProvider(doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadRequest, Status: "400 Bad Request", Body: io.NopCloser(strings.NewReader(`{"error":{"message":"The language nb is not supported for document_sentiment analysis."}}`))}, nil
+	}))
+
+	if _, err := p.Sentiment(context.Background(), "Takk!", "nb"); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("got err=%v, want the upstream message", err)
+	}
+}
@@ -0,0 +1,60 @@
+// Package sentiment scores the sentiment of chat messages with a pluggable
+// provider, so the sentiment of chats can be reported alongside feedback
+// ratings.
+package sentiment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/atb-as/kindly/chat"
+)
+
+// Score is the sentiment of a text.
+type Score struct {
+	// Score is from -1, negative, to 1, positive.
+	Score float64 `json:"score"`
+	// Magnitude is the strength of the sentiment from 0, regardless of
+	// sign, and grows with the length of the text.
+	Magnitude float64 `json:"magnitude"`
+}
+
+// Provider scores the sentiment of texts.
+type Provider interface {
+	// Sentiment returns the sentiment of text in the language, such as
+	// "nb", or nil if the provider has none. An empty language leaves it
+	// to the provider to detect.
+	Sentiment(ctx context.Context, text, language string) (*Score, error)
+}
+
+// Nop is a Provider without sentiment, for exports that do not annotate
+// messages.
+type Nop struct{}
+
+// Sentiment implements Provider.
+func (Nop) Sentiment(ctx context.Context, text, language string) (*Score, error) {
+	return nil, nil
+}
+
+// Annotate returns the sentiment of the user messages of a chat in the
+// language, by message ID. Bot and agent messages and empty messages are
+// not scored. Messages should be anonymized first, since their texts are
+// sent to the provider.
+func Annotate(ctx context.Context, p Provider, messages []*chat.Message, language string) (map[string]*Score, error) {
+	ret := make(map[string]*Score)
+	for _, msg := range messages {
+		if msg.Sender != chat.SenderUser || msg.Text == "" {
+			continue
+		}
+
+		s, err := p.Sentiment(ctx, msg.Text, language)
+		if err != nil {
+			return nil, fmt.Errorf("sentiment of message %s: %w", msg.ID, err)
+		}
+		if s != nil {
+			ret[msg.ID] = s
+		}
+	}
+
+	return ret, nil
+}
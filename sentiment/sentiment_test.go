@@ -0,0 +1,44 @@
+package sentiment_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atb-as/kindly/chat"
+	"github.com/atb-as/kindly/sentiment"
+)
+
+type providerFunc func(ctx context.Context, text, language string) (*sentiment.Score, error)
+
+func (f providerFunc) Sentiment(ctx context.Context, text, language string) (*sentiment.Score, error) {
+	return f(ctx, text, language)
+}
+
+func TestAnnotate(t *testing.T) {
+	var texts []string
+	p := providerFunc(func(ctx context.Context, text, language string) (*sentiment.Score, error) {
+		texts = append(texts, language+":"+text)
+		return &sentiment.Score{Score: -0.5, Magnitude: 0.5}, nil
+	})
+
+	scores, err := sentiment.Annotate(context.Background(), p, []*chat.Message{
+		{ID: "m1", Sender: chat.SenderUser, Text: "Where is my bag?"},
+		{ID: "m2", Sender: chat.SenderBot, Text: "Let me check."},
+		{ID: "m3", Sender: chat.SenderUser},
+		{ID: "m4", Sender: chat.SenderAgent, Text: "Found it!"},
+	}, "en")
+	if err != nil {
+		t.Fatalf("Annotate() err=%v", err)
+	}
+
+	if len(texts) != 1 || texts[0] != "en:Where is my bag?" {
+		t.Errorf("got texts %q, want only the user message", texts)
+	}
+	if len(scores) != 1 || scores["m1"].Score != -0.5 {
+		t.Errorf("got scores %v, want m1 scored", scores)
+	}
+
+	if scores, err := sentiment.Annotate(context.Background(), sentiment.Nop{}, []*chat.Message{{ID: "m1", Sender: chat.SenderUser, Text: "Hi"}}, ""); err != nil || len(scores) != 0 {
+		t.Errorf("got %v, %v, want no scores from Nop", scores, err)
+	}
+}
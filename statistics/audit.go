@@ -0,0 +1,91 @@
+package statistics
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AuditEntry records an outgoing request for data-access auditing.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Caller is the identity set with WithCaller, if any.
+	Caller    string `json:"caller,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	BotID     string `json:"bot_id"`
+	Method    string `json:"method"`
+	// Endpoint is the path below the bot, such as "sessions/series".
+	Endpoint string `json:"endpoint"`
+	// Filter is the query of the request, such as the period and sources.
+	Filter url.Values `json:"filter,omitempty"`
+	// Status is the status code of the response, or zero if the request
+	// failed before a response was received, as described by Error.
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AuditSink records audit entries, e.g. in a file or a logging service.
+type AuditSink interface {
+	Audit(ctx context.Context, e *AuditEntry) error
+}
+
+// WithAuditSink makes the client record every request it sends, including
+// retries, in s. Entries that can not be recorded are logged, but do not fail
+// the request.
+func WithAuditSink(s AuditSink) ClientOption {
+	return func(c *Client) {
+		c.audit = s
+	}
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context that makes the client record caller as the
+// identity on whose behalf requests are sent, such as a user or a hash of an
+// access token.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller set with WithCaller, or an empty
+// string.
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// recordAudit records r, sent at begin, with its response or error in the
+// audit sink, if any.
+func (c *Client) recordAudit(r *http.Request, begin time.Time, resp *http.Response, err error) {
+	if c.audit == nil {
+		return
+	}
+
+	ctx := r.Context()
+	botID := c.botID(ctx)
+	endpoint := r.URL.Path
+	if i := strings.Index(endpoint, "/"+botID+"/"); i >= 0 {
+		endpoint = endpoint[i+len(botID)+2:]
+	}
+	e := &AuditEntry{
+		Time:      begin.UTC(),
+		Caller:    CallerFromContext(ctx),
+		RequestID: RequestIDFromContext(ctx),
+		BotID:     botID,
+		Method:    r.Method,
+		Endpoint:  endpoint,
+		Filter:    r.URL.Query(),
+	}
+	if resp != nil {
+		e.Status = resp.StatusCode
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	if err := c.audit.Audit(ctx, e); err != nil {
+		c.logger.Log("msg", "recording audit entry failed", "endpoint", endpoint, "err", err)
+	}
+}
@@ -0,0 +1,37 @@
+// Package audit provides sinks for the audit entries recorded by
+// statistics.WithAuditSink.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// Open returns the sink described by uri:
+//
+//	file:///<path>
+//	logging://projects/<project>/logs/<log>
+//
+// A uri without a scheme is a file path. Cloud Logging uses Application
+// Default Credentials.
+func Open(ctx context.Context, uri string) (statistics.AuditSink, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("audit: parsing sink %q: %w", uri, err)
+	}
+
+	switch u.Scheme {
+	case "":
+		return NewFile(uri)
+	case "file":
+		return NewFile(u.Path)
+	case "logging":
+		return NewCloudLogging(ctx, strings.Trim(u.Host+u.Path, "/"))
+	default:
+		return nil, fmt.Errorf("audit: parsing sink %q: unsupported scheme %q", uri, u.Scheme)
+	}
+}
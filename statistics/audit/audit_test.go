@@ -0,0 +1,97 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/statistics/audit"
+)
+
+var entry = &statistics.AuditEntry{
+	Time:     time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+	Caller:   "alice",
+	BotID:    "1",
+	Method:   http.MethodGet,
+	Endpoint: "sessions/chats",
+	Status:   http.StatusOK,
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for i := 0; i < 2; i++ {
+		sink, err := audit.Open(context.Background(), "file://"+path)
+		if err != nil {
+			t.Fatalf("Open() err=%v", err)
+		}
+		if err := sink.Audit(context.Background(), entry); err != nil {
+			t.Fatalf("Audit() err=%v", err)
+		}
+		sink.(*audit.File).Close()
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var got statistics.AuditEntry
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Caller != "alice" || got.Endpoint != "sessions/chats" || !got.Time.Equal(entry.Time) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestCloudLogging(t *testing.T) {
+	var body struct {
+		LogName string `json:"logName"`
+		Entries []struct {
+			Timestamp   string                `json:"timestamp"`
+			JSONPayload statistics.AuditEntry `json:"jsonPayload"`
+		} `json:"entries"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/entries:write" {
+			t.Errorf("got path %q, want /entries:write", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	sink := &audit.CloudLogging{LogName: "projects/p/logs/kindly-audit", BaseURL: srv.URL}
+	if err := sink.Audit(context.Background(), entry); err != nil {
+		t.Fatalf("Audit() err=%v", err)
+	}
+
+	if body.LogName != "projects/p/logs/kindly-audit" || len(body.Entries) != 1 {
+		t.Fatalf("got %+v", body)
+	}
+	if got := body.Entries[0]; got.Timestamp != "2021-03-01T12:00:00Z" || got.JSONPayload.Caller != "alice" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestCloudLogging_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	sink := &audit.CloudLogging{LogName: "projects/p/logs/kindly-audit", BaseURL: srv.URL}
+	if err := sink.Audit(context.Background(), entry); err == nil {
+		t.Error("Audit() err=nil, want error")
+	}
+}
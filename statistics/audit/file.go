@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+// File appends audit entries to a file as JSON lines.
+type File struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFile opens the file at path for appending, creating it if missing.
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	return &File{f: f}, nil
+}
+
+// Audit implements statistics.AuditSink. Every entry is written with a
+// single write, so entries of concurrent processes are not interleaved.
+func (f *File) Audit(ctx context.Context, e *statistics.AuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	return f.f.Close()
+}
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+	"golang.org/x/oauth2/google"
+)
+
+const loggingURL = "https://logging.googleapis.com/v2"
+
+// CloudLogging writes audit entries to a Google Cloud Logging log, one
+// entries.write call per entry.
+type CloudLogging struct {
+	// LogName is the log resource name, projects/<project>/logs/<log>.
+	LogName string
+	BaseURL string
+	client  *http.Client
+}
+
+// NewCloudLogging returns a sink writing to the log with the given resource
+// name, authenticated with Application Default Credentials.
+func NewCloudLogging(ctx context.Context, logName string) (*CloudLogging, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/logging.write")
+	if err != nil {
+		return nil, fmt.Errorf("audit: cloud logging: %w", err)
+	}
+
+	return &CloudLogging{LogName: logName, client: client}, nil
+}
+
+// Audit implements statistics.AuditSink.
+func (l *CloudLogging) Audit(ctx context.Context, e *statistics.AuditEntry) error {
+	if l.BaseURL == "" {
+		l.BaseURL = loggingURL
+	}
+	if l.client == nil {
+		l.client = http.DefaultClient
+	}
+
+	type entry struct {
+		Timestamp   string                 `json:"timestamp"`
+		Severity    string                 `json:"severity"`
+		Labels      map[string]string      `json:"labels"`
+		JSONPayload *statistics.AuditEntry `json:"jsonPayload"`
+	}
+	body, err := json.Marshal(struct {
+		LogName  string            `json:"logName"`
+		Resource map[string]string `json:"resource"`
+		Entries  []entry           `json:"entries"`
+	}{
+		LogName:  l.LogName,
+		Resource: map[string]string{"type": "global"},
+		Entries: []entry{{
+			Timestamp:   e.Time.Format(time.RFC3339Nano),
+			Severity:    "INFO",
+			Labels:      map[string]string{"bot_id": e.BotID},
+			JSONPayload: e,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The entry is written even if the request that caused it was
+	// cancelled, as the request was still sent.
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, l.BaseURL+"/entries:write", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("audit: cloud logging: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("audit: cloud logging: write %s: unexpected status %q", l.LogName, resp.Status)
+	}
+
+	return nil
+}
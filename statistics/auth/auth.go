@@ -2,6 +2,7 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,9 +19,14 @@ const (
 )
 
 type TokenSource struct {
-	APIKey   string
-	BotID    string
-	TokenURL string
+	APIKey string
+	// Credentials, if set, provides the API key instead of APIKey. It is
+	// consulted every time a new token is fetched.
+	Credentials Credentials
+	BotID       string
+	TokenURL    string
+	// Client is used to fetch tokens. Defaults to http.DefaultClient.
+	Client *http.Client
 }
 
 var (
@@ -32,14 +38,27 @@ func (t *TokenSource) Token() (*oauth2.Token, error) {
 		t.TokenURL = fmt.Sprintf("%s/%s/sage/auth", tokenURLBase, t.BotID)
 	}
 
+	apiKey := t.APIKey
+	if t.Credentials != nil {
+		key, err := t.Credentials.APIKey(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRetrieveToken, err)
+		}
+		apiKey = key
+	}
+
 	req, err := http.NewRequest(http.MethodGet, t.TokenURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+t.APIKey)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1,14 +1,17 @@
 package auth_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/atb-as/kindly/statistics/auth"
+	"golang.org/x/oauth2"
 )
 
 func TestApiKeyTokenSource_Token(t *testing.T) {
@@ -89,3 +92,118 @@ func newTestSrv(status int, resp []byte) *httptest.Server {
 	}))
 	return srv
 }
+
+func TestTokenSource_Credentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer rotated" {
+			t.Errorf("got Authorization %q, want %q", got, "Bearer rotated")
+		}
+		w.Header().Set("Content-type", "application/json")
+		w.Write([]byte(`{"jwt":"token","ttl":300}`))
+	}))
+	defer srv.Close()
+
+	src := auth.TokenSource{
+		APIKey:      "stale",
+		Credentials: auth.StaticCredentials("rotated"),
+		TokenURL:    srv.URL,
+	}
+
+	if _, err := src.Token(); err != nil {
+		t.Errorf("err=%v", err)
+	}
+}
+
+func TestVault_APIKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/kindly/prod" {
+			t.Errorf("got path %q, want %q", r.URL.Path, "/v1/secret/data/kindly/prod")
+		}
+		if got := r.Header.Get("X-Vault-Token"); got != "vault-token" {
+			t.Errorf("got X-Vault-Token %q, want %q", got, "vault-token")
+		}
+		w.Write([]byte(`{"data":{"data":{"api_key":"key"},"metadata":{"version":2}}}`))
+	}))
+	defer srv.Close()
+
+	os.Setenv("VAULT_ADDR", srv.URL)
+	os.Setenv("VAULT_TOKEN", "vault-token")
+	defer os.Unsetenv("VAULT_ADDR")
+	defer os.Unsetenv("VAULT_TOKEN")
+
+	creds, err := auth.ParseCredentials(context.Background(), "vault://secret/kindly/prod")
+	if err != nil {
+		t.Fatalf("ParseCredentials() err=%v", err)
+	}
+
+	key, err := creds.APIKey(context.Background())
+	if err != nil {
+		t.Fatalf("APIKey() err=%v", err)
+	}
+	if key != "key" {
+		t.Errorf("got key %q, want %q", key, "key")
+	}
+}
+
+func TestParseCredentials_Unsupported(t *testing.T) {
+	if _, err := auth.ParseCredentials(context.Background(), "s3://bucket/key"); err == nil {
+		t.Errorf("expected err, got nil")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, k := range []string{"KINDLY_API_KEY", "KINDLY_CREDENTIALS"} {
+		defer os.Setenv(k, os.Getenv(k))
+	}
+
+	os.Setenv("KINDLY_API_KEY", "")
+	os.Setenv("KINDLY_CREDENTIALS", "")
+	if _, err := auth.FromEnv(); err == nil {
+		t.Errorf("expected err, got nil")
+	}
+
+	os.Setenv("KINDLY_API_KEY", "key")
+	creds, err := auth.FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv() err=%v", err)
+	}
+	if key, _ := creds.APIKey(context.Background()); key != "key" {
+		t.Errorf("got key %q, want %q", key, "key")
+	}
+
+	os.Setenv("KINDLY_CREDENTIALS", "env://OTHER_KEY")
+	if creds, _ := auth.FromEnv(); creds != auth.EnvCredentials("OTHER_KEY") {
+		t.Errorf("got credentials %v, want env://OTHER_KEY", creds)
+	}
+}
+
+// rotatingCredentials returns the key it is set to.
+type rotatingCredentials struct {
+	key string
+}
+
+func (c *rotatingCredentials) APIKey(ctx context.Context) (string, error) {
+	return c.key, nil
+}
+
+func TestKeySource_Rotation(t *testing.T) {
+	creds := &rotatingCredentials{key: "old"}
+	// Tokens are reused until they are about to expire, which tokens of a
+	// tiny Refresh always are.
+	src := oauth2.ReuseTokenSource(nil, &auth.KeySource{Credentials: creds, Refresh: time.Nanosecond})
+
+	tok, err := src.Token()
+	if err != nil || tok.AccessToken != "old" {
+		t.Fatalf("Token() got %v, err=%v, want the old key", tok, err)
+	}
+	creds.key = "new"
+	tok, err = src.Token()
+	if err != nil || tok.AccessToken != "new" {
+		t.Errorf("Token() got %v, err=%v, want the rotated key", tok, err)
+	}
+
+	tok, err = (&auth.KeySource{Credentials: creds}).Token()
+	if err != nil || time.Until(tok.Expiry) > auth.DefaultKeyRefresh || time.Until(tok.Expiry) < auth.DefaultKeyRefresh-time.Minute {
+		t.Errorf("Token() got expiry %v, want in %v", tok.Expiry, auth.DefaultKeyRefresh)
+	}
+}
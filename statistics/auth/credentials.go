@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Credentials provides the Kindly API key. Implementations fetch the key from
+// a secret store on every call, so a rotated key is picked up the next time a
+// token is fetched.
+type Credentials interface {
+	APIKey(ctx context.Context) (string, error)
+}
+
+// StaticCredentials is a fixed API key.
+type StaticCredentials string
+
+// APIKey implements Credentials.
+func (s StaticCredentials) APIKey(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+// EnvCredentials reads the API key from the named environment variable.
+type EnvCredentials string
+
+// APIKey implements Credentials.
+func (e EnvCredentials) APIKey(ctx context.Context) (string, error) {
+	key := os.Getenv(string(e))
+	if key == "" {
+		return "", fmt.Errorf("auth: environment variable %s is empty", string(e))
+	}
+
+	return key, nil
+}
+
+// ParseCredentials returns the Credentials described by uri:
+//
+//	gsm://projects/<project>/secrets/<secret>[/versions/<version>]
+//	vault://<mount>/<path>[#<field>]
+//	env://<VARIABLE>
+//
+// Google Secret Manager uses Application Default Credentials. Vault reads the
+// server address and token from VAULT_ADDR and VAULT_TOKEN.
+func ParseCredentials(ctx context.Context, uri string) (Credentials, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("auth: parsing credentials %q: %w", uri, err)
+	}
+
+	name := strings.Trim(u.Host+u.Path, "/")
+	switch u.Scheme {
+	case "gsm":
+		return NewSecretManager(ctx, name)
+	case "vault":
+		return NewVaultFromEnv(name, u.Fragment)
+	case "env":
+		return EnvCredentials(name), nil
+	default:
+		return nil, fmt.Errorf("auth: parsing credentials %q: unsupported scheme %q", uri, u.Scheme)
+	}
+}
+
+// FromEnv returns the Credentials described by KINDLY_CREDENTIALS, as parsed
+// by ParseCredentials, or else the API key in KINDLY_API_KEY.
+func FromEnv() (Credentials, error) {
+	if uri := os.Getenv("KINDLY_CREDENTIALS"); uri != "" {
+		return ParseCredentials(context.Background(), uri)
+	}
+	if os.Getenv("KINDLY_API_KEY") == "" {
+		return nil, fmt.Errorf("auth: neither KINDLY_CREDENTIALS nor KINDLY_API_KEY is set")
+	}
+
+	return EnvCredentials("KINDLY_API_KEY"), nil
+}
+
+// DefaultKeyRefresh is how long a KeySource token is used before the API key
+// is read again, unless configured with KeySource.Refresh.
+const DefaultKeyRefresh = 5 * time.Minute
+
+// KeySource is an oauth2.TokenSource that uses the API key itself as bearer
+// token, as the Kindly API outside of Sage expects. Its tokens expire after
+// Refresh, so a rotated key is picked up even when the source is wrapped in
+// oauth2.ReuseTokenSource, as oauth2.NewClient does.
+type KeySource struct {
+	Credentials Credentials
+	// Refresh defaults to DefaultKeyRefresh.
+	Refresh time.Duration
+}
+
+// Token implements oauth2.TokenSource.
+func (k *KeySource) Token() (*oauth2.Token, error) {
+	key, err := k.Credentials.APIKey(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := k.Refresh
+	if refresh <= 0 {
+		refresh = DefaultKeyRefresh
+	}
+
+	return &oauth2.Token{AccessToken: key, TokenType: "Bearer", Expiry: time.Now().Add(refresh)}, nil
+}
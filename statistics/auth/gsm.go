@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+)
+
+const secretManagerURL = "https://secretmanager.googleapis.com/v1"
+
+// SecretManager reads the API key from a Google Secret Manager secret
+// version.
+type SecretManager struct {
+	// Name is the secret version resource name,
+	// projects/<project>/secrets/<secret>/versions/<version>.
+	Name    string
+	BaseURL string
+	client  *http.Client
+}
+
+// NewSecretManager returns Credentials for the secret version with the given
+// resource name, authenticated with Application Default Credentials. The
+// "latest" version is used if name does not include one.
+func NewSecretManager(ctx context.Context, name string) (*SecretManager, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, fmt.Errorf("auth: secret manager: %w", err)
+	}
+
+	return &SecretManager{Name: name, client: client}, nil
+}
+
+// APIKey implements Credentials.
+func (s *SecretManager) APIKey(ctx context.Context) (string, error) {
+	if s.BaseURL == "" {
+		s.BaseURL = secretManagerURL
+	}
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s:access", s.BaseURL, s.Name), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("auth: secret manager: access %s: unexpected status %q", s.Name, resp.Status)
+	}
+
+	var v struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&v); err != nil {
+		return "", fmt.Errorf("auth: secret manager: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(v.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("auth: secret manager: decoding payload: %w", err)
+	}
+
+	return strings.TrimSpace(string(key)), nil
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Vault reads the API key from a HashiCorp Vault KV version 2 secret.
+type Vault struct {
+	Addr  string
+	Token string
+	// Path is <mount>/<path> of the secret.
+	Path string
+	// Field is the key within the secret holding the API key.
+	Field  string
+	client *http.Client
+}
+
+// NewVaultFromEnv returns Credentials reading field of the secret at path
+// from the Vault server at VAULT_ADDR, authenticated with VAULT_TOKEN. Field
+// defaults to "api_key".
+func NewVaultFromEnv(path, field string) (*Vault, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, fmt.Errorf("auth: vault: VAULT_ADDR is not set")
+	}
+	if field == "" {
+		field = "api_key"
+	}
+
+	return &Vault{Addr: addr, Token: os.Getenv("VAULT_TOKEN"), Path: path, Field: field}, nil
+}
+
+// APIKey implements Credentials. The latest version of the secret is read on
+// every call.
+func (v *Vault) APIKey(ctx context.Context) (string, error) {
+	if v.client == nil {
+		v.client = http.DefaultClient
+	}
+
+	mount, path := v.Path, ""
+	if i := strings.Index(v.Path, "/"); i >= 0 {
+		mount, path = v.Path[:i], v.Path[i+1:]
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimSuffix(v.Addr, "/"), mount, path), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("auth: vault: read %s: unexpected status %q", v.Path, resp.Status)
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&secret); err != nil {
+		return "", fmt.Errorf("auth: vault: %w", err)
+	}
+
+	key, ok := secret.Data.Data[v.Field].(string)
+	if !ok || key == "" {
+		return "", fmt.Errorf("auth: vault: secret %s has no field %q", v.Path, v.Field)
+	}
+
+	return key, nil
+}
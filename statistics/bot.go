@@ -0,0 +1,30 @@
+package statistics
+
+import (
+	"context"
+)
+
+type botIDKey struct{}
+
+// ForBot returns a context that makes the client call the API of botID
+// instead of its BotID, so a server handling requests for several bots can
+// share a single client. The doer of the client must be authorized for
+// botID.
+func ForBot(ctx context.Context, botID string) context.Context {
+	return context.WithValue(ctx, botIDKey{}, botID)
+}
+
+// BotIDFromContext returns the bot ID set with ForBot, or an empty string.
+func BotIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(botIDKey{}).(string)
+	return id
+}
+
+// botID returns the bot the calls made with ctx are for.
+func (c *Client) botID(ctx context.Context) string {
+	if id := BotIDFromContext(ctx); id != "" {
+		return id
+	}
+
+	return c.BotID
+}
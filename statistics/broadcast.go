@@ -0,0 +1,62 @@
+package statistics
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/atb-as/kindly"
+)
+
+// BroadcastCounts are the delivery and engagement counts of a broadcast.
+type BroadcastCounts struct {
+	Sent      int
+	Delivered int
+	Opened    int
+	Replied   int
+}
+
+// BroadcastStatistic is the counts of a single broadcast.
+type BroadcastStatistic struct {
+	ID    string `json:"broadcast_id"`
+	Title string `json:"broadcast_title"`
+	BroadcastCounts
+}
+
+// BroadcastCountByDate is the counts of a broadcast on a single date.
+type BroadcastCountByDate struct {
+	Date kindly.Time
+	BroadcastCounts
+}
+
+// Broadcasts returns the number of broadcast messages sent, delivered, opened
+// and replied to per broadcast sent in the requested time period.
+func (c *Client) Broadcasts(ctx context.Context, f *Filter) ([]*BroadcastStatistic, error) {
+	req, err := c.newRequest(ctx, "broadcasts/summary", f)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*BroadcastStatistic, 0)
+	if err := c.do(req, &ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// BroadcastTimeSeries returns the number of messages of the broadcast with
+// the given ID sent, delivered, opened and replied to, as a time series.
+func (c *Client) BroadcastTimeSeries(ctx context.Context, broadcastID string, f *Filter) ([]*BroadcastCountByDate, error) {
+	req, err := c.newRequest(ctx, fmt.Sprintf("broadcasts/%s/series", url.PathEscape(broadcastID)), f)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*BroadcastCountByDate, 0)
+	if err := c.do(req, &ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,60 @@
+package statistics
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrCallBudgetExceeded is returned instead of making an upstream call when
+// the CallBudget of the context has been used up.
+var ErrCallBudgetExceeded = errors.New("statistics: upstream call budget exceeded")
+
+// CallBudget counts the upstream calls, including retries, made with a
+// context and optionally limits them.
+type CallBudget struct {
+	mu    sync.Mutex
+	max   int
+	calls int
+}
+
+// NewCallBudget returns a budget of max calls. A max of zero or less only
+// counts calls.
+func NewCallBudget(max int) *CallBudget {
+	return &CallBudget{max: max}
+}
+
+// Calls returns the number of calls made so far.
+func (b *CallBudget) Calls() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.calls
+}
+
+// take accounts for a call, or returns ErrCallBudgetExceeded if there is no
+// budget left.
+func (b *CallBudget) take() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.max > 0 && b.calls >= b.max {
+		return ErrCallBudgetExceeded
+	}
+	b.calls++
+
+	return nil
+}
+
+type callBudgetKey struct{}
+
+// WithCallBudget returns a context that makes the client account its
+// upstream calls in b.
+func WithCallBudget(ctx context.Context, b *CallBudget) context.Context {
+	return context.WithValue(ctx, callBudgetKey{}, b)
+}
+
+func callBudgetFromContext(ctx context.Context) *CallBudget {
+	b, _ := ctx.Value(callBudgetKey{}).(*CallBudget)
+	return b
+}
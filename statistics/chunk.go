@@ -0,0 +1,29 @@
+package statistics
+
+import "time"
+
+// Chunks splits the period of f into consecutive filters of one day, or one
+// week for Week, that are otherwise equal to f. The last chunk ends at f.To.
+// Hour is treated as Day, since the API filters on whole days.
+//
+// Chunks lets totals-only endpoints be queried once per chunk to build a
+// time series.
+func (f *Filter) Chunks(g Granularity) []*Filter {
+	step := 24 * time.Hour
+	if g == Week {
+		step = 7 * 24 * time.Hour
+	}
+
+	var chunks []*Filter
+	for t := f.From; t.Before(f.To); t = t.Add(step) {
+		chunk := *f
+		chunk.From = t
+		chunk.To = t.Add(step)
+		if chunk.To.After(f.To) {
+			chunk.To = f.To
+		}
+		chunks = append(chunks, &chunk)
+	}
+
+	return chunks
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/atb-as/kindly"
@@ -22,6 +23,34 @@ type Client struct {
 	BaseURL string
 	logger  Logger
 	doer    Doer
+	// transport, if set, replaces the transport of doer.
+	transport http.RoundTripper
+
+	// labels, if set, normalizes the texts returned by ChatLabels.
+	labels *LabelCatalogue
+	// failover, if set, spreads calls over several base URLs.
+	failover *failover
+	// audit, if set, records every request sent.
+	audit AuditSink
+	// networkRetries is the number of retries of transient network errors;
+	// 0 is the default and a negative number disables them.
+	networkRetries int
+	// maxResponseSize is the largest response body read; 0 is the default
+	// and a negative number disables the limit.
+	maxResponseSize int64
+	// timeout and endpointTimeouts, if set, limit the time of requests.
+	timeout          time.Duration
+	endpointTimeouts map[string]time.Duration
+	// strictDecoding fails calls whose response has unknown fields.
+	strictDecoding bool
+	// conditional, if set, revalidates kept responses.
+	conditional *conditional
+	// eventHook, if set, receives the events of the client.
+	eventHook EventHook
+
+	quotaMu sync.Mutex
+	// quota is the quota reported with the most recent response.
+	quota *Quota
 }
 
 func NewClient(opts ...ClientOption) *Client {
@@ -29,6 +58,9 @@ func NewClient(opts ...ClientOption) *Client {
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.transport != nil {
+		c.doer = withTransport(c.doer, c.transport)
+	}
 
 	return c
 }
@@ -90,12 +122,25 @@ type Filter struct {
 	Timezone      string
 	Limit         int
 	Granularity   Granularity
-	Sources       []string
+	Sources       []Source
 	LanguageCodes []string
 }
 
 const dateLayout = "2006-01-02"
 
+// DefaultTimezone is the time zone of filters without a Timezone.
+const DefaultTimezone = "Europe/Oslo"
+
+// Location returns the time zone of f, in which Sage reports the dates of its
+// series.
+func (f *Filter) Location() (*time.Location, error) {
+	if f == nil || f.Timezone == "" {
+		return time.LoadLocation(DefaultTimezone)
+	}
+
+	return time.LoadLocation(f.Timezone)
+}
+
 func (f *Filter) Query() url.Values {
 	if f == nil {
 		return url.Values{}
@@ -104,7 +149,9 @@ func (f *Filter) Query() url.Values {
 	q := url.Values{}
 
 	if f.Timezone == "" {
-		q.Add("tz", "Europe/Oslo")
+		q.Add("tz", DefaultTimezone)
+	} else {
+		q.Add("tz", f.Timezone)
 	}
 
 	if !f.From.IsZero() {
@@ -124,7 +171,11 @@ func (f *Filter) Query() url.Values {
 	}
 
 	for _, source := range f.Sources {
-		q.Add("sources[]", source)
+		q.Add("sources[]", source.String())
+	}
+
+	for _, code := range f.LanguageCodes {
+		q.Add("language_codes[]", code)
 	}
 
 	return q
@@ -184,7 +235,7 @@ type Rating struct {
 // AggregatedFeedback returns the aggregated ratings of the bot given by users
 // in the specified period.
 func (c *Client) AggregatedFeedback(ctx context.Context, f *Filter) (*Feedback, error) {
-	req, err := c.newRequest(ctx, "feedback/summary", f.Query())
+	req, err := c.newRequest(ctx, "feedback/summary", f)
 	if err != nil {
 		return nil, err
 	}
@@ -201,7 +252,7 @@ func (c *Client) AggregatedFeedback(ctx context.Context, f *Filter) (*Feedback,
 // requests while closed, started handovers and ended handovers in the requested
 // time period.
 func (c *Client) HandoversTotal(ctx context.Context, f *Filter) (*Handovers, error) {
-	req, err := c.newRequest(ctx, "takeovers/totals", f.Query())
+	req, err := c.newRequest(ctx, "takeovers/totals", f)
 	if err != nil {
 		return nil, err
 	}
@@ -218,7 +269,7 @@ func (c *Client) HandoversTotal(ctx context.Context, f *Filter) (*Handovers, err
 // requests while closed, started handovers and ended handovers in the requested
 // time period, as a time series.
 func (c *Client) HandoversTimeSeries(ctx context.Context, f *Filter) ([]*HandoversTimeSeries, error) {
-	req, err := c.newRequest(ctx, "takeovers/series", f.Query())
+	req, err := c.newRequest(ctx, "takeovers/series", f)
 	if err != nil {
 		return nil, err
 	}
@@ -235,7 +286,7 @@ func (c *Client) HandoversTimeSeries(ctx context.Context, f *Filter) ([]*Handove
 // bot has happened. Returns top 3 pages by default, use f.Limit parameter to
 // request more results.
 func (c *Client) PageStatistics(ctx context.Context, f *Filter) ([]*PageStatistic, error) {
-	req, err := c.newRequest(ctx, "chatbubble/pages", f.Query())
+	req, err := c.newRequest(ctx, "chatbubble/pages", f)
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +302,7 @@ func (c *Client) PageStatistics(ctx context.Context, f *Filter) ([]*PageStatisti
 // FallbackRateTotal returns the number of and fraction of bot replies that are
 // fallbacks, as a total aggregate for the selected time interval.
 func (c *Client) FallbackRateTotal(ctx context.Context, f *Filter) (*RateTotal, error) {
-	req, err := c.newRequest(ctx, "fallbacks/total", f.Query())
+	req, err := c.newRequest(ctx, "fallbacks/total", f)
 	if err != nil {
 		return nil, err
 	}
@@ -264,10 +315,37 @@ func (c *Client) FallbackRateTotal(ctx context.Context, f *Filter) (*RateTotal,
 	return &ret, nil
 }
 
+// LanguageRateTotal is the fallback rate of a single language.
+type LanguageRateTotal struct {
+	LanguageCode string
+	RateTotal
+}
+
+// FallbackRateByLanguage returns the fallback rate total per language in
+// f.LanguageCodes, in the same order, with one upstream call per language.
+func (c *Client) FallbackRateByLanguage(ctx context.Context, f *Filter) ([]*LanguageRateTotal, error) {
+	if f == nil || len(f.LanguageCodes) == 0 {
+		return nil, fmt.Errorf("statistics: FallbackRateByLanguage: no language codes in filter")
+	}
+
+	ret := make([]*LanguageRateTotal, 0, len(f.LanguageCodes))
+	for _, code := range f.LanguageCodes {
+		temp := *f
+		temp.LanguageCodes = []string{code}
+		total, err := c.FallbackRateTotal(ctx, &temp)
+		if err != nil {
+			return nil, fmt.Errorf("language %s: %w", code, err)
+		}
+		ret = append(ret, &LanguageRateTotal{LanguageCode: code, RateTotal: *total})
+	}
+
+	return ret, nil
+}
+
 // FallbackRateTimeSeries returns the number of and fraction of bot replies that
 // are fallbacks, as an aggregated time series.
 func (c *Client) FallbackRateTimeSeries(ctx context.Context, f *Filter) ([]*CountByDateWithRate, error) {
-	req, err := c.newRequest(ctx, "fallbacks/series", f.Query())
+	req, err := c.newRequest(ctx, "fallbacks/series", f)
 	if err != nil {
 		return nil, err
 	}
@@ -282,7 +360,7 @@ func (c *Client) FallbackRateTimeSeries(ctx context.Context, f *Filter) ([]*Coun
 
 // UserMessages returns the number of messages from users.
 func (c *Client) UserMessages(ctx context.Context, f *Filter) ([]*CountByDate, error) {
-	req, err := c.newRequest(ctx, "sessions/messages", f.Query())
+	req, err := c.newRequest(ctx, "sessions/messages", f)
 	if err != nil {
 		return nil, err
 	}
@@ -297,7 +375,7 @@ func (c *Client) UserMessages(ctx context.Context, f *Filter) ([]*CountByDate, e
 
 // ChatSessions returns the number of chats where users engaged with the bot.
 func (c *Client) ChatSessions(ctx context.Context, f *Filter) ([]*CountByDate, error) {
-	req, err := c.newRequest(ctx, "sessions/chats", f.Query())
+	req, err := c.newRequest(ctx, "sessions/chats", f)
 	if err != nil {
 		return nil, err
 	}
@@ -317,7 +395,7 @@ type ChatLabel struct {
 }
 
 func (c *Client) ChatLabels(ctx context.Context, f *Filter) ([]*ChatLabel, error) {
-	req, err := c.newRequest(ctx, "chatlabels/added", f.Query())
+	req, err := c.newRequest(ctx, "chatlabels/added", f)
 	if err != nil {
 		return nil, err
 	}
@@ -327,20 +405,40 @@ func (c *Client) ChatLabels(ctx context.Context, f *Filter) ([]*ChatLabel, error
 		return nil, err
 	}
 
+	// The catalogue only holds the labels of the client's own bot.
+	if c.labels != nil && c.botID(ctx) == c.BotID {
+		// The labels are still useful with the texts reported by Sage.
+		if err := c.labels.Normalize(ctx, ret); err != nil {
+			c.logger.Log("msg", "normalizing label texts", "err", err)
+		}
+	}
+
 	return ret, nil
 }
 
-func (c *Client) newRequest(ctx context.Context, endpoint string, query url.Values) (*http.Request, error) {
-	if c.BaseURL == "" {
-		c.BaseURL = BaseURL
+// newRequest returns a request to endpoint for f, or the *ValidationError of
+// f without sending any.
+func (c *Client) newRequest(ctx context.Context, endpoint string, f *Filter) (*http.Request, error) {
+	if err := f.ValidateFor(endpoint); err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s/%s", c.BaseURL, c.BotID, endpoint), nil)
+	// The client is used concurrently, so the default is not stored.
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+
+	req, err := http.NewRequestWithContext(withEndpoint(ctx, endpoint), http.MethodGet, fmt.Sprintf("%s/%s/%s", baseURL, url.PathEscape(c.botID(ctx)), endpoint), nil)
 	if err != nil {
 		return nil, err
 	}
-	req.URL.RawQuery = query.Encode()
+	req.URL.RawQuery = f.Query().Encode()
 	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", kindly.UserAgent())
+	if id := RequestIDFromContext(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
 
 	return req, nil
 }
@@ -387,64 +485,149 @@ func isRetryable(err error) (bool, int) {
 	return false, 0
 }
 
+// withRetries calls fn until it succeeds, fails with an error that is not
+// retryable, or the context of r is done. Transient network errors are
+// retried up to maxNetworkRetries times with exponential backoff.
+func (c *Client) withRetries(r *http.Request, fn func() error) error {
+	networkRetries := 0
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		retryable, waitSeconds := isRetryable(err)
+		wait := time.Duration(waitSeconds) * time.Second
+		if !retryable && r.Context().Err() == nil && isTransient(err) && networkRetries < c.maxNetworkRetries() {
+			retryable, wait = true, networkRetryBase<<networkRetries
+			networkRetries++
+		}
+		if !retryable {
+			return err
+		}
+		kind := EventRetry
+		if e, ok := err.(*Error); ok && e.statusCode == http.StatusTooManyRequests {
+			kind = EventRateLimit
+		}
+		c.event(r.Context(), Event{Kind: kind, Attempt: attempt, Wait: wait, Err: err})
+		if t := ProgressFromContext(r.Context()); t != nil {
+			t.retried()
+		}
+		select {
+		case <-r.Context().Done():
+			return r.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
 func (c *Client) do(r *http.Request, v interface{}) error {
 	if c.doer == nil {
 		c.doer = http.DefaultClient
 	}
 
-	for {
-		body, err := c.execute(r)
-		if err != nil {
-			retryable, waitSeconds := isRetryable(err)
-			if !retryable {
-				return err
-			}
-			select {
-			case <-r.Context().Done():
-				return r.Context().Err()
-			case <-time.After(time.Duration(waitSeconds) * time.Second):
-				continue
-			}
-		}
+	var body io.Reader
+	err := c.withRetries(r, func() error {
+		var err error
+		body, err = c.execute(r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
 
-		w := responseWrapper{}
-		if err := json.NewDecoder(body).Decode(&w); err != nil {
-			return nil
-		}
+	w := responseWrapper{}
+	if err := json.NewDecoder(body).Decode(&w); err != nil {
+		return fmt.Errorf("statistics: %s: decoding response: %w", endpointFromContext(r.Context()), err)
+	}
 
-		if v == nil {
-			return nil
-		}
+	record(r, w.Data)
+	checkSchema(r.Context(), w.Data, v)
 
-		return json.Unmarshal(w.Data, &v)
+	if v == nil {
+		return nil
 	}
+	if c.strictDecoding {
+		dec := json.NewDecoder(bytes.NewReader(w.Data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			return fmt.Errorf("statistics: %s: %w", endpointFromContext(r.Context()), err)
+		}
+		return nil
+	}
+
+	return json.Unmarshal(w.Data, &v)
 }
 
 func (c *Client) execute(r *http.Request) (io.Reader, error) {
-	begin := time.Now()
+	endpoint := endpointFromContext(r.Context())
+	r, kept := c.conditional.prepare(r)
+	r, cancel, timedOut := c.withTimeout(r)
+	defer cancel()
 
-	resp, err := c.doer.Do(r)
+	resp, err := c.send(r)
 	if err != nil {
-		return nil, err
+		return nil, timedOut(err)
 	}
 	defer resp.Body.Close()
 
-	c.logger.Log("method", r.Method, "url", r.URL.String(), "code", resp.StatusCode, "took", time.Since(begin))
+	body, err := c.readResponse(endpoint, resp.Body)
+	if err != nil {
+		return nil, timedOut(err)
+	}
+
+	return bytes.NewReader(c.conditional.update(r, resp, body, kept)), nil
+}
+
+// send performs r and returns the response if its status is successful,
+// failing over to other base URLs if configured. The caller must close the
+// response body.
+func (c *Client) send(r *http.Request) (*http.Response, error) {
+	if c.failover != nil {
+		return c.failover.send(r, c.BaseURL, c, c.sendOnce)
+	}
+
+	return c.sendOnce(r)
+}
+
+func (c *Client) sendOnce(r *http.Request) (*http.Response, error) {
+	if b := callBudgetFromContext(r.Context()); b != nil {
+		if err := b.take(); err != nil {
+			return nil, err
+		}
+	}
+
+	begin := time.Now()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := c.doer.Do(r)
+	c.recordAudit(r, begin, resp, err)
 	if err != nil {
 		return nil, err
 	}
 
+	keyvals := []interface{}{"method", r.Method, "url", r.URL.String(), "bot", c.botID(r.Context()), "code", resp.StatusCode, "took", time.Since(begin)}
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		keyvals = append(keyvals, "request_id", id)
+	}
+	c.logger.Log(keyvals...)
+	c.recordQuota(resp)
+
 	if resp.StatusCode > 399 {
-		return nil, newResponseError(resp)
+		defer resp.Body.Close()
+		return nil, newResponseError(resp, c.responseLimit())
 	}
 
-	return bytes.NewReader(body), nil
+	return resp, nil
 }
 
-func newResponseError(resp *http.Response) error {
-	body, err := ioutil.ReadAll(resp.Body)
+// newResponseError returns the error of resp, with at most limit bytes of
+// its body unless limit is negative.
+func newResponseError(resp *http.Response, limit int64) error {
+	var r io.Reader = resp.Body
+	if limit >= 0 {
+		r = io.LimitReader(r, limit)
+	}
+	body, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
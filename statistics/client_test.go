@@ -3,14 +3,21 @@ package statistics_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/atb-as/kindly/cache"
 	"github.com/atb-as/kindly/statistics"
 )
 
@@ -63,11 +70,11 @@ type retryDoer struct {
 
 func (d *retryDoer) Do(r *http.Request) (*http.Response, error) {
 	d.n++
-	status := http.StatusTooManyRequests
+	status, body := http.StatusTooManyRequests, ""
 	if d.n > 2 {
-		status = http.StatusOK
+		status, body = http.StatusOK, `{"data":[]}`
 	}
-	return &http.Response{StatusCode: status, Header: http.Header{"Content-Length": []string{"3"}, "Retry-After": []string{"0"}}, Body: io.NopCloser(bytes.NewReader([]byte("")))}, nil
+	return &http.Response{StatusCode: status, Header: http.Header{"Content-Length": []string{"3"}, "Retry-After": []string{"0"}}, Body: io.NopCloser(strings.NewReader(body))}, nil
 }
 
 func TestClientDoer_Retries(t *testing.T) {
@@ -82,3 +89,861 @@ func TestClientDoer_Retries(t *testing.T) {
 		t.Errorf("expected doer to be called 3 times")
 	}
 }
+
+func TestClient_Progress(t *testing.T) {
+	var reports []statistics.ProgressStatus
+	tracker := statistics.NewProgressTracker(2, statistics.ProgressFunc(func(s statistics.ProgressStatus) {
+		reports = append(reports, s)
+	}))
+	client := statistics.NewClient(statistics.WithDoer(&retryDoer{}))
+
+	if _, err := client.UserMessages(statistics.WithProgress(context.Background(), tracker), nil); err != nil {
+		t.Fatalf("UserMessages() err=%v", err)
+	}
+	tracker.ChunkDone(10)
+
+	got := tracker.Status()
+	if got.Retries != 2 || got.Completed != 1 || got.Rows != 10 || len(reports) != 3 {
+		t.Errorf("got %+v after %d reports, want 2 retries, 1 chunk and 10 rows after 3", got, len(reports))
+	}
+	if eta := got.ETA(got.Started.Add(time.Minute)); eta != time.Minute {
+		t.Errorf("got ETA %s, want 1m0s", eta)
+	}
+}
+
+func TestClient_ResponseMeta(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[{"label_id":"1"},{"label_id":"2"}]}`))}, nil
+	})))
+
+	tests := []struct {
+		limit int
+		want  bool
+	}{
+		{0, false},
+		{2, true},
+		{3, false},
+	}
+	for _, tt := range tests {
+		meta := &statistics.ResponseMeta{}
+		ctx := statistics.WithResponseMeta(context.Background(), meta)
+		if _, err := c.ChatLabels(ctx, &statistics.Filter{Limit: tt.limit}); err != nil {
+			t.Fatalf("ChatLabels() err=%v", err)
+		}
+		if got := meta.Truncated(); got != tt.want {
+			t.Errorf("limit %d: got Truncated() %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestClient_ResponseMetaSeries(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[{"count":1},{"count":2}]}`))}, nil
+	})))
+
+	meta := &statistics.ResponseMeta{}
+	ctx := statistics.WithResponseMeta(context.Background(), meta)
+	if _, err := c.ChatSessions(ctx, &statistics.Filter{Limit: 2}); err != nil {
+		t.Fatalf("ChatSessions() err=%v", err)
+	}
+	if meta.Truncated() {
+		t.Errorf("expected series not to be reported as truncated")
+	}
+}
+
+func TestClient_RequestID(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if got := r.Header.Get("X-Request-ID"); got != "abc" {
+			t.Errorf("got X-Request-ID %q, want %q", got, "abc")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[]}`))}, nil
+	})))
+
+	ctx := statistics.WithRequestID(context.Background(), "abc")
+	if _, err := c.ChatSessions(ctx, nil); err != nil {
+		t.Errorf("ChatSessions() err=%v", err)
+	}
+}
+
+func TestClient_ForBot(t *testing.T) {
+	var paths []string
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		paths = append(paths, r.URL.Path)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[]}`))}, nil
+	})))
+	c.BotID = "1"
+
+	if _, err := c.ChatSessions(statistics.ForBot(context.Background(), "2"), nil); err != nil {
+		t.Fatalf("ChatSessions() err=%v", err)
+	}
+	if _, err := c.ChatSessions(context.Background(), nil); err != nil {
+		t.Fatalf("ChatSessions() err=%v", err)
+	}
+
+	want := []string{"/api/v1/stats/bot/2/sessions/chats", "/api/v1/stats/bot/1/sessions/chats"}
+	if len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("got paths %v, want %v", paths, want)
+	}
+}
+
+func TestClient_Sources(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if want := "/api/v1/stats/bot/1/sources"; r.URL.Path != want {
+			t.Errorf("got path %q, want %q", r.URL.Path, want)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":["web","whatsapp","app","facebook"]}`))}, nil
+	})))
+	c.BotID = "1"
+
+	got, err := c.Sources(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Sources() err=%v", err)
+	}
+	if want := []string{"app", "facebook", "web", "whatsapp"}; strings.Join(statistics.SourceStrings(got), ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestClient_DeviceStatistics(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if want := "/api/v1/stats/bot/1/chatbubble/devices"; r.URL.Path != want {
+			t.Errorf("got path %q, want %q", r.URL.Path, want)
+		}
+		body := `{"data":[{"device":"desktop","platform":"web","sessions":4},{"device":"mobile","platform":"ios","sessions":5},{"device":"mobile","platform":"web","sessions":3}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "1"
+
+	got, err := c.DeviceStatistics(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("DeviceStatistics() err=%v", err)
+	}
+	if len(got) != 3 || got[0].Platform != "ios" || got[0].Sessions != 5 {
+		t.Errorf("got first %+v, want mobile ios with 5 sessions", got[0])
+	}
+	if byDevice := statistics.SessionsByDevice(got); byDevice["mobile"] != 8 || byDevice["desktop"] != 4 {
+		t.Errorf("got %v, want 8 mobile and 4 desktop", byDevice)
+	}
+}
+
+func TestClient_HandoverQueueTimeSeries(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if want := "/api/v1/stats/bot/1/takeovers/queue/series"; r.URL.Path != want {
+			t.Errorf("got path %q, want %q", r.URL.Path, want)
+		}
+		body := `{"data":[{"date":"2021-02-01T00:00:00.000000","requests":8,"joined":6,"abandoned":2,"max_depth":3,"average_wait":90.5,"median_wait":"PT1M","max_wait":"300"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "1"
+
+	got, err := c.HandoverQueueTimeSeries(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("HandoverQueueTimeSeries() err=%v", err)
+	}
+	if len(got) != 1 || got[0].Date.Day() != 1 || got[0].Abandoned != 2 || got[0].MaxDepth != 3 {
+		t.Fatalf("got %+v, want 2 abandoned of 8 on 2021-02-01", got)
+	}
+	if rate := got[0].AbandonRate(); rate != 0.25 {
+		t.Errorf("got abandon rate %v, want 0.25", rate)
+	}
+	if wait := got[0].Wait(); wait != 90500*time.Millisecond {
+		t.Errorf("got wait %v, want 1m30.5s", wait)
+	}
+	if got[0].MedianWait.Duration != time.Minute || got[0].MaxWait.Duration != 5*time.Minute {
+		t.Errorf("got median wait %v and max wait %v, want 1m0s and 5m0s", got[0].MedianWait, got[0].MaxWait)
+	}
+}
+
+func TestClient_NetworkRetries(t *testing.T) {
+	calls := 0
+	doer := doerFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, &url.Error{Op: "Get", URL: r.URL.String(), Err: io.ErrUnexpectedEOF}
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[]}`))}, nil
+	})
+
+	c := statistics.NewClient(statistics.WithDoer(doer), statistics.WithNetworkRetries(1))
+	if _, err := c.ChatSessions(context.Background(), nil); err != nil {
+		t.Fatalf("ChatSessions() err=%v, want success after a retry", err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+
+	calls = 0
+	c = statistics.NewClient(statistics.WithDoer(doer), statistics.WithNetworkRetries(0))
+	if _, err := c.ChatSessions(context.Background(), nil); err == nil {
+		t.Error("ChatSessions() err=nil with retries disabled, want error")
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls with retries disabled, want 1", calls)
+	}
+}
+
+func TestClient_PageStatisticsSeries(t *testing.T) {
+	var limits []string
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query()
+		limits = append(limits, q.Get("limit"))
+		body := `{"data":[{"web_host":"atb.no","web_path":"/faq","sessions":5,"messages":9},{"web_host":"atb.no","web_path":"/","sessions":3,"messages":4}]}`
+		if q.Get("from") == "2021-02-02" {
+			body = `{"data":[{"web_host":"atb.no","web_path":"/other","sessions":7,"messages":7},{"web_host":"atb.no","web_path":"/faq","sessions":1,"messages":2}]}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "1"
+
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	got, err := c.PageStatisticsSeries(context.Background(), &statistics.Filter{From: from, To: from.AddDate(0, 0, 2), Limit: 2})
+	if err != nil {
+		t.Fatalf("PageStatisticsSeries() err=%v", err)
+	}
+
+	if want := []string{"2", "50", "50"}; strings.Join(limits, ",") != strings.Join(want, ",") {
+		t.Errorf("got limits %v, want %v", limits, want)
+	}
+	if len(got) != 2 || got[0].Path != "/faq" || got[1].Path != "/" {
+		t.Fatalf("got %+v, want /faq and /", got)
+	}
+	faq, root := got[0].Points, got[1].Points
+	if len(faq) != 2 || faq[1].Sessions != 1 || faq[1].Messages != 2 || !faq[1].Date.Equal(from.AddDate(0, 0, 1)) {
+		t.Errorf("got /faq points %+v %+v", faq[0], faq[1])
+	}
+	if len(root) != 2 || root[0].Sessions != 3 || root[1].Sessions != 0 {
+		t.Errorf("got / points %+v %+v, want 3 and 0 sessions", root[0], root[1])
+	}
+}
+
+func TestClient_ChatLabelsSeriesAll(t *testing.T) {
+	var mu sync.Mutex
+	var limits []string
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query()
+		mu.Lock()
+		limits = append(limits, q.Get("limit"))
+		mu.Unlock()
+		body := `{"data":[{"label_id":"a","label_text":"A","count":2},{"label_id":"b","label_text":"B","count":1}]}`
+		if q.Get("from") == "2021-02-02" {
+			body = `{"data":[{"label_id":"b","label_text":"B2","count":4}]}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	c.BotID = "1"
+
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	f := &statistics.Filter{From: from, To: from.AddDate(0, 0, 2), Limit: 10}
+	got, err := c.ChatLabelsSeriesAll(context.Background(), f)
+	if err != nil {
+		t.Fatalf("ChatLabelsSeriesAll() err=%v", err)
+	}
+
+	if want := []string{"100", "100"}; strings.Join(limits, ",") != strings.Join(want, ",") {
+		t.Errorf("got limits %v, want %v", limits, want)
+	}
+	if len(got) != 2 || got[0].ID != "b" || got[0].Text != "B2" || got[0].Total() != 5 || got[1].ID != "a" {
+		t.Fatalf("got %+v %+v, want b then a", got[0], got[1])
+	}
+	if a := got[1].Points; len(a) != 2 || a[0].Count != 2 || a[1].Count != 0 || !a[1].Date.Equal(from.AddDate(0, 0, 1)) {
+		t.Errorf("got a points %+v %+v, want 2 and 0", a[0], a[1])
+	}
+
+	s, err := c.ChatLabelSeries(context.Background(), "missing", f)
+	if err != nil {
+		t.Fatalf("ChatLabelSeries() err=%v", err)
+	}
+	if len(s.Points) != 2 || s.Total() != 0 {
+		t.Errorf("got %+v, want two empty points", s)
+	}
+}
+
+func TestChatLabelsByChunk(t *testing.T) {
+	src := labelsFunc(func(ctx context.Context, f *statistics.Filter) ([]*statistics.ChatLabel, error) {
+		if f.Sources[0] == "facebook" && f.From.Day() == 2 {
+			return nil, errors.New("boom")
+		}
+		return []*statistics.ChatLabel{{ID: f.Sources[0].String(), Count: f.From.Day()}}, nil
+	})
+
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	chunks := statistics.ChatLabelsByChunk(context.Background(), src, &statistics.Filter{
+		From:    from,
+		To:      from.AddDate(0, 0, 2),
+		Sources: []statistics.Source{statistics.Web, statistics.Facebook},
+	}, true)
+
+	var got []string
+	for _, c := range chunks {
+		if c.Err != nil {
+			got = append(got, c.Filter.Sources[0].String()+":err")
+			continue
+		}
+		got = append(got, fmt.Sprintf("%s:%d", c.Labels[0].ID, c.Labels[0].Count))
+	}
+	if want := "web:1 facebook:1 web:2 facebook:err"; strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+type labelsFunc func(ctx context.Context, f *statistics.Filter) ([]*statistics.ChatLabel, error)
+
+func (f labelsFunc) ChatLabels(ctx context.Context, filter *statistics.Filter) ([]*statistics.ChatLabel, error) {
+	return f(ctx, filter)
+}
+
+type auditFunc func(ctx context.Context, e *statistics.AuditEntry) error
+
+func (f auditFunc) Audit(ctx context.Context, e *statistics.AuditEntry) error {
+	return f(ctx, e)
+}
+
+func TestClient_Audit(t *testing.T) {
+	var entries []*statistics.AuditEntry
+	sink := auditFunc(func(ctx context.Context, e *statistics.AuditEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	c := statistics.NewClient(statistics.WithAuditSink(sink), statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(``))}, nil
+	})))
+	c.BotID = "1"
+
+	ctx := statistics.WithRequestID(statistics.WithCaller(context.Background(), "alice"), "req")
+	if _, err := c.ChatSessions(ctx, &statistics.Filter{Sources: []statistics.Source{statistics.Web}}); err == nil {
+		t.Fatal("ChatSessions() err=nil, want error")
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Caller != "alice" || e.RequestID != "req" || e.BotID != "1" || e.Method != http.MethodGet || e.Endpoint != "sessions/chats" || e.Status != http.StatusForbidden {
+		t.Errorf("got %+v", e)
+	}
+	if got := e.Filter.Get("sources[]"); got != "web" {
+		t.Errorf("got sources %q, want web", got)
+	}
+}
+
+func TestFilter_Chunks(t *testing.T) {
+	f := &statistics.Filter{
+		From:    time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2021, 2, 10, 0, 0, 0, 0, time.UTC),
+		Sources: []statistics.Source{statistics.Web},
+	}
+
+	if got := f.Chunks(statistics.Day); len(got) != 9 {
+		t.Errorf("got %d daily chunks, want 9", len(got))
+	}
+
+	got := f.Chunks(statistics.Week)
+	if len(got) != 2 {
+		t.Fatalf("got %d weekly chunks, want 2", len(got))
+	}
+	if want := time.Date(2021, 2, 8, 0, 0, 0, 0, time.UTC); !got[0].To.Equal(want) || !got[1].From.Equal(want) {
+		t.Errorf("got first chunk ending %s and second starting %s, want %s", got[0].To, got[1].From, want)
+	}
+	if !got[1].To.Equal(f.To) {
+		t.Errorf("got last chunk ending %s, want %s", got[1].To, f.To)
+	}
+	if got[1].Sources[0] != "web" {
+		t.Errorf("got Sources %v, want them copied", got[1].Sources)
+	}
+}
+
+func TestClient_Broadcasts(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		switch strings.TrimPrefix(r.URL.EscapedPath(), "/api/v1/stats/bot") {
+		case "/123/broadcasts/summary":
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[{"broadcast_id":"b1","broadcast_title":"Spring sale","sent":100,"delivered":90,"opened":40,"replied":5}]}`))}, nil
+		case "/123/broadcasts/b%2F1/series":
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[{"date":"2021-02-01T00:00:00.000000","sent":100,"delivered":90,"opened":30,"replied":4}]}`))}, nil
+		}
+		t.Errorf("unexpected path %q", r.URL.EscapedPath())
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})))
+	c.BotID = "123"
+
+	broadcasts, err := c.Broadcasts(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("c.Broadcasts() err=%v", err)
+	}
+	if len(broadcasts) != 1 || broadcasts[0].ID != "b1" || broadcasts[0].Delivered != 90 || broadcasts[0].Replied != 5 {
+		t.Errorf("got %+v, want broadcast b1 with 90 delivered and 5 replied", broadcasts[0])
+	}
+
+	series, err := c.BroadcastTimeSeries(context.Background(), "b/1", nil)
+	if err != nil {
+		t.Fatalf("c.BroadcastTimeSeries() err=%v", err)
+	}
+	if len(series) != 1 || series[0].Opened != 30 || series[0].Date.Day() != 1 {
+		t.Errorf("got %+v, want one day with 30 opened", series[0])
+	}
+}
+
+func TestClient_CallBudget(t *testing.T) {
+	calls := 0
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[]}`))}, nil
+	})))
+
+	b := statistics.NewCallBudget(2)
+	ctx := statistics.WithCallBudget(context.Background(), b)
+	for i := 0; i < 2; i++ {
+		if _, err := c.ChatSessions(ctx, nil); err != nil {
+			t.Fatalf("c.ChatSessions() err=%v", err)
+		}
+	}
+	if _, err := c.ChatSessions(ctx, nil); !errors.Is(err, statistics.ErrCallBudgetExceeded) {
+		t.Errorf("got err=%v, want ErrCallBudgetExceeded", err)
+	}
+	if calls != 2 || b.Calls() != 2 {
+		t.Errorf("got %d calls, %d accounted, want 2", calls, b.Calls())
+	}
+}
+
+func TestClient_FallbackRateByLanguage(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"data":{"count":10,"rate":0.1}}`
+		if got := r.URL.Query()["language_codes[]"]; len(got) != 1 {
+			t.Errorf("got language codes %v, want one", got)
+		} else if got[0] == "nn" {
+			body = `{"data":{"count":30,"rate":0.3}}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+
+	got, err := c.FallbackRateByLanguage(context.Background(), &statistics.Filter{LanguageCodes: []string{"nb", "nn"}})
+	if err != nil {
+		t.Fatalf("c.FallbackRateByLanguage() err=%v", err)
+	}
+	if len(got) != 2 || got[0].LanguageCode != "nb" || got[0].Rate != 0.1 || got[1].LanguageCode != "nn" || got[1].Rate != 0.3 {
+		t.Errorf("got %+v %+v, want nb at 0.1 and nn at 0.3", got[0], got[1])
+	}
+
+	if _, err := c.FallbackRateByLanguage(context.Background(), &statistics.Filter{}); err == nil {
+		t.Errorf("expected err for filter without language codes")
+	}
+}
+
+func TestClient_QuotaStatus(t *testing.T) {
+	calls := 0
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		h := http.Header{}
+		h.Set("X-RateLimit-Limit", "1000")
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(1000-calls))
+		h.Set("X-RateLimit-Reset", "3600")
+		return &http.Response{StatusCode: http.StatusOK, Header: h, Body: io.NopCloser(strings.NewReader(`{"data":{"count":10,"rate":0.1}}`))}, nil
+	})))
+
+	q, err := c.QuotaStatus(context.Background())
+	if err != nil {
+		t.Fatalf("c.QuotaStatus() err=%v", err)
+	}
+	if q.Limit != 1000 || q.Remaining != 999 || calls != 1 {
+		t.Errorf("got %+v after %d calls, want 999 of 1000 remaining after 1 call", q, calls)
+	}
+	if !q.Covers(999) || q.Covers(1000) {
+		t.Errorf("got Covers(999)=%v Covers(1000)=%v, want true and false", q.Covers(999), q.Covers(1000))
+	}
+	if got := q.Pace(q.Observed); got < 3*time.Second || got > 4*time.Second {
+		t.Errorf("got Pace() %v, want about 3.6s", got)
+	}
+
+	if _, err := c.QuotaStatus(context.Background()); err != nil || calls != 1 {
+		t.Errorf("got err=%v after %d calls, want the recorded quota without another call", err, calls)
+	}
+}
+
+func TestClient_QuotaStatusUnavailable(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":{"count":10,"rate":0.1}}`))}, nil
+	})))
+
+	if _, err := c.QuotaStatus(context.Background()); err != statistics.ErrQuotaUnavailable {
+		t.Errorf("got err=%v, want ErrQuotaUnavailable", err)
+	}
+}
+
+func TestClient_LabelCatalogue(t *testing.T) {
+	fetches := 0
+	cat := statistics.NewLabelCatalogue(func(ctx context.Context) (map[string]string, error) {
+		fetches++
+		return map[string]string{"1": "Refund"}, nil
+	}, time.Hour)
+	c := statistics.NewClient(statistics.WithLabelCatalogue(cat), statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"data":[{"label_id":"1","label_text":"refund ","count":3},{"label_id":"2","label_text":"Deleted","count":1}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+
+	for i := 0; i < 2; i++ {
+		labels, err := c.ChatLabels(context.Background(), &statistics.Filter{})
+		if err != nil {
+			t.Fatalf("ChatLabels() err=%v", err)
+		}
+		if labels[0].Text != "Refund" || labels[1].Text != "Deleted" {
+			t.Errorf("got texts %q and %q, want Refund and Deleted", labels[0].Text, labels[1].Text)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("got %d fetches, want 1 within the refresh interval", fetches)
+	}
+
+	if got := cat.History("1"); len(got) != 2 || got[0] != "Refund" || got[1] != "refund " {
+		t.Errorf("got history %q, want [Refund refund ]", got)
+	}
+}
+
+func TestClient_Failover(t *testing.T) {
+	primaryDown := true
+	var hosts []string
+	c := statistics.NewClient(
+		statistics.WithFailover([]string{"https://eu.example.com/api/v1/stats/bot", "https://us.example.com/api/v1/stats/bot"}, 2, time.Hour),
+		statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+			hosts = append(hosts, r.URL.Host)
+			if r.URL.Host == "eu.example.com" && primaryDown {
+				return nil, errors.New("connection refused")
+			}
+			if want := "/api/v1/stats/bot/123/sessions/chats"; r.URL.Path != want {
+				t.Errorf("got path %q, want %q", r.URL.Path, want)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[]}`))}, nil
+		})))
+	c.BotID = "123"
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.ChatSessions(context.Background(), &statistics.Filter{}); err != nil {
+			t.Fatalf("ChatSessions() err=%v", err)
+		}
+	}
+
+	// The primary is tried until it has failed twice, then skipped.
+	want := []string{"eu.example.com", "us.example.com", "eu.example.com", "us.example.com", "us.example.com"}
+	if fmt.Sprint(hosts) != fmt.Sprint(want) {
+		t.Errorf("got hosts %v, want %v", hosts, want)
+	}
+
+	status := c.Endpoints()
+	if status[0].State != statistics.CircuitOpen || status[0].Failures != 2 || status[1].State != statistics.CircuitClosed {
+		t.Errorf("got status %+v, want the primary open and the secondary closed", status)
+	}
+}
+
+func TestClient_FailoverClientError(t *testing.T) {
+	calls := 0
+	c := statistics.NewClient(
+		statistics.WithFailover([]string{"https://eu.example.com", "https://us.example.com"}, 1, time.Hour),
+		statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		})))
+
+	if _, err := c.ChatSessions(context.Background(), &statistics.Filter{}); err == nil {
+		t.Fatal("ChatSessions() err=nil, want error")
+	}
+	if calls != 1 || c.Endpoints()[0].State != statistics.CircuitClosed {
+		t.Errorf("got %d calls and status %+v, want no failover for a client error", calls, c.Endpoints())
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	var events []statistics.Event
+	rateLimited := true
+	c := statistics.NewClient(
+		statistics.WithFailover([]string{"https://eu.example.com", "https://us.example.com"}, 1, time.Hour),
+		statistics.WithEventHook(func(e statistics.Event) { events = append(events, e) }),
+		statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+			switch {
+			case r.URL.Host == "eu.example.com":
+				return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+			case rateLimited:
+				rateLimited = false
+				return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0"}}, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[]}`))}, nil
+		})))
+	c.BotID = "123"
+
+	ctx := statistics.WithRequestID(context.Background(), "req-1")
+	if _, err := c.ChatSessions(ctx, &statistics.Filter{}); err != nil {
+		t.Fatalf("ChatSessions() err=%v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("got events %+v, want the circuit opening and a rate limit wait", events)
+	}
+	if e := events[0]; e.Kind != statistics.EventCircuit || e.BaseURL != "https://eu.example.com" || e.From != statistics.CircuitClosed || e.To != statistics.CircuitOpen || e.Err == nil {
+		t.Errorf("got %+v, want the circuit of eu opened", e)
+	}
+	if e := events[1]; e.Kind != statistics.EventRateLimit || e.Attempt != 1 || e.Wait != 0 || e.Endpoint != "sessions/chats" || e.BotID != "123" || e.RequestID != "req-1" {
+		t.Errorf("got %+v, want the first attempt waiting out a 429", e)
+	}
+}
+
+func TestWorkspaceClient(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+
+		var body string
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/sessions/chats"):
+			body = `{"data":[{"date":"2021-02-01T00:00:00.000000","count":2},{"date":"2021-02-02T00:00:00.000000","count":3}]}`
+			if strings.Contains(r.URL.Path, "/bot/2/") {
+				body = `{"data":[{"date":"2021-02-02T00:00:00.000000","count":5}]}`
+			}
+		case strings.HasSuffix(r.URL.Path, "/fallbacks/total"):
+			body = `{"data":{"count":10,"rate":0.1}}`
+			if strings.Contains(r.URL.Path, "/bot/2/") {
+				body = `{"data":{"count":30,"rate":0.3}}`
+			}
+		default:
+			t.Errorf("unexpected path %q", r.URL.Path)
+			body = `{"data":[]}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	w := statistics.NewWorkspaceClient(statistics.BotClients(c, "1", "2"), statistics.WithWorkspaceCache(cache.NewMemory(), time.Minute))
+
+	for i := 0; i < 2; i++ {
+		sessions, err := w.ChatSessions(context.Background(), &statistics.Filter{})
+		if err != nil {
+			t.Fatalf("ChatSessions() err=%v", err)
+		}
+		if len(sessions) != 2 || sessions[0].Count != 2 || sessions[1].Count != 8 || sessions[1].Date.Day() != 2 {
+			t.Errorf("got sessions %v, want 2 and 8", sessions)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want one per bot and the second query cached", calls)
+	}
+
+	rate, err := w.FallbackRateTotal(context.Background(), &statistics.Filter{})
+	if err != nil {
+		t.Fatalf("FallbackRateTotal() err=%v", err)
+	}
+	// 40 fallbacks of 100 + 100 replies.
+	if rate.Count != 40 || rate.Rate != 0.2 {
+		t.Errorf("got %+v, want 40 fallbacks at 0.2", rate)
+	}
+}
+
+func TestMultiClient_Counts(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if strings.Contains(r.URL.Path, "/bot/3/") {
+			return &http.Response{StatusCode: http.StatusForbidden, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}
+		body := `{"data":[{"date":"2021-02-01T00:00:00.000000","count":2},{"date":"2021-02-02T00:00:00.000000","count":3}]}`
+		if strings.Contains(r.URL.Path, "/bot/2/") {
+			body = `{"data":[{"date":"2021-02-02T00:00:00.000000","count":5}]}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+	m := statistics.NewMultiClient(statistics.BotClients(c, "3", "1", "2"))
+
+	bots := m.Counts(context.Background(), func(ctx context.Context, s statistics.Service) ([]*statistics.CountByDate, error) {
+		return s.ChatSessions(ctx, &statistics.Filter{})
+	})
+	if len(bots) != 3 || bots[0].BotID != "1" || bots[0].Total() != 5 || bots[1].Total() != 5 {
+		t.Errorf("got %+v, want bots 1 and 2 with 5 sessions each", bots)
+	}
+	if bots[2].BotID != "3" || bots[2].Err == nil {
+		t.Errorf("got %+v, want bot 3 failed", bots[2])
+	}
+}
+
+func TestClient_MaxResponseSize(t *testing.T) {
+	body := `{"data":[{"date":"2021-02-01T00:00:00.000000","count":2}]}`
+	doer := doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	c := statistics.NewClient(statistics.WithDoer(doer), statistics.WithMaxResponseSize(int64(len(body)-1)))
+	if _, err := c.ChatSessions(context.Background(), &statistics.Filter{}); !errors.Is(err, statistics.ErrResponseTooLarge) {
+		t.Errorf("ChatSessions() err=%v, want ErrResponseTooLarge", err)
+	}
+
+	c = statistics.NewClient(statistics.WithDoer(doer), statistics.WithMaxResponseSize(int64(len(body))))
+	if sessions, err := c.ChatSessions(context.Background(), &statistics.Filter{}); err != nil || len(sessions) != 1 {
+		t.Errorf("ChatSessions() got %v, err=%v, want one session", sessions, err)
+	}
+}
+
+func TestClient_EndpointTimeout(t *testing.T) {
+	c := statistics.NewClient(
+		statistics.WithTimeout(time.Hour),
+		statistics.WithEndpointTimeout("sessions/chats", 10*time.Millisecond),
+		statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(r.URL.Path, "/messages") {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[]}`))}, nil
+			}
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		})))
+
+	if _, err := c.ChatSessions(context.Background(), &statistics.Filter{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ChatSessions() err=%v, want a deadline exceeded", err)
+	}
+	if _, err := c.UserMessages(context.Background(), &statistics.Filter{}); err != nil {
+		t.Errorf("UserMessages() err=%v", err)
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	for k, v := range map[string]string{"KINDLY_BOT_ID": "1", "KINDLY_API_KEY": "key", "KINDLY_CREDENTIALS": "", "KINDLY_BASE_URL": "https://stats.example.com/bot"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+
+	var url string
+	c, err := statistics.NewClientFromEnv(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		url = r.URL.String()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[]}`))}, nil
+	})))
+	if err != nil {
+		t.Fatalf("NewClientFromEnv() err=%v", err)
+	}
+	if _, err := c.ChatSessions(context.Background(), nil); err != nil {
+		t.Fatalf("ChatSessions() err=%v", err)
+	}
+	if want := "https://stats.example.com/bot/1/sessions/chats"; url != want {
+		t.Errorf("got url %q, want %q", url, want)
+	}
+
+	os.Setenv("KINDLY_BOT_ID", "")
+	if _, err := statistics.NewClientFromEnv(); err == nil {
+		t.Errorf("expected err without KINDLY_BOT_ID, got nil")
+	}
+}
+
+func TestClient_StrictDecoding(t *testing.T) {
+	c := statistics.NewClient(statistics.WithStrictDecoding(), statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[{"count":1,"date":"2021-01-01T00:00:00.000000","unique_count":1}]}`))}, nil
+	})))
+
+	if _, err := c.ChatSessions(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "unique_count") {
+		t.Errorf("got err=%v, want unknown field unique_count", err)
+	}
+}
+
+func TestClient_MalformedResponse(t *testing.T) {
+	for _, body := range []string{``, `{"data":[{"count":1,"date":"2021-01-01T0`, `<html>`} {
+		c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		})))
+
+		if _, err := c.ChatSessions(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "sessions/chats: decoding response") {
+			t.Errorf("body %q: got err=%v, want a decoding error naming the endpoint", body, err)
+		}
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"data":[]}`
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/sessions/chats"):
+			body = `{"data":[{"count":1,"date":"2021-01-01T00:00:00.000000","unique_count":1},{"count":2,"date":"2021-01-02T00:00:00.000000"}]}`
+		case strings.HasSuffix(r.URL.Path, "/fallbacks/total"):
+			body = `{"data":{"fallback_count":1,"rate":0.1}}`
+		case strings.HasSuffix(r.URL.Path, "/feedback/summary"):
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})), statistics.WithNetworkRetries(0))
+
+	drift, err := statistics.CheckSchema(context.Background(), c, &statistics.Filter{})
+	if err == nil {
+		t.Errorf("got err=nil, want the error of feedback/summary")
+	}
+
+	got := make(map[string]string)
+	for _, d := range drift {
+		got[d.Endpoint] = fmt.Sprintf("unknown=%v missing=%v", d.Unknown, d.Missing)
+	}
+	want := map[string]string{
+		"sessions/chats":  "unknown=[[].unique_count] missing=[]",
+		"fallbacks/total": "unknown=[fallback_count] missing=[count]",
+	}
+	if len(got) != len(want) || got["sessions/chats"] != want["sessions/chats"] || got["fallbacks/total"] != want["fallbacks/total"] {
+		t.Errorf("got drift %v, want %v", got, want)
+	}
+}
+
+func TestFilter_Validate(t *testing.T) {
+	day := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	f := &statistics.Filter{From: day.AddDate(0, 0, 2), To: day, Timezone: "Europe/Nowhere", Limit: -1, Granularity: statistics.Granularity(9), Sources: []statistics.Source{statistics.Web, "Web "}}
+
+	var verr *statistics.ValidationError
+	if err := f.Validate(); !errors.As(err, &verr) || len(verr.Problems) != 5 {
+		t.Fatalf("got err=%v, want 5 problems", err)
+	}
+
+	if err := (&statistics.Filter{From: day, To: day.AddDate(0, 0, 1), Granularity: statistics.Hour}).Validate(); err != nil {
+		t.Errorf("got err=%v for a valid filter", err)
+	}
+	if err := (*statistics.Filter)(nil).Validate(); err != nil {
+		t.Errorf("got err=%v for a nil filter", err)
+	}
+}
+
+func TestClient_InvalidFilter(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request %s", r.URL)
+		return nil, errors.New("unexpected request")
+	})))
+
+	_, err := c.FallbackRateTimeSeries(context.Background(), &statistics.Filter{Granularity: statistics.Hour})
+	var verr *statistics.ValidationError
+	if !errors.As(err, &verr) || verr.Endpoint != "fallbacks/series" {
+		t.Errorf("got err=%v, want a validation error for fallbacks/series", err)
+	}
+}
+
+func TestClient_ConditionalRequests(t *testing.T) {
+	var conditional []string
+	validators := true
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		conditional = append(conditional, r.Header.Get("If-None-Match")+"|"+r.Header.Get("If-Modified-Since"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			return &http.Response{StatusCode: http.StatusNotModified, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{"data":[{"date":"2021-02-01T00:00:00.000000","count":2}]}`))}
+		if validators {
+			resp.Header.Set("ETag", `"v1"`)
+			resp.Header.Set("Last-Modified", "Mon, 01 Feb 2021 00:00:00 GMT")
+		}
+		return resp, nil
+	})), statistics.WithConditionalRequests(cache.NewMemory(), time.Hour))
+
+	for i := 0; i < 2; i++ {
+		sessions, err := c.ChatSessions(context.Background(), &statistics.Filter{})
+		if err != nil {
+			t.Fatalf("ChatSessions() err=%v", err)
+		}
+		if len(sessions) != 1 || sessions[0].Count != 2 {
+			t.Errorf("call %d: got sessions %v, want the kept body", i, sessions)
+		}
+	}
+	if want := []string{"|", `"v1"|Mon, 01 Feb 2021 00:00:00 GMT`}; !reflect.DeepEqual(conditional, want) {
+		t.Errorf("got conditional headers %q, want %q", conditional, want)
+	}
+
+	// An upstream without validators gets plain requests.
+	validators = false
+	conditional = nil
+	for i := 0; i < 2; i++ {
+		if _, err := c.ChatSessions(context.Background(), &statistics.Filter{Limit: 1}); err != nil {
+			t.Fatalf("ChatSessions() err=%v", err)
+		}
+	}
+	if want := []string{"|", "|"}; !reflect.DeepEqual(conditional, want) {
+		t.Errorf("got conditional headers %q, want %q", conditional, want)
+	}
+}
@@ -0,0 +1,87 @@
+package statistics
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/atb-as/kindly/cache"
+)
+
+// WithConditionalRequests keeps the body of successful responses with an
+// ETag or Last-Modified header in c for ttl, and sends later requests to the
+// same URL with If-None-Match and If-Modified-Since. A 304 Not Modified
+// response is answered with the kept body, so pollers refreshing the same
+// range only transfer it when it changed. Responses without validators are
+// not kept, so an upstream that ignores conditional requests only costs a
+// cache lookup per call. Cache failures only cost the cache.
+func WithConditionalRequests(c cache.Cache, ttl time.Duration) ClientOption {
+	return func(cl *Client) {
+		cl.conditional = &conditional{cache: c, ttl: ttl}
+	}
+}
+
+// conditional keeps responses to revalidate.
+type conditional struct {
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// validated is a response kept to revalidate, with its validators.
+type validated struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+func (c *conditional) key(r *http.Request) string {
+	return "statistics|conditional|" + r.URL.String()
+}
+
+// prepare returns r with the validators of the response kept for its URL,
+// and that response, or r and nil if none is kept.
+func (c *conditional) prepare(r *http.Request) (*http.Request, *validated) {
+	if c == nil {
+		return r, nil
+	}
+	b, ok, err := c.cache.Get(r.Context(), c.key(r))
+	if err != nil || !ok {
+		return r, nil
+	}
+	var v validated
+	if err := json.Unmarshal(b, &v); err != nil {
+		return r, nil
+	}
+
+	r = r.Clone(r.Context())
+	if v.ETag != "" {
+		r.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		r.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	return r, &v
+}
+
+// update returns the body of the response to r: the kept body if resp is a
+// 304 Not Modified to a request with validators, and otherwise body, which
+// is kept if resp has validators.
+func (c *conditional) update(r *http.Request, resp *http.Response, body []byte, kept *validated) []byte {
+	if c == nil {
+		return body
+	}
+	if resp.StatusCode == http.StatusNotModified && kept != nil {
+		return kept.Body
+	}
+
+	v := validated{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified"), Body: body}
+	if v.ETag == "" && v.LastModified == "" {
+		return body
+	}
+	if b, err := json.Marshal(&v); err == nil {
+		c.cache.Set(r.Context(), c.key(r), b, c.ttl)
+	}
+
+	return body
+}
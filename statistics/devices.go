@@ -0,0 +1,45 @@
+package statistics
+
+import (
+	"context"
+	"sort"
+)
+
+// DeviceStatistic is the number of chat sessions started from one device
+// class and platform.
+type DeviceStatistic struct {
+	// Device is the device class, such as "mobile", "tablet" or "desktop".
+	Device string `json:"device"`
+	// Platform is the platform of the chat bubble, such as "ios",
+	// "android" or "web".
+	Platform string `json:"platform"`
+	Sessions int    `json:"sessions"`
+}
+
+// DeviceStatistics returns the chat bubble sessions in the period of f per
+// device class and platform, with the most sessions first. Where Sage does
+// not expose the breakdown, it fails with an *Error with status 404.
+func (c *Client) DeviceStatistics(ctx context.Context, f *Filter) ([]*DeviceStatistic, error) {
+	req, err := c.newRequest(ctx, "chatbubble/devices", f)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*DeviceStatistic, 0)
+	if err := c.do(req, &ret); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Sessions > ret[j].Sessions })
+
+	return ret, nil
+}
+
+// SessionsByDevice sums the sessions of stats per device class.
+func SessionsByDevice(stats []*DeviceStatistic) map[string]int {
+	ret := make(map[string]int)
+	for _, s := range stats {
+		ret[s.Device] += s.Sessions
+	}
+
+	return ret
+}
@@ -0,0 +1,42 @@
+package statistics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/atb-as/kindly/statistics/auth"
+	"golang.org/x/oauth2"
+)
+
+// DefaultTimeout is the time limit of requests of a client returned by
+// NewClientFromEnv, including token requests.
+const DefaultTimeout = 30 * time.Second
+
+// NewClientFromEnv returns a client for the bot in KINDLY_BOT_ID, fetching
+// tokens with the credentials of auth.FromEnv and calling KINDLY_BASE_URL if
+// set. Requests are limited to DefaultTimeout and transient errors are
+// retried as by NewClient; opts are applied last, so they can change both.
+func NewClientFromEnv(opts ...ClientOption) (*Client, error) {
+	botID := os.Getenv("KINDLY_BOT_ID")
+	if botID == "" {
+		return nil, fmt.Errorf("statistics: KINDLY_BOT_ID is not set")
+	}
+	creds, err := auth.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	doer := oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, &auth.TokenSource{
+		Credentials: creds,
+		BotID:       botID,
+		Client:      &http.Client{Timeout: DefaultTimeout},
+	}))
+	c := NewClient(append([]ClientOption{WithDoer(doer), WithTimeout(DefaultTimeout)}, opts...)...)
+	c.BotID = botID
+	c.BaseURL = os.Getenv("KINDLY_BASE_URL")
+
+	return c, nil
+}
@@ -0,0 +1,78 @@
+package statistics
+
+import (
+	"context"
+	"time"
+)
+
+// EventKind is the kind of an Event.
+type EventKind string
+
+const (
+	// EventRetry is a call retried after a 503 response or a transient
+	// network error.
+	EventRetry EventKind = "retry"
+	// EventRateLimit is a call retried after waiting out a 429 response.
+	EventRateLimit EventKind = "rate_limit"
+	// EventCircuit is a change of the state of the circuit breaker of a base
+	// URL configured with WithFailover.
+	EventCircuit EventKind = "circuit"
+)
+
+// Event is something the client did besides making a call that explains
+// slow calls, such as waiting to retry one. Events are logged with the
+// Logger of the client and passed to its EventHook, if any.
+type Event struct {
+	Kind      EventKind
+	Endpoint  string
+	BotID     string
+	RequestID string
+	// Attempt is the number of the retry of the call, starting at 1.
+	Attempt int
+	// Wait is how long the client waits before retrying.
+	Wait time.Duration
+	// Err is the error of the call that is retried, or that changed the
+	// state of the circuit breaker.
+	Err error
+	// BaseURL, From and To are the base URL and the states of a circuit
+	// breaker transition.
+	BaseURL string
+	From    CircuitState
+	To      CircuitState
+}
+
+// EventHook receives the events of a client, such as to count retries in a
+// metrics system. It is called synchronously, so it must be fast.
+type EventHook func(e Event)
+
+// WithEventHook passes the events of the client to h, besides logging them.
+func WithEventHook(h EventHook) ClientOption {
+	return func(c *Client) {
+		c.eventHook = h
+	}
+}
+
+// event fills in e from ctx, logs it and passes it to the hook.
+func (c *Client) event(ctx context.Context, e Event) {
+	e.Endpoint = endpointFromContext(ctx)
+	e.BotID = c.botID(ctx)
+	e.RequestID = RequestIDFromContext(ctx)
+
+	keyvals := []interface{}{"msg", "upstream " + string(e.Kind), "event", string(e.Kind), "endpoint", e.Endpoint, "bot", e.BotID}
+	if e.Kind == EventCircuit {
+		keyvals = append(keyvals, "base_url", e.BaseURL, "from", e.From.String(), "to", e.To.String())
+	} else {
+		keyvals = append(keyvals, "attempt", e.Attempt, "wait", e.Wait)
+	}
+	if e.Err != nil {
+		keyvals = append(keyvals, "err", e.Err)
+	}
+	if e.RequestID != "" {
+		keyvals = append(keyvals, "request_id", e.RequestID)
+	}
+	c.logger.Log(keyvals...)
+
+	if c.eventHook != nil {
+		c.eventHook(e)
+	}
+}
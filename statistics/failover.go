@@ -0,0 +1,213 @@
+package statistics
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit breaker of a base URL.
+type CircuitState int
+
+const (
+	// CircuitClosed means the base URL is healthy and used in order.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means the base URL failed repeatedly and is skipped until
+	// its cooldown has passed.
+	CircuitOpen
+	// CircuitHalfOpen means the cooldown has passed, and the next call
+	// probes whether the base URL has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// EndpointStatus is the health of a base URL configured with WithFailover.
+type EndpointStatus struct {
+	BaseURL string
+	State   CircuitState
+	// Failures is the number of consecutive failed calls.
+	Failures int
+	// OpenUntil is when an open circuit becomes half-open.
+	OpenUntil time.Time
+}
+
+// WithFailover makes the client fail over between baseURLs, such as a
+// primary and a secondary Sage region, tried in order. A base URL that fails
+// threshold consecutive calls with a network error or a 5xx status is
+// skipped for cooldown, after which the next call probes it again. If every
+// base URL is skipped, the one that becomes available first is tried anyway.
+func WithFailover(baseURLs []string, threshold int, cooldown time.Duration) ClientOption {
+	return func(c *Client) {
+		if len(baseURLs) == 0 {
+			return
+		}
+		if threshold < 1 {
+			threshold = 1
+		}
+
+		f := &failover{threshold: threshold, cooldown: cooldown}
+		for _, u := range baseURLs {
+			f.endpoints = append(f.endpoints, &endpoint{baseURL: strings.TrimSuffix(u, "/")})
+		}
+		c.BaseURL = f.endpoints[0].baseURL
+		c.failover = f
+	}
+}
+
+// Endpoints returns the health of the base URLs configured with
+// WithFailover, in the configured order. It is empty without failover.
+func (c *Client) Endpoints() []EndpointStatus {
+	if c.failover == nil {
+		return []EndpointStatus{}
+	}
+
+	return c.failover.status(time.Now())
+}
+
+type failover struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+}
+
+type endpoint struct {
+	baseURL   string
+	failures  int
+	openUntil time.Time
+}
+
+func (e *endpoint) state(now time.Time) CircuitState {
+	switch {
+	case e.openUntil.IsZero():
+		return CircuitClosed
+	case now.Before(e.openUntil):
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
+
+func (f *failover) status(now time.Time) []EndpointStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ret := make([]EndpointStatus, 0, len(f.endpoints))
+	for _, e := range f.endpoints {
+		ret = append(ret, EndpointStatus{BaseURL: e.baseURL, State: e.state(now), Failures: e.failures, OpenUntil: e.openUntil})
+	}
+
+	return ret
+}
+
+// candidates returns the endpoints to try, in order.
+func (f *failover) candidates(now time.Time) []*endpoint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ret := make([]*endpoint, 0, len(f.endpoints))
+	var first *endpoint
+	for _, e := range f.endpoints {
+		if e.state(now) != CircuitOpen {
+			ret = append(ret, e)
+			continue
+		}
+		if first == nil || e.openUntil.Before(first.openUntil) {
+			first = e
+		}
+	}
+	if len(ret) == 0 {
+		ret = append(ret, first)
+	}
+
+	return ret
+}
+
+// record records the outcome of a call to e, and returns the state of its
+// circuit before and after.
+func (f *failover) record(e *endpoint, ok bool, now time.Time) (from, to CircuitState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	from = e.state(now)
+	if ok {
+		e.failures = 0
+		e.openUntil = time.Time{}
+		return from, e.state(now)
+	}
+
+	e.failures++
+	// A failed probe of a half-open circuit opens it again right away.
+	if e.failures >= f.threshold || !e.openUntil.IsZero() {
+		e.openUntil = now.Add(f.cooldown)
+	}
+
+	return from, e.state(now)
+}
+
+// send performs r against each candidate endpoint in turn, replacing base,
+// the base URL r was created with, until one does not fail. Failures are
+// logged, and circuit transitions reported as events, with c.
+func (f *failover) send(r *http.Request, base string, c *Client, fn func(r *http.Request) (*http.Response, error)) (*http.Response, error) {
+	var lastErr error
+	for _, e := range f.candidates(time.Now()) {
+		req := r
+		if e.baseURL != base && strings.HasPrefix(r.URL.String(), base) {
+			u, err := url.Parse(e.baseURL + strings.TrimPrefix(r.URL.String(), base))
+			if err != nil {
+				return nil, err
+			}
+			req = r.Clone(r.Context())
+			req.URL = u
+			req.Host = ""
+		}
+
+		resp, err := fn(req)
+		if err != nil && (r.Context().Err() != nil || errors.Is(err, ErrCallBudgetExceeded)) {
+			return nil, err
+		}
+		if !endpointFailed(err) {
+			if from, to := f.record(e, true, time.Now()); from != to {
+				c.event(r.Context(), Event{Kind: EventCircuit, BaseURL: e.baseURL, From: from, To: to})
+			}
+			return resp, err
+		}
+
+		if from, to := f.record(e, false, time.Now()); from != to {
+			c.event(r.Context(), Event{Kind: EventCircuit, BaseURL: e.baseURL, From: from, To: to, Err: err})
+		}
+		c.logger.Log("msg", "upstream failed, trying next base url", "base_url", e.baseURL, "err", err)
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// endpointFailed reports whether err means the endpoint is unhealthy, as
+// opposed to the call being rejected.
+func endpointFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode() >= 500
+	}
+
+	return true
+}
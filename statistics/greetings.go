@@ -0,0 +1,28 @@
+package statistics
+
+import "context"
+
+// Greetings are the counts of the proactive greetings of the chat bubble.
+type Greetings struct {
+	// Shown is the number of greetings shown to visitors.
+	Shown int `json:"shown"`
+	// Opened is the number of greetings that opened a chat.
+	Opened int `json:"opened"`
+}
+
+// GreetingStatistics returns the greetings shown and opened in the period of
+// f. Where Sage does not expose greetings, it fails with an *Error with status
+// 404.
+func (c *Client) GreetingStatistics(ctx context.Context, f *Filter) (*Greetings, error) {
+	req, err := c.newRequest(ctx, "chatbubble/greetings", f)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret Greetings
+	if err := c.do(req, &ret); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
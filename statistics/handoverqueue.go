@@ -0,0 +1,83 @@
+package statistics
+
+import (
+	"context"
+	"time"
+
+	"github.com/atb-as/kindly"
+)
+
+// HandoverQueue describes how handover requests waited for an agent.
+type HandoverQueue struct {
+	// Requests is the number of handover requests that entered the queue.
+	Requests int `json:"requests"`
+	// Joined is the number of requests an agent joined.
+	Joined int `json:"joined"`
+	// Abandoned is the number of requests the user left before an agent
+	// joined.
+	Abandoned int `json:"abandoned"`
+	// MaxDepth is the largest number of requests waiting at the same time.
+	MaxDepth int `json:"max_depth"`
+	// AverageWait, MedianWait and MaxWait are how long users waited
+	// before an agent joined.
+	AverageWait kindly.Duration `json:"average_wait"`
+	MedianWait  kindly.Duration `json:"median_wait"`
+	MaxWait     kindly.Duration `json:"max_wait"`
+}
+
+// AbandonRate returns the share of requests abandoned while waiting, or zero
+// without requests.
+func (q *HandoverQueue) AbandonRate() float64 {
+	if q.Requests == 0 {
+		return 0
+	}
+
+	return float64(q.Abandoned) / float64(q.Requests)
+}
+
+// Wait returns the average wait as a duration.
+func (q *HandoverQueue) Wait() time.Duration {
+	return q.AverageWait.Duration
+}
+
+// HandoverQueueTimeSeries is the handover queue of the period starting at
+// Date.
+type HandoverQueueTimeSeries struct {
+	Date kindly.Time
+	HandoverQueue
+}
+
+// HandoverQueueTotal returns how long handover requests waited for an agent
+// and how many were abandoned while waiting in the requested time period.
+// Where Sage does not expose the queue, it fails with an *Error with status
+// 404.
+func (c *Client) HandoverQueueTotal(ctx context.Context, f *Filter) (*HandoverQueue, error) {
+	req, err := c.newRequest(ctx, "takeovers/queue/totals", f)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := HandoverQueue{}
+	if err := c.do(req, &ret); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// HandoverQueueTimeSeries returns how long handover requests waited for an
+// agent and how many were abandoned while waiting in the requested time
+// period, as a time series.
+func (c *Client) HandoverQueueTimeSeries(ctx context.Context, f *Filter) ([]*HandoverQueueTimeSeries, error) {
+	req, err := c.newRequest(ctx, "takeovers/queue/series", f)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*HandoverQueueTimeSeries, 0)
+	if err := c.do(req, &ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
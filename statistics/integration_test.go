@@ -0,0 +1,227 @@
+//go:build integration
+// +build integration
+
+package statistics_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/statistics/auth"
+	"golang.org/x/oauth2"
+)
+
+// The integration tests run every client method against a live bot, given by
+// KINDLY_BOT_ID and KINDLY_API_KEY or KINDLY_CREDENTIALS:
+//
+//	go test -tags=integration ./statistics/
+//
+// Assertions are tolerant of the bot's data, which changes from day to day,
+// but fail when the fields the client decodes are missing from the upstream
+// response.
+
+// recordingDoer keeps the body of the last response.
+type recordingDoer struct {
+	doer statistics.Doer
+	mu   sync.Mutex
+	last []byte
+}
+
+func (d *recordingDoer) Do(r *http.Request) (*http.Response, error) {
+	resp, err := d.doer.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	d.mu.Lock()
+	d.last = body
+	d.mu.Unlock()
+
+	return resp, nil
+}
+
+// fields returns the keys of the objects in the data of the last response,
+// which is either an object or an array of objects.
+func (d *recordingDoer) fields(t *testing.T) map[string]bool {
+	t.Helper()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var w struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(d.last, &w); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(w.Data, &objects); err != nil {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(w.Data, &object); err != nil {
+			t.Fatalf("decoding data: %v", err)
+		}
+		objects = append(objects, object)
+	}
+
+	ret := make(map[string]bool)
+	for _, o := range objects {
+		for k := range o {
+			ret[k] = true
+		}
+	}
+
+	return ret
+}
+
+// requireFields fails t if the last response had data without all of keys.
+func (d *recordingDoer) requireFields(t *testing.T, keys ...string) {
+	t.Helper()
+	fields := d.fields(t)
+	if len(fields) == 0 {
+		t.Logf("no data to check fields against")
+		return
+	}
+	for _, k := range keys {
+		if !fields[k] {
+			t.Errorf("field %q missing from response, got %v", k, fields)
+		}
+	}
+}
+
+func newIntegrationClient(t *testing.T) (*statistics.Client, *recordingDoer) {
+	t.Helper()
+	botID := os.Getenv("KINDLY_BOT_ID")
+	if botID == "" {
+		t.Skip("KINDLY_BOT_ID not set")
+	}
+
+	ts := &auth.TokenSource{APIKey: os.Getenv("KINDLY_API_KEY"), BotID: botID}
+	if uri := os.Getenv("KINDLY_CREDENTIALS"); uri != "" {
+		creds, err := auth.ParseCredentials(context.Background(), uri)
+		if err != nil {
+			t.Fatalf("auth.ParseCredentials() err=%v", err)
+		}
+		ts.Credentials = creds
+	}
+
+	rec := &recordingDoer{doer: oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, ts))}
+	c := statistics.NewClient(statistics.WithDoer(rec))
+	c.BotID = botID
+
+	return c, rec
+}
+
+func integrationFilter() *statistics.Filter {
+	to := time.Now().Truncate(24 * time.Hour)
+	return &statistics.Filter{
+		From:        to.Add(-7 * 24 * time.Hour),
+		To:          to,
+		Granularity: statistics.Day,
+		Limit:       5,
+	}
+}
+
+func TestIntegration(t *testing.T) {
+	c, rec := newIntegrationClient(t)
+	ctx := context.Background()
+	f := integrationFilter()
+
+	t.Run("ChatSessions", func(t *testing.T) {
+		if _, err := c.ChatSessions(ctx, f); err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		rec.requireFields(t, "count", "date")
+	})
+	t.Run("UserMessages", func(t *testing.T) {
+		if _, err := c.UserMessages(ctx, f); err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		rec.requireFields(t, "count", "date")
+	})
+	t.Run("ChatLabels", func(t *testing.T) {
+		labels, err := c.ChatLabels(ctx, f)
+		if err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		if len(labels) > f.Limit {
+			t.Errorf("got %d labels, want at most %d", len(labels), f.Limit)
+		}
+		rec.requireFields(t, "count", "label_id", "label_text")
+	})
+	t.Run("PageStatistics", func(t *testing.T) {
+		if _, err := c.PageStatistics(ctx, f); err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		rec.requireFields(t, "messages", "sessions", "web_host", "web_path")
+	})
+	t.Run("FallbackRateTotal", func(t *testing.T) {
+		total, err := c.FallbackRateTotal(ctx, f)
+		if err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		if total.Rate < 0 || total.Rate > 1 {
+			t.Errorf("got rate %v, want within [0, 1]", total.Rate)
+		}
+		rec.requireFields(t, "count", "rate")
+	})
+	t.Run("FallbackRateTimeSeries", func(t *testing.T) {
+		if _, err := c.FallbackRateTimeSeries(ctx, f); err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		rec.requireFields(t, "count", "date", "rate")
+	})
+	t.Run("HandoversTotal", func(t *testing.T) {
+		if _, err := c.HandoversTotal(ctx, f); err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		rec.requireFields(t, "ended", "requests", "requests_while_closed", "started")
+	})
+	t.Run("HandoversTimeSeries", func(t *testing.T) {
+		if _, err := c.HandoversTimeSeries(ctx, f); err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		rec.requireFields(t, "date", "ended", "requests", "requests_while_closed", "started")
+	})
+	t.Run("AggregatedFeedback", func(t *testing.T) {
+		if _, err := c.AggregatedFeedback(ctx, f); err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		rec.requireFields(t, "binary", "emojis")
+	})
+	t.Run("Broadcasts", func(t *testing.T) {
+		if _, err := c.Broadcasts(ctx, f); err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		rec.requireFields(t, "broadcast_id", "sent", "delivered", "opened", "replied")
+	})
+	t.Run("StreamChatSessions", func(t *testing.T) {
+		n := 0
+		if err := c.StreamChatSessions(ctx, f, func(*statistics.CountByDate) error {
+			n++
+			return nil
+		}); err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		sessions, err := c.ChatSessions(ctx, f)
+		if err != nil {
+			t.Fatalf("err=%v", err)
+		}
+		if n != len(sessions) {
+			t.Errorf("streamed %d items, got %d from ChatSessions", n, len(sessions))
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package statistics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LabelTexts returns the current text of every label of the bot, keyed by
+// label ID, e.g. from chat.Client.Labels.
+type LabelTexts func(ctx context.Context) (map[string]string, error)
+
+// LabelCatalogue maps label IDs to their current text, so labels renamed
+// during a period are reported under one spelling. It also keeps every
+// spelling seen for an ID. It is safe for concurrent use.
+type LabelCatalogue struct {
+	fetch   LabelTexts
+	refresh time.Duration
+
+	mu      sync.Mutex
+	texts   map[string]string
+	fetched time.Time
+	history map[string][]string
+}
+
+// NewLabelCatalogue returns a catalogue that fetches the label texts when
+// first used and again once they are older than refresh.
+func NewLabelCatalogue(fetch LabelTexts, refresh time.Duration) *LabelCatalogue {
+	return &LabelCatalogue{
+		fetch:   fetch,
+		refresh: refresh,
+		history: make(map[string][]string),
+	}
+}
+
+// WithLabelCatalogue makes ChatLabels replace the text reported by Sage with
+// the current text in cat.
+func WithLabelCatalogue(cat *LabelCatalogue) ClientOption {
+	return func(c *Client) {
+		c.labels = cat
+	}
+}
+
+// Text returns the current text of the label, or false if the label is not
+// in the catalogue.
+func (c *LabelCatalogue) Text(ctx context.Context, id string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(ctx); err != nil {
+		return "", false, err
+	}
+
+	text, ok := c.texts[id]
+	return text, ok, nil
+}
+
+// History returns every text seen for the label, oldest first.
+func (c *LabelCatalogue) History(id string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string{}, c.history[id]...)
+}
+
+// Normalize records the texts of labels in the history and replaces them with
+// the current text of each label in the catalogue. Labels missing from the
+// catalogue, such as deleted ones, keep their text.
+func (c *LabelCatalogue) Normalize(ctx context.Context, labels []*ChatLabel) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(ctx); err != nil {
+		return err
+	}
+
+	for _, label := range labels {
+		c.observe(label.ID, label.Text)
+		if text, ok := c.texts[label.ID]; ok {
+			label.Text = text
+		}
+	}
+
+	return nil
+}
+
+// load fetches the texts if they are missing or stale. A failed refresh
+// keeps serving the previous texts until the next refresh is due. c.mu must
+// be held.
+func (c *LabelCatalogue) load(ctx context.Context) error {
+	if c.texts != nil && time.Since(c.fetched) < c.refresh {
+		return nil
+	}
+
+	texts, err := c.fetch(ctx)
+	if err != nil {
+		if c.texts != nil {
+			c.fetched = time.Now()
+			return nil
+		}
+		return err
+	}
+
+	c.texts = texts
+	c.fetched = time.Now()
+	for id, text := range texts {
+		c.observe(id, text)
+	}
+
+	return nil
+}
+
+// observe adds text to the history of the label. c.mu must be held.
+func (c *LabelCatalogue) observe(id, text string) {
+	if text == "" {
+		return
+	}
+	for _, seen := range c.history[id] {
+		if seen == text {
+			return
+		}
+	}
+
+	c.history[id] = append(c.history[id], text)
+}
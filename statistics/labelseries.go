@@ -0,0 +1,169 @@
+package statistics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// labelChunkConcurrency is the number of chunks requested at once by
+	// ChatLabelsByChunk.
+	labelChunkConcurrency = 4
+	// labelSeriesChunkLimit is the minimum number of labels requested per
+	// chunk by the label series, so labels that are less used in a chunk are
+	// still counted.
+	labelSeriesChunkLimit = 100
+)
+
+// LabelSource is the subset of *Client used by the label series.
+type LabelSource interface {
+	ChatLabels(ctx context.Context, f *Filter) ([]*ChatLabel, error)
+}
+
+// LabelChunk is the labels added in a chunk of a period.
+type LabelChunk struct {
+	// Filter is the filter of the chunk. It has a single source if the
+	// chunks are split by source.
+	Filter *Filter
+	Labels []*ChatLabel
+	// Err is the error of the chunk, if it failed.
+	Err error
+}
+
+// ChatLabelsByChunk returns the labels added per day, or per week for Week
+// granularity, of the period of f, and per source of f if bySource is set.
+// Sage has no series of labels, so the chunks are requested concurrently.
+// The chunks are in order of date, then of source. A failed chunk carries its
+// error, so the other chunks can still be used.
+func ChatLabelsByChunk(ctx context.Context, src LabelSource, f *Filter, bySource bool) []*LabelChunk {
+	var chunks []*LabelChunk
+	for _, chunk := range f.Chunks(f.Granularity) {
+		if !bySource {
+			chunks = append(chunks, &LabelChunk{Filter: chunk})
+			continue
+		}
+		for _, source := range f.Sources {
+			c := *chunk
+			c.Sources = []Source{source}
+			chunks = append(chunks, &LabelChunk{Filter: &c})
+		}
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, labelChunkConcurrency)
+	for _, chunk := range chunks {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(chunk *LabelChunk) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			chunk.Labels, chunk.Err = src.ChatLabels(ctx, chunk.Filter)
+		}(chunk)
+	}
+	wg.Wait()
+
+	return chunks
+}
+
+// LabelSeries is the number of times a label was added over time.
+type LabelSeries struct {
+	ID string
+	// Text is the text of the label in the last chunk it was added in.
+	Text   string
+	Points []*LabelPoint
+}
+
+// Total returns the number of times the label was added in the period.
+func (s *LabelSeries) Total() int {
+	var n int
+	for _, p := range s.Points {
+		n += p.Count
+	}
+
+	return n
+}
+
+// LabelPoint is the number of times a label was added in the period starting
+// at Date.
+type LabelPoint struct {
+	Date  time.Time
+	Count int
+}
+
+// ChatLabelSeries returns the number of times the label with the given ID was
+// added per day, or per week for Week granularity, in the period of f. See
+// LabelSeriesOf.
+func (c *Client) ChatLabelSeries(ctx context.Context, labelID string, f *Filter) (*LabelSeries, error) {
+	series, err := LabelSeriesOf(ctx, c, f)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range series {
+		if s.ID == labelID {
+			return s, nil
+		}
+	}
+
+	ret := &LabelSeries{ID: labelID}
+	for _, chunk := range f.Chunks(f.Granularity) {
+		ret.Points = append(ret.Points, &LabelPoint{Date: chunk.From})
+	}
+
+	return ret, nil
+}
+
+// ChatLabelsSeriesAll returns the number of times every label added in the
+// period of f was added per day, or per week for Week granularity. See
+// LabelSeriesOf.
+func (c *Client) ChatLabelsSeriesAll(ctx context.Context, f *Filter) ([]*LabelSeries, error) {
+	return LabelSeriesOf(ctx, c, f)
+}
+
+// LabelSeriesOf returns the number of times every label added in the period
+// of f was added per day, or per week for Week granularity, most added first.
+// Every series has a point per chunk; labels outside the top labels of a
+// chunk count as zero for it. Each chunk requests at least the top 100
+// labels. The first failed chunk fails the series.
+func LabelSeriesOf(ctx context.Context, src LabelSource, f *Filter) ([]*LabelSeries, error) {
+	temp := *f
+	if temp.Limit < labelSeriesChunkLimit {
+		temp.Limit = labelSeriesChunkLimit
+	}
+	chunks := ChatLabelsByChunk(ctx, src, &temp, false)
+
+	ret := make([]*LabelSeries, 0)
+	index := make(map[string]*LabelSeries)
+	for i, chunk := range chunks {
+		if chunk.Err != nil {
+			return nil, chunk.Err
+		}
+		for _, label := range chunk.Labels {
+			s, ok := index[label.ID]
+			if !ok {
+				s = &LabelSeries{ID: label.ID, Points: make([]*LabelPoint, len(chunks))}
+				for j, c := range chunks {
+					s.Points[j] = &LabelPoint{Date: c.Filter.From}
+				}
+				index[label.ID] = s
+				ret = append(ret, s)
+			}
+			s.Text = label.Text
+			s.Points[i].Count += label.Count
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		ti, tj := ret[i].Total(), ret[j].Total()
+		if ti != tj {
+			return ti > tj
+		}
+		return ret[i].ID < ret[j].ID
+	})
+
+	return ret, nil
+}
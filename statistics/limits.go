@@ -0,0 +1,152 @@
+package statistics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// DefaultMaxResponseSize is the largest response body read unless configured
+// with WithMaxResponseSize.
+const DefaultMaxResponseSize = 64 << 20
+
+// ErrResponseTooLarge is returned when a response body exceeds the maximum
+// response size of the client.
+var ErrResponseTooLarge = errors.New("statistics: response too large")
+
+// WithMaxResponseSize fails calls whose response body is larger than n bytes
+// with ErrResponseTooLarge, instead of reading it into memory. The default is
+// DefaultMaxResponseSize; a negative n removes the limit.
+func WithMaxResponseSize(n int64) ClientOption {
+	return func(c *Client) {
+		c.maxResponseSize = n
+	}
+}
+
+// WithTimeout limits every request, including reading its response, to d.
+// Requests timing out are not retried. See WithEndpointTimeout.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithEndpointTimeout limits the requests of endpoint, such as
+// "sessions/chats" or "pages/series", to d, overriding WithTimeout. Slow
+// endpoints can be given more time than the rest.
+func WithEndpointTimeout(endpoint string, d time.Duration) ClientOption {
+	return func(c *Client) {
+		if c.endpointTimeouts == nil {
+			c.endpointTimeouts = make(map[string]time.Duration)
+		}
+		c.endpointTimeouts[endpoint] = d
+	}
+}
+
+// responseLimit returns the maximum response size, or -1 for none.
+func (c *Client) responseLimit() int64 {
+	switch {
+	case c.maxResponseSize < 0:
+		return -1
+	case c.maxResponseSize == 0:
+		return DefaultMaxResponseSize
+	default:
+		return c.maxResponseSize
+	}
+}
+
+// requestTimeout returns the timeout of requests to endpoint, or zero for
+// none.
+func (c *Client) requestTimeout(endpoint string) time.Duration {
+	if d, ok := c.endpointTimeouts[endpoint]; ok {
+		return d
+	}
+
+	return c.timeout
+}
+
+// withTimeout returns r limited to the timeout of its endpoint, if any. cancel
+// must be called once the response is read, and timedOut wraps the errors of
+// r to say it timed out, if it did.
+func (c *Client) withTimeout(r *http.Request) (ret *http.Request, cancel context.CancelFunc, timedOut func(error) error) {
+	endpoint := endpointFromContext(r.Context())
+	parent := r.Context()
+	timeout := c.requestTimeout(endpoint)
+	if timeout <= 0 {
+		return r, func() {}, func(err error) error { return err }
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	ret = r.WithContext(ctx)
+	timedOut = func(err error) error {
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+			return fmt.Errorf("statistics: %s timed out after %v: %w", endpoint, timeout, err)
+		}
+		return err
+	}
+
+	return ret, cancel, timedOut
+}
+
+// readResponse reads the body of a response to endpoint, failing with
+// ErrResponseTooLarge past the maximum response size.
+func (c *Client) readResponse(endpoint string, body io.Reader) ([]byte, error) {
+	return ioutil.ReadAll(c.limitResponse(endpoint, body))
+}
+
+// limitResponse returns body, failing reads with ErrResponseTooLarge past the
+// maximum response size, so responses that are not read into memory at once
+// are limited too.
+func (c *Client) limitResponse(endpoint string, body io.Reader) io.Reader {
+	limit := c.responseLimit()
+	if limit < 0 {
+		return body
+	}
+
+	return &limitedReader{r: body, n: limit, endpoint: endpoint, limit: limit}
+}
+
+// limitedReader reads from r until n bytes are left, then fails with
+// ErrResponseTooLarge if r has more.
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	endpoint string
+	limit    int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w: %s returned more than %d bytes", ErrResponseTooLarge, l.endpoint, l.limit)
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+
+	return n, err
+}
+
+type endpointKey struct{}
+
+// withEndpoint returns a context for requests to endpoint.
+func withEndpoint(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, endpointKey{}, endpoint)
+}
+
+// endpointFromContext returns the endpoint of the request with ctx.
+func endpointFromContext(ctx context.Context) string {
+	endpoint, _ := ctx.Value(endpointKey{}).(string)
+	return endpoint
+}
@@ -0,0 +1,80 @@
+package statistics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ResponseMeta collects information about the upstream responses for calls
+// made with a context returned by WithResponseMeta. It is safe for concurrent
+// use, so a single ResponseMeta may be shared by concurrent calls.
+type ResponseMeta struct {
+	mu        sync.Mutex
+	truncated bool
+}
+
+// Truncated reports whether any response from a top-N endpoint, such as
+// ChatLabels or PageStatistics, returned as many items as the requested
+// Limit, meaning more results were likely cut off upstream.
+func (m *ResponseMeta) Truncated() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.truncated
+}
+
+// limitedEndpoints are the top-N endpoints where Sage cuts results off at
+// the requested limit. Series endpoints return one item per date regardless.
+var limitedEndpoints = []string{"chatbubble/pages", "chatlabels/added"}
+
+type metaKey struct{}
+
+// WithResponseMeta returns a context that makes the client record response
+// metadata for calls using it in m.
+func WithResponseMeta(ctx context.Context, m *ResponseMeta) context.Context {
+	return context.WithValue(ctx, metaKey{}, m)
+}
+
+func metaFromContext(ctx context.Context) *ResponseMeta {
+	m, _ := ctx.Value(metaKey{}).(*ResponseMeta)
+	return m
+}
+
+// record updates the ResponseMeta in r's context, if any, from the data of
+// a successful response.
+func record(r *http.Request, data json.RawMessage) {
+	m := metaFromContext(r.Context())
+	if m == nil {
+		return
+	}
+
+	limited := false
+	for _, endpoint := range limitedEndpoints {
+		if strings.HasSuffix(r.URL.Path, "/"+endpoint) {
+			limited = true
+		}
+	}
+	if !limited {
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return
+	}
+
+	if len(items) >= limit {
+		m.mu.Lock()
+		m.truncated = true
+		m.mu.Unlock()
+	}
+}
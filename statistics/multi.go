@@ -0,0 +1,102 @@
+package statistics
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// multiConcurrency is the number of bots a MultiClient calls at once.
+const multiConcurrency = 4
+
+// MultiClient queries the same statistics of several bots, such as to
+// compare the bots of a portfolio in a group report. It keeps the result of
+// every bot, and a bot that fails does not fail the others; WorkspaceClient
+// builds on it to sum the bots into one.
+type MultiClient struct {
+	ids  []string
+	bots map[string]Service
+}
+
+// NewMultiClient returns a client for bots, keyed by bot ID. See BotClients
+// to query several bots with one client.
+func NewMultiClient(bots map[string]Service) *MultiClient {
+	m := &MultiClient{bots: bots}
+	for id := range bots {
+		m.ids = append(m.ids, id)
+	}
+	sort.Strings(m.ids)
+
+	return m
+}
+
+// BotIDs returns the IDs of the bots, sorted.
+func (m *MultiClient) BotIDs() []string {
+	return append([]string(nil), m.ids...)
+}
+
+// BotCounts are the counts of a bot.
+type BotCounts struct {
+	BotID  string
+	Counts []*CountByDate
+	// Err is the error of the bot, if it failed.
+	Err error
+}
+
+// Total returns the sum of the counts.
+func (b *BotCounts) Total() int {
+	total := 0
+	for _, c := range b.Counts {
+		total += c.Count
+	}
+
+	return total
+}
+
+// BotResult is the result of a call for a bot.
+type BotResult struct {
+	BotID string
+	Value interface{}
+	// Err is the error of the bot, if it failed.
+	Err error
+}
+
+// Each calls fn for every bot, concurrently, and returns the results of the
+// bots in the order of BotIDs. A failed bot carries its error, so the other
+// bots can still be reported.
+func (m *MultiClient) Each(ctx context.Context, fn func(ctx context.Context, s Service) (interface{}, error)) []*BotResult {
+	ret := make([]*BotResult, len(m.ids))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, multiConcurrency)
+	for i, id := range m.ids {
+		ret[i] = &BotResult{BotID: id}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(r *BotResult, s Service) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			r.Value, r.Err = fn(ctx, s)
+		}(ret[i], m.bots[id])
+	}
+	wg.Wait()
+
+	return ret
+}
+
+// Counts is like Each for calls returning counts.
+func (m *MultiClient) Counts(ctx context.Context, fn func(ctx context.Context, s Service) ([]*CountByDate, error)) []*BotCounts {
+	results := m.Each(ctx, func(ctx context.Context, s Service) (interface{}, error) {
+		return fn(ctx, s)
+	})
+
+	ret := make([]*BotCounts, 0, len(results))
+	for _, r := range results {
+		counts, _ := r.Value.([]*CountByDate)
+		ret = append(ret, &BotCounts{BotID: r.BotID, Counts: counts, Err: r.Err})
+	}
+
+	return ret
+}
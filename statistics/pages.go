@@ -0,0 +1,84 @@
+package statistics
+
+import (
+	"context"
+	"time"
+)
+
+// pageSeriesChunkLimit is the minimum number of pages requested per chunk by
+// PageStatisticsSeries, so top pages of the period that are less visited in
+// a chunk are still counted.
+const pageSeriesChunkLimit = 50
+
+// PageSeries is the engagement with one page over time.
+type PageSeries struct {
+	Host   string
+	Path   string
+	Points []*PagePoint
+}
+
+// PagePoint is the engagement with a page in the period starting at Date.
+type PagePoint struct {
+	Date     time.Time
+	Sessions int
+	Messages int
+}
+
+// PageSource is the subset of *Client used by PageSeriesOf.
+type PageSource interface {
+	PageStatistics(ctx context.Context, f *Filter) ([]*PageStatistic, error)
+}
+
+// PageStatisticsSeries returns the sessions and messages per day, or per week
+// for Week granularity, of the top f.Limit pages of the period of f. See
+// PageSeriesOf.
+func (c *Client) PageStatisticsSeries(ctx context.Context, f *Filter) ([]*PageSeries, error) {
+	return PageSeriesOf(ctx, c, f)
+}
+
+// PageSeriesOf returns the sessions and messages per day, or per week for
+// Week granularity, of the top f.Limit pages of the period of f, in the order
+// of the top list. Every series has a point per chunk; pages outside the top
+// pages of a chunk count as zero for it. Each chunk requests at least the top
+// 50 pages.
+func PageSeriesOf(ctx context.Context, src PageSource, f *Filter) ([]*PageSeries, error) {
+	top, err := src.PageStatistics(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	type page struct{ host, path string }
+	ret := make([]*PageSeries, 0, len(top))
+	index := make(map[page]*PageSeries, len(top))
+	for _, p := range top {
+		s := &PageSeries{Host: p.Host, Path: p.Path}
+		index[page{p.Host, p.Path}] = s
+		ret = append(ret, s)
+	}
+	if len(ret) == 0 {
+		return ret, nil
+	}
+
+	for _, chunk := range f.Chunks(f.Granularity) {
+		if chunk.Limit < pageSeriesChunkLimit {
+			chunk.Limit = pageSeriesChunkLimit
+		}
+		stats, err := src.PageStatistics(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range ret {
+			s.Points = append(s.Points, &PagePoint{Date: chunk.From})
+		}
+		for _, p := range stats {
+			if s, ok := index[page{p.Host, p.Path}]; ok {
+				point := s.Points[len(s.Points)-1]
+				point.Sessions += p.Sessions
+				point.Messages += p.Messages
+			}
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,185 @@
+package statistics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseGranularity returns the granularity named s, "day", "hour" or "week".
+// An empty s is Unspecified.
+func ParseGranularity(s string) (Granularity, error) {
+	switch s {
+	case "":
+		return Unspecified, nil
+	case "day":
+		return Day, nil
+	case "hour":
+		return Hour, nil
+	case "week":
+		return Week, nil
+	default:
+		return Unspecified, fmt.Errorf("statistics: unknown granularity %q, want day, hour or week", s)
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (g Granularity) MarshalText() ([]byte, error) {
+	if g == Unspecified {
+		return []byte{}, nil
+	}
+
+	return []byte(g.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (g *Granularity) UnmarshalText(b []byte) error {
+	v, err := ParseGranularity(string(b))
+	if err != nil {
+		return err
+	}
+	*g = v
+
+	return nil
+}
+
+// Preset is a named query that teams run repeatedly, such as the numbers of
+// a weekly report: a metric over the last days, at a granularity, for some
+// sources.
+type Preset struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Metric names what to query, as the tools name their metrics, e.g.
+	// "sessions" or "labels".
+	Metric string `json:"metric"`
+	// Days is the number of whole days up to today the preset covers.
+	Days        int         `json:"days"`
+	Granularity Granularity `json:"granularity,omitempty"`
+	// Sources are the sources to query; empty means the tool's default.
+	Sources []Source `json:"sources,omitempty"`
+}
+
+// Filter returns the filter of the preset for a query at now, covering the
+// Days whole days before the day of now.
+func (p *Preset) Filter(now time.Time) *Filter {
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	return &Filter{
+		From:        to.AddDate(0, 0, -p.Days),
+		To:          to,
+		Granularity: p.Granularity,
+		Sources:     p.Sources,
+	}
+}
+
+// Query returns the query parameters of the preset as the frontends accept
+// them, without the metric.
+func (p *Preset) Query() url.Values {
+	q := url.Values{"days": {strconv.Itoa(p.Days)}}
+	if p.Granularity != Unspecified {
+		q.Set("granularity", p.Granularity.String())
+	}
+	for _, s := range p.Sources {
+		q.Add("sources", s.String())
+	}
+
+	return q
+}
+
+func (p *Preset) validate() error {
+	switch {
+	case p.Name == "":
+		return fmt.Errorf("statistics: preset name is required")
+	case strings.ContainsAny(p.Name, " /?&="):
+		return fmt.Errorf("statistics: preset %q: name can not contain spaces or /?&=", p.Name)
+	case p.Metric == "":
+		return fmt.Errorf("statistics: preset %s: metric is required", p.Name)
+	case p.Days < 1:
+		return fmt.Errorf("statistics: preset %s: days must be positive", p.Name)
+	}
+
+	return nil
+}
+
+// DefaultPresets are the presets every registry starts with.
+var DefaultPresets = []*Preset{
+	{
+		Name:        "weekly-report",
+		Description: "Sessions per day over the last week",
+		Metric:      "sessions",
+		Days:        7,
+		Granularity: Day,
+	},
+	{
+		Name:        "monthly-board",
+		Description: "Sessions per week over the last four weeks",
+		Metric:      "sessions",
+		Days:        28,
+		Granularity: Week,
+	},
+}
+
+// Presets is a registry of presets by name, shared by the tools so a preset
+// means the same query everywhere.
+type Presets struct {
+	byName map[string]*Preset
+}
+
+// NewPresets returns a registry of the default presets and presets. Presets
+// named like a default preset replace it.
+func NewPresets(presets ...*Preset) (*Presets, error) {
+	r := &Presets{byName: make(map[string]*Preset)}
+	for _, p := range DefaultPresets {
+		r.byName[p.Name] = p
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range presets {
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("statistics: preset %s is defined twice", p.Name)
+		}
+		seen[p.Name] = true
+		r.byName[p.Name] = p
+	}
+
+	return r, nil
+}
+
+// ParsePresets returns a registry of the default presets and the JSON array
+// of presets in b.
+func ParsePresets(b []byte) (*Presets, error) {
+	var presets []*Preset
+	if err := json.Unmarshal(b, &presets); err != nil {
+		return nil, fmt.Errorf("statistics: parsing presets: %w", err)
+	}
+
+	return NewPresets(presets...)
+}
+
+// Get returns the preset named name.
+func (r *Presets) Get(name string) (*Preset, error) {
+	p, ok := r.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("statistics: unknown preset %q", name)
+	}
+
+	return p, nil
+}
+
+// List returns the presets sorted by name.
+func (r *Presets) List() []*Preset {
+	ret := make([]*Preset, 0, len(r.byName))
+	for _, p := range r.byName {
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+
+	return ret
+}
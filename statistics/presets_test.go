@@ -0,0 +1,65 @@
+package statistics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestParsePresets(t *testing.T) {
+	r, err := statistics.ParsePresets([]byte(`[
+		{"name": "weekly-report", "metric": "messages", "days": 7, "granularity": "day", "sources": ["web"]},
+		{"name": "labels-quarter", "metric": "labels", "days": 90, "granularity": "week"}
+	]`))
+	if err != nil {
+		t.Fatalf("ParsePresets() err=%v", err)
+	}
+
+	var names []string
+	for _, p := range r.List() {
+		names = append(names, p.Name)
+	}
+	if want := "labels-quarter,monthly-board,weekly-report"; strings.Join(names, ",") != want {
+		t.Errorf("got presets %v, want %s", names, want)
+	}
+
+	p, err := r.Get("weekly-report")
+	if err != nil {
+		t.Fatalf("Get() err=%v", err)
+	}
+	if p.Metric != "messages" {
+		t.Errorf("got metric %q, want the configured preset to replace the default", p.Metric)
+	}
+
+	now := time.Date(2021, 3, 10, 15, 30, 0, 0, time.UTC)
+	f := p.Filter(now)
+	if want := time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC); !f.From.Equal(want) || !f.To.Equal(want.AddDate(0, 0, 7)) {
+		t.Errorf("got period %s-%s, want the 7 days before %s", f.From, f.To, now)
+	}
+	if f.Granularity != statistics.Day || len(f.Sources) != 1 {
+		t.Errorf("got %+v, want daily granularity for web", f)
+	}
+	if got, want := p.Query().Encode(), "days=7&granularity=day&sources=web"; got != want {
+		t.Errorf("got query %q, want %q", got, want)
+	}
+
+	if _, err := r.Get("daily"); err == nil {
+		t.Error("Get(\"daily\") err=nil, want error")
+	}
+}
+
+func TestParsePresets_Invalid(t *testing.T) {
+	for _, b := range []string{
+		`[{"name": "x", "metric": "sessions", "days": 0}]`,
+		`[{"name": "x", "days": 7}]`,
+		`[{"name": "a b", "metric": "sessions", "days": 7}]`,
+		`[{"name": "x", "metric": "sessions", "days": 7, "granularity": "month"}]`,
+		`[{"name": "x", "metric": "sessions", "days": 7}, {"name": "x", "metric": "sessions", "days": 1}]`,
+	} {
+		if _, err := statistics.ParsePresets([]byte(b)); err == nil {
+			t.Errorf("ParsePresets(%s) err=nil, want error", b)
+		}
+	}
+}
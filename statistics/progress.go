@@ -0,0 +1,112 @@
+package statistics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ProgressStatus is the progress of a long-running export made of chunks,
+// such as one upstream query per metric or period.
+type ProgressStatus struct {
+	Completed int
+	Total     int
+	// Rows is the number of rows or points written so far.
+	Rows int
+	// Retries is the number of upstream calls retried after a 429 or 503.
+	Retries int
+	Started time.Time
+}
+
+// ETA returns the estimated time until the remaining chunks are completed,
+// assuming they take as long as the completed ones. It is zero until a chunk
+// has been completed.
+func (s ProgressStatus) ETA(now time.Time) time.Duration {
+	if s.Completed == 0 || s.Completed >= s.Total {
+		return 0
+	}
+	perChunk := now.Sub(s.Started) / time.Duration(s.Completed)
+
+	return perChunk * time.Duration(s.Total-s.Completed)
+}
+
+// Progress receives the status of an export whenever it changes.
+type Progress interface {
+	Progress(s ProgressStatus)
+}
+
+// ProgressFunc is a function implementing Progress.
+type ProgressFunc func(s ProgressStatus)
+
+// Progress implements Progress.
+func (f ProgressFunc) Progress(s ProgressStatus) {
+	f(s)
+}
+
+// ProgressTracker counts the chunks, rows and retries of an export and
+// reports them to a Progress. It is safe for concurrent use, so chunks may be
+// fetched concurrently.
+type ProgressTracker struct {
+	p Progress
+
+	mu sync.Mutex
+	s  ProgressStatus
+}
+
+// NewProgressTracker returns a tracker of an export of total chunks, which
+// reports to p.
+func NewProgressTracker(total int, p Progress) *ProgressTracker {
+	return &ProgressTracker{p: p, s: ProgressStatus{Total: total, Started: time.Now()}}
+}
+
+// AddTotal adds n chunks to the export, for exports that only learn how
+// many chunks they consist of as they go.
+func (t *ProgressTracker) AddTotal(n int) {
+	t.update(func(s *ProgressStatus) { s.Total += n })
+}
+
+// ChunkDone marks a chunk as completed, having written rows rows.
+func (t *ProgressTracker) ChunkDone(rows int) {
+	t.update(func(s *ProgressStatus) {
+		s.Completed++
+		s.Rows += rows
+	})
+}
+
+// Status returns the current status.
+func (t *ProgressTracker) Status() ProgressStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.s
+}
+
+func (t *ProgressTracker) retried() {
+	t.update(func(s *ProgressStatus) { s.Retries++ })
+}
+
+// update changes the status with fn and reports it. Reports are serialized,
+// so a Progress does not need to be safe for concurrent use.
+func (t *ProgressTracker) update(fn func(s *ProgressStatus)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fn(&t.s)
+	if t.p != nil {
+		t.p.Progress(t.s)
+	}
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context that makes the client count the retries of
+// its upstream calls in t.
+func WithProgress(ctx context.Context, t *ProgressTracker) context.Context {
+	return context.WithValue(ctx, progressKey{}, t)
+}
+
+// ProgressFromContext returns the tracker set with WithProgress, or nil.
+func ProgressFromContext(ctx context.Context) *ProgressTracker {
+	t, _ := ctx.Value(progressKey{}).(*ProgressTracker)
+	return t
+}
@@ -0,0 +1,111 @@
+package statistics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrQuotaUnavailable is returned by QuotaStatus when Sage did not report
+// the quota of the bot.
+var ErrQuotaUnavailable = errors.New("statistics: upstream did not report a quota")
+
+// Quota is the daily API quota of the bot, as reported by Sage in the
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers.
+type Quota struct {
+	Limit     int
+	Remaining int
+	// Reset is when Remaining is restored to Limit. It is zero if Sage did
+	// not report it.
+	Reset time.Time
+	// Observed is when the response carrying the quota was received.
+	Observed time.Time
+}
+
+// Covers reports whether the remaining quota allows for another calls calls
+// before it is reset.
+func (q *Quota) Covers(calls int) bool {
+	return q.Remaining >= calls
+}
+
+// Pace returns the interval between calls that spreads the remaining quota
+// evenly until it is reset. It is zero if the reset time is unknown or has
+// passed, and the time until the reset if no quota remains.
+func (q *Quota) Pace(now time.Time) time.Duration {
+	if q.Reset.IsZero() || !q.Reset.After(now) {
+		return 0
+	}
+	if q.Remaining <= 0 {
+		return q.Reset.Sub(now)
+	}
+
+	return q.Reset.Sub(now) / time.Duration(q.Remaining)
+}
+
+// QuotaStatus returns the quota reported with the most recent response. If
+// no call has been made with c yet, it fetches the fallback rate of the last
+// hour to learn it, which counts against the quota like any other call.
+func (c *Client) QuotaStatus(ctx context.Context) (*Quota, error) {
+	if q := c.lastQuota(); q != nil {
+		return q, nil
+	}
+
+	to := time.Now()
+	if _, err := c.FallbackRateTotal(ctx, &Filter{From: to.Add(-time.Hour), To: to}); err != nil {
+		return nil, err
+	}
+
+	if q := c.lastQuota(); q != nil {
+		return q, nil
+	}
+
+	return nil, ErrQuotaUnavailable
+}
+
+func (c *Client) lastQuota() *Quota {
+	c.quotaMu.Lock()
+	defer c.quotaMu.Unlock()
+
+	if c.quota == nil {
+		return nil
+	}
+	q := *c.quota
+	return &q
+}
+
+// recordQuota keeps the quota reported in the headers of resp, if any.
+func (c *Client) recordQuota(resp *http.Response) {
+	q := parseQuota(resp.Header, time.Now())
+	if q == nil {
+		return
+	}
+
+	c.quotaMu.Lock()
+	c.quota = q
+	c.quotaMu.Unlock()
+}
+
+func parseQuota(h http.Header, now time.Time) *Quota {
+	limit, err := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return nil
+	}
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return nil
+	}
+
+	q := &Quota{Limit: limit, Remaining: remaining, Observed: now}
+	// The reset is either a Unix time or a number of seconds from now.
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		if reset > 1e9 {
+			q.Reset = time.Unix(reset, 0)
+		} else {
+			q.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+
+	return q
+}
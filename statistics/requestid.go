@@ -0,0 +1,21 @@
+package statistics
+
+import (
+	"context"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context that makes the client send id as the
+// X-Request-ID header on upstream requests, and include it in log lines, so
+// that upstream calls can be correlated with the request that caused them.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID set with WithRequestID, or an
+// empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
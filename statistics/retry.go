@@ -0,0 +1,68 @@
+package statistics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// defaultNetworkRetries is how often a call failing with a transient network
+// error is retried unless configured with WithNetworkRetries.
+const defaultNetworkRetries = 3
+
+// networkRetryBase is the wait before the first retry of a transient network
+// error. It doubles with every retry.
+const networkRetryBase = time.Second
+
+// WithNetworkRetries makes the client retry calls failing with a transient
+// network error, such as a connection reset, a refused connection, a failed
+// DNS lookup or a timeout, up to n times, waiting 1s, 2s, 4s and so on in
+// between. The default is 3; 0 disables the retries. Calls are retried on 429
+// and 503 responses regardless.
+func WithNetworkRetries(n int) ClientOption {
+	return func(c *Client) {
+		if n <= 0 {
+			n = -1
+		}
+		c.networkRetries = n
+	}
+}
+
+// maxNetworkRetries returns the number of retries of transient network
+// errors.
+func (c *Client) maxNetworkRetries() int {
+	switch {
+	case c.networkRetries < 0:
+		return 0
+	case c.networkRetries == 0:
+		return defaultNetworkRetries
+	default:
+		return c.networkRetries
+	}
+}
+
+// isTransient reports whether err is a network error that may not recur,
+// as opposed to an error response or a cancelled context.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,269 @@
+package statistics
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WithStrictDecoding fails calls whose response has fields the client does
+// not decode, instead of ignoring them, so renamed fields are noticed rather
+// than read as zero values. See CheckSchema for fields that are missing.
+func WithStrictDecoding() ClientOption {
+	return func(c *Client) {
+		c.strictDecoding = true
+	}
+}
+
+// SchemaDrift is how the fields of the responses of an endpoint differ from
+// the fields the client decodes.
+type SchemaDrift struct {
+	Endpoint string
+	// Unknown are the fields of the responses the client does not decode,
+	// such as "emojis[].label".
+	Unknown []string
+	// Missing are the fields the client decodes that no response had.
+	Missing []string
+}
+
+// SchemaCheck collects the drift of the responses of calls made with a
+// context returned by WithSchemaCheck. It is safe for concurrent use.
+type SchemaCheck struct {
+	mu    sync.Mutex
+	drift map[string]*SchemaDrift
+}
+
+type schemaKey struct{}
+
+// WithSchemaCheck returns a context that makes the client compare the fields
+// of the responses to calls using it with those it decodes, in c.
+func WithSchemaCheck(ctx context.Context, c *SchemaCheck) context.Context {
+	return context.WithValue(ctx, schemaKey{}, c)
+}
+
+// Drift returns the endpoints whose responses had unknown or missing fields,
+// ordered by endpoint.
+func (c *SchemaCheck) Drift() []*SchemaDrift {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ret := make([]*SchemaDrift, 0, len(c.drift))
+	for _, d := range c.drift {
+		if len(d.Unknown) > 0 || len(d.Missing) > 0 {
+			ret = append(ret, d)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Endpoint < ret[j].Endpoint
+	})
+
+	return ret
+}
+
+func (c *SchemaCheck) add(endpoint string, unknown, missing []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.drift == nil {
+		c.drift = make(map[string]*SchemaDrift)
+	}
+	d, ok := c.drift[endpoint]
+	if !ok {
+		d = &SchemaDrift{Endpoint: endpoint}
+		c.drift[endpoint] = d
+	}
+	d.Unknown = mergeFields(d.Unknown, unknown)
+	d.Missing = mergeFields(d.Missing, missing)
+}
+
+func mergeFields(a, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	for _, f := range a {
+		seen[f] = true
+	}
+	for _, f := range b {
+		if !seen[f] {
+			seen[f] = true
+			a = append(a, f)
+		}
+	}
+	sort.Strings(a)
+
+	return a
+}
+
+// checkSchema records the drift of data, decoded into v, in the SchemaCheck
+// of ctx, if any.
+func checkSchema(ctx context.Context, data json.RawMessage, v interface{}) {
+	c, _ := ctx.Value(schemaKey{}).(*SchemaCheck)
+	if c == nil || v == nil {
+		return
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	var unknown, missing []string
+	compareFields(raw, reflect.TypeOf(v), "", &unknown, &missing)
+	c.add(endpointFromContext(ctx), unknown, missing)
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// compareFields adds the fields of raw that t does not decode to unknown,
+// and the fields t decodes that raw does not have to missing, prefixed by
+// path. Types decoding themselves are not looked into.
+func compareFields(raw interface{}, t reflect.Type, path string, unknown, missing *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshaler) || reflect.PtrTo(t).Implements(textUnmarshaler) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		items, ok := raw.([]interface{})
+		if !ok || len(items) == 0 {
+			return
+		}
+		// A field is only missing if no item has it.
+		var itemMissing []string
+		for i, item := range items {
+			var m []string
+			compareFields(item, t.Elem(), path+"[]", unknown, &m)
+			if i == 0 {
+				itemMissing = m
+			} else {
+				itemMissing = intersectFields(itemMissing, m)
+			}
+		}
+		*missing = append(*missing, itemMissing...)
+	case reflect.Map:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, v := range obj {
+			compareFields(v, t.Elem(), path+"."+k, unknown, missing)
+		}
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		for k, v := range obj {
+			f, ok := fields[strings.ToLower(k)]
+			if !ok {
+				*unknown = append(*unknown, fieldPath(path, k))
+				continue
+			}
+			compareFields(v, f.Type, fieldPath(path, k), unknown, missing)
+		}
+		for name := range fields {
+			if _, ok := lookupFold(obj, name); !ok {
+				*missing = append(*missing, fieldPath(path, name))
+			}
+		}
+	}
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// jsonFields returns the exported fields of t decoded by encoding/json, by
+// their lowercased JSON name, since encoding/json matches names
+// case-insensitively.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			for k, v := range jsonFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		fields[strings.ToLower(jsonName(f))] = f
+	}
+
+	return fields
+}
+
+func jsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+
+	return f.Name
+}
+
+func lookupFold(obj map[string]interface{}, name string) (interface{}, bool) {
+	for k, v := range obj {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+func intersectFields(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, f := range b {
+		in[f] = true
+	}
+	var ret []string
+	for _, f := range a {
+		if in[f] {
+			ret = append(ret, f)
+		}
+	}
+
+	return ret
+}
+
+// CheckSchema calls every endpoint of Service for f and returns how their
+// responses differ from the fields the client decodes, so changes upstream
+// are noticed before they corrupt reports. All endpoints are called even if
+// some fail; the first error is returned with the drift of the others.
+func CheckSchema(ctx context.Context, c *Client, f *Filter) ([]*SchemaDrift, error) {
+	check := &SchemaCheck{}
+	ctx = WithSchemaCheck(ctx, check)
+
+	var first error
+	for _, call := range []func() error{
+		func() error { _, err := c.AggregatedFeedback(ctx, f); return err },
+		func() error { _, err := c.HandoversTotal(ctx, f); return err },
+		func() error { _, err := c.HandoversTimeSeries(ctx, f); return err },
+		func() error { _, err := c.PageStatistics(ctx, f); return err },
+		func() error { _, err := c.FallbackRateTotal(ctx, f); return err },
+		func() error { _, err := c.FallbackRateTimeSeries(ctx, f); return err },
+		func() error { _, err := c.UserMessages(ctx, f); return err },
+		func() error { _, err := c.ChatSessions(ctx, f); return err },
+		func() error { _, err := c.ChatLabels(ctx, f); return err },
+	} {
+		if err := call(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return check.Drift(), first
+}
@@ -0,0 +1,20 @@
+package statistics
+
+import "context"
+
+// Service is the Statistics API as used by the tools in this module. *Client
+// is the default implementation; alternative backends can be swapped in by
+// implementing it.
+type Service interface {
+	AggregatedFeedback(ctx context.Context, f *Filter) (*Feedback, error)
+	HandoversTotal(ctx context.Context, f *Filter) (*Handovers, error)
+	HandoversTimeSeries(ctx context.Context, f *Filter) ([]*HandoversTimeSeries, error)
+	PageStatistics(ctx context.Context, f *Filter) ([]*PageStatistic, error)
+	FallbackRateTotal(ctx context.Context, f *Filter) (*RateTotal, error)
+	FallbackRateTimeSeries(ctx context.Context, f *Filter) ([]*CountByDateWithRate, error)
+	UserMessages(ctx context.Context, f *Filter) ([]*CountByDate, error)
+	ChatSessions(ctx context.Context, f *Filter) ([]*CountByDate, error)
+	ChatLabels(ctx context.Context, f *Filter) ([]*ChatLabel, error)
+}
+
+var _ Service = (*Client)(nil)
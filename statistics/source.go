@@ -0,0 +1,172 @@
+package statistics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Source is a channel chats come from, such as the web chat or Facebook
+// Messenger. Sage silently reports no data for sources it does not know, so
+// sources are parsed with ParseSource rather than converted from strings.
+type Source string
+
+const (
+	Web      Source = "web"
+	App      Source = "app"
+	Facebook Source = "facebook"
+	Slack    Source = "slack"
+	API      Source = "api"
+	Test     Source = "test"
+)
+
+// KnownSources are the sources of most bots. They serve as a hint for typos:
+// ParseSource rejects near misses of them, such as "facebok", but accepts
+// other sources, as Kindly adds channels over time. Client.Sources lists the
+// sources of a bot.
+var KnownSources = []Source{Web, App, Facebook, Slack, API, Test}
+
+func (s Source) String() string {
+	return string(s)
+}
+
+// Known reports whether s is one of KnownSources.
+func (s Source) Known() bool {
+	for _, k := range KnownSources {
+		if s == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check returns why s is not a valid source name, or nil if it is.
+func (s Source) check() error {
+	if s == "" {
+		return fmt.Errorf("empty source")
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return fmt.Errorf("invalid source %q, want lowercase letters, digits, \"_\" and \"-\"", s)
+		}
+	}
+	if typo, ok := s.typoOf(); ok {
+		return fmt.Errorf("unknown source %q, did you mean %q? want %s or another source of the bot", s, typo, sourceList(KnownSources))
+	}
+
+	return nil
+}
+
+// typoOf returns the known source s is a near miss of, if any. Names of up
+// to four letters may differ by one edit, longer names by two, where a
+// transposition of adjacent letters counts as one.
+func (s Source) typoOf() (Source, bool) {
+	if s.Known() {
+		return "", false
+	}
+
+	for _, k := range KnownSources {
+		max := 1
+		if len(k) > 4 {
+			max = 2
+		}
+		if editDistance(string(s), string(k)) <= max {
+			return k, true
+		}
+	}
+
+	return "", false
+}
+
+// editDistance returns the optimal string alignment distance of a and b.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+func minInt(v int, vs ...int) int {
+	for _, w := range vs {
+		if w < v {
+			v = w
+		}
+	}
+
+	return v
+}
+
+// ParseSource returns the source named s, ignoring case and surrounding
+// space. Empty names, names with other characters than letters, digits, "_"
+// and "-", and near misses of KnownSources are an error.
+func ParseSource(s string) (Source, error) {
+	v := Source(strings.ToLower(strings.TrimSpace(s)))
+	if err := v.check(); err != nil {
+		return "", fmt.Errorf("statistics: %w", err)
+	}
+
+	return v, nil
+}
+
+// ParseSources parses every name in names with ParseSource.
+func ParseSources(names []string) ([]Source, error) {
+	ret := make([]Source, 0, len(names))
+	for _, name := range names {
+		s, err := ParseSource(name)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+
+	return ret, nil
+}
+
+// SourceStrings returns the names of sources.
+func SourceStrings(sources []Source) []string {
+	ret := make([]string, 0, len(sources))
+	for _, s := range sources {
+		ret = append(ret, string(s))
+	}
+
+	return ret
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Source) MarshalText() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Source) UnmarshalText(b []byte) error {
+	v, err := ParseSource(string(b))
+	if err != nil {
+		return err
+	}
+	*s = v
+
+	return nil
+}
+
+func sourceList(sources []Source) string {
+	return strings.Join(SourceStrings(sources), ", ")
+}
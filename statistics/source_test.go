@@ -0,0 +1,56 @@
+package statistics_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestParseSource(t *testing.T) {
+	for in, want := range map[string]statistics.Source{"web": statistics.Web, " Facebook": statistics.Facebook, "SLACK": statistics.Slack, "WhatsApp": "whatsapp", "sms": "sms"} {
+		if got, err := statistics.ParseSource(in); err != nil || got != want {
+			t.Errorf("ParseSource(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+
+	for in, typo := range map[string]string{"wbe": "web", "facebok": "facebook", "Facbeook": "facebook", "slakc": "slack", "ap": "app"} {
+		_, err := statistics.ParseSource(in)
+		if err == nil || !strings.Contains(err.Error(), "did you mean \""+typo+"\"") || !strings.Contains(err.Error(), "want web, app, facebook") {
+			t.Errorf("ParseSource(%q) err=%v, want a hint of %s and the known sources listed", in, err, typo)
+		}
+	}
+	if _, err := statistics.ParseSources([]string{"web", ""}); err == nil {
+		t.Errorf("got no error for an empty source")
+	}
+	if _, err := statistics.ParseSource("web,facebook"); err == nil {
+		t.Errorf("got no error for a list of sources")
+	}
+}
+
+func TestFilter_ValidateSources(t *testing.T) {
+	f := &statistics.Filter{Sources: []statistics.Source{"whatsapp"}}
+	if err := f.Validate(); err != nil {
+		t.Errorf("Validate() err=%v, want other sources than the known ones accepted", err)
+	}
+
+	f.Sources = []statistics.Source{"facebok"}
+	if err := f.Validate(); err == nil {
+		t.Errorf("Validate() got no error for a typo of a known source")
+	}
+}
+
+func TestSource_UnmarshalText(t *testing.T) {
+	var p statistics.Preset
+	if err := json.Unmarshal([]byte(`{"name": "x", "metric": "sessions", "days": 7, "sources": ["Web", "app"]}`), &p); err != nil {
+		t.Fatalf("Unmarshal() err=%v", err)
+	}
+	if len(p.Sources) != 2 || p.Sources[0] != statistics.Web || p.Sources[1] != statistics.App {
+		t.Errorf("got sources %v, want web and app", p.Sources)
+	}
+
+	if err := json.Unmarshal([]byte(`{"sources": ["facebok"]}`), &p); err == nil {
+		t.Errorf("got no error for an unknown source")
+	}
+}
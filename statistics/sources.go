@@ -0,0 +1,32 @@
+package statistics
+
+import (
+	"context"
+	"sort"
+)
+
+// Sources returns the sources, such as Web and Facebook, the bot has
+// had chats from in the period of f, sorted by name. With a nil f every
+// source the bot ever had chats from is returned.
+func (c *Client) Sources(ctx context.Context, f *Filter) ([]Source, error) {
+	req, err := c.newRequest(ctx, "sources", f)
+	if err != nil {
+		return nil, err
+	}
+
+	// The names are taken as reported rather than parsed, so sources that
+	// look like typos of KnownSources are listed too.
+	names := make([]string, 0)
+	if err := c.do(req, &names); err != nil {
+		return nil, err
+	}
+	ret := make([]Source, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, Source(name))
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i] < ret[j]
+	})
+
+	return ret, nil
+}
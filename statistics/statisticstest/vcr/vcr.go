@@ -0,0 +1,359 @@
+// Package vcr records the responses of the Kindly APIs to fixture files and
+// replays them, so tests of clients can run against real responses without
+// network access or credentials.
+//
+// A Recorder is a Doer for statistics.WithDoer, or any client with a Doer
+// option. Without a fixture file it records through a real Doer and writes
+// the file on Save; with a fixture file it replays it:
+//
+//	rec, err := vcr.New("testdata/sessions.json", vcr.WithSecrets(os.Getenv("KINDLY_API_KEY")))
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer rec.Save()
+//	c := statistics.NewClient(statistics.WithDoer(rec))
+//
+// Set VCR_MODE=record to record the fixtures again.
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in fixtures.
+const Redacted = "REDACTED"
+
+// Mode selects whether a Recorder records or replays.
+type Mode int
+
+const (
+	// Auto replays the fixture file if it exists and records it otherwise,
+	// unless VCR_MODE is "record" or "replay".
+	Auto Mode = iota
+	// Record performs every request and records the responses, replacing
+	// the fixture file on Save.
+	Record
+	// Replay only serves recorded responses and fails other requests.
+	Replay
+)
+
+// Doer performs HTTP requests, such as *http.Client.
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the part of a request that responses are matched by.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// fixture is the file format of a Recorder.
+type fixture struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Option configures a Recorder.
+type Option func(r *Recorder)
+
+// WithDoer records through d instead of http.DefaultClient.
+func WithDoer(d Doer) Option {
+	return func(r *Recorder) {
+		r.doer = d
+	}
+}
+
+// WithMode overrides Auto.
+func WithMode(m Mode) Option {
+	return func(r *Recorder) {
+		r.mode = m
+	}
+}
+
+// WithSecrets replaces secrets, such as API keys and tokens, with Redacted
+// wherever they occur in recorded URLs, headers and bodies. Empty secrets are
+// ignored.
+func WithSecrets(secrets ...string) Option {
+	return func(r *Recorder) {
+		for _, s := range secrets {
+			if s != "" {
+				r.secrets = append(r.secrets, s)
+			}
+		}
+	}
+}
+
+// WithRedactedFields replaces the values of the named fields of JSON
+// response bodies with Redacted, at any depth, such as "jwt" or
+// "access_token".
+func WithRedactedFields(names ...string) Option {
+	return func(r *Recorder) {
+		for _, n := range names {
+			r.fields[n] = true
+		}
+	}
+}
+
+// redactedHeaders are response headers never recorded.
+var redactedHeaders = []string{"Set-Cookie", "Authorization", "Www-Authenticate"}
+
+// Recorder is a Doer that records or replays responses. It is safe for
+// concurrent use; concurrent requests for the same URL are replayed in the
+// order they were recorded.
+type Recorder struct {
+	path    string
+	doer    Doer
+	mode    Mode
+	secrets []string
+	fields  map[string]bool
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// New returns a Recorder of the fixture file at path.
+func New(path string, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:   path,
+		doer:   http.DefaultClient,
+		fields: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.mode == Auto {
+		switch os.Getenv("VCR_MODE") {
+		case "record":
+			r.mode = Record
+		case "replay":
+			r.mode = Replay
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && r.mode != Replay:
+		r.mode = Record
+		return r, nil
+	case err != nil:
+		return nil, fmt.Errorf("vcr: %w", err)
+	case r.mode == Record:
+		return r, nil
+	}
+
+	var f fixture
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("vcr: parsing %s: %w", path, err)
+	}
+	r.mode = Replay
+	r.interactions = f.Interactions
+	r.used = make([]bool, len(f.Interactions))
+
+	return r, nil
+}
+
+// Recording reports whether r records responses.
+func (r *Recorder) Recording() bool {
+	return r.mode == Record
+}
+
+// Do implements Doer.
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	if r.mode == Record {
+		return r.record(req)
+	}
+
+	return r.replay(req)
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	resp, err := r.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := resp.Header.Clone()
+	for _, h := range redactedHeaders {
+		hdr.Del(h)
+	}
+	for k, vs := range hdr {
+		for i := range vs {
+			vs[i] = r.scrub(vs[i])
+		}
+		hdr[k] = vs
+	}
+
+	in := &Interaction{
+		Request: Request{Method: req.Method, URL: r.scrub(req.URL.String())},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     hdr,
+			Body:       r.scrub(string(r.redactFields(body))),
+		},
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+
+	// The caller gets the response as received, not as recorded.
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	u := r.scrub(req.URL.String())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if r.used[i] || in.Request.Method != req.Method || !sameURL(in.Request.URL, u) {
+			continue
+		}
+		r.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("vcr: no recorded response for %s %s in %s", req.Method, u, r.path)
+}
+
+// sameURL reports whether the URLs are equal, regardless of the order of
+// their query parameters.
+func sameURL(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return a == b
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return a == b
+	}
+	qa, qb := ua.Query(), ub.Query()
+	ua.RawQuery, ub.RawQuery = "", ""
+
+	return ua.String() == ub.String() && qa.Encode() == qb.Encode()
+}
+
+// Save writes the recorded interactions to the fixture file, creating its
+// directory. It does nothing when replaying.
+func (r *Recorder) Save() error {
+	if r.mode != Record {
+		return nil
+	}
+
+	r.mu.Lock()
+	b, err := json.MarshalIndent(&fixture{Interactions: r.interactions}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("vcr: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("vcr: %w", err)
+	}
+	if err := ioutil.WriteFile(r.path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("vcr: %w", err)
+	}
+
+	return nil
+}
+
+// scrub replaces the secrets of r in s.
+func (r *Recorder) scrub(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+		// Secrets in URLs are escaped.
+		if escaped := url.QueryEscape(secret); escaped != secret {
+			s = strings.ReplaceAll(s, escaped, Redacted)
+		}
+	}
+
+	return s
+}
+
+// redactFields replaces the values of the redacted fields in the JSON
+// document b. Other bodies are returned as they are.
+func (r *Recorder) redactFields(b []byte) []byte {
+	if len(r.fields) == 0 {
+		return b
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return b
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return b
+	}
+
+	out, err := json.Marshal(r.redact(v))
+	if err != nil {
+		return b
+	}
+
+	return out
+}
+
+func (r *Recorder) redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if r.fields[k] {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = r.redact(val)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = r.redact(v[i])
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,90 @@
+package vcr_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+	"github.com/atb-as/kindly/statistics/statisticstest/vcr"
+)
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return d(r)
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "sessions.json")
+	f := &statistics.Filter{
+		From: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2021, 2, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	var calls int
+	upstream := doerFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Set-Cookie": {"s=secret-key"}, "X-Trace": {"secret-key"}},
+			Body:       io.NopCloser(strings.NewReader(`{"data":[{"count":3,"date":"2021-02-01T00:00:00.000000"}],"jwt":"token"}`)),
+		}, nil
+	})
+
+	rec, err := vcr.New(path, vcr.WithDoer(upstream), vcr.WithSecrets("secret-key"), vcr.WithRedactedFields("jwt"))
+	if err != nil {
+		t.Fatalf("New() err=%v", err)
+	}
+	if !rec.Recording() {
+		t.Fatalf("Recording()=false without a fixture file")
+	}
+	c := statistics.NewClient(statistics.WithDoer(rec))
+	c.BotID = "secret-key"
+	if _, err := c.ChatSessions(context.Background(), f); err != nil {
+		t.Fatalf("recording ChatSessions() err=%v", err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save() err=%v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); strings.Contains(s, "secret-key") || strings.Contains(s, "s=") || strings.Contains(s, `\"jwt\":\"token\"`) {
+		t.Errorf("fixture contains secrets:\n%s", s)
+	}
+
+	rec, err = vcr.New(path, vcr.WithDoer(upstream), vcr.WithSecrets("secret-key"), vcr.WithMode(vcr.Replay))
+	if err != nil {
+		t.Fatalf("New() err=%v", err)
+	}
+	c = statistics.NewClient(statistics.WithDoer(rec))
+	c.BotID = "secret-key"
+	got, err := c.ChatSessions(context.Background(), f)
+	if err != nil {
+		t.Fatalf("replaying ChatSessions() err=%v", err)
+	}
+	if len(got) != 1 || got[0].Count != 3 {
+		t.Errorf("got %+v, want a count of 3", got)
+	}
+	if calls != 1 {
+		t.Errorf("got %d upstream calls, want 1", calls)
+	}
+
+	if _, err := c.ChatSessions(context.Background(), f); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("got err=%v replaying a request twice, want no recorded response", err)
+	}
+}
+
+func TestRecorder_ReplayWithoutFixture(t *testing.T) {
+	if _, err := vcr.New(filepath.Join(t.TempDir(), "missing.json"), vcr.WithMode(vcr.Replay)); err == nil {
+		t.Errorf("New() err=nil, want missing fixture")
+	}
+}
@@ -0,0 +1,168 @@
+package statistics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// The Stream methods are variants of the time series methods that decode the
+// response one item at a time and pass each item to fn as it is read, instead
+// of buffering the whole series. Use them for long, fine-grained ranges such
+// as a year of hourly data. Returning an error from fn stops the stream and
+// is returned to the caller. The response size limit and timeouts of the
+// client apply to the whole stream. Streamed responses are never held in
+// memory as a whole, so they are not revalidated with conditional requests,
+// checked for schema drift or recorded.
+
+// StreamUserMessages is the streaming variant of UserMessages.
+func (c *Client) StreamUserMessages(ctx context.Context, f *Filter, fn func(*CountByDate) error) error {
+	return c.streamCounts(ctx, "sessions/messages", f, fn)
+}
+
+// StreamChatSessions is the streaming variant of ChatSessions.
+func (c *Client) StreamChatSessions(ctx context.Context, f *Filter, fn func(*CountByDate) error) error {
+	return c.streamCounts(ctx, "sessions/chats", f, fn)
+}
+
+// StreamFallbackRateTimeSeries is the streaming variant of
+// FallbackRateTimeSeries.
+func (c *Client) StreamFallbackRateTimeSeries(ctx context.Context, f *Filter, fn func(*CountByDateWithRate) error) error {
+	req, err := c.newRequest(ctx, "fallbacks/series", f)
+	if err != nil {
+		return err
+	}
+
+	return c.doStream(req, func(dec *json.Decoder) error {
+		item := &CountByDateWithRate{}
+		if err := dec.Decode(item); err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+// StreamHandoversTimeSeries is the streaming variant of HandoversTimeSeries.
+func (c *Client) StreamHandoversTimeSeries(ctx context.Context, f *Filter, fn func(*HandoversTimeSeries) error) error {
+	req, err := c.newRequest(ctx, "takeovers/series", f)
+	if err != nil {
+		return err
+	}
+
+	return c.doStream(req, func(dec *json.Decoder) error {
+		item := &HandoversTimeSeries{}
+		if err := dec.Decode(item); err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+func (c *Client) streamCounts(ctx context.Context, endpoint string, f *Filter, fn func(*CountByDate) error) error {
+	req, err := c.newRequest(ctx, endpoint, f)
+	if err != nil {
+		return err
+	}
+
+	return c.doStream(req, func(dec *json.Decoder) error {
+		item := &CountByDate{}
+		if err := dec.Decode(item); err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+// doStream executes r and calls decodeItem once for every element of the
+// "data" array in the response, with dec positioned at the element.
+func (c *Client) doStream(r *http.Request, decodeItem func(dec *json.Decoder) error) error {
+	if c.doer == nil {
+		c.doer = http.DefaultClient
+	}
+
+	// Every attempt has a timeout of its own, which for the last one also
+	// covers reading the stream.
+	cancel := func() {}
+	defer func() { cancel() }()
+	var timedOut func(error) error
+	var resp *http.Response
+	err := c.withRetries(r, func() error {
+		cancel()
+		var attempt *http.Request
+		attempt, cancel, timedOut = c.withTimeout(r)
+		var err error
+		resp, err = c.send(attempt)
+		return timedOut(err)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return timedOut(c.decodeStream(c.limitResponse(endpointFromContext(r.Context()), resp.Body), decodeItem))
+}
+
+// decodeStream calls decodeItem once for every element of the "data" array
+// in body.
+func (c *Client) decodeStream(body io.Reader, decodeItem func(dec *json.Decoder) error) error {
+	dec := json.NewDecoder(body)
+	if c.strictDecoding {
+		dec.DisallowUnknownFields()
+	}
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if key, ok := tok.(string); !ok || key != "data" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok == nil {
+			continue
+		}
+		if tok != json.Delim('[') {
+			return fmt.Errorf("statistics: stream: expected array, got %v", tok)
+		}
+
+		for dec.More() {
+			if err := decodeItem(dec); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+
+	// The rest of the response is read too, so it counts towards the
+	// response size limit.
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("statistics: stream: expected %v, got %v", delim, tok)
+	}
+
+	return nil
+}
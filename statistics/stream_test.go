@@ -0,0 +1,111 @@
+package statistics_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/statistics"
+)
+
+func TestClient_StreamChatSessions(t *testing.T) {
+	var body strings.Builder
+	body.WriteString(`{"meta":{"ignored":[1,2]},"data":[`)
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			body.WriteString(",")
+		}
+		fmt.Fprintf(&body, `{"count":%d,"date":"2021-02-01T00:00:00.000000"}`, i)
+	}
+	body.WriteString(`]}`)
+
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body.String()))}, nil
+	})))
+
+	n, sum := 0, 0
+	err := c.StreamChatSessions(context.Background(), nil, func(c *statistics.CountByDate) error {
+		n++
+		sum += c.Count
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChatSessions() err=%v", err)
+	}
+	if n != 1000 || sum != 999*1000/2 {
+		t.Errorf("got %d items summing to %d, want 1000 items summing to %d", n, sum, 999*1000/2)
+	}
+}
+
+func TestClient_StreamStops(t *testing.T) {
+	c := statistics.NewClient(statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[{"count":1},{"count":2}]}`))}, nil
+	})))
+
+	stop := errors.New("stop")
+	n := 0
+	err := c.StreamUserMessages(context.Background(), nil, func(c *statistics.CountByDate) error {
+		n++
+		return stop
+	})
+	if err != stop {
+		t.Errorf("got err=%v, want %v", err, stop)
+	}
+	if n != 1 {
+		t.Errorf("got %d calls, want 1", n)
+	}
+}
+
+func TestClient_StreamLimits(t *testing.T) {
+	body := `{"data":[{"date":"2021-02-01T00:00:00.000000","count":2}]}`
+	doer := doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+	count := func(*statistics.CountByDate) error { return nil }
+
+	c := statistics.NewClient(statistics.WithDoer(doer), statistics.WithMaxResponseSize(int64(len(body)-1)))
+	if err := c.StreamChatSessions(context.Background(), &statistics.Filter{}, count); !errors.Is(err, statistics.ErrResponseTooLarge) {
+		t.Errorf("StreamChatSessions() err=%v, want ErrResponseTooLarge", err)
+	}
+
+	c = statistics.NewClient(statistics.WithDoer(doer), statistics.WithMaxResponseSize(int64(len(body))))
+	if err := c.StreamChatSessions(context.Background(), &statistics.Filter{}, count); err != nil {
+		t.Errorf("StreamChatSessions() err=%v", err)
+	}
+
+	// The timeout also covers reading the stream.
+	c = statistics.NewClient(
+		statistics.WithEndpointTimeout("sessions/chats", 10*time.Millisecond),
+		statistics.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: &stalledBody{ctx: r.Context(), data: `{"data":[`}}, nil
+		})))
+	if err := c.StreamChatSessions(context.Background(), &statistics.Filter{}, count); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("StreamChatSessions() err=%v, want a deadline exceeded", err)
+	}
+}
+
+// stalledBody returns data, then blocks until ctx is done.
+type stalledBody struct {
+	ctx  context.Context
+	data string
+}
+
+func (b *stalledBody) Read(p []byte) (int, error) {
+	if b.data != "" {
+		n := copy(p, b.data)
+		b.data = b.data[n:]
+		return n, nil
+	}
+	<-b.ctx.Done()
+
+	return 0, b.ctx.Err()
+}
+
+func (b *stalledBody) Close() error {
+	return nil
+}
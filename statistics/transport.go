@@ -0,0 +1,72 @@
+package statistics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// WithTransport sends requests through rt, for instance one returned by
+// NewTransport. If the doer is an *http.Client, including one created by
+// oauth2.NewClient, rt replaces the transport underneath the authentication
+// so the two options can be combined in any order. Other doers are left
+// as is.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+// withTransport returns a copy of doer that sends requests through rt.
+func withTransport(doer Doer, rt http.RoundTripper) Doer {
+	hc, ok := doer.(*http.Client)
+	if !ok {
+		return doer
+	}
+
+	client := *hc
+	if t, ok := hc.Transport.(*oauth2.Transport); ok {
+		base := *t
+		base.Base = rt
+		client.Transport = &base
+	} else {
+		client.Transport = rt
+	}
+
+	return &client
+}
+
+// NewTransport returns a copy of http.DefaultTransport that trusts the PEM
+// encoded certificates in caFiles in addition to the system roots. Like the
+// default transport it honours HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+func NewTransport(caFiles ...string) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if len(caFiles) == 0 {
+		return t, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, name := range caFiles {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("reading CA bundle %s: no certificates found", name)
+		}
+	}
+
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.RootCAs = pool
+
+	return t, nil
+}
@@ -0,0 +1,89 @@
+package statistics_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/atb-as/kindly/statistics"
+	"golang.org/x/oauth2"
+)
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestClient_WithTransport(t *testing.T) {
+	var gotAuth string
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		gotAuth = r.Header.Get("Authorization")
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader([]byte(`{"data":[]}`)))}, nil
+	})
+
+	t.Run("Default doer", func(t *testing.T) {
+		gotAuth = "unset"
+		c := statistics.NewClient(statistics.WithTransport(rt))
+		if _, err := c.ChatLabels(context.Background(), nil); err != nil {
+			t.Fatalf("c.ChatLabels() err=%v", err)
+		}
+		if gotAuth != "" {
+			t.Errorf("got Authorization %q, want none", gotAuth)
+		}
+	})
+	t.Run("OAuth2 doer", func(t *testing.T) {
+		doer := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}))
+		c := statistics.NewClient(statistics.WithTransport(rt), statistics.WithDoer(doer))
+		if _, err := c.ChatLabels(context.Background(), nil); err != nil {
+			t.Fatalf("c.ChatLabels() err=%v", err)
+		}
+		if want := "Bearer token"; gotAuth != want {
+			t.Errorf("got Authorization %q, want %q", gotAuth, want)
+		}
+	})
+}
+
+func TestNewTransport(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		caFiles []string
+		wantErr bool
+	}{
+		{"System roots", nil, true},
+		{"CA bundle", []string{ca}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, err := statistics.NewTransport(tt.caFiles...)
+			if err != nil {
+				t.Fatalf("statistics.NewTransport() err=%v", err)
+			}
+			c := statistics.NewClient(statistics.WithTransport(rt))
+			c.BaseURL = srv.URL
+
+			_, err = c.ChatLabels(context.Background(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("c.ChatLabels() err=%v, wantErr=%v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package statistics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxLimit is the largest Limit of a filter.
+const MaxLimit = 1000
+
+// seriesGranularities are the granularities of the endpoints serving a time
+// series. Other endpoints ignore the granularity.
+var seriesGranularities = map[string][]Granularity{
+	"sessions/chats":         {Day, Hour, Week},
+	"sessions/messages":      {Day, Hour, Week},
+	"fallbacks/series":       {Day, Week},
+	"takeovers/series":       {Day, Hour, Week},
+	"takeovers/queue/series": {Day, Week},
+}
+
+// EndpointGranularities returns the granularities the endpoint, such as
+// "sessions/chats", serves a time series by, or nil if it only serves totals
+// of the period.
+func EndpointGranularities(endpoint string) []Granularity {
+	return append([]Granularity(nil), seriesGranularities[endpoint]...)
+}
+
+// ValidationError lists every problem of an invalid filter.
+type ValidationError struct {
+	// Endpoint is the endpoint the filter was validated for, if any.
+	Endpoint string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Endpoint != "" {
+		return fmt.Sprintf("statistics: invalid filter for %s: %s", e.Endpoint, strings.Join(e.Problems, "; "))
+	}
+
+	return "statistics: invalid filter: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate returns a *ValidationError listing every problem of f that would
+// make Sage reject it, or answer it with nothing, such as a period ending
+// before it starts. A nil filter is valid.
+func (f *Filter) Validate() error {
+	return f.validate("")
+}
+
+// ValidateFor is like Validate, and also checks that the endpoint, such as
+// "sessions/chats", supports the granularity of f. The client validates
+// filters for their endpoint before sending any request.
+func (f *Filter) ValidateFor(endpoint string) error {
+	return f.validate(endpoint)
+}
+
+func (f *Filter) validate(endpoint string) error {
+	if f == nil {
+		return nil
+	}
+
+	var problems []string
+	if !f.From.IsZero() && !f.To.IsZero() && f.From.After(f.To) {
+		problems = append(problems, fmt.Sprintf("from %s is after to %s", f.From.Format(dateLayout), f.To.Format(dateLayout)))
+	}
+	if f.Timezone != "" {
+		if _, err := time.LoadLocation(f.Timezone); err != nil {
+			problems = append(problems, fmt.Sprintf("unknown time zone %q", f.Timezone))
+		}
+	}
+	if f.Limit < 0 || f.Limit > MaxLimit {
+		problems = append(problems, fmt.Sprintf("limit %d is out of bounds, want 0 (the default) to %d", f.Limit, MaxLimit))
+	}
+	switch f.Granularity {
+	case Unspecified, Day, Hour, Week:
+		if supported, ok := seriesGranularities[endpoint]; ok && f.Granularity != Unspecified && !hasGranularity(supported, f.Granularity) {
+			problems = append(problems, fmt.Sprintf("granularity %s is not supported by %s, want %s", f.Granularity, endpoint, granularityList(supported)))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown granularity %d", f.Granularity))
+	}
+	for _, source := range f.Sources {
+		if err := source.check(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Endpoint: endpoint, Problems: problems}
+}
+
+func hasGranularity(gs []Granularity, g Granularity) bool {
+	for _, s := range gs {
+		if s == g {
+			return true
+		}
+	}
+
+	return false
+}
+
+func granularityList(gs []Granularity) string {
+	names := make([]string, 0, len(gs))
+	for _, g := range gs {
+		names = append(names, g.String())
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,438 @@
+package statistics
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atb-as/kindly"
+	"github.com/atb-as/kindly/cache"
+)
+
+// WorkspaceClient is a Service whose statistics are summed across the bots
+// of a workspace, for management that only cares about the combined numbers.
+// Sage has no workspace-level endpoints, so every call is made once per bot
+// through a MultiClient. The sums can be cached with WithWorkspaceCache, so repeated
+// queries do not make a call per bot each time.
+type WorkspaceClient struct {
+	multi *MultiClient
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+var _ Service = (*WorkspaceClient)(nil)
+
+// WorkspaceOption configures a WorkspaceClient.
+type WorkspaceOption func(w *WorkspaceClient)
+
+// WithWorkspaceCache keeps the sums in c for ttl.
+func WithWorkspaceCache(c cache.Cache, ttl time.Duration) WorkspaceOption {
+	return func(w *WorkspaceClient) {
+		w.cache = c
+		w.ttl = ttl
+	}
+}
+
+// NewWorkspaceClient returns a client summing the statistics of bots, keyed
+// by bot ID. See BotClients to query several bots with one client.
+func NewWorkspaceClient(bots map[string]Service, opts ...WorkspaceOption) *WorkspaceClient {
+	w := &WorkspaceClient{multi: NewMultiClient(bots)}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// BotClients returns a Service per bot ID that calls the API of the bot with
+// c, see ForBot. The doer of c must be authorized for every bot.
+func BotClients(c *Client, botIDs ...string) map[string]Service {
+	ret := make(map[string]Service, len(botIDs))
+	for _, id := range botIDs {
+		ret[id] = &botClient{c: c, id: id}
+	}
+
+	return ret
+}
+
+// botClient is a Client calling the API of another bot than its own.
+type botClient struct {
+	c  *Client
+	id string
+}
+
+func (b *botClient) AggregatedFeedback(ctx context.Context, f *Filter) (*Feedback, error) {
+	return b.c.AggregatedFeedback(ForBot(ctx, b.id), f)
+}
+
+func (b *botClient) HandoversTotal(ctx context.Context, f *Filter) (*Handovers, error) {
+	return b.c.HandoversTotal(ForBot(ctx, b.id), f)
+}
+
+func (b *botClient) HandoversTimeSeries(ctx context.Context, f *Filter) ([]*HandoversTimeSeries, error) {
+	return b.c.HandoversTimeSeries(ForBot(ctx, b.id), f)
+}
+
+func (b *botClient) PageStatistics(ctx context.Context, f *Filter) ([]*PageStatistic, error) {
+	return b.c.PageStatistics(ForBot(ctx, b.id), f)
+}
+
+func (b *botClient) FallbackRateTotal(ctx context.Context, f *Filter) (*RateTotal, error) {
+	return b.c.FallbackRateTotal(ForBot(ctx, b.id), f)
+}
+
+func (b *botClient) FallbackRateTimeSeries(ctx context.Context, f *Filter) ([]*CountByDateWithRate, error) {
+	return b.c.FallbackRateTimeSeries(ForBot(ctx, b.id), f)
+}
+
+func (b *botClient) UserMessages(ctx context.Context, f *Filter) ([]*CountByDate, error) {
+	return b.c.UserMessages(ForBot(ctx, b.id), f)
+}
+
+func (b *botClient) ChatSessions(ctx context.Context, f *Filter) ([]*CountByDate, error) {
+	return b.c.ChatSessions(ForBot(ctx, b.id), f)
+}
+
+func (b *botClient) ChatLabels(ctx context.Context, f *Filter) ([]*ChatLabel, error) {
+	return b.c.ChatLabels(ForBot(ctx, b.id), f)
+}
+
+// AggregatedFeedback returns the ratings of every bot, summed per rating.
+// Ratios are of the sums.
+func (w *WorkspaceClient) AggregatedFeedback(ctx context.Context, f *Filter) (*Feedback, error) {
+	ret := &Feedback{}
+	err := w.cached(ctx, "feedback", f, ret, func() (interface{}, error) {
+		results, err := w.each(ctx, func(ctx context.Context, s Service) (interface{}, error) {
+			return s.AggregatedFeedback(ctx, f)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var binary, emojis [][]*Rating
+		for _, r := range results {
+			binary = append(binary, r.(*Feedback).Binary)
+			emojis = append(emojis, r.(*Feedback).Emojis)
+		}
+		return &Feedback{Binary: sumRatings(binary), Emojis: sumRatings(emojis)}, nil
+	})
+
+	return ret, err
+}
+
+// HandoversTotal returns the handovers of every bot, summed.
+func (w *WorkspaceClient) HandoversTotal(ctx context.Context, f *Filter) (*Handovers, error) {
+	ret := &Handovers{}
+	err := w.cached(ctx, "handovers", f, ret, func() (interface{}, error) {
+		results, err := w.each(ctx, func(ctx context.Context, s Service) (interface{}, error) {
+			return s.HandoversTotal(ctx, f)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sum := &Handovers{}
+		for _, r := range results {
+			sum.add(r.(*Handovers))
+		}
+		return sum, nil
+	})
+
+	return ret, err
+}
+
+// HandoversTimeSeries returns the handovers of every bot, summed per date.
+func (w *WorkspaceClient) HandoversTimeSeries(ctx context.Context, f *Filter) ([]*HandoversTimeSeries, error) {
+	var ret []*HandoversTimeSeries
+	err := w.cached(ctx, "handovers/series", f, &ret, func() (interface{}, error) {
+		results, err := w.each(ctx, func(ctx context.Context, s Service) (interface{}, error) {
+			return s.HandoversTimeSeries(ctx, f)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		byDate := make(map[time.Time]*HandoversTimeSeries)
+		sum := make([]*HandoversTimeSeries, 0)
+		for _, r := range results {
+			for _, h := range r.([]*HandoversTimeSeries) {
+				s, ok := byDate[h.Date.Time]
+				if !ok {
+					s = &HandoversTimeSeries{Date: h.Date}
+					byDate[h.Date.Time] = s
+					sum = append(sum, s)
+				}
+				s.add(&h.Handovers)
+			}
+		}
+		sort.Slice(sum, func(i, j int) bool { return sum[i].Date.Before(sum[j].Date.Time) })
+		return sum, nil
+	})
+
+	return ret, err
+}
+
+func (h *Handovers) add(o *Handovers) {
+	h.Ended += o.Ended
+	h.Requests += o.Requests
+	h.RequestsWhileClosed += o.RequestsWhileClosed
+	h.Started += o.Started
+}
+
+// PageStatistics returns the top f.Limit pages of all bots, with the
+// sessions and messages of pages shared by bots summed.
+func (w *WorkspaceClient) PageStatistics(ctx context.Context, f *Filter) ([]*PageStatistic, error) {
+	var ret []*PageStatistic
+	err := w.cached(ctx, "pages", f, &ret, func() (interface{}, error) {
+		results, err := w.each(ctx, func(ctx context.Context, s Service) (interface{}, error) {
+			return s.PageStatistics(ctx, f)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		type page struct{ host, path string }
+		byPage := make(map[page]*PageStatistic)
+		sum := make([]*PageStatistic, 0)
+		for _, r := range results {
+			for _, p := range r.([]*PageStatistic) {
+				s, ok := byPage[page{p.Host, p.Path}]
+				if !ok {
+					s = &PageStatistic{Host: p.Host, Path: p.Path}
+					byPage[page{p.Host, p.Path}] = s
+					sum = append(sum, s)
+				}
+				s.Sessions += p.Sessions
+				s.Messages += p.Messages
+			}
+		}
+		sort.SliceStable(sum, func(i, j int) bool { return sum[i].Sessions > sum[j].Sessions })
+		if f != nil && f.Limit > 0 && len(sum) > f.Limit {
+			sum = sum[:f.Limit]
+		}
+		return sum, nil
+	})
+
+	return ret, err
+}
+
+// FallbackRateTotal returns the fallbacks of every bot, summed, and their
+// share of the bot replies of all bots. The replies of a bot are derived from
+// its rate, so bots without fallbacks do not count towards the replies.
+func (w *WorkspaceClient) FallbackRateTotal(ctx context.Context, f *Filter) (*RateTotal, error) {
+	ret := &RateTotal{}
+	err := w.cached(ctx, "fallbacks", f, ret, func() (interface{}, error) {
+		results, err := w.each(ctx, func(ctx context.Context, s Service) (interface{}, error) {
+			return s.FallbackRateTotal(ctx, f)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var rates []*RateTotal
+		for _, r := range results {
+			rates = append(rates, r.(*RateTotal))
+		}
+		sum := sumRates(rates)
+		return &sum, nil
+	})
+
+	return ret, err
+}
+
+// FallbackRateTimeSeries returns the fallbacks of every bot per date, summed
+// as by FallbackRateTotal.
+func (w *WorkspaceClient) FallbackRateTimeSeries(ctx context.Context, f *Filter) ([]*CountByDateWithRate, error) {
+	var ret []*CountByDateWithRate
+	err := w.cached(ctx, "fallbacks/series", f, &ret, func() (interface{}, error) {
+		results, err := w.each(ctx, func(ctx context.Context, s Service) (interface{}, error) {
+			return s.FallbackRateTimeSeries(ctx, f)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		byDate := make(map[time.Time][]*RateTotal)
+		dates := make([]kindly.Time, 0)
+		for _, r := range results {
+			for _, c := range r.([]*CountByDateWithRate) {
+				if _, ok := byDate[c.Date.Time]; !ok {
+					dates = append(dates, c.Date)
+				}
+				byDate[c.Date.Time] = append(byDate[c.Date.Time], &RateTotal{Count: c.Count, Rate: c.Rate})
+			}
+		}
+		sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j].Time) })
+
+		sum := make([]*CountByDateWithRate, 0, len(dates))
+		for _, d := range dates {
+			rate := sumRates(byDate[d.Time])
+			sum = append(sum, &CountByDateWithRate{CountByDate: CountByDate{Count: rate.Count, Date: d}, Rate: rate.Rate})
+		}
+		return sum, nil
+	})
+
+	return ret, err
+}
+
+// UserMessages returns the user messages of every bot, summed per date.
+func (w *WorkspaceClient) UserMessages(ctx context.Context, f *Filter) ([]*CountByDate, error) {
+	return w.counts(ctx, "messages", f, Service.UserMessages)
+}
+
+// ChatSessions returns the chat sessions of every bot, summed per date.
+func (w *WorkspaceClient) ChatSessions(ctx context.Context, f *Filter) ([]*CountByDate, error) {
+	return w.counts(ctx, "sessions", f, Service.ChatSessions)
+}
+
+func (w *WorkspaceClient) counts(ctx context.Context, name string, f *Filter, fetch func(Service, context.Context, *Filter) ([]*CountByDate, error)) ([]*CountByDate, error) {
+	var ret []*CountByDate
+	err := w.cached(ctx, name, f, &ret, func() (interface{}, error) {
+		results, err := w.each(ctx, func(ctx context.Context, s Service) (interface{}, error) {
+			return fetch(s, ctx, f)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		byDate := make(map[time.Time]*CountByDate)
+		sum := make([]*CountByDate, 0)
+		for _, r := range results {
+			for _, c := range r.([]*CountByDate) {
+				s, ok := byDate[c.Date.Time]
+				if !ok {
+					s = &CountByDate{Date: c.Date}
+					byDate[c.Date.Time] = s
+					sum = append(sum, s)
+				}
+				s.Count += c.Count
+			}
+		}
+		sort.Slice(sum, func(i, j int) bool { return sum[i].Date.Before(sum[j].Date.Time) })
+		return sum, nil
+	})
+
+	return ret, err
+}
+
+// ChatLabels returns the top f.Limit labels of all bots. Labels belong to a
+// single bot, so they are not summed.
+func (w *WorkspaceClient) ChatLabels(ctx context.Context, f *Filter) ([]*ChatLabel, error) {
+	var ret []*ChatLabel
+	err := w.cached(ctx, "labels", f, &ret, func() (interface{}, error) {
+		results, err := w.each(ctx, func(ctx context.Context, s Service) (interface{}, error) {
+			return s.ChatLabels(ctx, f)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		all := make([]*ChatLabel, 0)
+		for _, r := range results {
+			all = append(all, r.([]*ChatLabel)...)
+		}
+		sort.SliceStable(all, func(i, j int) bool { return all[i].Count > all[j].Count })
+		if f != nil && f.Limit > 0 && len(all) > f.Limit {
+			all = all[:f.Limit]
+		}
+		return all, nil
+	})
+
+	return ret, err
+}
+
+// each calls fn for every bot and returns the results in the order of the
+// bot IDs. The first error fails the call.
+func (w *WorkspaceClient) each(ctx context.Context, fn func(ctx context.Context, s Service) (interface{}, error)) ([]interface{}, error) {
+	results := w.multi.Each(ctx, fn)
+
+	ret := make([]interface{}, 0, len(results))
+	for _, r := range results {
+		if r.Err != nil {
+			return nil, fmt.Errorf("bot %s: %w", r.BotID, r.Err)
+		}
+		ret = append(ret, r.Value)
+	}
+
+	return ret, nil
+}
+
+// cached decodes the sum named name for f from the cache into out, or
+// computes, caches and decodes it. Cache failures only cost the cache.
+func (w *WorkspaceClient) cached(ctx context.Context, name string, f *Filter, out interface{}, compute func() (interface{}, error)) error {
+	key := fmt.Sprintf("workspace|%s|%s|%s", strings.Join(w.multi.ids, ","), name, f.Query().Encode())
+	if w.cache != nil {
+		if b, ok, err := w.cache.Get(ctx, key); err == nil && ok {
+			if err := gob.NewDecoder(bytes.NewReader(b)).Decode(out); err == nil {
+				return nil
+			}
+		}
+	}
+
+	v, err := compute()
+	if err != nil {
+		return err
+	}
+
+	// The sum is copied into out through its encoding, which is also what
+	// is cached.
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	if w.cache != nil {
+		w.cache.Set(ctx, key, buf.Bytes(), w.ttl)
+	}
+
+	return gob.NewDecoder(bytes.NewReader(buf.Bytes())).Decode(out)
+}
+
+// sumRatings sums the counts of ratings per rating value, in order of the
+// rating, and recomputes the ratios of the sums.
+func sumRatings(ratings [][]*Rating) []*Rating {
+	byRating := make(map[int]*Rating)
+	sum := make([]*Rating, 0)
+	total := 0
+	for _, rs := range ratings {
+		for _, r := range rs {
+			s, ok := byRating[r.Rating]
+			if !ok {
+				s = &Rating{Rating: r.Rating}
+				byRating[r.Rating] = s
+				sum = append(sum, s)
+			}
+			s.Count += r.Count
+			total += r.Count
+		}
+	}
+	sort.Slice(sum, func(i, j int) bool { return sum[i].Rating < sum[j].Rating })
+	for _, s := range sum {
+		if total > 0 {
+			s.Ratio = float64(s.Count) / float64(total)
+		}
+	}
+
+	return sum
+}
+
+// sumRates sums fallback counts and computes their share of the replies
+// derived from each rate.
+func sumRates(rates []*RateTotal) RateTotal {
+	var sum RateTotal
+	var replies float64
+	for _, r := range rates {
+		sum.Count += r.Count
+		if r.Rate > 0 {
+			replies += float64(r.Count) / r.Rate
+		}
+	}
+	if replies > 0 {
+		sum.Rate = float64(sum.Count) / replies
+	}
+
+	return sum
+}
@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// GCStats describes the versions removed by GC.
+type GCStats struct {
+	// Expired is the number of versions removed for their age.
+	Expired int64
+	// Duplicates is the number of versions removed for being equal to the
+	// next version of the same URL.
+	Duplicates int64
+}
+
+// GC removes the versions fetched more than keep ago, except the latest
+// version of each URL, so the latest stays available offline. Of consecutive
+// equal versions of a URL only the last is kept. The file is then compacted
+// to release the space of the removed versions.
+func (s *Store) GC(ctx context.Context, keep time.Duration) (*GCStats, error) {
+	stats := &GCStats{}
+	res, err := s.db.ExecContext(ctx, `
+DELETE FROM responses
+WHERE fetched < ?
+AND fetched < (SELECT MAX(fetched) FROM responses AS later WHERE later.url = responses.url)`, time.Now().Add(-keep).UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: removing expired versions: %w", err)
+	}
+	if stats.Expired, err = res.RowsAffected(); err != nil {
+		return nil, err
+	}
+
+	res, err = s.db.ExecContext(ctx, `
+DELETE FROM responses
+WHERE id IN (
+	SELECT id FROM (
+		SELECT id, body, LEAD(body) OVER (PARTITION BY url ORDER BY fetched) AS next
+		FROM responses
+	)
+	WHERE body = next
+)`)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: removing duplicate versions: %w", err)
+	}
+	if stats.Duplicates, err = res.RowsAffected(); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return nil, fmt.Errorf("sqlite: compacting: %w", err)
+	}
+
+	return stats, nil
+}
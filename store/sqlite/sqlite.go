@@ -0,0 +1,206 @@
+// Package sqlite keeps the responses of the Kindly APIs in a local SQLite
+// file, so repeated queries are answered without calling the API, queries
+// can be answered offline, and earlier versions of a response can be compared
+// with later ones.
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	// The driver registers itself as "sqlite3".
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DefaultMaxAge is how long a stored response is served unless configured
+// with WithMaxAge.
+const DefaultMaxAge = time.Hour
+
+// ErrNotStored is returned by an offline store for requests it has no
+// response to.
+var ErrNotStored = errors.New("sqlite: response not stored")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS responses (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	fetched INTEGER NOT NULL,
+	body BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS responses_url ON responses (url, fetched);
+`
+
+// Doer sends HTTP requests, such as an *http.Client or the doer of a
+// statistics.Client.
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+// Store is a SQLite file of API responses. Every response fetched through
+// the doer returned by Doer is kept as a new version, until removed by GC.
+type Store struct {
+	db      *sql.DB
+	maxAge  time.Duration
+	offline bool
+}
+
+// Option configures a Store.
+type Option func(s *Store)
+
+// WithMaxAge serves stored responses for d after they were fetched. The
+// default is DefaultMaxAge.
+func WithMaxAge(d time.Duration) Option {
+	return func(s *Store) {
+		s.maxAge = d
+	}
+}
+
+// WithOffline serves stored responses regardless of their age, and fails
+// requests with no stored response with ErrNotStored instead of sending them.
+func WithOffline() Option {
+	return func(s *Store) {
+		s.offline = true
+	}
+}
+
+// Open opens the store in the SQLite file at path, creating it if needed.
+func Open(path string, opts ...Option) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: opening %s: %w", path, err)
+	}
+	// SQLite allows one writer at a time.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite: creating schema in %s: %w", path, err)
+	}
+
+	s := &Store{db: db, maxAge: DefaultMaxAge}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// Close closes the file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Doer returns a doer that answers GET requests with the latest stored
+// response to their URL if it is fresh enough, and otherwise sends them with
+// next and stores successful responses. Stored responses carry no headers
+// but Content-Type.
+func (s *Store) Doer(next Doer) Doer {
+	return doerFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodGet {
+			return next.Do(r)
+		}
+
+		url := r.URL.String()
+		v, err := s.latest(r.Context(), url)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil && (s.offline || time.Since(v.Fetched) <= s.maxAge) {
+			return response(r, http.Header{"Content-Type": {"application/json"}}, v.Body), nil
+		}
+		if s.offline {
+			return nil, fmt.Errorf("%w: %s", ErrNotStored, url)
+		}
+
+		resp, err := next.Do(r)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return resp, err
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.db.ExecContext(r.Context(), "INSERT INTO responses (url, fetched, body) VALUES (?, ?, ?)", url, time.Now().UnixNano(), body); err != nil {
+			return nil, fmt.Errorf("sqlite: storing %s: %w", url, err)
+		}
+
+		return response(r, resp.Header, body), nil
+	})
+}
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return d(r)
+}
+
+func response(r *http.Request, header http.Header, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+// Version is a response as it was fetched at a time.
+type Version struct {
+	Fetched time.Time
+	Body    []byte
+}
+
+// Versions returns the stored responses to url, oldest first, so they can be
+// compared with each other.
+func (s *Store) Versions(ctx context.Context, url string) ([]*Version, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT fetched, body FROM responses WHERE url = ? ORDER BY fetched", url)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: reading %s: %w", url, err)
+	}
+	defer rows.Close()
+
+	var ret []*Version
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+	}
+
+	return ret, rows.Err()
+}
+
+// latest returns the latest stored response to url, or nil if there is none.
+func (s *Store) latest(ctx context.Context, url string) (*Version, error) {
+	v, err := scanVersion(s.db.QueryRowContext(ctx, "SELECT fetched, body FROM responses WHERE url = ? ORDER BY fetched DESC LIMIT 1", url))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return v, err
+}
+
+func scanVersion(row interface{ Scan(...interface{}) error }) (*Version, error) {
+	var fetched int64
+	v := &Version{}
+	if err := row.Scan(&fetched, &v.Body); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("sqlite: reading response: %w", err)
+	}
+	v.Fetched = time.Unix(0, fetched)
+
+	return v, nil
+}
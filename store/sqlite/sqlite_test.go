@@ -0,0 +1,127 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/store/sqlite"
+)
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return d(r)
+}
+
+// countingDoer answers every request with body, counting the requests.
+func countingDoer(calls *int, body *string) doerFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		*calls++
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(*body))}, nil
+	}
+}
+
+func get(t *testing.T, d sqlite.Doer, url string) (string, error) {
+	t.Helper()
+	r, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := d.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+
+	return string(b), err
+}
+
+func TestStore_Doer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kindly.db")
+	s, err := sqlite.Open(path, sqlite.WithMaxAge(time.Hour))
+	if err != nil {
+		t.Fatalf("Open() err=%v", err)
+	}
+	defer s.Close()
+
+	calls, body := 0, `{"data":[1]}`
+	d := s.Doer(countingDoer(&calls, &body))
+	for i := 0; i < 2; i++ {
+		got, err := get(t, d, "https://sage.kindly.ai/api/v1/stats/bot/1/sessions/chats?from=2021-01-01")
+		if err != nil {
+			t.Fatalf("Do() err=%v", err)
+		}
+		if got != body {
+			t.Errorf("got body %q, want %q", got, body)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("got %d upstream calls, want 1", calls)
+	}
+
+	s.Close()
+	s, err = sqlite.Open(path, sqlite.WithOffline())
+	if err != nil {
+		t.Fatalf("Open() err=%v", err)
+	}
+	d = s.Doer(countingDoer(&calls, &body))
+	if got, err := get(t, d, "https://sage.kindly.ai/api/v1/stats/bot/1/sessions/chats?from=2021-01-01"); err != nil || got != body {
+		t.Errorf("got body %q err=%v offline, want %q", got, err, body)
+	}
+	if _, err := get(t, d, "https://sage.kindly.ai/api/v1/stats/bot/1/sessions/chats?from=2021-02-01"); !errors.Is(err, sqlite.ErrNotStored) {
+		t.Errorf("got err=%v offline, want ErrNotStored", err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d upstream calls, want 1", calls)
+	}
+}
+
+func TestStore_GC(t *testing.T) {
+	s, err := sqlite.Open(filepath.Join(t.TempDir(), "kindly.db"), sqlite.WithMaxAge(0))
+	if err != nil {
+		t.Fatalf("Open() err=%v", err)
+	}
+	defer s.Close()
+
+	calls := 0
+	url := "https://sage.kindly.ai/api/v1/stats/bot/1/sessions/chats"
+	for _, body := range []string{"a", "a", "b", "b", "c"} {
+		body := body
+		if _, err := get(t, s.Doer(countingDoer(&calls, &body)), url); err != nil {
+			t.Fatalf("Do() err=%v", err)
+		}
+	}
+
+	stats, err := s.GC(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatalf("GC() err=%v", err)
+	}
+	if stats.Expired != 0 || stats.Duplicates != 2 {
+		t.Errorf("got %+v, want 2 duplicates removed", stats)
+	}
+	versions, err := s.Versions(context.Background(), url)
+	if err != nil {
+		t.Fatalf("Versions() err=%v", err)
+	}
+	var got []string
+	for _, v := range versions {
+		got = append(got, string(v.Body))
+	}
+	if strings.Join(got, ",") != "a,b,c" {
+		t.Errorf("got versions %v, want [a b c]", got)
+	}
+
+	if stats, err = s.GC(context.Background(), 0); err != nil {
+		t.Fatalf("GC() err=%v", err)
+	}
+	if stats.Expired != 2 {
+		t.Errorf("got %+v, want 2 expired versions removed", stats)
+	}
+	if versions, _ = s.Versions(context.Background(), url); len(versions) != 1 || string(versions[0].Body) != "c" {
+		t.Errorf("got %d versions, want only the latest", len(versions))
+	}
+}
@@ -0,0 +1,63 @@
+package kindly
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Version, Commit and BuildTime describe the build. They are set at build
+// time with e.g.
+//
+//	go build -ldflags "-X github.com/atb-as/kindly.Version=v1.2.0 -X github.com/atb-as/kindly.Commit=$(git rev-parse HEAD) -X github.com/atb-as/kindly.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// BuildInfo describes the running build.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Build returns the build info of the running binary. Without a Version set
+// at build time, the module version is used if the binary was built with go
+// install.
+func Build() BuildInfo {
+	b := BuildInfo{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if b.Version == "dev" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			b.Version = info.Main.Version
+		}
+	}
+
+	return b
+}
+
+// String returns the build info on one line, e.g. for -version flags.
+func (b BuildInfo) String() string {
+	s := b.Version
+	if b.Commit != "" {
+		s += " (" + b.Commit
+		if b.BuildTime != "" {
+			s += ", " + b.BuildTime
+		}
+		s += ")"
+	}
+
+	return fmt.Sprintf("%s %s", s, b.GoVersion)
+}
+
+// UserAgent is the User-Agent header sent by the API clients in this module.
+func UserAgent() string {
+	b := Build()
+	if len(b.Commit) > 7 {
+		return fmt.Sprintf("kindly-go/%s (%s)", b.Version, b.Commit[:7])
+	}
+
+	return "kindly-go/" + b.Version
+}
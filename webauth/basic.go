@@ -0,0 +1,48 @@
+// Package webauth provides authentication middleware for the HTTP frontends.
+package webauth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Basic returns middleware requiring HTTP basic authentication with one of
+// the given username/password pairs.
+func Basic(realm string, credentials map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if ok {
+				want, known := credentials[user]
+				if known && subtle.ConstantTimeCompare([]byte(pass), []byte(want)) == 1 {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
+
+// ParseCredentials parses comma-separated user:password pairs, as used in
+// environment variables.
+func ParseCredentials(s string) (map[string]string, error) {
+	credentials := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.Index(pair, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("webauth: credentials must be user:password pairs")
+		}
+		credentials[pair[:i]] = pair[i+1:]
+	}
+
+	return credentials, nil
+}
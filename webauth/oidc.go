@@ -0,0 +1,290 @@
+package webauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie = "webauth_session"
+	stateCookie   = "webauth_state"
+)
+
+// Purposes of signed cookies. Each is part of the signature, so a cookie
+// signed for one purpose is never valid for another.
+const (
+	sessionPurpose = "webauth session"
+	statePurpose   = "webauth state"
+)
+
+// OIDCConfig configures OIDC authentication with the authorization code flow.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL must be registered with the provider. Its path is handled
+	// by the middleware.
+	RedirectURL string
+	// AllowedDomains restricts access to users with a verified email address
+	// in one of the domains. Empty allows every user of the provider.
+	AllowedDomains []string
+	// SessionKey signs session cookies; use at least 32 random bytes.
+	SessionKey []byte
+	// SessionTTL defaults to 12 hours.
+	SessionTTL time.Duration
+}
+
+// OIDC is middleware that signs users in with an OpenID Connect provider and
+// keeps them signed in with a signed session cookie.
+type OIDC struct {
+	cfg          OIDCConfig
+	oauth        *oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+	callbackPath string
+}
+
+// session is the payload of the session cookie.
+type session struct {
+	Email   string `json:"email"`
+	Expires int64  `json:"exp"`
+}
+
+// loginState is the payload of the state cookie, kept while the user signs
+// in with the provider.
+type loginState struct {
+	State string `json:"state"`
+	Nonce string `json:"nonce"`
+	// ReturnTo is the request URI to return to after signing in.
+	ReturnTo string `json:"return_to"`
+}
+
+// NewOIDC discovers the provider configuration from the issuer. ctx is also
+// used to fetch the signing keys of the provider later, so it should not be
+// cancelled while the middleware is in use.
+func NewOIDC(ctx context.Context, cfg OIDCConfig) (*OIDC, error) {
+	if len(cfg.SessionKey) < 16 {
+		return nil, errors.New("webauth: oidc: session key is too short")
+	}
+	if cfg.SessionTTL == 0 {
+		cfg.SessionTTL = 12 * time.Hour
+	}
+
+	redirect, err := url.Parse(cfg.RedirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("webauth: oidc: redirect url: %w", err)
+	}
+
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("webauth: oidc: discovery: %w", err)
+	}
+
+	return &OIDC{
+		cfg: cfg,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email"},
+		},
+		verifier:     provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		callbackPath: redirect.Path,
+	}, nil
+}
+
+// Middleware requires a valid session, redirecting to the provider to sign in
+// if there is none.
+func (o *OIDC) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == o.callbackPath {
+			o.callback(w, r)
+			return
+		}
+
+		if c, err := r.Cookie(sessionCookie); err == nil {
+			if _, err := o.verifySession(c.Value); err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		ls := loginState{State: randomString(), Nonce: randomString(), ReturnTo: r.URL.RequestURI()}
+		value, err := o.sign(statePurpose, ls)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     stateCookie,
+			Value:    value,
+			Path:     "/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, o.oauth.AuthCodeURL(ls.State, oidc.Nonce(ls.Nonce)), http.StatusFound)
+	})
+}
+
+func (o *OIDC) callback(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(stateCookie)
+	if err != nil {
+		http.Error(w, "missing state", http.StatusBadRequest)
+		return
+	}
+	var ls loginState
+	if err := o.verify(statePurpose, c.Value, &ls); err != nil || ls.State == "" || ls.State != r.URL.Query().Get("state") {
+		http.Error(w, "invalid state", http.StatusBadRequest)
+		return
+	}
+
+	tok, err := o.oauth.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, "exchanging code failed", http.StatusUnauthorized)
+		return
+	}
+	rawIDToken, _ := tok.Extra("id_token").(string)
+	email, err := o.verifyIDToken(r.Context(), rawIDToken, ls.Nonce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	expires := time.Now().Add(o.cfg.SessionTTL)
+	value, err := o.sign(sessionPurpose, session{Email: email, Expires: expires.Unix()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1})
+
+	target := ls.ReturnTo
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+		target = "/"
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// verifySession returns the email address of a valid, unexpired session.
+func (o *OIDC) verifySession(value string) (string, error) {
+	var s session
+	if err := o.verify(sessionPurpose, value, &s); err != nil {
+		return "", err
+	}
+	if s.Email == "" {
+		return "", errors.New("malformed session")
+	}
+	if time.Now().Unix() > s.Expires {
+		return "", errors.New("session expired")
+	}
+
+	return s.Email, nil
+}
+
+// sign returns v as JSON with a signature for purpose.
+func (o *OIDC) sign(purpose string, v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(o.mac(purpose, payload)), nil
+}
+
+// verify checks that value was signed for purpose and decodes it into v.
+func (o *OIDC) verify(purpose, value string, v interface{}) error {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return errors.New("malformed cookie")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sig, o.mac(purpose, payload)) {
+		return errors.New("invalid signature")
+	}
+
+	return json.Unmarshal(payload, v)
+}
+
+// mac returns the signature of payload for purpose.
+func (o *OIDC) mac(purpose string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, o.cfg.SessionKey)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+// verifyIDToken checks the signature, claims and nonce of an ID token and
+// returns the email address of the user.
+func (o *OIDC) verifyIDToken(ctx context.Context, raw, nonce string) (string, error) {
+	token, err := o.verifier.Verify(ctx, raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid id token: %w", err)
+	}
+	if nonce == "" || !hmac.Equal([]byte(token.Nonce), []byte(nonce)) {
+		return "", errors.New("id token has wrong nonce")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return "", err
+	}
+
+	if len(o.cfg.AllowedDomains) > 0 {
+		if !claims.EmailVerified {
+			return "", errors.New("email address is not verified")
+		}
+		allowed := false
+		for _, domain := range o.cfg.AllowedDomains {
+			if strings.HasSuffix(strings.ToLower(claims.Email), "@"+strings.ToLower(domain)) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return "", errors.New("email domain is not allowed")
+		}
+	}
+
+	return claims.Email, nil
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
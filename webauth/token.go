@@ -0,0 +1,33 @@
+package webauth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Token returns middleware requiring one of the given access tokens, either
+// as a bearer token in the Authorization header or, for clients that can not
+// set headers, in the access_token query parameter.
+func Token(tokens []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.URL.Query().Get("access_token")
+			if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+				got = strings.TrimPrefix(h, "Bearer ")
+			}
+
+			if got != "" {
+				for _, token := range tokens {
+					if subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
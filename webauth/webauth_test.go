@@ -0,0 +1,285 @@
+package webauth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/atb-as/kindly/webauth"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func TestBasic(t *testing.T) {
+	creds, err := webauth.ParseCredentials("alice:secret, bob:hunter2")
+	if err != nil {
+		t.Fatalf("ParseCredentials() err=%v", err)
+	}
+	h := webauth.Basic("stats", creds)(ok)
+
+	tests := []struct {
+		user, pass string
+		want       int
+	}{
+		{"alice", "secret", http.StatusOK},
+		{"bob", "hunter2", http.StatusOK},
+		{"alice", "hunter2", http.StatusUnauthorized},
+		{"mallory", "secret", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.user != "" {
+			r.SetBasicAuth(tt.user, tt.pass)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s:%s: got status %d, want %d", tt.user, tt.pass, w.Code, tt.want)
+		}
+	}
+}
+
+// provider is a minimal OIDC provider issuing ID tokens for a fixed user.
+type provider struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	email string
+	// nonce is the nonce of the issued ID tokens.
+	nonce string
+}
+
+func newProvider(t *testing.T, email string) *provider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &provider{key: key, email: email}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/auth",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "at",
+			"token_type":   "Bearer",
+			"id_token":     p.idToken(t),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+
+	return p
+}
+
+func (p *provider) idToken(t *testing.T) string {
+	seg := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := seg(map[string]string{"alg": "RS256", "kid": "k1"}) + "." + seg(map[string]interface{}{
+		"iss":            p.URL,
+		"aud":            "client",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"email":          p.email,
+		"email_verified": true,
+		"nonce":          p.nonce,
+	})
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDC(t *testing.T) {
+	tests := []struct {
+		email string
+		want  int
+	}{
+		{"alice@atb.no", http.StatusOK},
+		{"mallory@example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		p := newProvider(t, tt.email)
+		defer p.Close()
+
+		o, err := webauth.NewOIDC(context.Background(), webauth.OIDCConfig{
+			Issuer:         p.URL,
+			ClientID:       "client",
+			ClientSecret:   "secret",
+			RedirectURL:    "https://stats.example.com/oidc/callback",
+			AllowedDomains: []string{"atb.no"},
+			SessionKey:     []byte("0123456789abcdef0123456789abcdef"),
+		})
+		if err != nil {
+			t.Fatalf("NewOIDC() err=%v", err)
+		}
+		h := o.Middleware(ok)
+
+		// Unauthenticated requests are redirected to the provider.
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?metric=chats", nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusFound)
+		}
+		loc, _ := url.Parse(w.Header().Get("Location"))
+		state := loc.Query().Get("state")
+		stateCookie := w.Result().Cookies()[0]
+		p.nonce = loc.Query().Get("nonce")
+
+		// The provider redirects back with a code.
+		r := httptest.NewRequest(http.MethodGet, "/oidc/callback?code=c&state="+state, nil)
+		r.AddCookie(stateCookie)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if tt.want != http.StatusOK {
+			if w.Code != tt.want {
+				t.Errorf("%s: got callback status %d, want %d", tt.email, w.Code, tt.want)
+			}
+			continue
+		}
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "/?metric=chats" {
+			t.Fatalf("%s: got callback %d to %q, want redirect to original URL", tt.email, w.Code, w.Header().Get("Location"))
+		}
+
+		var session *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "webauth_session" {
+				session = c
+			}
+		}
+		if session == nil {
+			t.Fatalf("%s: expected session cookie", tt.email)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(session)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: got status %d with session, want %d", tt.email, w.Code, http.StatusOK)
+		}
+
+		// A tampered session is rejected.
+		session.Value = "x" + session.Value
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(session)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusFound {
+			t.Errorf("%s: got status %d with tampered session, want redirect", tt.email, w.Code)
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	h := webauth.Token([]string{"t1", "t2"})(ok)
+
+	tests := []struct {
+		header, query string
+		want          int
+	}{
+		{"Bearer t1", "", http.StatusOK},
+		{"", "t2", http.StatusOK},
+		{"Bearer nope", "", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/sessions?access_token="+tt.query, nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%q/%q: got status %d, want %d", tt.header, tt.query, w.Code, tt.want)
+		}
+	}
+}
+
+// newOIDC returns the middleware of an OIDC client of p in front of ok.
+func newOIDC(t *testing.T, p *provider) http.Handler {
+	o, err := webauth.NewOIDC(context.Background(), webauth.OIDCConfig{
+		Issuer:       p.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://stats.example.com/oidc/callback",
+		SessionKey:   []byte("0123456789abcdef0123456789abcdef"),
+	})
+	if err != nil {
+		t.Fatalf("NewOIDC() err=%v", err)
+	}
+
+	return o.Middleware(ok)
+}
+
+func TestOIDC_StateIsNotASession(t *testing.T) {
+	p := newProvider(t, "alice@atb.no")
+	defer p.Close()
+	h := newOIDC(t, p)
+
+	// The return URL is chosen by the caller, and looks like the expiry of
+	// a session.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?a|99999999999", nil))
+	state := w.Result().Cookies()[0]
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "webauth_session", Value: state.Value})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusFound {
+		t.Errorf("got status %d with the state cookie as session, want redirect", w.Code)
+	}
+}
+
+func TestOIDC_Nonce(t *testing.T) {
+	p := newProvider(t, "alice@atb.no")
+	defer p.Close()
+	h := newOIDC(t, p)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	loc, _ := url.Parse(w.Header().Get("Location"))
+	if loc.Query().Get("nonce") == "" {
+		t.Fatal("expected a nonce in the authorization request")
+	}
+	p.nonce = "replayed"
+
+	r := httptest.NewRequest(http.MethodGet, "/oidc/callback?code=c&state="+loc.Query().Get("state"), nil)
+	r.AddCookie(w.Result().Cookies()[0])
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got callback status %d with the wrong nonce, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,177 @@
+// Package workspace provides a client for the Kindly workspace API, used to
+// discover the bots available to an API key.
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/atb-as/kindly"
+)
+
+const BaseURL = "https://api.kindly.ai/api/v2"
+
+type Client struct {
+	BaseURL string
+	logger  Logger
+	doer    Doer
+}
+
+func NewClient(opts ...ClientOption) *Client {
+	c := &Client{logger: &nopLogger{}, doer: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+type ClientOption func(c *Client)
+
+// WithDoer sets the Doer used for requests. The Doer is responsible for
+// authenticating requests with the workspace API key.
+func WithDoer(doer Doer) ClientOption {
+	return func(c *Client) {
+		c.doer = doer
+	}
+}
+
+func WithLogger(logger Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+type nopLogger struct {
+}
+
+func (l *nopLogger) Log(keyvals ...interface{}) error {
+	return nil
+}
+
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
+// Bot describes a bot in the workspace.
+type Bot struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Languages []*Language `json:"languages"`
+}
+
+// Language is a language enabled for a bot.
+type Language struct {
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// Bots lists the bots in the workspace.
+func (c *Client) Bots(ctx context.Context) ([]*Bot, error) {
+	req, err := c.newRequest(ctx, "bots")
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*Bot, 0)
+	if err := c.do(req, &ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Bot returns the bot with the given ID.
+func (c *Client) Bot(ctx context.Context, botID string) (*Bot, error) {
+	req, err := c.newRequest(ctx, "bot/"+url.PathEscape(botID))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := Bot{}
+	if err := c.do(req, &ret); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, endpoint string) (*http.Request, error) {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", baseURL, endpoint), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", kindly.UserAgent())
+
+	return req, nil
+}
+
+type Error struct {
+	statusCode int
+	body       []byte
+}
+
+func (e *Error) StatusCode() int {
+	return e.statusCode
+}
+
+func (e *Error) Body() []byte {
+	return e.body
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("workspace: errenous status from upstream: %q", http.StatusText(e.StatusCode()))
+}
+
+type responseWrapper struct {
+	Data json.RawMessage `json:"data"`
+}
+
+func (c *Client) do(r *http.Request, v interface{}) error {
+	if c.doer == nil {
+		c.doer = http.DefaultClient
+	}
+
+	begin := time.Now()
+
+	resp, err := c.doer.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	c.logger.Log("method", r.Method, "url", r.URL.String(), "code", resp.StatusCode, "took", time.Since(begin))
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode > 399 {
+		return &Error{statusCode: resp.StatusCode, body: body}
+	}
+
+	w := responseWrapper{}
+	if err := json.Unmarshal(body, &w); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(w.Data, v)
+}
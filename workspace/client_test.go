@@ -0,0 +1,75 @@
+package workspace_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/atb-as/kindly/workspace"
+)
+
+type doerFunc func(r *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return d(r)
+}
+
+func TestClient_Bots(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		c := workspace.NewClient(workspace.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+			if want := workspace.BaseURL + "/bots"; r.URL.String() != want {
+				t.Errorf("got URL %q, want %q", r.URL.String(), want)
+			}
+
+			body := `{"data":[{"id":"1","name":"AtB","languages":[{"code":"nb","name":"Norwegian","active":true}]}]}`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		})))
+
+		bots, err := c.Bots(context.Background())
+		if err != nil {
+			t.Fatalf("c.Bots() err=%v", err)
+		}
+
+		if len(bots) != 1 {
+			t.Fatalf("got %d bots, want 1", len(bots))
+		}
+		if bots[0].Name != "AtB" {
+			t.Errorf("got Name %q, want %q", bots[0].Name, "AtB")
+		}
+		if len(bots[0].Languages) != 1 || bots[0].Languages[0].Code != "nb" {
+			t.Errorf("unexpected languages: %+v", bots[0].Languages)
+		}
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		c := workspace.NewClient(workspace.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader(""))}, nil
+		})))
+
+		if _, err := c.Bots(context.Background()); err == nil {
+			t.Errorf("expected err, got nil")
+		} else if err, ok := err.(*workspace.Error); !ok || err.StatusCode() != http.StatusUnauthorized {
+			t.Errorf("expected *workspace.Error with status 401, got %v", err)
+		}
+	})
+}
+
+func TestClient_Bot(t *testing.T) {
+	c := workspace.NewClient(workspace.WithDoer(doerFunc(func(r *http.Request) (*http.Response, error) {
+		if want := workspace.BaseURL + "/bot/1%2F..%2Fbots"; r.URL.String() != want {
+			t.Errorf("got URL %q, want %q", r.URL.String(), want)
+		}
+
+		body := `{"data":{"id":"1","name":"AtB"}}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})))
+
+	bot, err := c.Bot(context.Background(), "1/../bots")
+	if err != nil {
+		t.Fatalf("c.Bot() err=%v", err)
+	}
+	if bot.Name != "AtB" {
+		t.Errorf("got Name %q, want %q", bot.Name, "AtB")
+	}
+}